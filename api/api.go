@@ -1,17 +1,325 @@
 package api
 
 import (
+	"context"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p-core/peer"
 	"golang.org/x/xerrors"
 
+	"github.com/filecoin-project/venus-market/types"
+
 	marketapi "github.com/filecoin-project/venus/venus-shared/api/market"
 	clientapi "github.com/filecoin-project/venus/venus-shared/api/market/client"
+	mtypes "github.com/filecoin-project/venus/venus-shared/types/market"
 )
 
 //mock for gen
 var _ = xerrors.New("") // nolint
 
-type MarketFullNode = marketapi.IMarket
-type MarketFullStruct = marketapi.IMarketStruct
+// MarketFullNode extends the shared market api with the methods only venus-market implements
+type MarketFullNode interface {
+	marketapi.IMarket
+
+	// ActorAdd adds the miner to the storage miners of the config file
+	ActorAdd(ctx context.Context, mAddr address.Address, account string) error //perm:admin
+	// ActorRemove removes a miner of the config file, the miners of venus-auth are removed there
+	ActorRemove(ctx context.Context, mAddr address.Address) error //perm:admin
+	// ActorSetDisabled disables the miner or enables it again, a disabled miner takes no new deals and publishes no ask
+	ActorSetDisabled(ctx context.Context, mAddr address.Address, disabled bool) error //perm:admin
+	ActorStates(ctx context.Context) ([]types.MinerState, error)                      //perm:read
+
+	MarketListStorageAskHistory(ctx context.Context, mAddr address.Address, limit int) ([]*types.StorageAskChange, error) //perm:read
+
+	MarketSetRetrievalAskRule(ctx context.Context, rule *types.RetrievalAskRule) error                                            //perm:admin
+	MarketRemoveRetrievalAskRule(ctx context.Context, mAddr address.Address, kind types.RetrievalAskRuleKind, value string) error //perm:admin
+	MarketListRetrievalAskRules(ctx context.Context, mAddr address.Address) ([]*types.RetrievalAskRule, error)                    //perm:read
+
+	MarketListMessages(ctx context.Context, from address.Address) ([]*types.NonceMessage, error)            //perm:read
+	MarketReplaceMessage(ctx context.Context, mCid cid.Cid, params types.MsgReplaceParams) (cid.Cid, error) //perm:admin
+
+	DagstoreTransientUsage(ctx context.Context) (*types.DagstoreTransientUsage, error) //perm:read
+
+	PiecesGC(ctx context.Context, dryRun bool) (*types.PieceGCReport, error) //perm:admin
+	// PiecesVerify recomputes the commP of the piece files, all the stored pieces when none is given
+	PiecesVerify(ctx context.Context, pieces []cid.Cid) ([]types.PieceVerifyResult, error) //perm:admin
+	PiecesListVerify(ctx context.Context) ([]*types.PieceVerifyResult, error)              //perm:read
+
+	// MarketListClientUsage lists what every client address used of its deal quota
+	MarketListClientUsage(ctx context.Context) ([]types.ClientUsage, error) //perm:read
+
+	// MarketAssignUnPackedDeals, MarketMarkDealsAsPacking and MarketUpdateDealOnPacking lease the deals to the sealer
+	// given rather than to the account of the token like AssignUnPackedDeals, MarkDealsAsPacking and UpdateDealOnPacking
+	// do, the sealers sharing an account tell their deals apart that way
+	MarketAssignUnPackedDeals(ctx context.Context, miner address.Address, sealer string, ssize abi.SectorSize, spec *mtypes.GetDealSpec) ([]*mtypes.DealInfoIncludePath, error) //perm:write
+	MarketMarkDealsAsPacking(ctx context.Context, miner address.Address, sealer string, dealIDs []abi.DealID) error                                                             //perm:write
+	MarketUpdateDealOnPacking(ctx context.Context, miner address.Address, sealer string, dealID abi.DealID, sectorID abi.SectorNumber, offset abi.PaddedPieceSize) error        //perm:write
+
+	// MarketRenewDealLeases is the heartbeat of a sealer, it extends the leases of the deals assigned to it
+	MarketRenewDealLeases(ctx context.Context, miner address.Address, sealer string, dealIDs []abi.DealID) error //perm:write
+	MarketListDealLeases(ctx context.Context, miner address.Address) ([]*types.DealLease, error)                 //perm:read
+	MarketReleaseDealLeases(ctx context.Context, miner address.Address, dealIDs []abi.DealID) error              //perm:admin
+
+	// MarketListDealRisks lists the published deals not packed nor precommitted yet with the least slack first
+	MarketListDealRisks(ctx context.Context, miner address.Address) ([]types.DealRisk, error) //perm:read
+
+	// MarketListFunds lists the market escrow of the addresses, of the miners and the addresses the fund manager
+	// keeps track of when none is given
+	MarketListFunds(ctx context.Context, addrs []address.Address) ([]types.FundState, error) //perm:read
+	// MarketReconcileFunds releases the reservation of the miner its live deals do not hold, a dry run only reports it
+	MarketReconcileFunds(ctx context.Context, miner address.Address, dryRun bool) (*types.FundReconcile, error) //perm:admin
+
+	// SignerStatus lists the health of the signer backends and the backend signing for the worker of every miner
+	SignerStatus(ctx context.Context) (*types.SignerStatus, error) //perm:read
+
+	MarketNetPeers(ctx context.Context) ([]types.NetPeer, error)                   //perm:read
+	MarketNetBlock(ctx context.Context, peers []peer.ID, subnets []string) error   //perm:admin
+	MarketNetUnblock(ctx context.Context, peers []peer.ID, subnets []string) error //perm:admin
+	MarketNetBlockList(ctx context.Context) (*types.NetBlockList, error)           //perm:read
+	MarketNetConnect(ctx context.Context, info peer.AddrInfo) error                //perm:admin
+	MarketNetDisconnect(ctx context.Context, p peer.ID) error                      //perm:admin
+	MarketNetPing(ctx context.Context, p peer.ID) (time.Duration, error)           //perm:read
+	MarketNetFindPeer(ctx context.Context, p peer.ID) (peer.AddrInfo, error)       //perm:read
+	MarketNetBandwidth(ctx context.Context) (*types.NetBandwidth, error)           //perm:read
+	MarketNetReachability(ctx context.Context) (*types.NetReachability, error)     //perm:read
+}
+
+type MarketFullStruct struct {
+	marketapi.IMarketStruct
+
+	Internal struct {
+		ActorAdd         func(ctx context.Context, mAddr address.Address, account string) error `perm:"admin"`
+		ActorRemove      func(ctx context.Context, mAddr address.Address) error                 `perm:"admin"`
+		ActorSetDisabled func(ctx context.Context, mAddr address.Address, disabled bool) error  `perm:"admin"`
+		ActorStates      func(ctx context.Context) ([]types.MinerState, error)                  `perm:"read"`
+
+		MarketListStorageAskHistory func(ctx context.Context, mAddr address.Address, limit int) ([]*types.StorageAskChange, error) `perm:"read"`
+
+		MarketSetRetrievalAskRule    func(ctx context.Context, rule *types.RetrievalAskRule) error                                         `perm:"admin"`
+		MarketRemoveRetrievalAskRule func(ctx context.Context, mAddr address.Address, kind types.RetrievalAskRuleKind, value string) error `perm:"admin"`
+		MarketListRetrievalAskRules  func(ctx context.Context, mAddr address.Address) ([]*types.RetrievalAskRule, error)                   `perm:"read"`
+
+		MarketListMessages   func(ctx context.Context, from address.Address) ([]*types.NonceMessage, error)          `perm:"read"`
+		MarketReplaceMessage func(ctx context.Context, mCid cid.Cid, params types.MsgReplaceParams) (cid.Cid, error) `perm:"admin"`
+
+		DagstoreTransientUsage func(ctx context.Context) (*types.DagstoreTransientUsage, error) `perm:"read"`
+
+		PiecesGC         func(ctx context.Context, dryRun bool) (*types.PieceGCReport, error)           `perm:"admin"`
+		PiecesVerify     func(ctx context.Context, pieces []cid.Cid) ([]types.PieceVerifyResult, error) `perm:"admin"`
+		PiecesListVerify func(ctx context.Context) ([]*types.PieceVerifyResult, error)                  `perm:"read"`
+
+		MarketListClientUsage func(ctx context.Context) ([]types.ClientUsage, error) `perm:"read"`
+
+		MarketAssignUnPackedDeals func(ctx context.Context, miner address.Address, sealer string, ssize abi.SectorSize, spec *mtypes.GetDealSpec) ([]*mtypes.DealInfoIncludePath, error) `perm:"write"`
+		MarketMarkDealsAsPacking  func(ctx context.Context, miner address.Address, sealer string, dealIDs []abi.DealID) error                                                            `perm:"write"`
+		MarketUpdateDealOnPacking func(ctx context.Context, miner address.Address, sealer string, dealID abi.DealID, sectorID abi.SectorNumber, offset abi.PaddedPieceSize) error        `perm:"write"`
+
+		MarketRenewDealLeases   func(ctx context.Context, miner address.Address, sealer string, dealIDs []abi.DealID) error `perm:"write"`
+		MarketListDealLeases    func(ctx context.Context, miner address.Address) ([]*types.DealLease, error)                `perm:"read"`
+		MarketReleaseDealLeases func(ctx context.Context, miner address.Address, dealIDs []abi.DealID) error                `perm:"admin"`
+
+		MarketListDealRisks func(ctx context.Context, miner address.Address) ([]types.DealRisk, error) `perm:"read"`
+
+		MarketListFunds      func(ctx context.Context, addrs []address.Address) ([]types.FundState, error)               `perm:"read"`
+		MarketReconcileFunds func(ctx context.Context, miner address.Address, dryRun bool) (*types.FundReconcile, error) `perm:"admin"`
+
+		SignerStatus func(ctx context.Context) (*types.SignerStatus, error) `perm:"read"`
+
+		MarketNetPeers        func(ctx context.Context) ([]types.NetPeer, error)                 `perm:"read"`
+		MarketNetBlock        func(ctx context.Context, peers []peer.ID, subnets []string) error `perm:"admin"`
+		MarketNetUnblock      func(ctx context.Context, peers []peer.ID, subnets []string) error `perm:"admin"`
+		MarketNetBlockList    func(ctx context.Context) (*types.NetBlockList, error)             `perm:"read"`
+		MarketNetConnect      func(ctx context.Context, info peer.AddrInfo) error                `perm:"admin"`
+		MarketNetDisconnect   func(ctx context.Context, p peer.ID) error                         `perm:"admin"`
+		MarketNetPing         func(ctx context.Context, p peer.ID) (time.Duration, error)        `perm:"read"`
+		MarketNetFindPeer     func(ctx context.Context, p peer.ID) (peer.AddrInfo, error)        `perm:"read"`
+		MarketNetBandwidth    func(ctx context.Context) (*types.NetBandwidth, error)             `perm:"read"`
+		MarketNetReachability func(ctx context.Context) (*types.NetReachability, error)          `perm:"read"`
+	}
+}
+
+func (s *MarketFullStruct) ActorAdd(p0 context.Context, p1 address.Address, p2 string) error {
+	return s.Internal.ActorAdd(p0, p1, p2)
+}
+
+func (s *MarketFullStruct) ActorRemove(p0 context.Context, p1 address.Address) error {
+	return s.Internal.ActorRemove(p0, p1)
+}
+
+func (s *MarketFullStruct) ActorSetDisabled(p0 context.Context, p1 address.Address, p2 bool) error {
+	return s.Internal.ActorSetDisabled(p0, p1, p2)
+}
+
+func (s *MarketFullStruct) ActorStates(p0 context.Context) ([]types.MinerState, error) {
+	return s.Internal.ActorStates(p0)
+}
+
+func (s *MarketFullStruct) MarketListStorageAskHistory(p0 context.Context, p1 address.Address, p2 int) ([]*types.StorageAskChange, error) {
+	return s.Internal.MarketListStorageAskHistory(p0, p1, p2)
+}
+
+func (s *MarketFullStruct) MarketSetRetrievalAskRule(p0 context.Context, p1 *types.RetrievalAskRule) error {
+	return s.Internal.MarketSetRetrievalAskRule(p0, p1)
+}
+
+func (s *MarketFullStruct) MarketRemoveRetrievalAskRule(p0 context.Context, p1 address.Address, p2 types.RetrievalAskRuleKind, p3 string) error {
+	return s.Internal.MarketRemoveRetrievalAskRule(p0, p1, p2, p3)
+}
+
+func (s *MarketFullStruct) MarketListRetrievalAskRules(p0 context.Context, p1 address.Address) ([]*types.RetrievalAskRule, error) {
+	return s.Internal.MarketListRetrievalAskRules(p0, p1)
+}
+
+func (s *MarketFullStruct) MarketListMessages(p0 context.Context, p1 address.Address) ([]*types.NonceMessage, error) {
+	return s.Internal.MarketListMessages(p0, p1)
+}
+
+func (s *MarketFullStruct) MarketReplaceMessage(p0 context.Context, p1 cid.Cid, p2 types.MsgReplaceParams) (cid.Cid, error) {
+	return s.Internal.MarketReplaceMessage(p0, p1, p2)
+}
+
+func (s *MarketFullStruct) DagstoreTransientUsage(p0 context.Context) (*types.DagstoreTransientUsage, error) {
+	return s.Internal.DagstoreTransientUsage(p0)
+}
+
+func (s *MarketFullStruct) PiecesGC(p0 context.Context, p1 bool) (*types.PieceGCReport, error) {
+	return s.Internal.PiecesGC(p0, p1)
+}
+
+func (s *MarketFullStruct) PiecesVerify(p0 context.Context, p1 []cid.Cid) ([]types.PieceVerifyResult, error) {
+	return s.Internal.PiecesVerify(p0, p1)
+}
+
+func (s *MarketFullStruct) PiecesListVerify(p0 context.Context) ([]*types.PieceVerifyResult, error) {
+	return s.Internal.PiecesListVerify(p0)
+}
+
+func (s *MarketFullStruct) MarketListClientUsage(p0 context.Context) ([]types.ClientUsage, error) {
+	return s.Internal.MarketListClientUsage(p0)
+}
+
+func (s *MarketFullStruct) MarketAssignUnPackedDeals(p0 context.Context, p1 address.Address, p2 string, p3 abi.SectorSize, p4 *mtypes.GetDealSpec) ([]*mtypes.DealInfoIncludePath, error) {
+	return s.Internal.MarketAssignUnPackedDeals(p0, p1, p2, p3, p4)
+}
+
+func (s *MarketFullStruct) MarketMarkDealsAsPacking(p0 context.Context, p1 address.Address, p2 string, p3 []abi.DealID) error {
+	return s.Internal.MarketMarkDealsAsPacking(p0, p1, p2, p3)
+}
+
+func (s *MarketFullStruct) MarketUpdateDealOnPacking(p0 context.Context, p1 address.Address, p2 string, p3 abi.DealID, p4 abi.SectorNumber, p5 abi.PaddedPieceSize) error {
+	return s.Internal.MarketUpdateDealOnPacking(p0, p1, p2, p3, p4, p5)
+}
+
+func (s *MarketFullStruct) MarketRenewDealLeases(p0 context.Context, p1 address.Address, p2 string, p3 []abi.DealID) error {
+	return s.Internal.MarketRenewDealLeases(p0, p1, p2, p3)
+}
+
+func (s *MarketFullStruct) MarketListDealLeases(p0 context.Context, p1 address.Address) ([]*types.DealLease, error) {
+	return s.Internal.MarketListDealLeases(p0, p1)
+}
+
+func (s *MarketFullStruct) MarketReleaseDealLeases(p0 context.Context, p1 address.Address, p2 []abi.DealID) error {
+	return s.Internal.MarketReleaseDealLeases(p0, p1, p2)
+}
+
+func (s *MarketFullStruct) MarketListDealRisks(p0 context.Context, p1 address.Address) ([]types.DealRisk, error) {
+	return s.Internal.MarketListDealRisks(p0, p1)
+}
+
+func (s *MarketFullStruct) MarketListFunds(p0 context.Context, p1 []address.Address) ([]types.FundState, error) {
+	return s.Internal.MarketListFunds(p0, p1)
+}
+
+func (s *MarketFullStruct) MarketReconcileFunds(p0 context.Context, p1 address.Address, p2 bool) (*types.FundReconcile, error) {
+	return s.Internal.MarketReconcileFunds(p0, p1, p2)
+}
+
+func (s *MarketFullStruct) SignerStatus(p0 context.Context) (*types.SignerStatus, error) {
+	return s.Internal.SignerStatus(p0)
+}
+
+func (s *MarketFullStruct) MarketNetPeers(p0 context.Context) ([]types.NetPeer, error) {
+	return s.Internal.MarketNetPeers(p0)
+}
+
+func (s *MarketFullStruct) MarketNetBlock(p0 context.Context, p1 []peer.ID, p2 []string) error {
+	return s.Internal.MarketNetBlock(p0, p1, p2)
+}
+
+func (s *MarketFullStruct) MarketNetUnblock(p0 context.Context, p1 []peer.ID, p2 []string) error {
+	return s.Internal.MarketNetUnblock(p0, p1, p2)
+}
+
+func (s *MarketFullStruct) MarketNetBlockList(p0 context.Context) (*types.NetBlockList, error) {
+	return s.Internal.MarketNetBlockList(p0)
+}
+
+func (s *MarketFullStruct) MarketNetConnect(p0 context.Context, p1 peer.AddrInfo) error {
+	return s.Internal.MarketNetConnect(p0, p1)
+}
+
+func (s *MarketFullStruct) MarketNetDisconnect(p0 context.Context, p1 peer.ID) error {
+	return s.Internal.MarketNetDisconnect(p0, p1)
+}
+
+func (s *MarketFullStruct) MarketNetPing(p0 context.Context, p1 peer.ID) (time.Duration, error) {
+	return s.Internal.MarketNetPing(p0, p1)
+}
+
+func (s *MarketFullStruct) MarketNetFindPeer(p0 context.Context, p1 peer.ID) (peer.AddrInfo, error) {
+	return s.Internal.MarketNetFindPeer(p0, p1)
+}
+
+func (s *MarketFullStruct) MarketNetBandwidth(p0 context.Context) (*types.NetBandwidth, error) {
+	return s.Internal.MarketNetBandwidth(p0)
+}
+
+func (s *MarketFullStruct) MarketNetReachability(p0 context.Context) (*types.NetReachability, error) {
+	return s.Internal.MarketNetReachability(p0)
+}
+
+var _ MarketFullNode = (*MarketFullStruct)(nil)
+
+// MarketClientNode extends the shared market client api with the methods only venus-market implements
+type MarketClientNode interface {
+	clientapi.IMarketClient
+
+	ClientListDealRenewals(ctx context.Context) ([]types.DealRenewal, error)              //perm:read
+	ClientRenewDeal(ctx context.Context, proposalCid cid.Cid) (*types.DealRenewal, error) //perm:write
+
+	ClientRetrieveWithFailover(ctx context.Context, order types.RetrievalFailoverOrder) (*types.RetrievalFailoverResult, error) //perm:admin
+	ClientListRetrievalReputations(ctx context.Context) ([]types.ProviderReputation, error)                                     //perm:read
+}
+
+type MarketClientStruct struct {
+	clientapi.IMarketClientStruct
+
+	Internal struct {
+		ClientListDealRenewals func(ctx context.Context) ([]types.DealRenewal, error)                     `perm:"read"`
+		ClientRenewDeal        func(ctx context.Context, proposalCid cid.Cid) (*types.DealRenewal, error) `perm:"write"`
+
+		ClientRetrieveWithFailover     func(ctx context.Context, order types.RetrievalFailoverOrder) (*types.RetrievalFailoverResult, error) `perm:"admin"`
+		ClientListRetrievalReputations func(ctx context.Context) ([]types.ProviderReputation, error)                                         `perm:"read"`
+	}
+}
+
+func (s *MarketClientStruct) ClientListDealRenewals(p0 context.Context) ([]types.DealRenewal, error) {
+	return s.Internal.ClientListDealRenewals(p0)
+}
+
+func (s *MarketClientStruct) ClientRenewDeal(p0 context.Context, p1 cid.Cid) (*types.DealRenewal, error) {
+	return s.Internal.ClientRenewDeal(p0, p1)
+}
+
+func (s *MarketClientStruct) ClientRetrieveWithFailover(p0 context.Context, p1 types.RetrievalFailoverOrder) (*types.RetrievalFailoverResult, error) {
+	return s.Internal.ClientRetrieveWithFailover(p0, p1)
+}
+
+func (s *MarketClientStruct) ClientListRetrievalReputations(p0 context.Context) ([]types.ProviderReputation, error) {
+	return s.Internal.ClientListRetrievalReputations(p0)
+}
 
-type MarketClientNode = clientapi.IMarketClient
-type MarketClientStruct = clientapi.IMarketClientStruct
+var _ MarketClientNode = (*MarketClientStruct)(nil)
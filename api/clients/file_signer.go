@@ -0,0 +1,63 @@
+package clients
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+
+	"github.com/filecoin-project/go-address"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/venus-market/keystore"
+
+	vCrypto "github.com/filecoin-project/venus/pkg/crypto"
+	types2 "github.com/filecoin-project/venus/venus-shared/types"
+)
+
+// keyFileSigner signs with the keys of a file holding one hex encoded key exported by `wallet export` per line, the
+// keys are in clear so it is meant for development
+type keyFileSigner struct {
+	keys map[address.Address]*types2.KeyInfo
+}
+
+func newKeyFileSigner(path string) (*keyFileSigner, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[address.Address]*types2.KeyInfo)
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ki, err := keystore.DecodeKey(line)
+		if err != nil {
+			return nil, xerrors.Errorf("key of line %d: %w", i+1, err)
+		}
+		addr, err := keystore.KeyAddress(ki)
+		if err != nil {
+			return nil, xerrors.Errorf("key of line %d: %w", i+1, err)
+		}
+		keys[addr] = ki
+	}
+	return &keyFileSigner{keys: keys}, nil
+}
+
+func (s *keyFileSigner) WalletHas(_ context.Context, addr address.Address) (bool, error) {
+	_, ok := s.keys[addr]
+	return ok, nil
+}
+
+func (s *keyFileSigner) WalletSign(_ context.Context, addr address.Address, msg []byte, _ types2.MsgMeta) (*vCrypto.Signature, error) {
+	ki, ok := s.keys[addr]
+	if !ok {
+		return nil, xerrors.Errorf("key of %s not found", addr)
+	}
+	sigType, err := keystore.SigType(ki.Type)
+	if err != nil {
+		return nil, err
+	}
+	return vCrypto.Sign(msg, ki.PrivateKey, sigType)
+}
@@ -38,7 +38,9 @@ type GatewayClient struct {
 func (gatewayClient *GatewayClient) WalletHas(ctx context.Context, addr address.Address) (bool, error) {
 	account, err := gatewayClient.importMgr.GetAccount(ctx, addr)
 	if err != nil {
-		return false, err
+		// the gateway has no key of an address without account, the signer mux tries the next backend
+		log.Debugf("gateway has no key of %s: %v", addr, err)
+		return false, nil
 	}
 	return gatewayClient.innerClient.WalletHas(ctx, account, addr)
 }
@@ -5,11 +5,15 @@ import (
 	types2 "github.com/filecoin-project/venus/venus-shared/types/messager"
 	"time"
 
+	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/venus-market/minermgr"
+	"github.com/filecoin-project/venus-market/models/repo"
+	mtypes "github.com/filecoin-project/venus-market/types"
 	"github.com/filecoin-project/venus-market/utils"
 	v1api "github.com/filecoin-project/venus/venus-shared/api/chain/v1"
 	"github.com/filecoin-project/venus/venus-shared/types"
+	"github.com/ipfs-force-community/venus-common-utils/metrics"
 	"github.com/ipfs/go-cid"
 	xerrors "github.com/pkg/errors"
 	"go.uber.org/fx"
@@ -21,10 +25,17 @@ type IMixMessage interface {
 	GetMessageChainCid(ctx context.Context, mid cid.Cid) (*cid.Cid, error)
 	WaitMsg(ctx context.Context, mCid cid.Cid, confidence uint64, loopBackLimit abi.ChainEpoch, allowReplaced bool) (*types.MsgLookup, error)
 	SearchMsg(ctx context.Context, from types.TipSetKey, mCid cid.Cid, loopBackLimit abi.ChainEpoch, allowReplaced bool) (*types.MsgLookup, error)
+
+	// ListMessages and ReplaceMessage work on the messages tracked locally in solo mode
+	ListMessages(ctx context.Context, from address.Address) ([]*mtypes.NonceMessage, error)
+	ReplaceMessage(ctx context.Context, mCid cid.Cid, params mtypes.MsgReplaceParams) (cid.Cid, error)
 }
 
 type MPoolReplaceParams struct {
 	fx.In
+	Lc            fx.Lifecycle
+	MetricsCtx    metrics.MetricsCtx
+	Repo          repo.Repo
 	FullNode      v1api.FullNode
 	Singer        ISinger           `optional:"true"`
 	VenusMessager IVenusMessager    `optional:"true"`
@@ -40,13 +51,23 @@ type MixMsgClient struct {
 }
 
 func NewMixMsgClient(params MPoolReplaceParams) IMixMessage {
-	return &MixMsgClient{
-		full:        params.FullNode,
-		messager:    params.VenusMessager,
-		addrMgr:     params.Mgr,
-		signer:      params.Singer,
-		nonceAssign: newNonceAssign(params.FullNode),
+	msgClient := &MixMsgClient{
+		full:     params.FullNode,
+		messager: params.VenusMessager,
+		addrMgr:  params.Mgr,
+		signer:   params.Singer,
+	}
+	if params.VenusMessager == nil {
+		msgClient.nonceAssign = newNonceAssign(params.FullNode, params.Repo.NonceRepo())
+		ctx := metrics.LifecycleCtx(params.MetricsCtx, params.Lc)
+		params.Lc.Append(fx.Hook{
+			OnStart: func(context.Context) error {
+				go msgClient.nonceAssign.Start(ctx)
+				return nil
+			},
+		})
 	}
+	return msgClient
 }
 func (msgClient *MixMsgClient) PushMessage(ctx context.Context, p1 *types.Message, p2 *types.MessageSendSpec) (cid.Cid, error) {
 	if msgClient.messager == nil {
@@ -62,30 +83,8 @@ func (msgClient *MixMsgClient) PushMessage(ctx context.Context, p1 *types.Messag
 		if err != nil {
 			return cid.Undef, err
 		}
-		//estiamte -> sign -> push
-		estimatedMsg, err := msgClient.full.GasEstimateMessageGas(ctx, p1, sendSpec, types.EmptyTSK)
-		if err != nil {
-			return cid.Undef, err
-		}
-		estimatedMsg.Nonce, err = msgClient.nonceAssign.AssignNonce(ctx, p1.From)
-		if err != nil {
-			return cid.Undef, err
-		}
-		storageBlock, err := estimatedMsg.ToStorageBlock()
-		if err != nil {
-			return cid.Undef, err
-		}
-		sig, err := msgClient.full.WalletSign(ctx, estimatedMsg.From, storageBlock.Cid().Bytes(), types.MsgMeta{
-			Type:  types.MTChainMsg,
-			Extra: storageBlock.RawData(),
-		})
-		if err != nil {
-			return cid.Undef, err
-		}
-		signedCid, err := msgClient.full.MpoolPush(ctx, &types.SignedMessage{
-			Message:   *estimatedMsg,
-			Signature: *sig,
-		})
+		//estiamte -> assign nonce -> sign -> push
+		signedCid, err := msgClient.nonceAssign.PushMessage(ctx, p1, sendSpec)
 		if err != nil {
 			return cid.Undef, err
 		}
@@ -124,7 +123,9 @@ func (msgClient *MixMsgClient) PushMessage(ctx context.Context, p1 *types.Messag
 
 func (msgClient *MixMsgClient) WaitMsg(ctx context.Context, mCid cid.Cid, confidence uint64, loopbackLimit abi.ChainEpoch, allowReplaced bool) (*types.MsgLookup, error) {
 	if msgClient.messager == nil || mCid.Prefix() != utils.MidPrefix {
-		return msgClient.full.StateWaitMsg(ctx, mCid, confidence, loopbackLimit, allowReplaced)
+		return msgClient.lookupTracked(ctx, mCid, allowReplaced, func(allowReplaced bool) (*types.MsgLookup, error) {
+			return msgClient.full.StateWaitMsg(ctx, mCid, confidence, loopbackLimit, allowReplaced)
+		})
 	} else {
 		tm := time.NewTicker(time.Second * 30)
 		defer tm.Stop()
@@ -187,7 +188,9 @@ func (msgClient *MixMsgClient) WaitMsg(ctx context.Context, mCid cid.Cid, confid
 
 func (msgClient *MixMsgClient) SearchMsg(ctx context.Context, from types.TipSetKey, mCid cid.Cid, loopbackLimit abi.ChainEpoch, allowReplaced bool) (*types.MsgLookup, error) {
 	if msgClient.messager == nil || mCid.Prefix() != utils.MidPrefix {
-		return msgClient.full.StateSearchMsg(ctx, from, mCid, loopbackLimit, allowReplaced)
+		return msgClient.lookupTracked(ctx, mCid, allowReplaced, func(allowReplaced bool) (*types.MsgLookup, error) {
+			return msgClient.full.StateSearchMsg(ctx, from, mCid, loopbackLimit, allowReplaced)
+		})
 	} else {
 		msg, err := msgClient.messager.GetMessageByUid(ctx, mCid.String())
 		if err != nil {
@@ -259,3 +262,66 @@ func (msgClient *MixMsgClient) GetMessageChainCid(ctx context.Context, mid cid.C
 	}
 
 }
+
+// lookupTracked makes a caller find a message the tracker replaced with a higher fee, the caller does not know it was
+// replaced. The tracker may replace the message while the lookup blocks, so the replacements are allowed for the tracked
+// messages and the message found instead is checked against the tracker when the lookup returns
+func (msgClient *MixMsgClient) lookupTracked(ctx context.Context, mCid cid.Cid, allowReplaced bool, lookup func(allowReplaced bool) (*types.MsgLookup, error)) (*types.MsgLookup, error) {
+	tracked := msgClient.trackedMessage(ctx, mCid)
+	if tracked == nil || allowReplaced {
+		return lookup(allowReplaced)
+	}
+	if err := checkFilled(tracked, mCid); err != nil {
+		return nil, err
+	}
+
+	res, err := lookup(true)
+	if err != nil || res == nil || res.Message == mCid {
+		return res, err
+	}
+	if tracked = msgClient.trackedMessage(ctx, mCid); tracked == nil {
+		return nil, xerrors.Errorf("message %s was replaced by %s and is not tracked anymore", mCid, res.Message)
+	}
+	if err := checkFilled(tracked, mCid); err != nil {
+		return nil, err
+	}
+	if tracked.Cid != res.Message {
+		return nil, xerrors.Errorf("message %s was replaced by %s, not by the tracker", mCid, res.Message)
+	}
+	return res, nil
+}
+
+// trackedMessage returns the message of the tracker, nil when it does not track it
+func (msgClient *MixMsgClient) trackedMessage(ctx context.Context, mCid cid.Cid) *mtypes.NonceMessage {
+	if msgClient.nonceAssign == nil {
+		return nil
+	}
+	tracked, err := msgClient.nonceAssign.findMessage(ctx, mCid)
+	if err != nil {
+		log.Warnf("find tracked message %s: %v", mCid, err)
+		return nil
+	}
+	return tracked
+}
+
+// checkFilled fails for a message whose nonce was filled, it never lands
+func checkFilled(tracked *mtypes.NonceMessage, mCid cid.Cid) error {
+	if tracked.Filler && tracked.OriginCid == mCid && tracked.Cid != mCid {
+		return xerrors.Errorf("message %s could not land, its nonce %d was filled by %s", mCid, tracked.Nonce, tracked.Cid)
+	}
+	return nil
+}
+
+func (msgClient *MixMsgClient) ListMessages(ctx context.Context, from address.Address) ([]*mtypes.NonceMessage, error) {
+	if msgClient.nonceAssign == nil {
+		return nil, xerrors.Errorf("messages are tracked by venus-messager")
+	}
+	return msgClient.nonceAssign.listMessages(ctx, from)
+}
+
+func (msgClient *MixMsgClient) ReplaceMessage(ctx context.Context, mCid cid.Cid, params mtypes.MsgReplaceParams) (cid.Cid, error) {
+	if msgClient.nonceAssign == nil {
+		return cid.Undef, xerrors.Errorf("messages are tracked by venus-messager")
+	}
+	return msgClient.nonceAssign.ReplaceMessage(ctx, mCid, params)
+}
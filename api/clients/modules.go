@@ -67,8 +67,10 @@ var ClientsOpts = func(server bool, mode string, mCfg *config.Messager, signerCf
 			builder.Override(new(IVenusMessager), MessagerClient)),
 		builder.ApplyIf(
 			func(s *builder.Settings) bool {
-				return len(signerCfg.SignerType) > 0 && len(signerCfg.Url) > 0
+				return signerCfg.SignerType == "local" || len(signerCfg.SignerType) > 0 && len(signerCfg.Url) > 0 ||
+					len(signerCfg.Backends) > 0
 			},
+			builder.Override(new(*SignerMux), NewSignerMux),
 			builder.Override(new(ISinger), NewISignerClient),
 			builder.Override(ReplaceWalletMethod, ConvertWalletToISinge),
 		),
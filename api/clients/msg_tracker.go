@@ -0,0 +1,208 @@
+package clients
+
+import (
+	"context"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/venus-market/models/repo"
+	mtypes "github.com/filecoin-project/venus-market/types"
+
+	"github.com/filecoin-project/venus/venus-shared/actors/policy"
+	"github.com/filecoin-project/venus/venus-shared/types"
+)
+
+const (
+	// a message that is next to land and stays pending this long after it was pushed is replaced with a higher fee
+	msgStuckEpochs = abi.ChainEpoch(10)
+	// replace by fee rule of the mpool, a replacement pays at least 1.25 times the premium plus one
+	rbfNum   = 64
+	rbfDenom = 256
+)
+
+// defaultReplaceMaxFee caps the replacements of messages pushed without a max fee, it is the default max fee of the daemon
+var defaultReplaceMaxFee = big.NewInt(70_000_000_000_000_000)
+
+// trackExecuted looks up how the messages below the chain nonce landed and forgets them once they are final
+func (nonceAssign *nonceAssigner) trackExecuted(ctx context.Context, head *types.TipSet, addr address.Address, chainNonce uint64) error {
+	msgs, err := nonceAssign.repo.ListNonceMessages(ctx, addr)
+	if err != nil {
+		return err
+	}
+	keepFrom := chainNonce
+	for _, msg := range msgs {
+		if msg.Nonce >= chainNonce {
+			break
+		}
+		if msg.State == mtypes.MsgStatePending {
+			if err := nonceAssign.trackLanded(ctx, head, msg); err != nil {
+				return xerrors.Errorf("search message %s: %w", msg.Cid, err)
+			}
+		}
+		if keepFrom == chainNonce && (msg.State == mtypes.MsgStatePending || head.Height() < msg.Height+policy.ChainFinality) {
+			keepFrom = msg.Nonce
+		}
+	}
+	return nonceAssign.repo.RemoveNonceMessages(ctx, addr, keepFrom)
+}
+
+// replaceStuck bumps the fee of the message next to land when it stays pending too long, it blocks every later one
+func (nonceAssign *nonceAssigner) replaceStuck(ctx context.Context, head *types.TipSet, msg *mtypes.NonceMessage) error {
+	if msg == nil || msg.State != mtypes.MsgStatePending || head.Height()-msg.PushedEpoch < msgStuckEpochs {
+		return nil
+	}
+	if _, err := nonceAssign.replaceMessage(ctx, head, msg, mtypes.MsgReplaceParams{}); err != nil {
+		log.Warnf("replace stuck message %s with nonce %d of %s: %v", msg.Cid, msg.Nonce, msg.From, err)
+		// try again when it is stuck for another while
+		msg.PushedEpoch = head.Height()
+		msg.UpdatedAt = time.Now()
+		return nonceAssign.repo.SaveNonceMessage(ctx, msg)
+	}
+	return nil
+}
+
+// trackLanded looks up the executed message, a replacement of it is found as well
+func (nonceAssign *nonceAssigner) trackLanded(ctx context.Context, head *types.TipSet, msg *mtypes.NonceMessage) error {
+	// the message landed after it was pushed last, no need to search further back
+	limit := head.Height() - msg.PushedEpoch + 1
+	if msg.PushedEpoch <= 0 || limit > policy.ChainFinality {
+		limit = policy.ChainFinality
+	}
+	lookup, err := nonceAssign.full.StateSearchMsg(ctx, head.Key(), msg.Cid, limit, true)
+	if err != nil {
+		return err
+	}
+	if lookup == nil {
+		log.Warnf("nonce %d of %s was used by another message than %s", msg.Nonce, msg.From, msg.Cid)
+		msg.State = mtypes.MsgStateDropped
+		msg.Height = head.Height()
+	} else {
+		msg.State = mtypes.MsgStateOnChain
+		msg.Height = lookup.Height
+		msg.ExitCode = lookup.Receipt.ExitCode
+		if lookup.Message.Defined() {
+			msg.Cid = lookup.Message
+		}
+	}
+	msg.UpdatedAt = time.Now()
+	return nonceAssign.repo.SaveNonceMessage(ctx, msg)
+}
+
+// replaceMessage pushes the message again with a higher fee. the premium is raised at least as much as the mpool
+// requires for a replacement and the fee never exceeds the max fee of the message
+func (nonceAssign *nonceAssigner) replaceMessage(ctx context.Context, head *types.TipSet, tracked *mtypes.NonceMessage, params mtypes.MsgReplaceParams) (cid.Cid, error) {
+	if tracked.State != mtypes.MsgStatePending {
+		return cid.Undef, xerrors.Errorf("message %s is %s, only pending messages can be replaced", tracked.Cid, tracked.State)
+	}
+	if tracked.Message == nil {
+		return cid.Undef, xerrors.Errorf("message %s was not saved", tracked.Cid)
+	}
+
+	maxFee := tracked.MaxFee
+	if !params.MaxFee.Nil() && !params.MaxFee.IsZero() {
+		maxFee = params.MaxFee
+	}
+	if maxFee.Nil() || maxFee.IsZero() {
+		maxFee = defaultReplaceMaxFee
+	}
+
+	msg := tracked.Message.Message
+	minPremium := minRBFPremium(msg.GasPremium)
+	if !params.GasPremium.Nil() && !params.GasPremium.IsZero() {
+		if params.GasPremium.LessThan(minPremium) {
+			return cid.Undef, xerrors.Errorf("gas premium %s is below %s required to replace message %s", params.GasPremium, minPremium, tracked.Cid)
+		}
+		msg.GasPremium = params.GasPremium
+		msg.GasFeeCap = big.Max(msg.GasFeeCap, msg.GasPremium)
+	} else {
+		estimate := msg
+		estimate.GasFeeCap = big.Zero()
+		estimate.GasPremium = big.Zero()
+		estimated, err := nonceAssign.full.GasEstimateMessageGas(ctx, &estimate, &types.MessageSendSpec{MaxFee: maxFee}, head.Key())
+		if err != nil {
+			return cid.Undef, xerrors.Errorf("estimate gas: %w", err)
+		}
+		msg.GasPremium = big.Max(estimated.GasPremium, minPremium)
+		msg.GasFeeCap = big.Max(big.Max(estimated.GasFeeCap, msg.GasFeeCap), msg.GasPremium)
+	}
+	if !params.GasFeeCap.Nil() && !params.GasFeeCap.IsZero() {
+		msg.GasFeeCap = params.GasFeeCap
+	}
+
+	capReplaceFee(&msg, maxFee)
+	if msg.GasPremium.LessThan(minPremium) {
+		return cid.Undef, xerrors.Errorf("replacing message %s needs a premium of %s, it exceeds the max fee %s", tracked.Cid, minPremium, types.FIL(maxFee))
+	}
+
+	signedCid, signed, err := nonceAssign.signAndPush(ctx, &msg)
+	if err != nil {
+		return cid.Undef, err
+	}
+	log.Infof("replace message %s with nonce %d of %s by %s, premium %s fee cap %s", tracked.Cid, tracked.Nonce, tracked.From, signedCid, msg.GasPremium, msg.GasFeeCap)
+	tracked.Cid = signedCid
+	tracked.Message = signed
+	tracked.Replaced++
+	tracked.PushedEpoch = head.Height()
+	tracked.UpdatedAt = time.Now()
+	return signedCid, nonceAssign.repo.SaveNonceMessage(ctx, tracked)
+}
+
+func minRBFPremium(premium abi.TokenAmount) abi.TokenAmount {
+	bump := big.Div(big.Mul(premium, big.NewInt(rbfNum)), big.NewInt(rbfDenom))
+	return big.Add(big.Add(premium, bump), big.NewInt(1))
+}
+
+// capReplaceFee lowers the fee cap, and the premium with it, so that the message never pays more than maxFee
+func capReplaceFee(msg *types.Message, maxFee abi.TokenAmount) {
+	if msg.GasLimit <= 0 {
+		return
+	}
+	maxFeeCap := big.Div(maxFee, big.NewInt(msg.GasLimit))
+	if msg.GasFeeCap.GreaterThan(maxFeeCap) {
+		msg.GasFeeCap = maxFeeCap
+	}
+	if msg.GasPremium.GreaterThan(msg.GasFeeCap) {
+		msg.GasPremium = msg.GasFeeCap
+	}
+}
+
+// listMessages returns the tracked messages of from, or of all addresses when from is undefined
+func (nonceAssign *nonceAssigner) listMessages(ctx context.Context, from address.Address) ([]*mtypes.NonceMessage, error) {
+	return nonceAssign.repo.ListNonceMessages(ctx, from)
+}
+
+// findMessage returns the tracked message pushed or replaced with mCid, nil if it is not tracked
+func (nonceAssign *nonceAssigner) findMessage(ctx context.Context, mCid cid.Cid) (*mtypes.NonceMessage, error) {
+	msg, err := nonceAssign.repo.GetNonceMessageByCid(ctx, mCid)
+	if err != nil {
+		if xerrors.Is(err, repo.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return msg, nil
+}
+
+// ReplaceMessage replaces the pending message pushed or replaced with mCid by one paying a higher fee
+func (nonceAssign *nonceAssigner) ReplaceMessage(ctx context.Context, mCid cid.Cid, params mtypes.MsgReplaceParams) (cid.Cid, error) {
+	nonceAssign.lk.Lock()
+	defer nonceAssign.lk.Unlock()
+
+	tracked, err := nonceAssign.findMessage(ctx, mCid)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if tracked == nil {
+		return cid.Undef, xerrors.Errorf("message %s is not tracked", mCid)
+	}
+	head, err := nonceAssign.full.ChainHead(ctx)
+	if err != nil {
+		return cid.Undef, err
+	}
+	return nonceAssign.replaceMessage(ctx, head, tracked, params)
+}
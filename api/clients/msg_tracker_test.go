@@ -0,0 +1,245 @@
+package clients
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/crypto"
+	"github.com/filecoin-project/go-state-types/exitcode"
+	"github.com/ipfs/go-cid"
+	blocksutil "github.com/ipfs/go-ipfs-blocksutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/venus-market/models"
+	"github.com/filecoin-project/venus-market/models/badger"
+	mtypes "github.com/filecoin-project/venus-market/types"
+	"github.com/filecoin-project/venus-market/utils/test_helper"
+
+	"github.com/filecoin-project/venus/pkg/testhelpers"
+	"github.com/filecoin-project/venus/venus-shared/actors/policy"
+	v1api "github.com/filecoin-project/venus/venus-shared/api/chain/v1"
+	"github.com/filecoin-project/venus/venus-shared/types"
+)
+
+func TestMinRBFPremium(t *testing.T) {
+	require.Equal(t, big.NewInt(1), minRBFPremium(big.Zero()))
+	require.Equal(t, big.NewInt(126), minRBFPremium(big.NewInt(100)))
+	require.Equal(t, big.NewInt(1251), minRBFPremium(big.NewInt(1000)))
+}
+
+func TestCapReplaceFee(t *testing.T) {
+	cases := []struct {
+		name       string
+		feeCap     int64
+		premium    int64
+		maxFee     int64
+		expFeeCap  int64
+		expPremium int64
+	}{
+		{"below max fee", 100, 50, 1000 * 1000, 100, 50},
+		{"fee cap capped", 2000, 50, 1000 * 1000, 1000, 50},
+		{"premium capped with fee cap", 2000, 1500, 1000 * 1000, 1000, 1000},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			msg := &types.Message{
+				GasLimit:   1000,
+				GasFeeCap:  big.NewInt(c.feeCap),
+				GasPremium: big.NewInt(c.premium),
+			}
+			capReplaceFee(msg, big.NewInt(c.maxFee))
+			require.Equal(t, big.NewInt(c.expFeeCap), msg.GasFeeCap)
+			require.Equal(t, big.NewInt(c.expPremium), msg.GasPremium)
+		})
+	}
+}
+
+// fakeMsgChain is a daemon for the messages of one address, the pending messages land when the test executes them
+type fakeMsgChain struct {
+	v1api.FullNode
+	t      *testing.T
+	height abi.ChainEpoch
+	// nonce is the chain nonce of the address
+	nonce  uint64
+	mpool  map[uint64]*types.SignedMessage
+	landed map[cid.Cid]*types.MsgLookup
+}
+
+func newFakeMsgChain(t *testing.T) *fakeMsgChain {
+	return &fakeMsgChain{
+		t:      t,
+		height: 100,
+		mpool:  make(map[uint64]*types.SignedMessage),
+		landed: make(map[cid.Cid]*types.MsgLookup),
+	}
+}
+
+func (f *fakeMsgChain) ChainHead(context.Context) (*types.TipSet, error) {
+	blk := test_helper.MakeTestBlock(f.t)
+	blk.Height = f.height
+	return testhelpers.RequireNewTipSet(f.t, blk), nil
+}
+
+func (f *fakeMsgChain) StateGetActor(context.Context, address.Address, types.TipSetKey) (*types.Actor, error) {
+	return &types.Actor{Nonce: f.nonce}, nil
+}
+
+func (f *fakeMsgChain) MpoolGetNonce(context.Context, address.Address) (uint64, error) {
+	next := f.nonce
+	for nonce := range f.mpool {
+		if nonce >= next {
+			next = nonce + 1
+		}
+	}
+	return next, nil
+}
+
+func (f *fakeMsgChain) MpoolPush(_ context.Context, msg *types.SignedMessage) (cid.Cid, error) {
+	f.mpool[msg.Message.Nonce] = msg
+	return msg.Cid(), nil
+}
+
+func (f *fakeMsgChain) GasEstimateMessageGas(_ context.Context, msg *types.Message, _ *types.MessageSendSpec, _ types.TipSetKey) (*types.Message, error) {
+	estimated := *msg
+	estimated.GasLimit = 1000
+	estimated.GasFeeCap = big.NewInt(100)
+	estimated.GasPremium = big.NewInt(10)
+	return &estimated, nil
+}
+
+func (f *fakeMsgChain) WalletSign(context.Context, address.Address, []byte, types.MsgMeta) (*crypto.Signature, error) {
+	return &crypto.Signature{Type: crypto.SigTypeSecp256k1, Data: []byte{1}}, nil
+}
+
+func (f *fakeMsgChain) StateSearchMsg(_ context.Context, _ types.TipSetKey, msg cid.Cid, _ abi.ChainEpoch, _ bool) (*types.MsgLookup, error) {
+	return f.landed[msg], nil
+}
+
+// execute lands the pending message of the next nonce with the exit code
+func (f *fakeMsgChain) execute(exit exitcode.ExitCode) cid.Cid {
+	msg, ok := f.mpool[f.nonce]
+	require.True(f.t, ok, "no pending message with nonce %d", f.nonce)
+	delete(f.mpool, f.nonce)
+	f.landed[msg.Cid()] = &types.MsgLookup{
+		Message: msg.Cid(),
+		Receipt: types.MessageReceipt{ExitCode: exit},
+		Height:  f.height,
+	}
+	f.nonce++
+	return msg.Cid()
+}
+
+func TestMsgTracker(t *testing.T) {
+	ctx := context.Background()
+	from, _ := address.NewIDAddress(1000)
+	to, _ := address.NewIDAddress(2000)
+	chain := newFakeMsgChain(t)
+	nonceRepo := badger.NewNonceRepo(models.BadgerDB(t))
+	assigner := newNonceAssign(chain, nonceRepo)
+
+	push := func() cid.Cid {
+		msgCid, err := assigner.PushMessage(ctx, &types.Message{From: from, To: to, Value: big.Zero()}, nil)
+		require.NoError(t, err)
+		return msgCid
+	}
+	tracked := func(msgCid cid.Cid) *mtypes.NonceMessage {
+		msg, err := assigner.findMessage(ctx, msgCid)
+		require.NoError(t, err)
+		require.NotNil(t, msg)
+		return msg
+	}
+
+	// a message stuck at the head of the nonces is replaced with a higher premium, it is still found by its cid
+	replaced := push()
+	chain.height += msgStuckEpochs
+	assigner.reconcile(ctx)
+	msg := tracked(replaced)
+	require.Equal(t, 1, msg.Replaced)
+	require.NotEqual(t, replaced, msg.Cid)
+	require.Equal(t, replaced, msg.OriginCid)
+	require.Equal(t, msg.Cid, chain.mpool[0].Cid())
+	require.Equal(t, big.NewInt(13), chain.mpool[0].Message.GasPremium)
+
+	// the replacement lands
+	landed := chain.execute(exitcode.Ok)
+	chain.height++
+	assigner.reconcile(ctx)
+	msg = tracked(replaced)
+	require.Equal(t, mtypes.MsgStateOnChain, msg.State)
+	require.Equal(t, landed, msg.Cid)
+
+	// a message failing on chain keeps its exit code, a message whose nonce was used by another one is dropped
+	failed := push()
+	chain.execute(exitcode.ErrInsufficientFunds)
+	dropped := push()
+	chain.mpool[chain.nonce].Message.Method = 1
+	chain.execute(exitcode.Ok)
+	chain.height++
+	assigner.reconcile(ctx)
+	msg = tracked(failed)
+	require.Equal(t, mtypes.MsgStateOnChain, msg.State)
+	require.Equal(t, exitcode.ErrInsufficientFunds, msg.ExitCode)
+	require.Equal(t, mtypes.MsgStateDropped, tracked(dropped).State)
+
+	// the market restarts while the daemons lost a pending message, the ledger pushes it again
+	lost := push()
+	delete(chain.mpool, chain.nonce)
+	restarted := newNonceAssign(chain, nonceRepo)
+	restarted.reconcile(ctx)
+	require.Equal(t, lost, chain.mpool[chain.nonce].Cid())
+	msg, err := restarted.findMessage(ctx, lost)
+	require.NoError(t, err)
+	require.Equal(t, 1, msg.Repushed)
+	require.Equal(t, mtypes.MsgStatePending, msg.State)
+
+	// the executed messages are forgotten once final
+	chain.execute(exitcode.Ok)
+	chain.height += policy.ChainFinality + 1
+	restarted.reconcile(ctx)
+	msgs, err := restarted.listMessages(ctx, from)
+	require.NoError(t, err)
+	require.Empty(t, msgs)
+}
+
+func TestLookupTracked(t *testing.T) {
+	ctx := context.Background()
+	from, _ := address.NewIDAddress(1000)
+	to, _ := address.NewIDAddress(2000)
+	chain := newFakeMsgChain(t)
+	assigner := newNonceAssign(chain, badger.NewNonceRepo(models.BadgerDB(t)))
+	msgClient := &MixMsgClient{full: chain, nonceAssign: assigner}
+
+	push := func() cid.Cid {
+		msgCid, err := assigner.PushMessage(ctx, &types.Message{From: from, To: to, Value: big.Zero()}, nil)
+		require.NoError(t, err)
+		return msgCid
+	}
+
+	// the tracker replaces the message while it is waited for, the replacement is found
+	waited := push()
+	res, err := msgClient.lookupTracked(ctx, waited, false, func(allowReplaced bool) (*types.MsgLookup, error) {
+		require.True(t, allowReplaced)
+		chain.height += msgStuckEpochs
+		assigner.reconcile(ctx)
+		return chain.landed[chain.execute(exitcode.Ok)], nil
+	})
+	require.NoError(t, err)
+	require.NotEqual(t, waited, res.Message)
+
+	// a replacement the tracker does not know is not taken for the message
+	waited = push()
+	_, err = msgClient.lookupTracked(ctx, waited, false, func(bool) (*types.MsgLookup, error) {
+		return &types.MsgLookup{Message: blocksutil.NewBlockGenerator().Next().Cid()}, nil
+	})
+	require.Error(t, err)
+
+	// the messages the tracker does not know keep the caller's choice
+	_, err = msgClient.lookupTracked(ctx, blocksutil.NewBlockGenerator().Next().Cid(), false, func(allowReplaced bool) (*types.MsgLookup, error) {
+		require.False(t, allowReplaced)
+		return nil, nil
+	})
+	require.NoError(t, err)
+}
@@ -2,30 +2,298 @@ package clients
 
 import (
 	"context"
+	"sync"
+	"time"
+
 	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/big"
+	builtin7 "github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/venus-market/models/repo"
+	mtypes "github.com/filecoin-project/venus-market/types"
+
 	v1api "github.com/filecoin-project/venus/venus-shared/api/chain/v1"
-	"sync"
+	"github.com/filecoin-project/venus/venus-shared/types"
 )
 
 type INonceAssigner interface {
-	AssignNonce(ctx context.Context, addr address.Address) (uint64, error)
+	// PushMessage estimates, assigns a nonce to, signs and pushes the message
+	PushMessage(ctx context.Context, msg *types.Message, spec *types.MessageSendSpec) (cid.Cid, error)
 }
 
+// nonceAssigner assigns nonces in solo mode from a ledger saved in the local repo.
+// when several daemons are behind a proxy MpoolGetNonce of one of them may lag behind the nonces already used,
+// the ledger keeps the next nonce across restarts and tracks the pushed messages, see msg_tracker.go, so that a nonce
+// lost by the daemons or a message stuck at a low fee does not stall every later message of the address
 type nonceAssigner struct {
 	lk   sync.Mutex
 	full v1api.FullNode
+	repo repo.INonceRepo
 }
 
-func newNonceAssign(full v1api.FullNode) *nonceAssigner {
-	return &nonceAssigner{full: full, lk: sync.Mutex{}}
+var _ INonceAssigner = (*nonceAssigner)(nil)
+
+func newNonceAssign(full v1api.FullNode, nonceRepo repo.INonceRepo) *nonceAssigner {
+	return &nonceAssigner{full: full, repo: nonceRepo, lk: sync.Mutex{}}
 }
 
-//AssignNonce assign next nonce for address, in solo mode, should use a seperate address for market message, should save nonce
-//when only connect one daemon, MpoolGetNonce works well, but may have conflict nonce if use multiple daemon behind proxy
-//todo save assgined nonce in local database
-func (nonceAssign *nonceAssigner) AssignNonce(ctx context.Context, addr address.Address) (uint64, error) {
+func (nonceAssign *nonceAssigner) PushMessage(ctx context.Context, msg *types.Message, spec *types.MessageSendSpec) (cid.Cid, error) {
 	nonceAssign.lk.Lock()
 	defer nonceAssign.lk.Unlock()
 
-	return nonceAssign.full.MpoolGetNonce(ctx, addr)
+	nonce, err := nonceAssign.nextNonce(ctx, msg.From)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("assign nonce for %s: %w", msg.From, err)
+	}
+	signedCid, signed, err := nonceAssign.pushWithNonce(ctx, msg, spec, nonce)
+	if err != nil {
+		// the nonce is not used, the ledger stays where it is
+		return cid.Undef, err
+	}
+
+	now := time.Now()
+	nonceMsg := &mtypes.NonceMessage{
+		From:      msg.From,
+		Nonce:     nonce,
+		Cid:       signedCid,
+		OriginCid: signedCid,
+		Message:   signed,
+		MaxFee:    big.Zero(),
+		State:     mtypes.MsgStatePending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if head, err := nonceAssign.full.ChainHead(ctx); err == nil {
+		nonceMsg.PushedEpoch = head.Height()
+	} else {
+		log.Warnf("get chain head: %v", err)
+	}
+	if spec != nil && !spec.MaxFee.Nil() {
+		nonceMsg.MaxFee = spec.MaxFee
+	}
+	if err := nonceAssign.repo.SaveNonceMessage(ctx, nonceMsg); err != nil {
+		log.Errorf("save message %s with nonce %d of %s: %v", nonceMsg.Cid, nonce, msg.From, err)
+	}
+	if err := nonceAssign.repo.SetNextNonce(ctx, msg.From, nonce+1); err != nil {
+		// the message is in the mpool, MpoolGetNonce covers it the next time
+		log.Errorf("save next nonce %d of %s: %v", nonce+1, msg.From, err)
+	}
+	return nonceMsg.Cid, nil
+}
+
+// nextNonce returns the larger one of the ledger and the daemon view, a nonce used outside of venus-market is never assigned again.
+// must be called with lk held
+func (nonceAssign *nonceAssigner) nextNonce(ctx context.Context, addr address.Address) (uint64, error) {
+	mpoolNonce, err := nonceAssign.full.MpoolGetNonce(ctx, addr)
+	if err != nil {
+		return 0, err
+	}
+	next, err := nonceAssign.repo.GetNextNonce(ctx, addr)
+	if err != nil {
+		if xerrors.Is(err, repo.ErrNotFound) {
+			return mpoolNonce, nil
+		}
+		return 0, err
+	}
+	if mpoolNonce > next {
+		return mpoolNonce, nil
+	}
+	return next, nil
+}
+
+// pushWithNonce estimates the gas of the message, signs it with the nonce and pushes it to the daemon
+func (nonceAssign *nonceAssigner) pushWithNonce(ctx context.Context, msg *types.Message, spec *types.MessageSendSpec, nonce uint64) (cid.Cid, *types.SignedMessage, error) {
+	estimatedMsg, err := nonceAssign.full.GasEstimateMessageGas(ctx, msg, spec, types.EmptyTSK)
+	if err != nil {
+		return cid.Undef, nil, err
+	}
+	estimatedMsg.Nonce = nonce
+	return nonceAssign.signAndPush(ctx, estimatedMsg)
+}
+
+func (nonceAssign *nonceAssigner) signAndPush(ctx context.Context, msg *types.Message) (cid.Cid, *types.SignedMessage, error) {
+	storageBlock, err := msg.ToStorageBlock()
+	if err != nil {
+		return cid.Undef, nil, err
+	}
+	sig, err := nonceAssign.full.WalletSign(ctx, msg.From, storageBlock.Cid().Bytes(), types.MsgMeta{
+		Type:  types.MTChainMsg,
+		Extra: storageBlock.RawData(),
+	})
+	if err != nil {
+		return cid.Undef, nil, err
+	}
+	signed := &types.SignedMessage{
+		Message:   *msg,
+		Signature: *sig,
+	}
+	signedCid, err := nonceAssign.full.MpoolPush(ctx, signed)
+	if err != nil {
+		return cid.Undef, nil, err
+	}
+	return signedCid, signed, nil
+}
+
+// Start reconciles the ledger with the chain and tracks the pushed messages at startup and on every head change
+func (nonceAssign *nonceAssigner) Start(ctx context.Context) {
+	nonceAssign.reconcile(ctx)
+	for {
+		notifs, err := nonceAssign.full.ChainNotify(ctx)
+		if err != nil {
+			log.Warnf("subscribe head changes for nonce ledger: %v", err)
+		} else {
+			for range notifs {
+				nonceAssign.reconcile(ctx)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Warnf("exit nonce reconcile by context")
+			return
+		case <-time.After(time.Minute):
+		}
+	}
+}
+
+func (nonceAssign *nonceAssigner) reconcile(ctx context.Context) {
+	addrs, err := nonceAssign.repo.ListNonceAddrs(ctx)
+	if err != nil {
+		log.Errorf("list addresses of nonce ledger: %v", err)
+		return
+	}
+	head, err := nonceAssign.full.ChainHead(ctx)
+	if err != nil {
+		log.Errorf("get chain head: %v", err)
+		return
+	}
+	for _, addr := range addrs {
+		if err := nonceAssign.reconcileAddr(ctx, head, addr); err != nil {
+			log.Warnf("reconcile nonce of %s: %v", addr, err)
+		}
+	}
+}
+
+func (nonceAssign *nonceAssigner) reconcileAddr(ctx context.Context, head *types.TipSet, addr address.Address) error {
+	nonceAssign.lk.Lock()
+	defer nonceAssign.lk.Unlock()
+
+	actor, err := nonceAssign.full.StateGetActor(ctx, addr, head.Key())
+	if err != nil {
+		return err
+	}
+	// messages below the chain nonce were executed, they are kept until they are final
+	if err := nonceAssign.trackExecuted(ctx, head, addr, actor.Nonce); err != nil {
+		return err
+	}
+	next, err := nonceAssign.repo.GetNextNonce(ctx, addr)
+	if err != nil {
+		return err
+	}
+	if next <= actor.Nonce {
+		if next < actor.Nonce {
+			log.Infof("nonce ledger of %s moves from %d to chain nonce %d", addr, next, actor.Nonce)
+			return nonceAssign.repo.SetNextNonce(ctx, addr, actor.Nonce)
+		}
+		return nil
+	}
+
+	msgs, err := nonceAssign.repo.ListNonceMessages(ctx, addr)
+	if err != nil {
+		return err
+	}
+	msgByNonce := make(map[uint64]*mtypes.NonceMessage, len(msgs))
+	for _, msg := range msgs {
+		msgByNonce[msg.Nonce] = msg
+	}
+
+	// the daemons lost a nonce below the ledger, every later message waits for it
+	repaired := false
+	var lastRepaired uint64
+	for {
+		mpoolNonce, err := nonceAssign.full.MpoolGetNonce(ctx, addr)
+		if err != nil {
+			return err
+		}
+		if mpoolNonce >= next {
+			if repaired {
+				return nil
+			}
+			return nonceAssign.replaceStuck(ctx, head, msgByNonce[actor.Nonce])
+		}
+		if repaired && mpoolNonce <= lastRepaired {
+			// the daemon answering MpoolGetNonce does not see the pushed message yet, try again on the next head
+			return nil
+		}
+		if err := nonceAssign.repairNonce(ctx, head, addr, mpoolNonce, msgByNonce[mpoolNonce]); err != nil {
+			return xerrors.Errorf("repair nonce %d: %w", mpoolNonce, err)
+		}
+		repaired, lastRepaired = true, mpoolNonce
+	}
+}
+
+// repairNonce pushes the saved message of the nonce again. when it is rejected, the message is estimated and signed again
+// with the same nonce, and when that fails too or nothing is saved for the nonce, it is filled with a zero value self send
+func (nonceAssign *nonceAssigner) repairNonce(ctx context.Context, head *types.TipSet, addr address.Address, nonce uint64, saved *mtypes.NonceMessage) error {
+	now := time.Now()
+	if saved != nil && saved.Message != nil {
+		_, err := nonceAssign.full.MpoolPush(ctx, saved.Message)
+		if err == nil {
+			log.Infof("push message %s with nonce %d of %s again", saved.Cid, nonce, addr)
+			saved.Repushed++
+			saved.PushedEpoch = head.Height()
+			saved.UpdatedAt = now
+			return nonceAssign.repo.SaveNonceMessage(ctx, saved)
+		}
+		log.Warnf("push message %s with nonce %d of %s again: %v", saved.Cid, nonce, addr, err)
+
+		msg := saved.Message.Message
+		msg.GasLimit = 0
+		msg.GasFeeCap = big.Zero()
+		msg.GasPremium = big.Zero()
+		signedCid, signed, err := nonceAssign.pushWithNonce(ctx, &msg, &types.MessageSendSpec{MaxFee: saved.MaxFee}, nonce)
+		if err == nil {
+			log.Infof("replace message %s with nonce %d of %s by %s", saved.Cid, nonce, addr, signedCid)
+			saved.Cid = signedCid
+			saved.Message = signed
+			saved.Repushed++
+			saved.PushedEpoch = head.Height()
+			saved.UpdatedAt = now
+			return nonceAssign.repo.SaveNonceMessage(ctx, saved)
+		}
+		log.Warnf("replace message %s with nonce %d of %s: %v", saved.Cid, nonce, addr, err)
+	}
+
+	filler := &types.Message{
+		From:   addr,
+		To:     addr,
+		Value:  big.Zero(),
+		Method: builtin7.MethodSend,
+	}
+	signedCid, signed, err := nonceAssign.pushWithNonce(ctx, filler, nil, nonce)
+	if err != nil {
+		return xerrors.Errorf("fill nonce: %w", err)
+	}
+	log.Warnf("fill nonce %d of %s with self send %s", nonce, addr, signedCid)
+	fillerMsg := &mtypes.NonceMessage{
+		From:        addr,
+		Nonce:       nonce,
+		Cid:         signedCid,
+		OriginCid:   signedCid,
+		Message:     signed,
+		MaxFee:      big.Zero(),
+		State:       mtypes.MsgStatePending,
+		Filler:      true,
+		PushedEpoch: head.Height(),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if saved != nil {
+		// callers still look the message up by the cid it was pushed with
+		fillerMsg.OriginCid = saved.OriginCid
+		fillerMsg.CreatedAt = saved.CreatedAt
+	}
+	return nonceAssign.repo.SaveNonceMessage(ctx, fillerMsg)
 }
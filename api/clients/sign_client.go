@@ -9,9 +9,7 @@ import (
 	vCrypto "github.com/filecoin-project/venus/pkg/crypto"
 	types2 "github.com/filecoin-project/venus/venus-shared/types"
 	"github.com/ipfs-force-community/venus-common-utils/apiinfo"
-	"github.com/ipfs-force-community/venus-common-utils/metrics"
 	"go.uber.org/fx"
-	"golang.org/x/xerrors"
 )
 
 type MsgMeta struct {
@@ -41,34 +39,34 @@ func (walletClient *WalletClient) WalletSign(ctx context.Context, k address.Addr
 	return walletClient.Internal.WalletSign(ctx, k, msg, meta)
 }
 
+// KeystorePassword opens the keystores of the local signers, it is read from the command line or the terminal and
+// never from the config
+type KeystorePassword string
+
 type SignerParams struct {
 	fx.In
 	SignerCfg *config.Signer
 	Mgr       minermgr.IAddrMgr `optional:"true"`
+	Home      *config.HomeDir   `optional:"true"`
+	Password  KeystorePassword  `optional:"true"`
 }
 
-func NewISignerClient(mctx metrics.MetricsCtx, lc fx.Lifecycle, params SignerParams) (ISinger, error) {
-	var signer ISinger
-	var closer jsonrpc.ClientCloser
-	var err error
-	switch params.SignerCfg.SignerType {
-	case "wallet":
-		signer, closer, err = newWalletClient(context.Background(), params.SignerCfg.Token, params.SignerCfg.Url)
-	case "gateway":
-		signer, closer, err = newGatewayWalletClient(context.Background(), params.Mgr, params.SignerCfg)
-	default:
-		return nil, xerrors.Errorf("unsupport sign type %s", params.SignerCfg.SignerType)
+// NeedsKeystorePassword reports whether a signer of the config is a local keystore
+func NeedsKeystorePassword(cfg *config.Signer) bool {
+	if cfg.SignerType == "local" {
+		return true
+	}
+	for _, backend := range cfg.Backends {
+		if backend.Type == "local" {
+			return true
+		}
 	}
+	return false
+}
 
-	lc.Append(fx.Hook{
-		OnStop: func(_ context.Context) error {
-			if closer != nil {
-				closer()
-			}
-			return nil
-		},
-	})
-	return signer, err
+// NewISignerClient signs through the signer mux, which routes every address to a backend
+func NewISignerClient(mux *SignerMux) (ISinger, error) {
+	return mux, nil
 }
 
 func newWalletClient(ctx context.Context, token, url string) (*WalletClient, jsonrpc.ClientCloser, error) {
@@ -0,0 +1,353 @@
+package clients
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-jsonrpc"
+	"go.uber.org/fx"
+	"go.uber.org/multierr"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/venus-market/config"
+	"github.com/filecoin-project/venus-market/keystore"
+	"github.com/filecoin-project/venus-market/minermgr"
+	mtypes "github.com/filecoin-project/venus-market/types"
+
+	vCrypto "github.com/filecoin-project/venus/pkg/crypto"
+	types2 "github.com/filecoin-project/venus/venus-shared/types"
+
+	"github.com/ipfs-force-community/venus-common-utils/metrics"
+)
+
+// DefaultSignerBackend is the name of the backend of the type, the url and the token of the signer config
+const DefaultSignerBackend = "default"
+
+const signerCheckTimeout = 10 * time.Second
+
+type signerBackend struct {
+	name   string
+	typ    string
+	signer ISinger
+	closer jsonrpc.ClientCloser
+	// addrs are routed to the backend first, a backend without any serves every address
+	addrs map[address.Address]struct{}
+
+	lk        sync.Mutex
+	healthy   bool
+	lastErr   string
+	lastCheck time.Time
+	// probe is the address the health check asks for, the first address routed to the backend or the last address
+	// the backend was asked for
+	probe address.Address
+}
+
+func newSignerBackend(mctx metrics.MetricsCtx, mgr minermgr.IAddrMgr, home *config.HomeDir, password KeystorePassword, cfg config.SignerBackend) (*signerBackend, error) {
+	b := &signerBackend{
+		name:    cfg.Name,
+		typ:     cfg.Type,
+		addrs:   make(map[address.Address]struct{}, len(cfg.Addresses)),
+		healthy: true,
+	}
+	for _, addr := range config.ConvertConfigAddress(cfg.Addresses) {
+		if b.probe == address.Undef {
+			b.probe = addr
+		}
+		b.addrs[addr] = struct{}{}
+	}
+
+	var err error
+	switch cfg.Type {
+	case "wallet":
+		b.signer, b.closer, err = newWalletClient(context.Background(), cfg.Token, cfg.Url)
+	case "gateway":
+		if mgr == nil {
+			return nil, xerrors.New("gateway signer needs the accounts of the miners")
+		}
+		b.signer, b.closer, err = newGatewayWalletClient(mctx, mgr, &config.Signer{SignerType: cfg.Type, Url: cfg.Url, Token: cfg.Token})
+	case "local":
+		dir := cfg.Path
+		if len(dir) == 0 {
+			if home == nil {
+				return nil, xerrors.New("local signer needs the repo home or a keystore path")
+			}
+			dir = filepath.Join(string(*home), keystore.DefaultDir)
+		}
+		b.signer, err = keystore.NewKeyStore(dir, string(password))
+	case "file":
+		b.signer, err = newKeyFileSigner(cfg.Path)
+	default:
+		return nil, xerrors.Errorf("unsupport sign type %s", cfg.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *signerBackend) routes(addr address.Address) bool {
+	_, ok := b.addrs[addr]
+	return ok
+}
+
+func (b *signerBackend) isHealthy() bool {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	return b.healthy
+}
+
+func (b *signerBackend) setHealth(err error) {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	b.lastCheck = time.Now()
+	if err != nil {
+		if b.healthy {
+			log.Warnf("signer backend %s is unhealthy: %v", b.name, err)
+		}
+		b.healthy, b.lastErr = false, err.Error()
+		return
+	}
+	if !b.healthy {
+		log.Infof("signer backend %s is healthy again", b.name)
+	}
+	b.healthy, b.lastErr = true, ""
+}
+
+func (b *signerBackend) walletHas(ctx context.Context, addr address.Address) (bool, error) {
+	b.lk.Lock()
+	if len(b.addrs) == 0 {
+		b.probe = addr
+	}
+	b.lk.Unlock()
+
+	has, err := b.signer.WalletHas(ctx, addr)
+	if err != nil {
+		b.setHealth(err)
+		return false, xerrors.Errorf("signer backend %s: %w", b.name, err)
+	}
+	return has, nil
+}
+
+// available asks the backend for addr again to tell a backend that can not be reached from a backend refusing a
+// request
+func (b *signerBackend) available(ctx context.Context, addr address.Address) bool {
+	ctx, cancel := context.WithTimeout(ctx, signerCheckTimeout)
+	defer cancel()
+	_, err := b.signer.WalletHas(ctx, addr)
+	return err == nil
+}
+
+func (b *signerBackend) check(ctx context.Context) {
+	b.lk.Lock()
+	probe := b.probe
+	b.lk.Unlock()
+	// a backend never asked for an address stays healthy until it fails
+	if probe == address.Undef {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, signerCheckTimeout)
+	defer cancel()
+	_, err := b.signer.WalletHas(ctx, probe)
+	b.setHealth(err)
+}
+
+// SignerMux routes the addresses to the signer backends, a backend failing is skipped for the next one having the
+// key until its health check succeeds
+type SignerMux struct {
+	backends []*signerBackend
+}
+
+var _ ISinger = (*SignerMux)(nil)
+
+func NewSignerMux(mctx metrics.MetricsCtx, lc fx.Lifecycle, params SignerParams) (*SignerMux, error) {
+	cfg := params.SignerCfg
+	var backendCfgs []config.SignerBackend
+	if cfg.SignerType == "local" || len(cfg.SignerType) > 0 && len(cfg.Url) > 0 {
+		backendCfgs = append(backendCfgs, config.SignerBackend{
+			Name:  DefaultSignerBackend,
+			Type:  cfg.SignerType,
+			Url:   cfg.Url,
+			Token: cfg.Token,
+		})
+	}
+	backendCfgs = append(backendCfgs, cfg.Backends...)
+
+	mux := &SignerMux{}
+	closeAll := func() {
+		for _, b := range mux.backends {
+			if b.closer != nil {
+				b.closer()
+			}
+		}
+	}
+	names := make(map[string]struct{}, len(backendCfgs))
+	for _, backendCfg := range backendCfgs {
+		if _, ok := names[backendCfg.Name]; ok || backendCfg.Name == "" {
+			closeAll()
+			return nil, xerrors.Errorf("signer backend name %q is empty or used twice", backendCfg.Name)
+		}
+		names[backendCfg.Name] = struct{}{}
+
+		b, err := newSignerBackend(mctx, params.Mgr, params.Home, params.Password, backendCfg)
+		if err != nil {
+			closeAll()
+			return nil, xerrors.Errorf("create signer backend %s: %w", backendCfg.Name, err)
+		}
+		mux.backends = append(mux.backends, b)
+	}
+	if len(mux.backends) == 0 {
+		return nil, xerrors.New("no signer backend configured")
+	}
+
+	interval := time.Duration(cfg.HealthCheckInterval)
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ctx := metrics.LifecycleCtx(mctx, lc)
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go mux.Start(ctx, interval)
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			closeAll()
+			return nil
+		},
+	})
+	return mux, nil
+}
+
+func (m *SignerMux) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, b := range m.backends {
+				b.check(ctx)
+			}
+		case <-ctx.Done():
+			log.Warnf("exit signer health check by context")
+			return
+		}
+	}
+}
+
+// candidates lists the backends routing addr then the backends serving every address, the unhealthy ones last
+func (m *SignerMux) candidates(addr address.Address) []*signerBackend {
+	var routed, others, unhealthy []*signerBackend
+	for _, b := range m.backends {
+		if !b.routes(addr) && len(b.addrs) > 0 {
+			continue
+		}
+		switch {
+		case !b.isHealthy():
+			unhealthy = append(unhealthy, b)
+		case b.routes(addr):
+			routed = append(routed, b)
+		default:
+			others = append(others, b)
+		}
+	}
+	return append(append(routed, others...), unhealthy...)
+}
+
+// route returns the first backend having the key of addr
+func (m *SignerMux) route(ctx context.Context, addr address.Address) (*signerBackend, error) {
+	var errs error
+	for _, b := range m.candidates(addr) {
+		has, err := b.walletHas(ctx, addr)
+		if err != nil {
+			errs = multierr.Append(errs, err)
+			continue
+		}
+		if has {
+			return b, nil
+		}
+	}
+	if errs != nil {
+		return nil, errs
+	}
+	return nil, nil
+}
+
+// Route returns the name of the backend signing for addr, empty when no backend has its key
+func (m *SignerMux) Route(ctx context.Context, addr address.Address) (string, error) {
+	b, err := m.route(ctx, addr)
+	if err != nil || b == nil {
+		return "", err
+	}
+	return b.name, nil
+}
+
+func (m *SignerMux) WalletHas(ctx context.Context, addr address.Address) (bool, error) {
+	b, err := m.route(ctx, addr)
+	if err != nil {
+		return false, err
+	}
+	return b != nil, nil
+}
+
+func (m *SignerMux) WalletSign(ctx context.Context, addr address.Address, msg []byte, meta types2.MsgMeta) (*vCrypto.Signature, error) {
+	var errs error
+	for _, b := range m.candidates(addr) {
+		has, err := b.walletHas(ctx, addr)
+		if err != nil {
+			errs = multierr.Append(errs, err)
+			continue
+		}
+		if !has {
+			continue
+		}
+		sig, err := b.signer.WalletSign(ctx, addr, msg, meta)
+		if err == nil {
+			return sig, nil
+		}
+		if ctx.Err() != nil {
+			return nil, xerrors.Errorf("sign for %s: %w", addr, ctx.Err())
+		}
+		// a backend still answering refused the signature, its policy must not be bypassed by the next backend
+		if b.available(ctx, addr) {
+			return nil, xerrors.Errorf("signer backend %s refused to sign for %s: %w", b.name, addr, err)
+		}
+		log.Warnf("signer backend %s failed to sign for %s, try the next one: %v", b.name, addr, err)
+		b.setHealth(err)
+		errs = multierr.Append(errs, xerrors.Errorf("signer backend %s: %w", b.name, err))
+	}
+	if errs != nil {
+		return nil, xerrors.Errorf("sign for %s: %w", addr, errs)
+	}
+	return nil, xerrors.Errorf("no signer backend has the key of %s", addr)
+}
+
+// Status returns the health of the backends at their last check, a backend never checked is unknown
+func (m *SignerMux) Status() []mtypes.SignerBackendState {
+	states := make([]mtypes.SignerBackendState, 0, len(m.backends))
+	for _, b := range m.backends {
+		b.lk.Lock()
+		state := mtypes.SignerBackendState{
+			Name:      b.name,
+			Type:      b.typ,
+			Healthy:   b.healthy,
+			Unknown:   b.lastCheck.IsZero(),
+			LastError: b.lastErr,
+			LastCheck: b.lastCheck,
+			Addresses: make([]address.Address, 0, len(b.addrs)),
+		}
+		b.lk.Unlock()
+		for addr := range b.addrs {
+			state.Addresses = append(state.Addresses, addr)
+		}
+		sort.Slice(state.Addresses, func(i, j int) bool {
+			return state.Addresses[i].String() < state.Addresses[j].String()
+		})
+		states = append(states, state)
+	}
+	return states
+}
@@ -0,0 +1,159 @@
+package clients
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/crypto"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/venus-market/keystore"
+
+	vCrypto "github.com/filecoin-project/venus/pkg/crypto"
+	types2 "github.com/filecoin-project/venus/venus-shared/types"
+)
+
+// testingSigner has the keys of addrs, it fails every call while down
+type testingSigner struct {
+	name  string
+	addrs map[address.Address]struct{}
+	down  bool
+	// refuse fails the signatures of a signer still answering, like a wallet policy does
+	refuse bool
+}
+
+func (s *testingSigner) WalletHas(_ context.Context, addr address.Address) (bool, error) {
+	if s.down {
+		return false, xerrors.Errorf("%s is down", s.name)
+	}
+	_, ok := s.addrs[addr]
+	return ok, nil
+}
+
+func (s *testingSigner) WalletSign(_ context.Context, addr address.Address, _ []byte, _ types2.MsgMeta) (*vCrypto.Signature, error) {
+	if s.down {
+		return nil, xerrors.Errorf("%s is down", s.name)
+	}
+	if s.refuse {
+		return nil, xerrors.Errorf("%s refuses to sign", s.name)
+	}
+	return &vCrypto.Signature{Type: crypto.SigTypeSecp256k1, Data: []byte(s.name)}, nil
+}
+
+func newTestingBackend(name string, routes []address.Address, keys ...address.Address) (*signerBackend, *testingSigner) {
+	signer := &testingSigner{name: name, addrs: make(map[address.Address]struct{})}
+	for _, addr := range keys {
+		signer.addrs[addr] = struct{}{}
+	}
+	b := &signerBackend{name: name, typ: "wallet", signer: signer, addrs: make(map[address.Address]struct{}), healthy: true}
+	for _, addr := range routes {
+		if b.probe == address.Undef {
+			b.probe = addr
+		}
+		b.addrs[addr] = struct{}{}
+	}
+	return b, signer
+}
+
+func TestSignerMux(t *testing.T) {
+	ctx := context.Background()
+	worker1, _ := address.NewIDAddress(1)
+	worker2, _ := address.NewIDAddress(2)
+	unknown, _ := address.NewIDAddress(3)
+
+	def, defSigner := newTestingBackend(DefaultSignerBackend, nil, worker1, worker2)
+	gateway, gatewaySigner := newTestingBackend("gateway", []address.Address{worker1}, worker1)
+	mux := &SignerMux{backends: []*signerBackend{def, gateway}}
+
+	// worker1 is routed to the gateway, worker2 is served by the default backend
+	for addr, expected := range map[address.Address]string{worker1: "gateway", worker2: DefaultSignerBackend} {
+		backend, err := mux.Route(ctx, addr)
+		require.NoError(t, err)
+		require.Equal(t, expected, backend)
+		sig, err := mux.WalletSign(ctx, addr, []byte("msg"), types2.MsgMeta{})
+		require.NoError(t, err)
+		require.Equal(t, []byte(expected), sig.Data)
+	}
+	// the catch-all backend was never checked
+	status := mux.Status()
+	require.True(t, status[0].Unknown)
+
+	has, err := mux.WalletHas(ctx, unknown)
+	require.NoError(t, err)
+	require.False(t, has)
+	_, err = mux.WalletSign(ctx, unknown, []byte("msg"), types2.MsgMeta{})
+	require.Error(t, err)
+
+	// the gateway refuses to sign, the default backend is not asked instead
+	gatewaySigner.refuse = true
+	_, err = mux.WalletSign(ctx, worker1, []byte("msg"), types2.MsgMeta{})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "refused")
+	require.True(t, mux.Status()[1].Healthy)
+	gatewaySigner.refuse = false
+
+	// the gateway fails, the default backend signs for worker1
+	gatewaySigner.down = true
+	sig, err := mux.WalletSign(ctx, worker1, []byte("msg"), types2.MsgMeta{})
+	require.NoError(t, err)
+	require.Equal(t, []byte(DefaultSignerBackend), sig.Data)
+	status = mux.Status()
+	require.False(t, status[1].Healthy)
+	require.NotEmpty(t, status[1].LastError)
+	require.Equal(t, []address.Address{worker1}, status[1].Addresses)
+	require.False(t, status[1].Unknown)
+
+	// the gateway is skipped until its health check succeeds
+	gatewaySigner.down = false
+	backend, err := mux.Route(ctx, worker1)
+	require.NoError(t, err)
+	require.Equal(t, DefaultSignerBackend, backend)
+	gateway.check(ctx)
+	backend, err = mux.Route(ctx, worker1)
+	require.NoError(t, err)
+	require.Equal(t, "gateway", backend)
+
+	// every backend is down
+	defSigner.down, gatewaySigner.down = true, true
+	_, err = mux.WalletSign(ctx, worker2, []byte("msg"), types2.MsgMeta{})
+	require.Error(t, err)
+}
+
+func TestKeyFileSigner(t *testing.T) {
+	ctx := context.Background()
+
+	var lines []byte
+	var addrs []address.Address
+	for _, kt := range []types2.KeyType{types2.KTSecp256k1, types2.KTBLS} {
+		sigType, err := keystore.SigType(kt)
+		require.NoError(t, err)
+		priv, err := vCrypto.Generate(sigType)
+		require.NoError(t, err)
+		ki := &types2.KeyInfo{Type: kt, PrivateKey: priv}
+		addr, err := keystore.KeyAddress(ki)
+		require.NoError(t, err)
+		addrs = append(addrs, addr)
+
+		encoded, err := keystore.EncodeKey(ki)
+		require.NoError(t, err)
+		lines = append(lines, []byte(encoded+"\n")...)
+	}
+	path := filepath.Join(t.TempDir(), "keys")
+	require.NoError(t, ioutil.WriteFile(path, lines, 0600))
+
+	signer, err := newKeyFileSigner(path)
+	require.NoError(t, err)
+	for _, addr := range addrs {
+		has, err := signer.WalletHas(ctx, addr)
+		require.NoError(t, err)
+		require.True(t, has)
+
+		sig, err := signer.WalletSign(ctx, addr, []byte("msg"), types2.MsgMeta{})
+		require.NoError(t, err)
+		require.NoError(t, vCrypto.Verify(sig, addr, []byte("msg")))
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/filecoin-project/venus-market/api"
 	clients2 "github.com/filecoin-project/venus-market/api/clients"
 	"github.com/filecoin-project/venus-market/client"
+	"github.com/filecoin-project/venus-market/types"
 	"github.com/filecoin-project/venus/pkg/constants"
 	vTypes "github.com/filecoin-project/venus/venus-shared/types"
 	"github.com/ipfs/go-cid"
@@ -15,7 +16,9 @@ var _ api.MarketClientNode = (*MarketClientNodeImpl)(nil)
 type MarketClientNodeImpl struct {
 	client.API
 	FundAPI
-	Messager clients2.IMixMessage
+	Messager    clients2.IMixMessage
+	DealRenewer *client.DealRenewer
+	Failover    *client.RetrievalFailover
 }
 
 func (m *MarketClientNodeImpl) MessagerWaitMessage(ctx context.Context, mid cid.Cid) (*vTypes.MsgLookup, error) {
@@ -38,3 +41,19 @@ func (m *MarketClientNodeImpl) MessagerGetMessage(ctx context.Context, mid cid.C
 	//ChainGetMessage method has been replace in messager mode
 	return m.Messager.GetMessage(ctx, mid)
 }
+
+func (m *MarketClientNodeImpl) ClientListDealRenewals(ctx context.Context) ([]types.DealRenewal, error) {
+	return m.DealRenewer.List(ctx)
+}
+
+func (m *MarketClientNodeImpl) ClientRenewDeal(ctx context.Context, proposalCid cid.Cid) (*types.DealRenewal, error) {
+	return m.DealRenewer.Renew(ctx, proposalCid)
+}
+
+func (m *MarketClientNodeImpl) ClientRetrieveWithFailover(ctx context.Context, order types.RetrievalFailoverOrder) (*types.RetrievalFailoverResult, error) {
+	return m.Failover.Retrieve(ctx, order)
+}
+
+func (m *MarketClientNodeImpl) ClientListRetrievalReputations(ctx context.Context) ([]types.ProviderReputation, error) {
+	return m.Failover.ListReputations(ctx)
+}
@@ -0,0 +1,161 @@
+package impl
+
+import (
+	"context"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/metrics"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/venus-market/network"
+	mtypes "github.com/filecoin-project/venus-market/types"
+)
+
+func (m MarketNodeImpl) MarketNetPeers(ctx context.Context) ([]mtypes.NetPeer, error) {
+	if err := checkOperator(ctx); err != nil {
+		return nil, err
+	}
+	cm := m.Host.ConnManager()
+	peers := make([]mtypes.NetPeer, 0)
+	for _, p := range m.Host.Network().Peers() {
+		conns := m.Host.Network().ConnsToPeer(p)
+		if len(conns) == 0 {
+			continue
+		}
+		info := mtypes.NetPeer{
+			ID:        p,
+			Addr:      conns[0].RemoteMultiaddr().String(),
+			Direction: conns[0].Stat().Direction,
+			Conns:     len(conns),
+			Latency:   m.Host.Peerstore().LatencyEWMA(p),
+			Protected: cm.IsProtected(p, ""),
+		}
+		if agent, err := m.Host.Peerstore().Get(p, "AgentVersion"); err == nil {
+			info.Agent, _ = agent.(string)
+		}
+		for _, conn := range conns {
+			info.Streams += len(conn.GetStreams())
+		}
+		if tags := cm.GetTagInfo(p); tags != nil {
+			info.TagValue = tags.Value
+		}
+		peers = append(peers, info)
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].ID < peers[j].ID
+	})
+	return peers, nil
+}
+
+func parseSubnets(subnets []string) ([]*net.IPNet, error) {
+	res := make([]*net.IPNet, 0, len(subnets))
+	for _, s := range subnets {
+		subnet, err := network.ParseSubnet(s)
+		if err != nil {
+			return nil, xerrors.Errorf("invalid ip range %s: %w", s, err)
+		}
+		res = append(res, subnet)
+	}
+	return res, nil
+}
+
+func (m MarketNodeImpl) MarketNetBlock(ctx context.Context, peers []peer.ID, subnets []string) error {
+	if err := checkOperator(ctx); err != nil {
+		return err
+	}
+	ipNets, err := parseSubnets(subnets)
+	if err != nil {
+		return err
+	}
+	if err := m.ConnGater.BlockPeers(ctx, peers); err != nil {
+		return err
+	}
+	if err := m.ConnGater.BlockSubnets(ctx, ipNets); err != nil {
+		return err
+	}
+	m.ConnGater.DisconnectBlocked(m.Host.Network())
+	return nil
+}
+
+func (m MarketNodeImpl) MarketNetUnblock(ctx context.Context, peers []peer.ID, subnets []string) error {
+	if err := checkOperator(ctx); err != nil {
+		return err
+	}
+	ipNets, err := parseSubnets(subnets)
+	if err != nil {
+		return err
+	}
+	if err := m.ConnGater.UnblockPeers(ctx, peers); err != nil {
+		return err
+	}
+	return m.ConnGater.UnblockSubnets(ctx, ipNets)
+}
+
+func (m MarketNodeImpl) MarketNetBlockList(ctx context.Context) (*mtypes.NetBlockList, error) {
+	if err := checkOperator(ctx); err != nil {
+		return nil, err
+	}
+	return m.ConnGater.BlockList(), nil
+}
+
+func (m MarketNodeImpl) MarketNetConnect(ctx context.Context, info peer.AddrInfo) error {
+	if err := checkOperator(ctx); err != nil {
+		return err
+	}
+	return m.Host.Connect(ctx, info)
+}
+
+func (m MarketNodeImpl) MarketNetDisconnect(ctx context.Context, p peer.ID) error {
+	if err := checkOperator(ctx); err != nil {
+		return err
+	}
+	return m.Host.Network().ClosePeer(p)
+}
+
+func (m MarketNodeImpl) MarketNetPing(ctx context.Context, p peer.ID) (time.Duration, error) {
+	if err := checkOperator(ctx); err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	res := <-ping.Ping(ctx, m.Host, p)
+	return res.RTT, res.Error
+}
+
+// MarketNetFindPeer looks the peer up in the peerstore, the market runs no dht to ask other peers
+func (m MarketNodeImpl) MarketNetFindPeer(ctx context.Context, p peer.ID) (peer.AddrInfo, error) {
+	if err := checkOperator(ctx); err != nil {
+		return peer.AddrInfo{}, err
+	}
+	info := m.Host.Peerstore().PeerInfo(p)
+	if len(info.Addrs) == 0 {
+		return peer.AddrInfo{}, xerrors.Errorf("no address known for peer %s", p)
+	}
+	return info, nil
+}
+
+func (m MarketNodeImpl) MarketNetBandwidth(ctx context.Context) (*mtypes.NetBandwidth, error) {
+	if err := checkOperator(ctx); err != nil {
+		return nil, err
+	}
+	bw := &mtypes.NetBandwidth{
+		Total:      m.BandwidthReporter.GetBandwidthTotals(),
+		ByPeer:     make(map[string]metrics.Stats),
+		ByProtocol: m.BandwidthReporter.GetBandwidthByProtocol(),
+	}
+	for p, stats := range m.BandwidthReporter.GetBandwidthByPeer() {
+		bw.ByPeer[p.String()] = stats
+	}
+	return bw, nil
+}
+
+func (m MarketNodeImpl) MarketNetReachability(ctx context.Context) (*mtypes.NetReachability, error) {
+	if err := checkOperator(ctx); err != nil {
+		return nil, err
+	}
+	return network.Reachability(m.Host), nil
+}
@@ -0,0 +1,209 @@
+package impl
+
+import (
+	"context"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-jsonrpc/auth"
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/venus-market/rpc"
+
+	vTypes "github.com/filecoin-project/venus/venus-shared/types"
+)
+
+// ErrNotOwner is returned when the token of an account reaches for a miner of another account
+var ErrNotOwner = xerrors.New("miner does not belong to the account of the token")
+
+// tenantAccount returns the account a request is limited to. in pool mode every token of venus-auth is limited to the miners
+// of its account, except for admin tokens of the operators. scoped is false for requests that may reach every miner
+func tenantAccount(ctx context.Context) (account string, scoped bool) {
+	account, ok := rpc.AccountFromContext(ctx)
+	if !ok || auth.HasPerm(ctx, nil, "admin") {
+		return "", false
+	}
+	return account, true
+}
+
+// minerFilter returns whether the request may reach a miner, it is nil when the request may reach every miner
+func (m MarketNodeImpl) minerFilter(ctx context.Context) (func(address.Address) bool, error) {
+	account, scoped := tenantAccount(ctx)
+	if !scoped {
+		return nil, nil
+	}
+	owned := make(map[address.Address]struct{})
+	if account != "" {
+		users, err := m.MinerMgr.ActorList(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			if user.Account == account {
+				owned[user.Addr] = struct{}{}
+			}
+		}
+	}
+	return func(miner address.Address) bool {
+		_, ok := owned[miner]
+		return ok
+	}, nil
+}
+
+// checkMiner rejects the request when the account of the token does not own the miner
+func (m MarketNodeImpl) checkMiner(ctx context.Context, miner address.Address) error {
+	account, scoped := tenantAccount(ctx)
+	if !scoped {
+		return nil
+	}
+	if account == "" {
+		return xerrors.Errorf("token has no account: %w", ErrNotOwner)
+	}
+	owner, err := m.MinerMgr.GetAccount(ctx, miner)
+	if err != nil || owner != account {
+		return xerrors.Errorf("%s is not a miner of account %s: %w", miner, account, ErrNotOwner)
+	}
+	return nil
+}
+
+// checkDeal rejects the request when the account of the token does not own the provider of the storage deal
+func (m MarketNodeImpl) checkDeal(ctx context.Context, proposalCid cid.Cid) error {
+	if _, scoped := tenantAccount(ctx); !scoped {
+		return nil
+	}
+	deal, err := m.Repo.StorageDealRepo().GetDeal(ctx, proposalCid)
+	if err != nil {
+		return err
+	}
+	return m.checkMiner(ctx, deal.Proposal.Provider)
+}
+
+// checkOperator rejects the tokens of accounts for the methods acting on the whole market
+func checkOperator(ctx context.Context) error {
+	if account, scoped := tenantAccount(ctx); scoped {
+		return xerrors.Errorf("account %s may only reach its own miners, the method acts on every miner", account)
+	}
+	return nil
+}
+
+// ownedPieces returns the pieces of the storage deals whose provider passes filter, the deals are queried by provider
+func (m MarketNodeImpl) ownedPieces(ctx context.Context, filter func(address.Address) bool) (map[cid.Cid]struct{}, error) {
+	users, err := m.MinerMgr.ActorList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pieces := make(map[cid.Cid]struct{})
+	for _, user := range users {
+		if !filter(user.Addr) {
+			continue
+		}
+		deals, err := m.Repo.StorageDealRepo().ListDealByAddr(ctx, user.Addr)
+		if err != nil {
+			return nil, err
+		}
+		for _, deal := range deals {
+			pieces[deal.Proposal.PieceCID] = struct{}{}
+		}
+	}
+	return pieces, nil
+}
+
+// checkAddress rejects the request when the address is neither a miner of the account of the token nor the owner, the
+// worker or a control address of one of them
+func (m MarketNodeImpl) checkAddress(ctx context.Context, addr address.Address) error {
+	account, scoped := tenantAccount(ctx)
+	if !scoped {
+		return nil
+	}
+	filter, err := m.minerFilter(ctx)
+	if err != nil {
+		return err
+	}
+	if filter(addr) {
+		return nil
+	}
+
+	id, err := m.FullNode.StateLookupID(ctx, addr, vTypes.EmptyTSK)
+	if err == nil {
+		if filter(id) {
+			return nil
+		}
+		users, err := m.MinerMgr.ActorList(ctx)
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			if !filter(user.Addr) {
+				continue
+			}
+			mi, err := m.FullNode.StateMinerInfo(ctx, user.Addr, vTypes.EmptyTSK)
+			if err != nil {
+				log.Warnf("get miner info of %s: %v", user.Addr, err)
+				continue
+			}
+			if mi.Owner == id || mi.Worker == id {
+				return nil
+			}
+			for _, control := range mi.ControlAddresses {
+				if control == id {
+					return nil
+				}
+			}
+		}
+	}
+	return xerrors.Errorf("%s is not an address of the miners of account %s: %w", addr, account, ErrNotOwner)
+}
+
+// checkPiece rejects the request when no storage deal of the account holds the piece
+func (m MarketNodeImpl) checkPiece(ctx context.Context, pieceCid cid.Cid) error {
+	filter, err := m.minerFilter(ctx)
+	if err != nil || filter == nil {
+		return err
+	}
+	pieces, err := m.ownedPieces(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if _, ok := pieces[pieceCid]; !ok {
+		return xerrors.Errorf("piece %s: %w", pieceCid, ErrNotOwner)
+	}
+	return nil
+}
+
+// checkResource rejects the request when the resource of the piece storage is a piece no storage deal of the account
+// holds, the resources named otherwise are left to the operators
+func (m MarketNodeImpl) checkResource(ctx context.Context, resource string) error {
+	pieceCid, err := cid.Decode(resource)
+	if err != nil {
+		return checkOperator(ctx)
+	}
+	return m.checkPiece(ctx, pieceCid)
+}
+
+// checkMessage rejects the request when the message is not sent from an address of the miners of the account
+func (m MarketNodeImpl) checkMessage(ctx context.Context, mid cid.Cid) error {
+	if _, scoped := tenantAccount(ctx); !scoped {
+		return nil
+	}
+	msg, err := m.Messager.GetMessage(ctx, mid)
+	if err != nil {
+		return err
+	}
+	return m.checkAddress(ctx, msg.From)
+}
+
+// tokenSealer is the sealer the deals are leased to by the methods of the shared market api, which have no sealer
+// parameter. it is the account of the token, so the sealers sharing an account, and every sealer of a solo market,
+// must use the Market* methods taking their sealer id to hold leases of their own
+func tokenSealer(ctx context.Context) string {
+	account, _ := rpc.AccountFromContext(ctx)
+	return account
+}
+
+// checkSealer rejects the empty sealer id, the leases keyed on it would be shared by every sealer leaving it out
+func checkSealer(sealer string) error {
+	if sealer == "" {
+		return xerrors.New("sealer id is required")
+	}
+	return nil
+}
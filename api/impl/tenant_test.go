@@ -0,0 +1,267 @@
+package impl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/piecestore"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-jsonrpc/auth"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/market"
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/venus-market/api/clients"
+	"github.com/filecoin-project/venus-market/minermgr"
+	"github.com/filecoin-project/venus-market/models/repo"
+	"github.com/filecoin-project/venus-market/piecestorage"
+	"github.com/filecoin-project/venus-market/rpc"
+	"github.com/filecoin-project/venus-market/storageprovider"
+
+	"github.com/filecoin-project/venus/venus-shared/actors/builtin/miner"
+	v1api "github.com/filecoin-project/venus/venus-shared/api/chain/v1"
+	vTypes "github.com/filecoin-project/venus/venus-shared/types"
+	types "github.com/filecoin-project/venus/venus-shared/types/market"
+)
+
+type fakeAddrMgr struct {
+	minermgr.IAddrMgr
+	users []types.User
+}
+
+func (f *fakeAddrMgr) ActorList(context.Context) ([]types.User, error) {
+	return f.users, nil
+}
+
+func (f *fakeAddrMgr) Has(_ context.Context, addr address.Address) bool {
+	_, err := f.GetAccount(context.Background(), addr)
+	return err == nil
+}
+
+func (f *fakeAddrMgr) GetAccount(_ context.Context, addr address.Address) (string, error) {
+	for _, user := range f.users {
+		if user.Addr == addr {
+			return user.Account, nil
+		}
+	}
+	return "", repo.ErrNotFound
+}
+
+type fakeRepo struct {
+	repo.Repo
+	deals *fakeDealRepo
+}
+
+func (f *fakeRepo) StorageDealRepo() repo.StorageDealRepo {
+	return f.deals
+}
+
+type fakeDealRepo struct {
+	repo.StorageDealRepo
+	deals []*types.MinerDeal
+}
+
+func (f *fakeDealRepo) ListDeal(context.Context) ([]*types.MinerDeal, error) {
+	return f.deals, nil
+}
+
+func (f *fakeDealRepo) ListDealByAddr(_ context.Context, mAddr address.Address) ([]*types.MinerDeal, error) {
+	var out []*types.MinerDeal
+	for _, deal := range f.deals {
+		if deal.Proposal.Provider == mAddr {
+			out = append(out, deal)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeDealRepo) GetPieceInfo(_ context.Context, pieceCID cid.Cid) (*piecestore.PieceInfo, error) {
+	return &piecestore.PieceInfo{PieceCID: pieceCID}, nil
+}
+
+type fakeStorageAsk struct {
+	storageprovider.IStorageAsk
+	set []address.Address
+}
+
+func (f *fakeStorageAsk) SetAsk(_ context.Context, mAddr address.Address, _ abi.TokenAmount, _ abi.TokenAmount, _ abi.ChainEpoch, _ ...storagemarket.StorageAskOption) error {
+	f.set = append(f.set, mAddr)
+	return nil
+}
+
+type fakeMessager struct {
+	clients.IMixMessage
+	msgs map[cid.Cid]*vTypes.Message
+}
+
+func (f *fakeMessager) GetMessage(_ context.Context, mid cid.Cid) (*vTypes.Message, error) {
+	msg, ok := f.msgs[mid]
+	if !ok {
+		return nil, repo.ErrNotFound
+	}
+	return msg, nil
+}
+
+func (f *fakeMessager) WaitMsg(context.Context, cid.Cid, uint64, abi.ChainEpoch, bool) (*vTypes.MsgLookup, error) {
+	return &vTypes.MsgLookup{}, nil
+}
+
+type fakePieceStorage struct {
+	piecestorage.IPieceStorage
+}
+
+func (f *fakePieceStorage) Type() piecestorage.Protocol {
+	return piecestorage.S3
+}
+
+func (f *fakePieceStorage) GetReadUrl(_ context.Context, resource string) (string, error) {
+	return "read/" + resource, nil
+}
+
+func (f *fakePieceStorage) GetWriteUrl(_ context.Context, resource string) (string, error) {
+	return "write/" + resource, nil
+}
+
+// fakeTenantFull knows the id addresses only, the miners have no owner, worker or control address
+type fakeTenantFull struct {
+	v1api.FullNode
+}
+
+func (f *fakeTenantFull) StateLookupID(_ context.Context, addr address.Address, _ vTypes.TipSetKey) (address.Address, error) {
+	return addr, nil
+}
+
+func (f *fakeTenantFull) StateMinerInfo(context.Context, address.Address, vTypes.TipSetKey) (miner.MinerInfo, error) {
+	return miner.MinerInfo{}, nil
+}
+
+type fakeDealAssigner struct {
+	storageprovider.DealAssiger
+}
+
+func (f *fakeDealAssigner) GetUnPackedDeals(context.Context, address.Address, *types.GetDealSpec) ([]*types.DealInfoIncludePath, error) {
+	return []*types.DealInfoIncludePath{{}}, nil
+}
+
+func TestTenantScope(t *testing.T) {
+	newCid := func(data string) cid.Cid {
+		mh, err := multihash.Sum([]byte(data), multihash.SHA2_256, -1)
+		require.NoError(t, err)
+		return cid.NewCidV1(cid.Raw, mh)
+	}
+	aliceMiner, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+	bobMiner, err := address.NewIDAddress(1001)
+	require.NoError(t, err)
+	newDeal := func(provider address.Address, piece string) *types.MinerDeal {
+		return &types.MinerDeal{
+			ClientDealProposal: market.ClientDealProposal{
+				Proposal: market.DealProposal{Provider: provider, PieceCID: newCid(piece)},
+			},
+			ProposalCid: newCid(provider.String() + piece),
+		}
+	}
+
+	aliceMsg := &vTypes.Message{From: aliceMiner, To: aliceMiner}
+	storageAsk := &fakeStorageAsk{}
+	m := MarketNodeImpl{
+		FullNode:     &fakeTenantFull{},
+		Messager:     &fakeMessager{msgs: map[cid.Cid]*vTypes.Message{aliceMsg.Cid(): aliceMsg}},
+		PieceStorage: &fakePieceStorage{},
+		MinerMgr: &fakeAddrMgr{users: []types.User{
+			{Addr: aliceMiner, Account: "alice"},
+			{Addr: bobMiner, Account: "bob"},
+		}},
+		Repo: &fakeRepo{deals: &fakeDealRepo{deals: []*types.MinerDeal{
+			newDeal(aliceMiner, "alice piece"),
+			newDeal(bobMiner, "bob piece"),
+			newDeal(bobMiner, "bob other piece"),
+		}}},
+		StorageAsk:   storageAsk,
+		DealAssigner: &fakeDealAssigner{},
+	}
+
+	ctx := context.Background()
+	alice := rpc.WithAccount(ctx, "alice")
+	bob := rpc.WithAccount(ctx, "bob")
+	admin := auth.WithPerm(rpc.WithAccount(ctx, "operator"), []auth.Permission{"admin"})
+
+	providers := func(ctx context.Context) []address.Address {
+		deals, err := m.MarketListIncompleteDeals(ctx, address.Undef)
+		require.NoError(t, err)
+		var out []address.Address
+		for _, deal := range deals {
+			out = append(out, deal.Proposal.Provider)
+		}
+		return out
+	}
+	require.Equal(t, []address.Address{aliceMiner}, providers(alice))
+	require.Equal(t, []address.Address{bobMiner, bobMiner}, providers(bob))
+	require.Len(t, providers(admin), 3)
+	require.Len(t, providers(ctx), 3)
+
+	_, err = m.MarketListIncompleteDeals(alice, bobMiner)
+	require.ErrorIs(t, err, ErrNotOwner)
+
+	users, err := m.ActorList(bob)
+	require.NoError(t, err)
+	require.Equal(t, []types.User{{Addr: bobMiner, Account: "bob"}}, users)
+
+	price := vTypes.NewInt(1)
+	require.ErrorIs(t, m.MarketSetAsk(alice, bobMiner, price, price, 100, 0, 0), ErrNotOwner)
+	require.NoError(t, m.MarketSetAsk(bob, bobMiner, price, price, 100, 0, 0))
+	require.NoError(t, m.MarketSetAsk(admin, aliceMiner, price, price, 100, 0, 0))
+	require.Equal(t, []address.Address{bobMiner, aliceMiner}, storageAsk.set)
+
+	_, err = m.GetUnPackedDeals(bob, aliceMiner, &types.GetDealSpec{})
+	require.ErrorIs(t, err, ErrNotOwner)
+	deals, err := m.GetUnPackedDeals(alice, aliceMiner, &types.GetDealSpec{})
+	require.NoError(t, err)
+	require.Len(t, deals, 1)
+
+	// the leases are keyed on the sealer id, a sealer leaving it out would share them with every other one
+	_, err = m.MarketAssignUnPackedDeals(alice, aliceMiner, "", 2048, &types.GetDealSpec{})
+	require.Error(t, err)
+
+	_, err = m.PiecesGetPieceInfo(alice, newCid("bob piece"))
+	require.ErrorIs(t, err, ErrNotOwner)
+	_, err = m.PiecesGetPieceInfo(bob, newCid("bob piece"))
+	require.NoError(t, err)
+
+	pieces, err := m.PiecesListPieces(bob)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []cid.Cid{newCid("bob piece"), newCid("bob other piece")}, pieces)
+
+	// the presigned urls of a piece are given to the accounts holding it, the other resources to the operators
+	_, err = m.GetWriteUrl(alice, newCid("bob piece").String())
+	require.ErrorIs(t, err, ErrNotOwner)
+	_, err = m.GetReadUrl(alice, newCid("bob piece").String())
+	require.ErrorIs(t, err, ErrNotOwner)
+	url, err := m.GetWriteUrl(bob, newCid("bob piece").String())
+	require.NoError(t, err)
+	require.Equal(t, "write/"+newCid("bob piece").String(), url)
+	_, err = m.GetReadUrl(bob, "sealed/s-t01001-1")
+	require.Error(t, err)
+	_, err = m.GetReadUrl(admin, "sealed/s-t01001-1")
+	require.NoError(t, err)
+
+	// the messages are visible to the account of their sender
+	_, err = m.MessagerGetMessage(bob, aliceMsg.Cid())
+	require.ErrorIs(t, err, ErrNotOwner)
+	_, err = m.MessagerWaitMessage(bob, aliceMsg.Cid())
+	require.ErrorIs(t, err, ErrNotOwner)
+	msg, err := m.MessagerGetMessage(alice, aliceMsg.Cid())
+	require.NoError(t, err)
+	require.Equal(t, aliceMiner, msg.From)
+	_, err = m.MessagerWaitMessage(alice, aliceMsg.Cid())
+	require.NoError(t, err)
+
+	// an account without miners reaches nothing, and methods acting on the whole market are left to the operators
+	_, err = m.GetUnPackedDeals(rpc.WithAccount(ctx, "carol"), aliceMiner, &types.GetDealSpec{})
+	require.ErrorIs(t, err, ErrNotOwner)
+	require.Empty(t, providers(rpc.WithAccount(ctx, "carol")))
+	require.Error(t, m.MarketPublishPendingDeals(alice))
+}
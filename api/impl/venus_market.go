@@ -18,6 +18,7 @@ import (
 	"github.com/ipfs/go-cid"
 	logging "github.com/ipfs/go-log/v2"
 	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/metrics"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"go.uber.org/fx"
 	"golang.org/x/xerrors"
@@ -34,9 +35,11 @@ import (
 	"github.com/filecoin-project/venus-market/api"
 	clients2 "github.com/filecoin-project/venus-market/api/clients"
 	"github.com/filecoin-project/venus-market/config"
+	mdagstore "github.com/filecoin-project/venus-market/dagstore"
 	"github.com/filecoin-project/venus-market/network"
 	"github.com/filecoin-project/venus-market/piecestorage"
 	"github.com/filecoin-project/venus-market/storageprovider"
+	mtypes "github.com/filecoin-project/venus-market/types"
 	types "github.com/filecoin-project/venus/venus-shared/types/market"
 
 	"github.com/filecoin-project/venus-market/paychmgr"
@@ -56,6 +59,8 @@ type MarketNodeImpl struct {
 
 	FullNode          v1api.FullNode
 	Host              host.Host
+	ConnGater         *network.ConnGater
+	BandwidthReporter metrics.Reporter
 	StorageProvider   storageprovider.StorageProviderV2
 	RetrievalProvider retrievalprovider.IRetrievalProvider
 	DataTransfer      network.ProviderDataTransfer
@@ -63,8 +68,15 @@ type MarketNodeImpl struct {
 	DealAssigner      storageprovider.DealAssiger
 
 	Messager                                    clients2.IMixMessage
+	SignerMux                                   *clients2.SignerMux `optional:"true"`
 	StorageAsk                                  storageprovider.IStorageAsk
 	DAGStore                                    *dagstore.DAGStore
+	BlockIndexer                                mdagstore.BlockIndexer
+	Transients                                  *mdagstore.TransientCache
+	PieceGC                                     *storageprovider.PieceGC
+	PieceVerifier                               *storageprovider.PieceVerifier
+	ClientQuota                                 *storageprovider.ClientQuota
+	DealRiskMonitor                             *storageprovider.DealRiskMonitor
 	PieceStorage                                piecestorage.IPieceStorage
 	MinerMgr                                    minermgr.IAddrMgr
 	PaychAPI                                    *paychmgr.PaychAPI
@@ -90,14 +102,34 @@ type MarketNodeImpl struct {
 }
 
 func (m MarketNodeImpl) ActorList(ctx context.Context) ([]types.User, error) {
-	return m.MinerMgr.ActorList(ctx)
+	users, err := m.MinerMgr.ActorList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := m.minerFilter(ctx)
+	if err != nil || filter == nil {
+		return users, err
+	}
+	out := make([]types.User, 0, len(users))
+	for _, user := range users {
+		if filter(user.Addr) {
+			out = append(out, user)
+		}
+	}
+	return out, nil
 }
 
 func (m MarketNodeImpl) ActorExist(ctx context.Context, addr address.Address) (bool, error) {
+	if err := m.checkMiner(ctx, addr); err != nil {
+		return false, nil
+	}
 	return m.MinerMgr.Has(ctx, addr), nil
 }
 
 func (m MarketNodeImpl) ActorSectorSize(ctx context.Context, addr address.Address) (abi.SectorSize, error) {
+	if err := m.checkMiner(ctx, addr); err != nil {
+		return 0, err
+	}
 	if bHas := m.MinerMgr.Has(ctx, addr); bHas {
 		minerInfo, err := m.FullNode.StateMinerInfo(ctx, addr, vTypes.EmptyTSK)
 		if err != nil {
@@ -110,7 +142,49 @@ func (m MarketNodeImpl) ActorSectorSize(ctx context.Context, addr address.Addres
 	return 0, xerrors.New("not found")
 }
 
+func (m MarketNodeImpl) ActorAdd(ctx context.Context, addr address.Address, account string) error {
+	if err := checkOperator(ctx); err != nil {
+		return err
+	}
+	return m.MinerMgr.AddAddress(ctx, types.User{Addr: addr, Account: account})
+}
+
+func (m MarketNodeImpl) ActorRemove(ctx context.Context, addr address.Address) error {
+	if err := checkOperator(ctx); err != nil {
+		return err
+	}
+	return m.MinerMgr.RemoveAddress(ctx, addr)
+}
+
+func (m MarketNodeImpl) ActorSetDisabled(ctx context.Context, addr address.Address, disabled bool) error {
+	if err := m.checkMiner(ctx, addr); err != nil {
+		return err
+	}
+	return m.MinerMgr.SetDisabled(ctx, addr, disabled)
+}
+
+func (m MarketNodeImpl) ActorStates(ctx context.Context) ([]mtypes.MinerState, error) {
+	states, err := m.MinerMgr.MinerStates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := m.minerFilter(ctx)
+	if err != nil || filter == nil {
+		return states, err
+	}
+	out := make([]mtypes.MinerState, 0, len(states))
+	for _, state := range states {
+		if filter(state.Addr) {
+			out = append(out, state)
+		}
+	}
+	return out, nil
+}
+
 func (m MarketNodeImpl) MarketImportDealData(ctx context.Context, propCid cid.Cid, path string) error {
+	if err := m.checkDeal(ctx, propCid); err != nil {
+		return err
+	}
 	fi, err := os.Open(path)
 	if err != nil {
 		return xerrors.Errorf("failed to open file: %w", err)
@@ -125,27 +199,57 @@ func (m MarketNodeImpl) MarketListDeals(ctx context.Context, addrs []address.Add
 }
 
 func (m MarketNodeImpl) MarketListRetrievalDeals(ctx context.Context, mAddr address.Address) ([]types.ProviderDealState, error) {
+	if mAddr != address.Undef {
+		if err := m.checkMiner(ctx, mAddr); err != nil {
+			return nil, err
+		}
+	}
+	filter, err := m.minerFilter(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	var out []types.ProviderDealState
 	deals, err := m.RetrievalProvider.ListDeals(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	// the miner of a retrieval deal is the provider of the storage deal the data is retrieved from
+	providers := make(map[cid.Cid]address.Address)
 	for _, deal := range deals {
+		if mAddr != address.Undef || filter != nil {
+			provider, ok := providers[deal.SelStorageProposalCid]
+			if !ok {
+				if storageDeal, err := m.Repo.StorageDealRepo().GetDeal(ctx, deal.SelStorageProposalCid); err == nil {
+					provider = storageDeal.Proposal.Provider
+				}
+				providers[deal.SelStorageProposalCid] = provider
+			}
+			if (mAddr != address.Undef && provider != mAddr) || (filter != nil && !filter(provider)) {
+				continue
+			}
+		}
 		if deal.ChannelID != nil {
 			if deal.ChannelID.Initiator == "" || deal.ChannelID.Responder == "" {
 				deal.ChannelID = nil // don't try to push unparsable peer IDs over jsonrpc
 			}
 		}
-		// todo: 按miner过滤交易
 		out = append(out, *deal)
 	}
 	return out, nil
 }
 
 func (m MarketNodeImpl) MarketGetDealUpdates(ctx context.Context) (<-chan storagemarket.MinerDeal, error) {
+	filter, err := m.minerFilter(ctx)
+	if err != nil {
+		return nil, err
+	}
 	results := make(chan storagemarket.MinerDeal)
 	unsub := m.StorageProvider.SubscribeToEvents(func(evt storagemarket.ProviderEvent, deal storagemarket.MinerDeal) {
+		if filter != nil && !filter(deal.Proposal.Provider) {
+			return
+		}
 		select {
 		case results <- deal:
 		case <-ctx.Done():
@@ -168,25 +272,41 @@ func (m MarketNodeImpl) MarketListIncompleteDeals(ctx context.Context, mAddr add
 			return nil, err
 		}
 	} else {
+		if err := m.checkMiner(ctx, mAddr); err != nil {
+			return nil, err
+		}
 		deals, err = m.Repo.StorageDealRepo().ListDealByAddr(ctx, mAddr)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	resDeals := make([]storagemarket.MinerDeal, len(deals))
-	for idx, deal := range deals {
-		resDeals[idx] = *deal.FilMarketMinerDeal()
+	filter, err := m.minerFilter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resDeals := make([]storagemarket.MinerDeal, 0, len(deals))
+	for _, deal := range deals {
+		if filter != nil && !filter(deal.Proposal.Provider) {
+			continue
+		}
+		resDeals = append(resDeals, *deal.FilMarketMinerDeal())
 	}
 
 	return resDeals, nil
 }
 
 func (m MarketNodeImpl) UpdateStorageDealStatus(ctx context.Context, dealProposal cid.Cid, state storagemarket.StorageDealStatus) error {
+	if err := m.checkDeal(ctx, dealProposal); err != nil {
+		return err
+	}
 	return m.Repo.StorageDealRepo().UpdateDealStatus(ctx, dealProposal, state)
 }
 
 func (m MarketNodeImpl) MarketSetAsk(ctx context.Context, mAddr address.Address, price vTypes.BigInt, verifiedPrice vTypes.BigInt, duration abi.ChainEpoch, minPieceSize abi.PaddedPieceSize, maxPieceSize abi.PaddedPieceSize) error {
+	if err := m.checkMiner(ctx, mAddr); err != nil {
+		return err
+	}
 	options := []storagemarket.StorageAskOption{
 		storagemarket.MinPieceSize(minPieceSize),
 		storagemarket.MaxPieceSize(maxPieceSize),
@@ -196,14 +316,41 @@ func (m MarketNodeImpl) MarketSetAsk(ctx context.Context, mAddr address.Address,
 }
 
 func (m MarketNodeImpl) MarketListAsk(ctx context.Context) ([]*storagemarket.SignedStorageAsk, error) {
-	return m.StorageAsk.ListAsk(ctx)
+	asks, err := m.StorageAsk.ListAsk(ctx)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := m.minerFilter(ctx)
+	if err != nil || filter == nil {
+		return asks, err
+	}
+	out := make([]*storagemarket.SignedStorageAsk, 0, len(asks))
+	for _, ask := range asks {
+		if ask.Ask != nil && filter(ask.Ask.Miner) {
+			out = append(out, ask)
+		}
+	}
+	return out, nil
 }
 
 func (m MarketNodeImpl) MarketGetAsk(ctx context.Context, mAddr address.Address) (*storagemarket.SignedStorageAsk, error) {
+	if err := m.checkMiner(ctx, mAddr); err != nil {
+		return nil, err
+	}
 	return m.StorageAsk.GetAsk(ctx, mAddr)
 }
 
+func (m MarketNodeImpl) MarketListStorageAskHistory(ctx context.Context, mAddr address.Address, limit int) ([]*mtypes.StorageAskChange, error) {
+	if err := m.checkMiner(ctx, mAddr); err != nil {
+		return nil, err
+	}
+	return m.StorageAsk.ListAskHistory(ctx, mAddr, limit)
+}
+
 func (m MarketNodeImpl) MarketSetRetrievalAsk(ctx context.Context, mAddr address.Address, ask *retrievalmarket.Ask) error {
+	if err := m.checkMiner(ctx, mAddr); err != nil {
+		return err
+	}
 	return m.Repo.RetrievalAskRepo().SetAsk(ctx, &types.RetrievalAsk{
 		Miner:                   mAddr,
 		PricePerByte:            ask.PricePerByte,
@@ -213,11 +360,74 @@ func (m MarketNodeImpl) MarketSetRetrievalAsk(ctx context.Context, mAddr address
 	})
 }
 
+func (m MarketNodeImpl) MarketSetRetrievalAskRule(ctx context.Context, rule *mtypes.RetrievalAskRule) error {
+	if err := m.checkMiner(ctx, rule.Miner); err != nil {
+		return err
+	}
+	value, err := retrievalprovider.NormalizeAskRuleValue(rule.Kind, rule.Value)
+	if err != nil {
+		return err
+	}
+	rule.Value = value
+	return m.Repo.RetrievalAskRepo().SetAskRule(ctx, rule)
+}
+
+func (m MarketNodeImpl) MarketRemoveRetrievalAskRule(ctx context.Context, mAddr address.Address, kind mtypes.RetrievalAskRuleKind, value string) error {
+	if err := m.checkMiner(ctx, mAddr); err != nil {
+		return err
+	}
+	value, err := retrievalprovider.NormalizeAskRuleValue(kind, value)
+	if err != nil {
+		return err
+	}
+	return m.Repo.RetrievalAskRepo().RemoveAskRule(ctx, mAddr, kind, value)
+}
+
+func (m MarketNodeImpl) MarketListRetrievalAskRules(ctx context.Context, mAddr address.Address) ([]*mtypes.RetrievalAskRule, error) {
+	if mAddr != address.Undef {
+		if err := m.checkMiner(ctx, mAddr); err != nil {
+			return nil, err
+		}
+	}
+	rules, err := m.Repo.RetrievalAskRepo().ListAskRules(ctx, mAddr)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := m.minerFilter(ctx)
+	if err != nil || filter == nil {
+		return rules, err
+	}
+	out := make([]*mtypes.RetrievalAskRule, 0, len(rules))
+	for _, rule := range rules {
+		if filter(rule.Miner) {
+			out = append(out, rule)
+		}
+	}
+	return out, nil
+}
+
 func (m MarketNodeImpl) MarketListRetrievalAsk(ctx context.Context) ([]*types.RetrievalAsk, error) {
-	return m.Repo.RetrievalAskRepo().ListAsk(ctx)
+	asks, err := m.Repo.RetrievalAskRepo().ListAsk(ctx)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := m.minerFilter(ctx)
+	if err != nil || filter == nil {
+		return asks, err
+	}
+	out := make([]*types.RetrievalAsk, 0, len(asks))
+	for _, ask := range asks {
+		if filter(ask.Miner) {
+			out = append(out, ask)
+		}
+	}
+	return out, nil
 }
 
 func (m MarketNodeImpl) MarketGetRetrievalAsk(ctx context.Context, mAddr address.Address) (*retrievalmarket.Ask, error) {
+	if err := m.checkMiner(ctx, mAddr); err != nil {
+		return nil, err
+	}
 	ask, err := m.Repo.RetrievalAskRepo().GetAsk(ctx, mAddr)
 	if err != nil {
 		return nil, err
@@ -231,6 +441,9 @@ func (m MarketNodeImpl) MarketGetRetrievalAsk(ctx context.Context, mAddr address
 }
 
 func (m MarketNodeImpl) MarketListDataTransfers(ctx context.Context) ([]types.DataTransferChannel, error) {
+	if err := checkOperator(ctx); err != nil {
+		return nil, err
+	}
 	inProgressChannels, err := m.DataTransfer.InProgressChannels(ctx)
 	if err != nil {
 		return nil, err
@@ -245,6 +458,9 @@ func (m MarketNodeImpl) MarketListDataTransfers(ctx context.Context) ([]types.Da
 }
 
 func (m MarketNodeImpl) MarketDataTransferUpdates(ctx context.Context) (<-chan types.DataTransferChannel, error) {
+	if err := checkOperator(ctx); err != nil {
+		return nil, err
+	}
 	channels := make(chan types.DataTransferChannel)
 
 	unsub := m.DataTransfer.SubscribeToEvents(func(evt datatransfer.Event, channelState datatransfer.ChannelState) {
@@ -264,6 +480,9 @@ func (m MarketNodeImpl) MarketDataTransferUpdates(ctx context.Context) (<-chan t
 }
 
 func (m MarketNodeImpl) MarketRestartDataTransfer(ctx context.Context, transferID datatransfer.TransferID, otherPeer peer.ID, isInitiator bool) error {
+	if err := checkOperator(ctx); err != nil {
+		return err
+	}
 	selfPeer := m.Host.ID()
 	if isInitiator {
 		return m.DataTransfer.RestartDataTransferChannel(ctx, datatransfer.ChannelID{Initiator: selfPeer, Responder: otherPeer, ID: transferID})
@@ -272,6 +491,9 @@ func (m MarketNodeImpl) MarketRestartDataTransfer(ctx context.Context, transferI
 }
 
 func (m MarketNodeImpl) MarketCancelDataTransfer(ctx context.Context, transferID datatransfer.TransferID, otherPeer peer.ID, isInitiator bool) error {
+	if err := checkOperator(ctx); err != nil {
+		return err
+	}
 	selfPeer := m.Host.ID()
 	if isInitiator {
 		return m.DataTransfer.CloseDataTransferChannel(ctx, datatransfer.ChannelID{Initiator: selfPeer, Responder: otherPeer, ID: transferID})
@@ -280,23 +502,65 @@ func (m MarketNodeImpl) MarketCancelDataTransfer(ctx context.Context, transferID
 }
 
 func (m MarketNodeImpl) MarketPendingDeals(ctx context.Context) ([]types.PendingDealInfo, error) {
-	return m.DealPublisher.PendingDeals(), nil
+	pending := m.DealPublisher.PendingDeals()
+	filter, err := m.minerFilter(ctx)
+	if err != nil || filter == nil {
+		return pending, err
+	}
+	out := make([]types.PendingDealInfo, 0, len(pending))
+	for _, info := range pending {
+		deals := info.Deals[:0:0]
+		for _, deal := range info.Deals {
+			if filter(deal.Proposal.Provider) {
+				deals = append(deals, deal)
+			}
+		}
+		if len(deals) > 0 {
+			info.Deals = deals
+			out = append(out, info)
+		}
+	}
+	return out, nil
 }
 
 func (m MarketNodeImpl) MarketPublishPendingDeals(ctx context.Context) error {
+	if err := checkOperator(ctx); err != nil {
+		return err
+	}
 	m.DealPublisher.ForcePublishPendingDeals()
 	return nil
 }
 
 func (m MarketNodeImpl) PiecesListPieces(ctx context.Context) ([]cid.Cid, error) {
-	return m.Repo.StorageDealRepo().ListPieceInfoKeys(ctx)
+	filter, err := m.minerFilter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if filter == nil {
+		return m.Repo.StorageDealRepo().ListPieceInfoKeys(ctx)
+	}
+	pieces, err := m.ownedPieces(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]cid.Cid, 0, len(pieces))
+	for piece := range pieces {
+		out = append(out, piece)
+	}
+	return out, nil
 }
 
 func (m MarketNodeImpl) PiecesListCidInfos(ctx context.Context) ([]cid.Cid, error) {
+	if err := checkOperator(ctx); err != nil {
+		return nil, err
+	}
 	return m.Repo.CidInfoRepo().ListCidInfoKeys(ctx)
 }
 
 func (m MarketNodeImpl) PiecesGetPieceInfo(ctx context.Context, pieceCid cid.Cid) (*piecestore.PieceInfo, error) {
+	if err := m.checkPiece(ctx, pieceCid); err != nil {
+		return nil, err
+	}
 	pi, err := m.Repo.StorageDealRepo().GetPieceInfo(ctx, pieceCid)
 	if err != nil {
 		return nil, err
@@ -310,6 +574,28 @@ func (m MarketNodeImpl) PiecesGetCIDInfo(ctx context.Context, payloadCid cid.Cid
 		return nil, err
 	}
 
+	filter, err := m.minerFilter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if filter != nil {
+		// only the locations in the pieces of the account are visible
+		pieces, err := m.ownedPieces(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		locations := ci.PieceBlockLocations[:0:0]
+		for _, location := range ci.PieceBlockLocations {
+			if _, ok := pieces[location.PieceCID]; ok {
+				locations = append(locations, location)
+			}
+		}
+		if len(locations) == 0 {
+			return nil, xerrors.Errorf("payload %s: %w", payloadCid, ErrNotOwner)
+		}
+		ci.PieceBlockLocations = locations
+	}
+
 	return &ci, nil
 }
 
@@ -318,6 +604,9 @@ func (m MarketNodeImpl) DealsConsiderOnlineStorageDeals(ctx context.Context) (bo
 }
 
 func (m MarketNodeImpl) DealsSetConsiderOnlineStorageDeals(ctx context.Context, b bool) error {
+	if err := checkOperator(ctx); err != nil {
+		return err
+	}
 	return m.SetConsiderOnlineStorageDealsConfigFunc(b)
 }
 
@@ -326,6 +615,9 @@ func (m MarketNodeImpl) DealsConsiderOnlineRetrievalDeals(ctx context.Context) (
 }
 
 func (m MarketNodeImpl) DealsSetConsiderOnlineRetrievalDeals(ctx context.Context, b bool) error {
+	if err := checkOperator(ctx); err != nil {
+		return err
+	}
 	return m.SetConsiderOnlineRetrievalDealsConfigFunc(b)
 }
 
@@ -334,6 +626,9 @@ func (m MarketNodeImpl) DealsPieceCidBlocklist(ctx context.Context) ([]cid.Cid,
 }
 
 func (m MarketNodeImpl) DealsSetPieceCidBlocklist(ctx context.Context, cids []cid.Cid) error {
+	if err := checkOperator(ctx); err != nil {
+		return err
+	}
 	return m.SetStorageDealPieceCidBlocklistConfigFunc(cids)
 }
 
@@ -342,6 +637,9 @@ func (m MarketNodeImpl) DealsConsiderOfflineStorageDeals(ctx context.Context) (b
 }
 
 func (m MarketNodeImpl) DealsSetConsiderOfflineStorageDeals(ctx context.Context, b bool) error {
+	if err := checkOperator(ctx); err != nil {
+		return err
+	}
 	return m.SetConsiderOfflineStorageDealsConfigFunc(b)
 }
 
@@ -350,6 +648,9 @@ func (m MarketNodeImpl) DealsConsiderOfflineRetrievalDeals(ctx context.Context)
 }
 
 func (m MarketNodeImpl) DealsSetConsiderOfflineRetrievalDeals(ctx context.Context, b bool) error {
+	if err := checkOperator(ctx); err != nil {
+		return err
+	}
 	return m.SetConsiderOfflineRetrievalDealsConfigFunc(b)
 }
 
@@ -358,6 +659,9 @@ func (m MarketNodeImpl) DealsConsiderVerifiedStorageDeals(ctx context.Context) (
 }
 
 func (m MarketNodeImpl) DealsSetConsiderVerifiedStorageDeals(ctx context.Context, b bool) error {
+	if err := checkOperator(ctx); err != nil {
+		return err
+	}
 	return m.SetConsiderVerifiedStorageDealsConfigFunc(b)
 }
 
@@ -366,6 +670,9 @@ func (m MarketNodeImpl) DealsConsiderUnverifiedStorageDeals(ctx context.Context)
 }
 
 func (m MarketNodeImpl) DealsSetConsiderUnverifiedStorageDeals(ctx context.Context, b bool) error {
+	if err := checkOperator(ctx); err != nil {
+		return err
+	}
 	return m.SetConsiderUnverifiedStorageDealsConfigFunc(b)
 }
 
@@ -374,15 +681,38 @@ func (m MarketNodeImpl) SectorGetSealDelay(ctx context.Context) (time.Duration,
 }
 
 func (m MarketNodeImpl) SectorSetExpectedSealDuration(ctx context.Context, duration time.Duration) error {
+	if err := checkOperator(ctx); err != nil {
+		return err
+	}
 	return m.SetExpectedSealDurationFunc(duration)
 }
 
 func (m MarketNodeImpl) MessagerWaitMessage(ctx context.Context, mid cid.Cid) (*vTypes.MsgLookup, error) {
+	if err := m.checkMessage(ctx, mid); err != nil {
+		return nil, err
+	}
 	//WaitMsg method has been replace in messager mode
 	return m.Messager.WaitMsg(ctx, mid, constants.MessageConfidence, constants.LookbackNoLimit, false)
 }
 
+func (m MarketNodeImpl) MarketListMessages(ctx context.Context, from address.Address) ([]*mtypes.NonceMessage, error) {
+	if err := checkOperator(ctx); err != nil {
+		return nil, err
+	}
+	return m.Messager.ListMessages(ctx, from)
+}
+
+func (m MarketNodeImpl) MarketReplaceMessage(ctx context.Context, mCid cid.Cid, params mtypes.MsgReplaceParams) (cid.Cid, error) {
+	if err := checkOperator(ctx); err != nil {
+		return cid.Undef, err
+	}
+	return m.Messager.ReplaceMessage(ctx, mCid, params)
+}
+
 func (m MarketNodeImpl) MessagerPushMessage(ctx context.Context, msg *vTypes.Message, meta *vTypes.MessageSendSpec) (cid.Cid, error) {
+	if err := m.checkAddress(ctx, msg.From); err != nil {
+		return cid.Undef, err
+	}
 	var spec *vTypes.MessageSendSpec
 	if meta != nil {
 		spec = &vTypes.MessageSendSpec{
@@ -394,10 +724,22 @@ func (m MarketNodeImpl) MessagerPushMessage(ctx context.Context, msg *vTypes.Mes
 }
 
 func (m MarketNodeImpl) MessagerGetMessage(ctx context.Context, mid cid.Cid) (*vTypes.Message, error) {
-	return m.Messager.GetMessage(ctx, mid)
+	msg, err := m.Messager.GetMessage(ctx, mid)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkAddress(ctx, msg.From); err != nil {
+		return nil, err
+	}
+	return msg, nil
 }
 
 func (m MarketNodeImpl) listDeals(ctx context.Context, addrs []address.Address) ([]vTypes.MarketDeal, error) {
+	filter, err := m.minerFilter(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	ts, err := m.FullNode.ChainHead(ctx)
 	if err != nil {
 		return nil, err
@@ -421,7 +763,7 @@ func (m MarketNodeImpl) listDeals(ctx context.Context, addrs []address.Address)
 	}
 
 	for _, deal := range allDeals {
-		if m.MinerMgr.Has(ctx, deal.Proposal.Provider) && has(deal.Proposal.Provider) {
+		if m.MinerMgr.Has(ctx, deal.Proposal.Provider) && has(deal.Proposal.Provider) && (filter == nil || filter(deal.Proposal.Provider)) {
 			out = append(out, deal)
 		}
 	}
@@ -441,6 +783,9 @@ func (m MarketNodeImpl) ID(context.Context) (peer.ID, error) {
 }
 
 func (m MarketNodeImpl) DagstoreListShards(ctx context.Context) ([]types.DagstoreShardInfo, error) {
+	if err := checkOperator(ctx); err != nil {
+		return nil, err
+	}
 	info := m.DAGStore.AllShardsInfo()
 	ret := make([]types.DagstoreShardInfo, 0, len(info))
 	for k, i := range info {
@@ -465,6 +810,9 @@ func (m MarketNodeImpl) DagstoreListShards(ctx context.Context) ([]types.Dagstor
 }
 
 func (m MarketNodeImpl) DagstoreInitializeShard(ctx context.Context, key string) error {
+	if err := checkOperator(ctx); err != nil {
+		return err
+	}
 	k := shard.KeyFromString(key)
 
 	info, err := m.DAGStore.GetShardInfo(k)
@@ -502,6 +850,9 @@ func (m MarketNodeImpl) DagstoreInitializeShard(ctx context.Context, key string)
 }
 
 func (m MarketNodeImpl) DagstoreInitializeAll(ctx context.Context, params types.DagstoreInitializeAllParams) (<-chan types.DagstoreInitializeAllEvent, error) {
+	if err := checkOperator(ctx); err != nil {
+		return nil, err
+	}
 	// prepare the thottler tokens.
 	var throttle chan struct{}
 	if c := params.MaxConcurrency; c > 0 {
@@ -516,7 +867,26 @@ func (m MarketNodeImpl) DagstoreInitializeAll(ctx context.Context, params types.
 
 	info := m.DAGStore.AllShardsInfo()
 	var toInitialize []string
+	// the shards initialized before the block index existed only have their blocks indexed
+	toIndex := make(map[string]cid.Cid)
 	for k, i := range info {
+		if i.ShardState == dagstore.ShardStateAvailable || i.ShardState == dagstore.ShardStateServing {
+			pieceCid, err := cid.Decode(k.String())
+			if err != nil {
+				log.Warnw("DagstoreInitializeAll: failed to decode shard key as piece CID; skipping", "shard_key", k.String(), "error", err)
+				continue
+			}
+			has, err := m.BlockIndexer.HasBlockIndex(ctx, pieceCid)
+			if err != nil {
+				log.Warnw("DagstoreInitializeAll: failed to check block index; skipping", "piece_cid", pieceCid, "error", err)
+				continue
+			}
+			if !has {
+				toIndex[k.String()] = pieceCid
+				toInitialize = append(toInitialize, k.String())
+			}
+			continue
+		}
 		if i.ShardState != dagstore.ShardStateNew {
 			continue
 		}
@@ -597,7 +967,12 @@ func (m MarketNodeImpl) DagstoreInitializeAll(ctx context.Context, params types.
 					return
 				}
 
-				err := m.DagstoreInitializeShard(ctx, k)
+				var err error
+				if pieceCid, ok := toIndex[k]; ok {
+					err = m.BlockIndexer.IndexBlocks(ctx, pieceCid)
+				} else {
+					err = m.DagstoreInitializeShard(ctx, k)
+				}
 
 				if throttle != nil {
 					throttle <- struct{}{}
@@ -624,6 +999,9 @@ func (m MarketNodeImpl) DagstoreInitializeAll(ctx context.Context, params types.
 }
 
 func (m MarketNodeImpl) DagstoreRecoverShard(ctx context.Context, key string) error {
+	if err := checkOperator(ctx); err != nil {
+		return err
+	}
 
 	k := shard.KeyFromString(key)
 
@@ -650,7 +1028,45 @@ func (m MarketNodeImpl) DagstoreRecoverShard(ctx context.Context, key string) er
 	return res.Error
 }
 
+func (m MarketNodeImpl) DagstoreTransientUsage(ctx context.Context) (*mtypes.DagstoreTransientUsage, error) {
+	if err := checkOperator(ctx); err != nil {
+		return nil, err
+	}
+	return m.Transients.Usage(), nil
+}
+
+func (m MarketNodeImpl) PiecesGC(ctx context.Context, dryRun bool) (*mtypes.PieceGCReport, error) {
+	if err := checkOperator(ctx); err != nil {
+		return nil, err
+	}
+	return m.PieceGC.Run(ctx, dryRun)
+}
+
+func (m MarketNodeImpl) PiecesVerify(ctx context.Context, pieces []cid.Cid) ([]mtypes.PieceVerifyResult, error) {
+	if err := checkOperator(ctx); err != nil {
+		return nil, err
+	}
+	return m.PieceVerifier.Verify(ctx, pieces)
+}
+
+func (m MarketNodeImpl) PiecesListVerify(ctx context.Context) ([]*mtypes.PieceVerifyResult, error) {
+	if err := checkOperator(ctx); err != nil {
+		return nil, err
+	}
+	return m.Repo.PieceVerifyRepo().ListPieceVerify(ctx)
+}
+
+func (m MarketNodeImpl) MarketListClientUsage(ctx context.Context) ([]mtypes.ClientUsage, error) {
+	if err := checkOperator(ctx); err != nil {
+		return nil, err
+	}
+	return m.ClientQuota.Usage(ctx)
+}
+
 func (m MarketNodeImpl) DagstoreGC(ctx context.Context) ([]types.DagstoreShardResult, error) {
+	if err := checkOperator(ctx); err != nil {
+		return nil, err
+	}
 	if m.DAGStore == nil {
 		return nil, fmt.Errorf("dagstore not available on this node")
 	}
@@ -676,26 +1092,244 @@ func (m MarketNodeImpl) DagstoreGC(ctx context.Context) ([]types.DagstoreShardRe
 }
 
 func (m MarketNodeImpl) GetUnPackedDeals(ctx context.Context, miner address.Address, spec *types.GetDealSpec) ([]*types.DealInfoIncludePath, error) {
+	if err := m.checkMiner(ctx, miner); err != nil {
+		return nil, err
+	}
 	return m.DealAssigner.GetUnPackedDeals(ctx, miner, spec)
 }
 
 func (m MarketNodeImpl) AssignUnPackedDeals(ctx context.Context, miner address.Address, ssize abi.SectorSize, spec *types.GetDealSpec) ([]*types.DealInfoIncludePath, error) {
-	return m.DealAssigner.AssignUnPackedDeals(ctx, miner, ssize, spec)
+	if err := m.checkMiner(ctx, miner); err != nil {
+		return nil, err
+	}
+	return m.DealAssigner.AssignUnPackedDeals(ctx, miner, tokenSealer(ctx), ssize, spec)
+}
+
+func (m MarketNodeImpl) MarketAssignUnPackedDeals(ctx context.Context, miner address.Address, sealer string, ssize abi.SectorSize, spec *types.GetDealSpec) ([]*types.DealInfoIncludePath, error) {
+	if err := m.checkMiner(ctx, miner); err != nil {
+		return nil, err
+	}
+	if err := checkSealer(sealer); err != nil {
+		return nil, err
+	}
+	return m.DealAssigner.AssignUnPackedDeals(ctx, miner, sealer, ssize, spec)
 }
 
 func (m MarketNodeImpl) MarkDealsAsPacking(ctx context.Context, miner address.Address, deals []abi.DealID) error {
-	return m.DealAssigner.MarkDealsAsPacking(ctx, miner, deals)
+	if err := m.checkMiner(ctx, miner); err != nil {
+		return err
+	}
+	return m.DealAssigner.MarkDealsAsPacking(ctx, miner, tokenSealer(ctx), deals)
+}
+
+func (m MarketNodeImpl) MarketMarkDealsAsPacking(ctx context.Context, miner address.Address, sealer string, dealIDs []abi.DealID) error {
+	if err := m.checkMiner(ctx, miner); err != nil {
+		return err
+	}
+	if err := checkSealer(sealer); err != nil {
+		return err
+	}
+	return m.DealAssigner.MarkDealsAsPacking(ctx, miner, sealer, dealIDs)
 }
 
 func (m MarketNodeImpl) UpdateDealOnPacking(ctx context.Context, miner address.Address, dealId abi.DealID, sectorid abi.SectorNumber, offset abi.PaddedPieceSize) error {
-	return m.DealAssigner.UpdateDealOnPacking(ctx, miner, dealId, sectorid, offset)
+	if err := m.checkMiner(ctx, miner); err != nil {
+		return err
+	}
+	return m.DealAssigner.UpdateDealOnPacking(ctx, miner, tokenSealer(ctx), dealId, sectorid, offset)
+}
+
+func (m MarketNodeImpl) MarketUpdateDealOnPacking(ctx context.Context, miner address.Address, sealer string, dealID abi.DealID, sectorID abi.SectorNumber, offset abi.PaddedPieceSize) error {
+	if err := m.checkMiner(ctx, miner); err != nil {
+		return err
+	}
+	if err := checkSealer(sealer); err != nil {
+		return err
+	}
+	return m.DealAssigner.UpdateDealOnPacking(ctx, miner, sealer, dealID, sectorID, offset)
 }
 
 func (m MarketNodeImpl) UpdateDealStatus(ctx context.Context, miner address.Address, dealId abi.DealID, status string) error {
+	if err := m.checkMiner(ctx, miner); err != nil {
+		return err
+	}
 	return m.DealAssigner.UpdateDealStatus(ctx, miner, dealId, status)
 }
 
+func (m MarketNodeImpl) MarketRenewDealLeases(ctx context.Context, miner address.Address, sealer string, dealIDs []abi.DealID) error {
+	if err := m.checkMiner(ctx, miner); err != nil {
+		return err
+	}
+	if err := checkSealer(sealer); err != nil {
+		return err
+	}
+	return m.DealAssigner.RenewDealLeases(ctx, miner, sealer, dealIDs)
+}
+
+func (m MarketNodeImpl) MarketListDealLeases(ctx context.Context, miner address.Address) ([]*mtypes.DealLease, error) {
+	// the leases of all the miners are for the operator only
+	if miner == address.Undef {
+		if err := checkOperator(ctx); err != nil {
+			return nil, err
+		}
+	} else if err := m.checkMiner(ctx, miner); err != nil {
+		return nil, err
+	}
+	return m.DealAssigner.ListDealLeases(ctx, miner)
+}
+
+func (m MarketNodeImpl) MarketReleaseDealLeases(ctx context.Context, miner address.Address, dealIDs []abi.DealID) error {
+	if err := m.checkMiner(ctx, miner); err != nil {
+		return err
+	}
+	return m.DealAssigner.ReleaseDealLeases(ctx, miner, dealIDs)
+}
+
+func (m MarketNodeImpl) MarketListDealRisks(ctx context.Context, miner address.Address) ([]mtypes.DealRisk, error) {
+	if miner == address.Undef {
+		if err := checkOperator(ctx); err != nil {
+			return nil, err
+		}
+	} else if err := m.checkMiner(ctx, miner); err != nil {
+		return nil, err
+	}
+	return m.DealRiskMonitor.DealRisks(ctx, miner)
+}
+
+func (m MarketNodeImpl) MarketListFunds(ctx context.Context, addrs []address.Address) ([]mtypes.FundState, error) {
+	filter, err := m.minerFilter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		users, err := m.MinerMgr.ActorList(ctx)
+		if err != nil {
+			return nil, err
+		}
+		seen := make(map[address.Address]struct{})
+		for _, user := range users {
+			seen[user.Addr] = struct{}{}
+			addrs = append(addrs, user.Addr)
+		}
+		for _, addr := range m.FMgr.Addresses() {
+			if _, ok := seen[addr]; !ok {
+				addrs = append(addrs, addr)
+			}
+		}
+	} else if filter != nil {
+		for _, addr := range addrs {
+			if err := m.checkMiner(ctx, addr); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	states := make([]mtypes.FundState, 0, len(addrs))
+	for _, addr := range addrs {
+		if filter != nil && !filter(addr) {
+			continue
+		}
+		state, err := m.FMgr.FundState(ctx, addr)
+		if err != nil {
+			return nil, err
+		}
+		if state.Deals, err = storageprovider.FundDeals(ctx, m.Repo.StorageDealRepo(), addr); err != nil {
+			return nil, err
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+func (m MarketNodeImpl) MarketReconcileFunds(ctx context.Context, miner address.Address, dryRun bool) (*mtypes.FundReconcile, error) {
+	if err := m.checkMiner(ctx, miner); err != nil {
+		return nil, err
+	}
+	return storageprovider.ReconcileFunds(ctx, m.Repo.StorageDealRepo(), m.FMgr, miner, dryRun)
+}
+
+func (m MarketNodeImpl) MarketAddBalance(ctx context.Context, wallet, addr address.Address, amt vTypes.BigInt) (cid.Cid, error) {
+	if err := m.checkFundAddresses(ctx, wallet, addr); err != nil {
+		return cid.Undef, err
+	}
+	return m.FundAPI.MarketAddBalance(ctx, wallet, addr, amt)
+}
+
+func (m MarketNodeImpl) MarketGetReserved(ctx context.Context, addr address.Address) (vTypes.BigInt, error) {
+	if err := m.checkAddress(ctx, addr); err != nil {
+		return vTypes.BigInt{}, err
+	}
+	return m.FundAPI.MarketGetReserved(ctx, addr)
+}
+
+func (m MarketNodeImpl) MarketReserveFunds(ctx context.Context, wallet address.Address, addr address.Address, amt vTypes.BigInt) (cid.Cid, error) {
+	if err := m.checkFundAddresses(ctx, wallet, addr); err != nil {
+		return cid.Undef, err
+	}
+	return m.FundAPI.MarketReserveFunds(ctx, wallet, addr, amt)
+}
+
+func (m MarketNodeImpl) MarketReleaseFunds(ctx context.Context, addr address.Address, amt vTypes.BigInt) error {
+	if err := m.checkAddress(ctx, addr); err != nil {
+		return err
+	}
+	return m.FundAPI.MarketReleaseFunds(ctx, addr, amt)
+}
+
+func (m MarketNodeImpl) MarketWithdraw(ctx context.Context, wallet, addr address.Address, amt vTypes.BigInt) (cid.Cid, error) {
+	if err := m.checkFundAddresses(ctx, wallet, addr); err != nil {
+		return cid.Undef, err
+	}
+	return m.FundAPI.MarketWithdraw(ctx, wallet, addr, amt)
+}
+
+// checkFundAddresses rejects the requests moving the escrow of addr with wallet unless the account owns both
+func (m MarketNodeImpl) checkFundAddresses(ctx context.Context, wallet, addr address.Address) error {
+	if err := m.checkAddress(ctx, addr); err != nil {
+		return err
+	}
+	return m.checkAddress(ctx, wallet)
+}
+
+func (m MarketNodeImpl) SignerStatus(ctx context.Context) (*mtypes.SignerStatus, error) {
+	if err := checkOperator(ctx); err != nil {
+		return nil, err
+	}
+	if m.SignerMux == nil {
+		return nil, xerrors.New("no signer is configured")
+	}
+
+	users, err := m.MinerMgr.ActorList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	status := &mtypes.SignerStatus{
+		Backends: m.SignerMux.Status(),
+		Routes:   make([]mtypes.SignerRoute, 0, len(users)),
+	}
+	for _, user := range users {
+		route := mtypes.SignerRoute{Miner: user.Addr, Addr: user.Addr}
+		// a miner actor signs with its worker, the other addresses of the market sign for themselves
+		if minerInfo, err := m.FullNode.StateMinerInfo(ctx, user.Addr, vTypes.EmptyTSK); err == nil {
+			route.Addr = minerInfo.Worker
+		}
+		signer, err := m.FullNode.StateAccountKey(ctx, route.Addr, vTypes.EmptyTSK)
+		if err == nil {
+			route.Addr = signer
+			route.Backend, err = m.SignerMux.Route(ctx, signer)
+		}
+		if err != nil {
+			route.Error = err.Error()
+		}
+		status.Routes = append(status.Routes, route)
+	}
+	return status, nil
+}
+
 func (m MarketNodeImpl) DealsImportData(ctx context.Context, dealPropCid cid.Cid, fname string) error {
+	if err := m.checkDeal(ctx, dealPropCid); err != nil {
+		return err
+	}
 	fi, err := os.Open(fname)
 	if err != nil {
 		return xerrors.Errorf("failed to open given file: %w", err)
@@ -706,14 +1340,30 @@ func (m MarketNodeImpl) DealsImportData(ctx context.Context, dealPropCid cid.Cid
 }
 
 func (m MarketNodeImpl) GetDeals(ctx context.Context, miner address.Address, pageIndex, pageSize int) ([]*types.DealInfo, error) {
+	if err := m.checkMiner(ctx, miner); err != nil {
+		return nil, err
+	}
 	return m.DealAssigner.GetDeals(ctx, miner, pageIndex, pageSize)
 }
 
 func (m MarketNodeImpl) PaychVoucherList(ctx context.Context, pch address.Address) ([]*paych.SignedVoucher, error) {
+	if _, scoped := tenantAccount(ctx); scoped {
+		// the vouchers are visible to the account owning the receiving side of the channel
+		status, err := m.PaychAPI.PaychStatus(ctx, pch)
+		if err != nil {
+			return nil, err
+		}
+		if err := m.checkAddress(ctx, status.ControlAddr); err != nil {
+			return nil, err
+		}
+	}
 	return m.PaychAPI.PaychVoucherList(ctx, pch)
 }
 
 func (m MarketNodeImpl) ImportV1Data(ctx context.Context, src string) error {
+	if err := checkOperator(ctx); err != nil {
+		return err
+	}
 	type minerDealsIncludeStatus struct {
 		MinerDeal storagemarket.MinerDeal
 		DealInfo  piecestore.DealInfo
@@ -808,6 +1458,9 @@ func (m MarketNodeImpl) ImportV1Data(ctx context.Context, src string) error {
 }
 
 func (m MarketNodeImpl) GetReadUrl(ctx context.Context, s2 string) (string, error) {
+	if err := m.checkResource(ctx, s2); err != nil {
+		return "", err
+	}
 	if m.PieceStorage.Type() != piecestorage.S3 {
 		return "", xerrors.New("presign read only support s3")
 	}
@@ -815,6 +1468,9 @@ func (m MarketNodeImpl) GetReadUrl(ctx context.Context, s2 string) (string, erro
 }
 
 func (m MarketNodeImpl) GetWriteUrl(ctx context.Context, s2 string) (string, error) {
+	if err := m.checkResource(ctx, s2); err != nil {
+		return "", err
+	}
 	if m.PieceStorage.Type() != piecestorage.S3 {
 		return "", xerrors.New("presign read only support s3")
 	}
@@ -3,6 +3,8 @@ package cli
 import (
 	"bytes"
 	"fmt"
+	"os"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/libp2p/go-libp2p-core/peer"
@@ -22,6 +24,11 @@ var ActorCmd = &cli.Command{
 	Usage: "manipulate the miner actor",
 	Subcommands: []*cli.Command{
 		actorListCmd,
+		actorStatesCmd,
+		actorAddCmd,
+		actorRemoveCmd,
+		actorDisableCmd,
+		actorEnableCmd,
 		actorSetAddrsCmd,
 		actorSetPeeridCmd,
 		actorInfoCmd,
@@ -43,12 +50,24 @@ var actorListCmd = &cli.Command{
 		if err != nil {
 			return err
 		}
+		states, err := nodeAPI.ActorStates(cctx.Context)
+		if err != nil {
+			return err
+		}
+		// the worker, owner and control addresses of the miners have no state of their own
+		minerStates := make(map[address.Address]string, len(states))
+		for _, state := range states {
+			minerStates[state.Addr] = "enabled"
+			if state.Disabled() {
+				minerStates[state.Addr] = "disabled by " + strings.Join(state.DisabledBy, ",")
+			}
+		}
 
 		buf := &bytes.Buffer{}
 		tw := tabwriter.NewWriter(buf, 2, 4, 2, ' ', 0)
-		_, _ = fmt.Fprintln(tw, "miner\taccount")
+		_, _ = fmt.Fprintln(tw, "miner\taccount\tstate")
 		for _, miner := range miners {
-			_, _ = fmt.Fprintf(tw, "%s\t%s\n", miner.Addr.String(), miner.Account)
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", miner.Addr.String(), miner.Account, minerStates[miner.Addr])
 		}
 		if err := tw.Flush(); err != nil {
 			return err
@@ -59,6 +78,129 @@ var actorListCmd = &cli.Command{
 	},
 }
 
+var actorStatesCmd = &cli.Command{
+	Name:  "states",
+	Usage: "list the miners with the metadata and the state merged from their sources",
+	Action: func(cctx *cli.Context) error {
+		nodeAPI, closer, err := NewMarketNode(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		states, err := nodeAPI.ActorStates(ReqContext(cctx))
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "miner\taccount\tsector size\ttags\tsources\tdisabled by")
+		for _, state := range states {
+			sectorSize := "-"
+			if state.SectorSize != 0 {
+				sectorSize = types.SizeStr(types.NewInt(uint64(state.SectorSize)))
+			}
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", state.Addr, state.Account, sectorSize,
+				strings.Join(state.Tags, ","), strings.Join(state.Sources, ","), strings.Join(state.DisabledBy, ","))
+		}
+		return tw.Flush()
+	},
+}
+
+var actorAddCmd = &cli.Command{
+	Name:      "add",
+	Usage:     "add a miner to the storage miners of the config file",
+	ArgsUsage: "<miner address>",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "account",
+			Usage:    "account of the miner in venus-auth",
+			Required: true,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			return cli.ShowSubcommandHelp(cctx)
+		}
+		nodeAPI, closer, err := NewMarketNode(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		maddr, err := address.NewFromString(cctx.Args().First())
+		if err != nil {
+			return fmt.Errorf("parsing miner address: %w", err)
+		}
+		if err := nodeAPI.ActorAdd(ReqContext(cctx), maddr, cctx.String("account")); err != nil {
+			return err
+		}
+		fmt.Printf("miner %s added\n", maddr)
+		return nil
+	},
+}
+
+var actorRemoveCmd = &cli.Command{
+	Name:      "remove",
+	Usage:     "remove a miner from the storage miners of the config file, disable it first to let its deals finish",
+	ArgsUsage: "<miner address>",
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			return cli.ShowSubcommandHelp(cctx)
+		}
+		nodeAPI, closer, err := NewMarketNode(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		maddr, err := address.NewFromString(cctx.Args().First())
+		if err != nil {
+			return fmt.Errorf("parsing miner address: %w", err)
+		}
+		if err := nodeAPI.ActorRemove(ReqContext(cctx), maddr); err != nil {
+			return err
+		}
+		fmt.Printf("miner %s removed\n", maddr)
+		return nil
+	},
+}
+
+var actorDisableCmd = &cli.Command{
+	Name:      "disable",
+	Usage:     "reject the new deals of a miner and withdraw its ask, the deals accepted before go on",
+	ArgsUsage: "<miner address>",
+	Action: func(cctx *cli.Context) error {
+		return setActorDisabled(cctx, true)
+	},
+}
+
+var actorEnableCmd = &cli.Command{
+	Name:      "enable",
+	Usage:     "enable a miner disabled by `actor disable` again",
+	ArgsUsage: "<miner address>",
+	Action: func(cctx *cli.Context) error {
+		return setActorDisabled(cctx, false)
+	},
+}
+
+func setActorDisabled(cctx *cli.Context, disabled bool) error {
+	if cctx.NArg() != 1 {
+		return cli.ShowSubcommandHelp(cctx)
+	}
+	nodeAPI, closer, err := NewMarketNode(cctx)
+	if err != nil {
+		return err
+	}
+	defer closer()
+
+	maddr, err := address.NewFromString(cctx.Args().First())
+	if err != nil {
+		return fmt.Errorf("parsing miner address: %w", err)
+	}
+	return nodeAPI.ActorSetDisabled(ReqContext(cctx), maddr, disabled)
+}
+
 var actorSetAddrsCmd = &cli.Command{
 	Name:  "set-addrs",
 	Usage: "set addresses that your miner can be publicly dialed on",
@@ -6,6 +6,7 @@ import (
 
 	types "github.com/filecoin-project/venus/venus-shared/types/market"
 
+	"github.com/docker/go-units"
 	"github.com/fatih/color"
 	"github.com/urfave/cli/v2"
 
@@ -21,6 +22,7 @@ var DagstoreCmd = &cli.Command{
 		dagstoreRecoverShardCmd,
 		dagstoreInitializeAllCmd,
 		dagstoreGcCmd,
+		dagstoreUsageCmd,
 	},
 }
 
@@ -150,7 +152,7 @@ var dagstoreRecoverShardCmd = &cli.Command{
 
 var dagstoreInitializeAllCmd = &cli.Command{
 	Name:  "initialize-all",
-	Usage: "Initialize all uninitialized shards, streaming results as they're produced; only shards for unsealed pieces are initialized by default",
+	Usage: "Initialize all uninitialized shards and index the blocks of the initialized shards missing from the block index, streaming results as they're produced; only shards for unsealed pieces are initialized by default",
 	Flags: []cli.Flag{
 		&cli.UintFlag{
 			Name:     "concurrency",
@@ -260,3 +262,63 @@ var dagstoreGcCmd = &cli.Command{
 		return nil
 	},
 }
+
+var dagstoreUsageCmd = &cli.Command{
+	Name:  "usage",
+	Usage: "Show the disk use of the transients the shards are read from, with the hit rate and the evictions",
+	Action: func(cctx *cli.Context) error {
+		marketsApi, closer, err := NewMarketNode(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		usage, err := marketsApi.DagstoreTransientUsage(ctx)
+		if err != nil {
+			return err
+		}
+
+		hitRate := "-"
+		if total := usage.Hits + usage.Misses; total > 0 {
+			hitRate = fmt.Sprintf("%.1f%%", float64(usage.Hits)*100/float64(total))
+		}
+		sizeQuota := units.BytesSize(float64(usage.Size))
+		if usage.MaxSize > 0 {
+			sizeQuota += " / " + units.BytesSize(float64(usage.MaxSize))
+		} else {
+			sizeQuota += " (unlimited)"
+		}
+		countQuota := fmt.Sprint(usage.Count)
+		if usage.MaxCount > 0 {
+			countQuota += fmt.Sprintf(" / %d", usage.MaxCount)
+		} else {
+			countQuota += " (unlimited)"
+		}
+		fmt.Printf("Size: %s\n", sizeQuota)
+		fmt.Printf("Transients: %s\n", countQuota)
+		fmt.Printf("Hit rate: %s (%d hits, %d misses)\n", hitRate, usage.Hits, usage.Misses)
+		fmt.Printf("Evictions: %d\n", usage.Evictions)
+		fmt.Printf("Rejections: %d\n", usage.Rejections)
+
+		if len(usage.Transients) == 0 {
+			return nil
+		}
+		fmt.Println()
+		tw := tablewriter.New(
+			tablewriter.Col("Piece"),
+			tablewriter.Col("Size"),
+			tablewriter.Col("Readers"),
+			tablewriter.Col("LastUsed"),
+		)
+		for _, t := range usage.Transients {
+			tw.Write(map[string]interface{}{
+				"Piece":    t.PieceCID,
+				"Size":     units.BytesSize(float64(t.Size)),
+				"Readers":  t.Readers,
+				"LastUsed": t.LastUsed.Format("2006-01-02 15:04:05"),
+			})
+		}
+		return tw.Flush(os.Stdout)
+	},
+}
@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/urfave/cli/v2"
+)
+
+var dealsLeasesCmd = &cli.Command{
+	Name:  "leases",
+	Usage: "manage the deals assigned to sealers",
+	Subcommands: []*cli.Command{
+		dealsLeasesListCmd,
+		dealsLeasesReleaseCmd,
+	},
+}
+
+var dealsLeasesListCmd = &cli.Command{
+	Name:  "list",
+	Usage: "list the deals assigned to sealers and not packed yet",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "miner",
+			Usage: "list the leases of the miner only",
+		},
+		&cli.BoolFlag{
+			Name:  "at-risk",
+			Usage: "list only the deals starting before they could be sealed",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := NewMarketNode(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		maddr := address.Undef
+		if cctx.IsSet("miner") {
+			if maddr, err = address.NewFromString(cctx.String("miner")); err != nil {
+				return xerrors.Errorf("parsing miner address: %w", err)
+			}
+		}
+		leases, err := api.MarketListDealLeases(ctx, maddr)
+		if err != nil {
+			return xerrors.Errorf("listing deal leases: %w", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintf(w, "Miner\tDealID\tOwner\tAssigned\tExpiry\tStartEpoch\tAtRisk\n")
+		for _, lease := range leases {
+			if cctx.Bool("at-risk") && !lease.AtRisk {
+				continue
+			}
+			expiry := "never"
+			if !lease.Expiry.IsZero() {
+				expiry = lease.Expiry.Format(time.RFC3339)
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%d\t%t\n",
+				lease.Miner,
+				lease.DealID,
+				lease.Owner,
+				lease.AssignedAt.Format(time.RFC3339),
+				expiry,
+				lease.StartEpoch,
+				lease.AtRisk,
+			)
+		}
+		return w.Flush()
+	},
+}
+
+var dealsLeasesReleaseCmd = &cli.Command{
+	Name:      "release",
+	Usage:     "return assigned deals to the deals waiting to be assigned, whichever sealer holds them",
+	ArgsUsage: "<dealID>...",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "miner",
+			Required: true,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() == 0 {
+			return xerrors.New("expected at least one deal id")
+		}
+		maddr, err := address.NewFromString(cctx.String("miner"))
+		if err != nil {
+			return xerrors.Errorf("parsing miner address: %w", err)
+		}
+		dealIDs := make([]abi.DealID, 0, cctx.NArg())
+		for _, arg := range cctx.Args().Slice() {
+			dealID, err := strconv.ParseUint(arg, 10, 64)
+			if err != nil {
+				return xerrors.Errorf("parsing deal id %s: %w", arg, err)
+			}
+			dealIDs = append(dealIDs, abi.DealID(dealID))
+		}
+
+		api, closer, err := NewMarketNode(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		if err := api.MarketReleaseDealLeases(ctx, maddr, dealIDs); err != nil {
+			return xerrors.Errorf("releasing deal leases: %w", err)
+		}
+		fmt.Printf("released %d deals\n", len(dealIDs))
+		return nil
+	},
+}
@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/urfave/cli/v2"
+
+	"github.com/filecoin-project/venus-market/types"
+)
+
+var dealsAtRiskCmd = &cli.Command{
+	Name:  "at-risk",
+	Usage: "list the published deals at risk to miss their start epoch",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "miner",
+			Usage: "list the deals of the miner only",
+		},
+		&cli.BoolFlag{
+			Name:  "all",
+			Usage: "list the deals with enough slack too",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := NewMarketNode(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		maddr := address.Undef
+		if cctx.IsSet("miner") {
+			if maddr, err = address.NewFromString(cctx.String("miner")); err != nil {
+				return xerrors.Errorf("parsing miner address: %w", err)
+			}
+		}
+		risks, err := api.MarketListDealRisks(ctx, maddr)
+		if err != nil {
+			return xerrors.Errorf("listing deals at risk: %w", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintf(w, "Miner\tDealID\tPieceCid\tPieceSize\tState\tPieceStatus\tStartEpoch\tSlack\tLevel\n")
+		for _, risk := range risks {
+			if !cctx.Bool("all") && risk.Level == types.DealRiskOK {
+				continue
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%d\t%s\t%d\t%s\t%s\t%d\t%d\t%s\n",
+				risk.Miner,
+				risk.DealID,
+				risk.PieceCID,
+				risk.PieceSize,
+				risk.State,
+				risk.PieceStatus,
+				risk.StartEpoch,
+				risk.Slack,
+				risk.Level,
+			)
+		}
+		return w.Flush()
+	},
+}
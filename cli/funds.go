@@ -0,0 +1,273 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+
+	"github.com/filecoin-project/venus/venus-shared/types"
+)
+
+var FundsCmd = &cli.Command{
+	Name:  "funds",
+	Usage: "manage the market escrow of the miners and clients",
+	Subcommands: []*cli.Command{
+		fundsListCmd,
+		fundsAddCmd,
+		fundsWithdrawCmd,
+		fundsReconcileCmd,
+	},
+}
+
+var fundsListCmd = &cli.Command{
+	Name:      "list",
+	Usage:     "list the escrow, the reservations and the pending requests of the market addresses",
+	ArgsUsage: "[address...]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "verbose",
+			Usage: "print the pending requests and the deals holding a reservation",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := NewMarketNode(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		var addrs []address.Address
+		for _, arg := range cctx.Args().Slice() {
+			addr, err := address.NewFromString(arg)
+			if err != nil {
+				return xerrors.Errorf("parsing address %s: %w", arg, err)
+			}
+			addrs = append(addrs, addr)
+		}
+		states, err := api.MarketListFunds(ctx, addrs)
+		if err != nil {
+			return xerrors.Errorf("listing funds: %w", err)
+		}
+
+		if !cctx.Bool("verbose") {
+			w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+			_, _ = fmt.Fprintf(w, "Address\tEscrow\tLocked\tReserved\tAvailable\tPending\tMessage\n")
+			for _, state := range states {
+				msg := "-"
+				if state.MsgCid != nil {
+					msg = state.MsgCid.String()
+				}
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
+					state.Addr,
+					types.FIL(state.Escrow),
+					types.FIL(state.Locked),
+					types.FIL(state.Reserved),
+					types.FIL(state.Available),
+					len(state.Reservations)+len(state.Releases)+len(state.Withdrawals),
+					msg,
+				)
+			}
+			return w.Flush()
+		}
+
+		for _, state := range states {
+			fmt.Printf("%s:\n", state.Addr)
+			fmt.Printf("  Escrow:    %s\n", types.FIL(state.Escrow))
+			fmt.Printf("  Locked:    %s\n", types.FIL(state.Locked))
+			fmt.Printf("  Reserved:  %s\n", types.FIL(state.Reserved))
+			fmt.Printf("  Available: %s\n", types.FIL(state.Available))
+			if state.MsgCid != nil {
+				kind := state.MsgKind
+				if kind == "" {
+					kind = "unknown"
+				}
+				fmt.Printf("  Message:   %s (%s)\n", state.MsgCid, kind)
+			}
+			for _, req := range state.Reservations {
+				fmt.Printf("  Pending reservation: %s from %s\n", types.FIL(req.Amount), req.Wallet)
+			}
+			for _, req := range state.Releases {
+				fmt.Printf("  Pending release:     %s\n", types.FIL(req.Amount))
+			}
+			for _, req := range state.Withdrawals {
+				fmt.Printf("  Pending withdrawal:  %s to %s\n", types.FIL(req.Amount), req.Wallet)
+			}
+			for _, deal := range state.Deals {
+				msg := "-"
+				if deal.AddFundsCid != nil {
+					msg = deal.AddFundsCid.String()
+				}
+				fmt.Printf("  Deal %s %s reserves %s, add funds message %s\n", deal.ProposalCid, deal.State, types.FIL(deal.FundsReserved), msg)
+			}
+		}
+		return nil
+	},
+}
+
+var fundsAddCmd = &cli.Command{
+	Name:      "add",
+	Usage:     "add funds to the market escrow of an address",
+	ArgsUsage: "<amount (FIL)>",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "from",
+			Usage:    "the wallet to move the funds from",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "address",
+			Usage:    "the miner or client address to move the funds to",
+			Required: true,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := NewMarketNode(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		if cctx.Args().Len() != 1 {
+			return xerrors.Errorf("must pass the amount to add")
+		}
+		f, err := types.ParseFIL(cctx.Args().First())
+		if err != nil {
+			return xerrors.Errorf("parsing 'amount' argument: %w", err)
+		}
+		from, err := address.NewFromString(cctx.String("from"))
+		if err != nil {
+			return xerrors.Errorf("parsing from address: %w", err)
+		}
+		addr, err := address.NewFromString(cctx.String("address"))
+		if err != nil {
+			return xerrors.Errorf("parsing market address: %w", err)
+		}
+
+		msgCid, err := api.MarketAddBalance(ctx, from, addr, abi.TokenAmount(f))
+		if err != nil {
+			return xerrors.Errorf("add balance: %w", err)
+		}
+		fmt.Printf("AddBalance message cid: %s\n", msgCid)
+		return nil
+	},
+}
+
+var fundsWithdrawCmd = &cli.Command{
+	Name:      "withdraw",
+	Usage:     "withdraw the escrow of an address neither locked nor reserved",
+	ArgsUsage: "[amount (FIL), all the available funds when omitted]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "wallet",
+			Usage:    "the wallet sending the message, the owner or the worker of a miner",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "address",
+			Usage:    "the miner or client address to withdraw from",
+			Required: true,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := NewMarketNode(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		wallet, err := address.NewFromString(cctx.String("wallet"))
+		if err != nil {
+			return xerrors.Errorf("parsing wallet address: %w", err)
+		}
+		addr, err := address.NewFromString(cctx.String("address"))
+		if err != nil {
+			return xerrors.Errorf("parsing market address: %w", err)
+		}
+
+		states, err := api.MarketListFunds(ctx, []address.Address{addr})
+		if err != nil {
+			return xerrors.Errorf("getting funds of %s: %w", addr, err)
+		}
+		if len(states) != 1 {
+			return xerrors.Errorf("no funds of %s", addr)
+		}
+		state := states[0]
+
+		amt := state.Available
+		if cctx.Args().Present() {
+			f, err := types.ParseFIL(cctx.Args().First())
+			if err != nil {
+				return xerrors.Errorf("parsing 'amount' argument: %w", err)
+			}
+			amt = abi.TokenAmount(f)
+		}
+		if amt.LessThanEqual(big.Zero()) {
+			return xerrors.Errorf("nothing to withdraw, available (%s) = escrow (%s) - locked (%s) - reserved (%s)",
+				types.FIL(state.Available), types.FIL(state.Escrow), types.FIL(state.Locked), types.FIL(state.Reserved))
+		}
+		if amt.GreaterThan(state.Available) {
+			return xerrors.Errorf("can't withdraw %s, only %s is available", types.FIL(amt), types.FIL(state.Available))
+		}
+
+		msgCid, err := api.MarketWithdraw(ctx, wallet, addr, amt)
+		if err != nil {
+			return xerrors.Errorf("withdraw: %w", err)
+		}
+		fmt.Printf("WithdrawBalance message cid: %s\n", msgCid)
+		return nil
+	},
+}
+
+var fundsReconcileCmd = &cli.Command{
+	Name:  "reconcile",
+	Usage: "release the reservation of a miner its live deals do not hold anymore",
+	Description: `A deal that errors, is slashed or expires before it releases its provider collateral leaks its
+   reservation, the reserved funds can not be withdrawn until they are released. The deals still failing release
+   their reservation themselves and are not taken for leaks.`,
+	Flags: []cli.Flag{
+		requiredMinerFlag,
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "only print the reservation that would be released",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := NewMarketNode(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		miner, err := address.NewFromString(cctx.String("miner"))
+		if err != nil {
+			return xerrors.Errorf("parsing miner address: %w", err)
+		}
+		res, err := api.MarketReconcileFunds(ctx, miner, cctx.Bool("dry-run"))
+		if err != nil {
+			return xerrors.Errorf("reconciling funds of %s: %w", miner, err)
+		}
+
+		fmt.Printf("Reserved:      %s\n", types.FIL(res.Reserved))
+		fmt.Printf("Held by deals: %s\n", types.FIL(res.Expected))
+		for _, proposalCid := range res.LeakedDeals {
+			fmt.Printf("Leaked by deal %s\n", proposalCid)
+		}
+		if cctx.Bool("dry-run") {
+			fmt.Printf("Would release: %s\n", types.FIL(res.Released))
+		} else {
+			fmt.Printf("Released:      %s\n", types.FIL(res.Released))
+		}
+		return nil
+	},
+}
@@ -261,6 +261,15 @@ var getAskCmd = &cli.Command{
 			Name:     "miner",
 			Required: true,
 		},
+		&cli.BoolFlag{
+			Name:  "history",
+			Usage: "print the changes of the ask, the latest first",
+		},
+		&cli.IntFlag{
+			Name:  "limit",
+			Usage: "max number of changes to print with --history",
+			Value: 20,
+		},
 	},
 	Action: func(cctx *cli.Context) error {
 		ctx := DaemonContext(cctx)
@@ -312,6 +321,25 @@ var getAskCmd = &cli.Command{
 
 		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%d\n", types.FIL(ask.Price), types.FIL(ask.VerifiedPrice), types.SizeStr(types.NewInt(uint64(ask.MinPieceSize))), types.SizeStr(types.NewInt(uint64(ask.MaxPieceSize))), ask.Expiry, rem, ask.SeqNo)
 
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if !cctx.Bool("history") {
+			return nil
+		}
+
+		changes, err := smapi.MarketListStorageAskHistory(ctx, maddr, cctx.Int("limit"))
+		if err != nil {
+			return err
+		}
+
+		fmt.Println()
+		fmt.Println("History:")
+		w = tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "Time\tPrice per GiB/Epoch\tVerified\tMin. Piece Size (padded)\tMax. Piece Size (padded)\tExpiry (Epoch)\tSeq. No.\tReason\n")
+		for _, c := range changes {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\n", c.CreatedAt.Format(time.RFC3339), types.FIL(c.Price), types.FIL(c.VerifiedPrice), types.SizeStr(types.NewInt(uint64(c.MinPieceSize))), types.SizeStr(types.NewInt(uint64(c.MaxPieceSize))), c.Expiry, c.SeqNo, c.Reason)
+		}
 		return w.Flush()
 	},
 }
@@ -331,6 +359,9 @@ var StorageDealsCmd = &cli.Command{
 		resetBlocklistCmd,
 		setSealDurationCmd,
 		dealsPendingPublish,
+		dealsClientsCmd,
+		dealsLeasesCmd,
+		dealsAtRiskCmd,
 	},
 }
 
@@ -924,3 +955,47 @@ var dealsPendingPublish = &cli.Command{
 		return nil
 	},
 }
+
+var dealsClientsCmd = &cli.Command{
+	Name:  "clients",
+	Usage: "list the deals of every client address and what they used of their quota",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := NewMarketNode(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		usages, err := api.MarketListClientUsage(ctx)
+		if err != nil {
+			return xerrors.Errorf("getting client usage: %w", err)
+		}
+
+		quota := func(used, limit uint64) string {
+			res := units.BytesSize(float64(used))
+			if limit > 0 {
+				res += " / " + units.BytesSize(float64(limit))
+			}
+			return res
+		}
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintf(w, "Client\tDeals\tTotal\tProposals/min\tPending\tLast day\tLast 30 days\n")
+		for _, usage := range usages {
+			proposals := fmt.Sprint(usage.ProposalsLastMinute)
+			if usage.Limits.ProposalsPerMinute > 0 {
+				proposals += fmt.Sprintf(" / %d", usage.Limits.ProposalsPerMinute)
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\n",
+				usage.Client,
+				usage.Deals,
+				units.BytesSize(float64(usage.TotalBytes)),
+				proposals,
+				quota(usage.PendingBytes, usage.Limits.MaxPendingBytes),
+				quota(usage.BytesLastDay, usage.Limits.MaxBytesPerDay),
+				quota(usage.BytesLastMonth, usage.Limits.MaxBytesPerMonth),
+			)
+		}
+		return w.Flush()
+	},
+}
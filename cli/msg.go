@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/ipfs/go-cid"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	mtypes "github.com/filecoin-project/venus-market/types"
+	"github.com/filecoin-project/venus/venus-shared/types"
+)
+
+var MessageCmd = &cli.Command{
+	Name:  "msg",
+	Usage: "manage the messages pushed to the daemon directly in solo mode",
+	Subcommands: []*cli.Command{
+		msgListCmd,
+		msgReplaceCmd,
+	},
+}
+
+var msgListCmd = &cli.Command{
+	Name:  "list",
+	Usage: "list the tracked messages",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "from",
+			Usage: "only list the messages of the address",
+		},
+		&cli.BoolFlag{
+			Name:  "all",
+			Usage: "also list the messages that already landed",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := NewMarketNode(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := DaemonContext(cctx)
+
+		from := address.Undef
+		if cctx.IsSet("from") {
+			from, err = address.NewFromString(cctx.String("from"))
+			if err != nil {
+				return xerrors.Errorf("parse from address: %w", err)
+			}
+		}
+
+		msgs, err := api.MarketListMessages(ctx, from)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintf(w, "From\tNonce\tCid\tState\tMethod\tPremium\tFeeCap\tReplaced\tRepushed\tHeight\tExitCode\tUpdated\n")
+		for _, msg := range msgs {
+			if !cctx.Bool("all") && msg.State != mtypes.MsgStatePending {
+				continue
+			}
+			method, premium, feeCap := "-", "-", "-"
+			if msg.Message != nil {
+				method = fmt.Sprintf("%d", msg.Message.Message.Method)
+				premium = msg.Message.Message.GasPremium.String()
+				feeCap = msg.Message.Message.GasFeeCap.String()
+			}
+			state := string(msg.State)
+			if msg.Filler {
+				state += " (filler)"
+			}
+			height, exitCode := "-", "-"
+			if msg.State != mtypes.MsgStatePending {
+				height = fmt.Sprintf("%d", msg.Height)
+			}
+			if msg.State == mtypes.MsgStateOnChain {
+				exitCode = msg.ExitCode.String()
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\t%s\t%s\n",
+				msg.From, msg.Nonce, msg.Cid, state, method, premium, feeCap, msg.Replaced, msg.Repushed,
+				height, exitCode, msg.UpdatedAt.Format("2006-01-02 15:04:05"))
+		}
+		return w.Flush()
+	},
+}
+
+var msgReplaceCmd = &cli.Command{
+	Name:      "replace",
+	Usage:     "replace a pending message by one paying a higher fee, the fee is estimated unless it is given",
+	ArgsUsage: "<message cid>",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "gas-premium",
+			Usage: "gas premium of the replacement in attoFIL",
+		},
+		&cli.StringFlag{
+			Name:  "gas-feecap",
+			Usage: "gas fee cap of the replacement in attoFIL",
+		},
+		&cli.StringFlag{
+			Name:  "max-fee",
+			Usage: "the most the replacement may pay in FIL, defaults to the max fee the message was pushed with",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			return xerrors.Errorf("must pass the cid of the message")
+		}
+		mCid, err := cid.Decode(cctx.Args().First())
+		if err != nil {
+			return xerrors.Errorf("parse message cid: %w", err)
+		}
+
+		params := mtypes.MsgReplaceParams{
+			GasPremium: big.Zero(),
+			GasFeeCap:  big.Zero(),
+			MaxFee:     big.Zero(),
+		}
+		if cctx.IsSet("gas-premium") {
+			if params.GasPremium, err = big.FromString(cctx.String("gas-premium")); err != nil {
+				return xerrors.Errorf("parse gas premium: %w", err)
+			}
+		}
+		if cctx.IsSet("gas-feecap") {
+			if params.GasFeeCap, err = big.FromString(cctx.String("gas-feecap")); err != nil {
+				return xerrors.Errorf("parse gas fee cap: %w", err)
+			}
+		}
+		if cctx.IsSet("max-fee") {
+			maxFee, err := types.ParseFIL(cctx.String("max-fee"))
+			if err != nil {
+				return xerrors.Errorf("parse max fee: %w", err)
+			}
+			params.MaxFee = big.Int(maxFee)
+		}
+
+		api, closer, err := NewMarketNode(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		replaced, err := api.MarketReplaceMessage(DaemonContext(cctx), mCid, params)
+		if err != nil {
+			return err
+		}
+		fmt.Println("replaced by", replaced)
+		return nil
+	},
+}
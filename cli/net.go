@@ -1,8 +1,24 @@
 package cli
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p-core/metrics"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	ma "github.com/multiformats/go-multiaddr"
 	"github.com/urfave/cli/v2"
+
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+
+	"github.com/filecoin-project/venus-market/cli/tablewriter"
 )
 
 var NetCmd = &cli.Command{
@@ -11,6 +27,16 @@ var NetCmd = &cli.Command{
 	Subcommands: []*cli.Command{
 		NetListen,
 		NetId,
+		NetPeers,
+		NetConnect,
+		NetDisconnect,
+		NetPing,
+		NetFindPeer,
+		NetBandwidth,
+		NetReachability,
+		NetProbe,
+		NetBlock,
+		NetUnblock,
 	},
 }
 
@@ -58,3 +84,478 @@ var NetId = &cli.Command{
 		return nil
 	},
 }
+
+var NetPeers = &cli.Command{
+	Name:  "peers",
+	Usage: "List the connected peers",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := NewMarketNode(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		peers, err := api.MarketNetPeers(ctx)
+		if err != nil {
+			return err
+		}
+
+		tw := tablewriter.New(
+			tablewriter.Col("Peer"),
+			tablewriter.Col("Addr"),
+			tablewriter.Col("Direction"),
+			tablewriter.Col("Latency"),
+			tablewriter.Col("Conns"),
+			tablewriter.Col("Streams"),
+			tablewriter.Col("Protected"),
+			tablewriter.Col("Tags"),
+			tablewriter.Col("Agent"),
+		)
+		for _, p := range peers {
+			latency := "-"
+			if p.Latency > 0 {
+				latency = p.Latency.Round(time.Microsecond).String()
+			}
+			tw.Write(map[string]interface{}{
+				"Peer":      p.ID,
+				"Addr":      p.Addr,
+				"Direction": p.Direction,
+				"Latency":   latency,
+				"Conns":     p.Conns,
+				"Streams":   p.Streams,
+				"Protected": p.Protected,
+				"Tags":      p.TagValue,
+				"Agent":     p.Agent,
+			})
+		}
+		return tw.Flush(os.Stdout)
+	},
+}
+
+// parseBlockArgs splits the arguments into peer ids and ip ranges
+func parseBlockArgs(cctx *cli.Context) ([]peer.ID, []string) {
+	var peers []peer.ID
+	var subnets []string
+	for _, arg := range cctx.Args().Slice() {
+		if p, err := peer.Decode(arg); err == nil {
+			peers = append(peers, p)
+			continue
+		}
+		subnets = append(subnets, arg)
+	}
+	return peers, subnets
+}
+
+var NetBlock = &cli.Command{
+	Name:      "block",
+	Usage:     "Refuse the connections of peers or ip ranges, list the blocked ones without arguments",
+	ArgsUsage: "[peer id | ip | cidr]...",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := NewMarketNode(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		if cctx.Args().Present() {
+			peers, subnets := parseBlockArgs(cctx)
+			return api.MarketNetBlock(ctx, peers, subnets)
+		}
+
+		list, err := api.MarketNetBlockList(ctx)
+		if err != nil {
+			return err
+		}
+		for _, p := range list.Peers {
+			fmt.Println(p)
+		}
+		for _, s := range list.Subnets {
+			fmt.Println(s)
+		}
+		for _, p := range list.ConfigPeers {
+			fmt.Printf("%s (config)\n", p)
+		}
+		for _, s := range list.ConfigSubnets {
+			fmt.Printf("%s (config)\n", s)
+		}
+		return nil
+	},
+}
+
+var NetUnblock = &cli.Command{
+	Name:      "unblock",
+	Usage:     "Accept the connections of peers or ip ranges blocked by `net block`",
+	ArgsUsage: "<peer id | ip | cidr>...",
+	Action: func(cctx *cli.Context) error {
+		if !cctx.Args().Present() {
+			return ShowHelp(cctx, fmt.Errorf("must specify a peer id or an ip range"))
+		}
+
+		api, closer, err := NewMarketNode(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		peers, subnets := parseBlockArgs(cctx)
+		return api.MarketNetUnblock(ctx, peers, subnets)
+	},
+}
+
+// parseAddrInfos parses multiaddrs ending with /p2p/<peer id>
+func parseAddrInfos(args []string) ([]peer.AddrInfo, error) {
+	maddrs := make([]ma.Multiaddr, 0, len(args))
+	for _, arg := range args {
+		maddr, err := ma.NewMultiaddr(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid multiaddr %s: %w", arg, err)
+		}
+		maddrs = append(maddrs, maddr)
+	}
+	return peer.AddrInfosFromP2pAddrs(maddrs...)
+}
+
+var NetConnect = &cli.Command{
+	Name:      "connect",
+	Usage:     "Connect to a peer",
+	ArgsUsage: "<multiaddr/p2p/peer id | peer id>...",
+	Action: func(cctx *cli.Context) error {
+		if !cctx.Args().Present() {
+			return ShowHelp(cctx, fmt.Errorf("must specify the address of a peer"))
+		}
+
+		api, closer, err := NewMarketNode(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		var infos []peer.AddrInfo
+		var addrs []string
+		for _, arg := range cctx.Args().Slice() {
+			// a bare peer id is connected to at the addresses the node knows
+			if p, err := peer.Decode(arg); err == nil {
+				info, err := api.MarketNetFindPeer(ctx, p)
+				if err != nil {
+					return err
+				}
+				infos = append(infos, info)
+				continue
+			}
+			addrs = append(addrs, arg)
+		}
+		parsed, err := parseAddrInfos(addrs)
+		if err != nil {
+			return err
+		}
+		infos = append(infos, parsed...)
+
+		for _, info := range infos {
+			fmt.Printf("connect %s: ", info.ID)
+			if err := api.MarketNetConnect(ctx, info); err != nil {
+				fmt.Println("failure")
+				return err
+			}
+			fmt.Println("success")
+		}
+		return nil
+	},
+}
+
+var NetDisconnect = &cli.Command{
+	Name:      "disconnect",
+	Usage:     "Close the connections to a peer",
+	ArgsUsage: "<peer id>...",
+	Action: func(cctx *cli.Context) error {
+		if !cctx.Args().Present() {
+			return ShowHelp(cctx, fmt.Errorf("must specify a peer id"))
+		}
+
+		api, closer, err := NewMarketNode(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		for _, arg := range cctx.Args().Slice() {
+			p, err := peer.Decode(arg)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("disconnect %s: ", p)
+			if err := api.MarketNetDisconnect(ctx, p); err != nil {
+				fmt.Println("failure")
+				return err
+			}
+			fmt.Println("success")
+		}
+		return nil
+	},
+}
+
+var NetPing = &cli.Command{
+	Name:      "ping",
+	Usage:     "Ping a peer",
+	ArgsUsage: "<peer id>",
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:    "count",
+			Aliases: []string{"c"},
+			Value:   10,
+			Usage:   "number of pings",
+		},
+		&cli.DurationFlag{
+			Name:    "interval",
+			Aliases: []string{"i"},
+			Value:   time.Second,
+			Usage:   "time to wait between pings",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return ShowHelp(cctx, fmt.Errorf("must specify a peer id"))
+		}
+		p, err := peer.Decode(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		api, closer, err := NewMarketNode(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		var sum time.Duration
+		var ok int
+		for i := 0; i < cctx.Int("count"); i++ {
+			if i > 0 {
+				select {
+				case <-time.After(cctx.Duration("interval")):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			rtt, err := api.MarketNetPing(ctx, p)
+			if err != nil {
+				fmt.Printf("ping %s: %v\n", p, err)
+				continue
+			}
+			fmt.Printf("pong %s: time=%v\n", p, rtt)
+			sum += rtt
+			ok++
+		}
+		if ok > 0 {
+			fmt.Printf("%d/%d pongs, average %v\n", ok, cctx.Int("count"), sum/time.Duration(ok))
+		}
+		return nil
+	},
+}
+
+var NetFindPeer = &cli.Command{
+	Name:      "findpeer",
+	Usage:     "Show the addresses the node knows for a peer",
+	ArgsUsage: "<peer id>",
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return ShowHelp(cctx, fmt.Errorf("must specify a peer id"))
+		}
+		p, err := peer.Decode(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		api, closer, err := NewMarketNode(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		info, err := api.MarketNetFindPeer(ctx, p)
+		if err != nil {
+			return err
+		}
+		for _, addr := range info.Addrs {
+			fmt.Println(addr)
+		}
+		return nil
+	},
+}
+
+var NetBandwidth = &cli.Command{
+	Name:  "bandwidth",
+	Usage: "Show the traffic of the node",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "by-peer",
+			Usage: "list the traffic of each peer",
+		},
+		&cli.BoolFlag{
+			Name:  "by-protocol",
+			Usage: "list the traffic of each protocol",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := NewMarketNode(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		bw, err := api.MarketNetBandwidth(ctx)
+		if err != nil {
+			return err
+		}
+
+		tw := tablewriter.New(
+			tablewriter.Col("Segment"),
+			tablewriter.Col("TotalIn"),
+			tablewriter.Col("TotalOut"),
+			tablewriter.Col("RateIn"),
+			tablewriter.Col("RateOut"),
+		)
+		write := func(segment string, stats metrics.Stats) {
+			tw.Write(map[string]interface{}{
+				"Segment":  segment,
+				"TotalIn":  units.BytesSize(float64(stats.TotalIn)),
+				"TotalOut": units.BytesSize(float64(stats.TotalOut)),
+				"RateIn":   units.BytesSize(stats.RateIn) + "/s",
+				"RateOut":  units.BytesSize(stats.RateOut) + "/s",
+			})
+		}
+		// the busiest first
+		writeSorted := func(segments map[string]metrics.Stats) {
+			keys := make([]string, 0, len(segments))
+			for key := range segments {
+				keys = append(keys, key)
+			}
+			sort.Slice(keys, func(i, j int) bool {
+				return segments[keys[i]].TotalIn+segments[keys[i]].TotalOut > segments[keys[j]].TotalIn+segments[keys[j]].TotalOut
+			})
+			for _, key := range keys {
+				write(key, segments[key])
+			}
+		}
+
+		switch {
+		case cctx.Bool("by-peer"):
+			writeSorted(bw.ByPeer)
+		case cctx.Bool("by-protocol"):
+			segments := make(map[string]metrics.Stats, len(bw.ByProtocol))
+			for proto, stats := range bw.ByProtocol {
+				if proto == "" {
+					proto = "<unknown>"
+				}
+				segments[string(proto)] = stats
+			}
+			writeSorted(segments)
+		default:
+			write("Total", bw.Total)
+		}
+		return tw.Flush(os.Stdout)
+	},
+}
+
+var NetReachability = &cli.Command{
+	Name:  "reachability",
+	Usage: "Show whether the node is dialable from the internet, as found by autonat",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := NewMarketNode(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		info, err := api.MarketNetReachability(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Reachability:", info.Reachability)
+		if info.PublicAddr != "" {
+			fmt.Println("Public address:", info.PublicAddr)
+		}
+		return nil
+	},
+}
+
+// probeChecks are the protocols a client negotiates to make a deal, newest version first
+var probeChecks = []struct {
+	name      string
+	protocols []protocol.ID
+}{
+	{"storage ask", []protocol.ID{storagemarket.AskProtocolID, storagemarket.OldAskProtocolID}},
+	{"storage deal", []protocol.ID{storagemarket.DealProtocolID111, storagemarket.DealProtocolID110, storagemarket.DealProtocolID101}},
+	{"deal status", []protocol.ID{storagemarket.DealStatusProtocolID, storagemarket.OldDealStatusProtocolID}},
+	{"retrieval query", []protocol.ID{retrievalmarket.QueryProtocolID, retrievalmarket.OldQueryProtocolID}},
+}
+
+var NetProbe = &cli.Command{
+	Name:      "probe",
+	Usage:     "Check that a client can connect to a market and negotiate the deal protocols",
+	ArgsUsage: "<multiaddr/p2p/peer id>",
+	Description: `The probe dials the address from a temporary libp2p host, as a client would,
+   so it does not need the market api. Probe the announced address of the market from another
+   network to check that clients can reach it.`,
+	Flags: []cli.Flag{
+		&cli.DurationFlag{
+			Name:  "timeout",
+			Value: 30 * time.Second,
+			Usage: "timeout of the connection and of each protocol",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return ShowHelp(cctx, fmt.Errorf("must specify the address of a market"))
+		}
+		infos, err := parseAddrInfos(cctx.Args().Slice())
+		if err != nil {
+			return err
+		}
+		info := infos[0]
+		timeout := cctx.Duration("timeout")
+
+		h, err := libp2p.New(libp2p.NoListenAddrs)
+		if err != nil {
+			return err
+		}
+		defer h.Close() //nolint:errcheck
+
+		ctx := ReqContext(cctx)
+		start := time.Now()
+		connectCtx, cancel := context.WithTimeout(ctx, timeout)
+		err = h.Connect(connectCtx, info)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("connect to %s: %w", info.ID, err)
+		}
+		fmt.Printf("connect: ok (%v)\n", time.Since(start).Round(time.Millisecond))
+
+		var failed int
+		for _, check := range probeChecks {
+			start := time.Now()
+			streamCtx, cancel := context.WithTimeout(ctx, timeout)
+			s, err := h.NewStream(streamCtx, info.ID, check.protocols...)
+			cancel()
+			if err != nil {
+				failed++
+				fmt.Printf("%s: failed: %v\n", check.name, err)
+				continue
+			}
+			fmt.Printf("%s: ok %s (%v)\n", check.name, s.Protocol(), time.Since(start).Round(time.Millisecond))
+			_ = s.Reset()
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d protocols failed", failed, len(probeChecks))
+		}
+		return nil
+	},
+}
@@ -5,10 +5,13 @@ import (
 	"os"
 	"text/tabwriter"
 
+	"golang.org/x/xerrors"
+
 	"github.com/ipfs/go-cid"
 	"github.com/urfave/cli/v2"
 
 	"github.com/filecoin-project/venus-market/cli/tablewriter"
+	"github.com/filecoin-project/venus-market/types"
 )
 
 var PiecesCmd = &cli.Command{
@@ -20,6 +23,8 @@ var PiecesCmd = &cli.Command{
 		piecesListCidInfosCmd,
 		piecesInfoCmd,
 		piecesCidInfoCmd,
+		piecesGcCmd,
+		piecesVerifyCmd,
 	},
 }
 
@@ -190,3 +195,154 @@ var piecesCidInfoCmd = &cli.Command{
 		return w.Flush()
 	},
 }
+
+var piecesGcCmd = &cli.Command{
+	Name:  "gc",
+	Usage: "Remove the shards and piece files of the pieces whose deals are all terminated",
+	Description: `The pieces whose deals are all expired, slashed or failed are removed once the grace period
+   elapsed since their last deal terminated: the dagstore shard, the block index, the transient copy
+   and the piece file in the piece storage. The pieces removed by a previous run are not listed again.`,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "only list the pieces that would be removed",
+		},
+		&cli.BoolFlag{
+			Name:  "report",
+			Usage: "also list the pieces kept within the grace period, with a summary by state",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := NewMarketNode(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		report, err := nodeApi.PiecesGC(ctx, cctx.Bool("dry-run"))
+		if err != nil {
+			return err
+		}
+
+		tw := tablewriter.New(
+			tablewriter.Col("Piece"),
+			tablewriter.Col("Deals"),
+			tablewriter.Col("DeadSince"),
+			tablewriter.Col("State"),
+			tablewriter.NewLineCol("Error"),
+		)
+		states := make(map[types.PieceGCState]int)
+		for _, item := range report.Pieces {
+			states[item.State]++
+			if item.State == types.PieceGCGrace && !cctx.Bool("report") {
+				continue
+			}
+			tw.Write(map[string]interface{}{
+				"Piece":     item.PieceCID,
+				"Deals":     item.Deals,
+				"DeadSince": item.DeadSince.Format("2006-01-02 15:04:05"),
+				"State":     item.State,
+				"Error":     item.Error,
+			})
+		}
+		if err := tw.Flush(os.Stdout); err != nil {
+			return err
+		}
+
+		if cctx.Bool("report") {
+			fmt.Printf("\nGrace period: %s\n", report.GracePeriod)
+			for _, state := range []types.PieceGCState{types.PieceGCGrace, types.PieceGCWouldRemove, types.PieceGCRemoved, types.PieceGCFailed} {
+				if states[state] > 0 {
+					fmt.Printf("%s: %d\n", state, states[state])
+				}
+			}
+		}
+		return nil
+	},
+}
+
+var piecesVerifyCmd = &cli.Command{
+	Name:      "verify",
+	Usage:     "Recompute the commP of piece files to find the corrupted or missing ones",
+	ArgsUsage: "[pieceCid...]",
+	Description: `The piece files are read from the piece storage and their commP is compared with the piece cid,
+   the result is recorded with the time of the check. --all verifies the pieces of all the live deals,
+   --list shows the recorded results without reading any file.`,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "all",
+			Usage: "verify all the stored pieces",
+		},
+		&cli.BoolFlag{
+			Name:  "list",
+			Usage: "list the results of the last checks",
+		},
+		&cli.BoolFlag{
+			Name:  "failed",
+			Usage: "only list the pieces that are not ok",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := NewMarketNode(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		var results []types.PieceVerifyResult
+		if cctx.Bool("list") {
+			list, err := nodeApi.PiecesListVerify(ctx)
+			if err != nil {
+				return err
+			}
+			for _, res := range list {
+				results = append(results, *res)
+			}
+		} else {
+			if cctx.Bool("all") == cctx.Args().Present() {
+				return xerrors.New("must specify either piece cids or --all")
+			}
+			pieces := make([]cid.Cid, 0, cctx.NArg())
+			for _, arg := range cctx.Args().Slice() {
+				pieceCid, err := cid.Decode(arg)
+				if err != nil {
+					return xerrors.Errorf("invalid piece cid %s: %w", arg, err)
+				}
+				pieces = append(pieces, pieceCid)
+			}
+			if results, err = nodeApi.PiecesVerify(ctx, pieces); err != nil {
+				return err
+			}
+		}
+
+		tw := tablewriter.New(
+			tablewriter.Col("Piece"),
+			tablewriter.Col("State"),
+			tablewriter.Col("Size"),
+			tablewriter.Col("VerifiedAt"),
+			tablewriter.NewLineCol("Error"),
+		)
+		failed := 0
+		for _, res := range results {
+			if res.State != types.PieceVerifyOK {
+				failed++
+			} else if cctx.Bool("failed") {
+				continue
+			}
+			tw.Write(map[string]interface{}{
+				"Piece":      res.PieceCID,
+				"State":      res.State,
+				"Size":       res.Size,
+				"VerifiedAt": res.VerifiedAt.Format("2006-01-02 15:04:05"),
+				"Error":      res.Error,
+			})
+		}
+		if err := tw.Flush(os.Stdout); err != nil {
+			return err
+		}
+		fmt.Printf("\n%d pieces, %d not ok\n", len(results), failed)
+		return nil
+	},
+}
@@ -11,7 +11,10 @@ import (
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
 
+	"github.com/filecoin-project/venus-market/retrievalprovider"
+	mtypes "github.com/filecoin-project/venus-market/types"
 	"github.com/filecoin-project/venus/venus-shared/types"
 )
 
@@ -199,6 +202,26 @@ var retrievalSetAskCmd = &cli.Command{
 			Name:     "payment-addr",
 			Required: true,
 		},
+		&cli.StringFlag{
+			Name:  "piece",
+			Usage: "Set the ask for retrievals from the piece cid instead of the default ask",
+		},
+		&cli.StringFlag{
+			Name:  "payload",
+			Usage: "Set the ask for retrievals of the payload cid instead of the default ask",
+		},
+		&cli.StringFlag{
+			Name:  "client",
+			Usage: "Set the ask for a client instead of the default ask, the peer id of the retrieval client or the key address of its wallet. a wallet is only known once the client paid a retrieval from it",
+		},
+		&cli.StringFlag{
+			Name:  "label",
+			Usage: "Set the ask for retrievals from storage deals whose label matches the glob pattern instead of the default ask",
+		},
+		&cli.BoolFlag{
+			Name:  "remove",
+			Usage: "Remove the ask set by --piece, --payload, --client or --label",
+		},
 	},
 	Action: func(cctx *cli.Context) error {
 		ctx := DaemonContext(cctx)
@@ -214,6 +237,28 @@ var retrievalSetAskCmd = &cli.Command{
 			return err
 		}
 
+		var rule *mtypes.RetrievalAskRule
+		for _, kind := range mtypes.RetrievalAskRuleKinds {
+			if !cctx.IsSet(string(kind)) {
+				continue
+			}
+			if rule != nil {
+				return xerrors.New("only one of --piece, --payload, --client and --label can be set")
+			}
+			value, err := retrievalprovider.NormalizeAskRuleValue(kind, cctx.String(string(kind)))
+			if err != nil {
+				return err
+			}
+			rule = &mtypes.RetrievalAskRule{Miner: mAddr, Kind: kind, Value: value}
+		}
+		if cctx.Bool("remove") {
+			if rule == nil {
+				return xerrors.New("--remove requires one of --piece, --payload, --client and --label")
+			}
+			return api.MarketRemoveRetrievalAskRule(ctx, mAddr, rule.Kind, rule.Value)
+		}
+
+		// a rule starts from the default ask, unless it exists already
 		ask, err := api.MarketGetRetrievalAsk(ctx, mAddr)
 		if err != nil {
 			if err.Error() != "record not found" {
@@ -221,6 +266,23 @@ var retrievalSetAskCmd = &cli.Command{
 			}
 			ask = &retrievalmarket.Ask{}
 		}
+		if rule != nil {
+			rules, err := api.MarketListRetrievalAskRules(ctx, mAddr)
+			if err != nil {
+				return err
+			}
+			for _, r := range rules {
+				if r.Kind == rule.Kind && r.Value == rule.Value {
+					ask = &retrievalmarket.Ask{
+						PricePerByte:            r.PricePerByte,
+						UnsealPrice:             r.UnsealPrice,
+						PaymentInterval:         r.PaymentInterval,
+						PaymentIntervalIncrease: r.PaymentIntervalIncrease,
+					}
+					break
+				}
+			}
+		}
 
 		if cctx.IsSet("price") {
 			v, err := types.ParseFIL(cctx.String("price"))
@@ -254,6 +316,13 @@ var retrievalSetAskCmd = &cli.Command{
 			ask.PaymentIntervalIncrease = uint64(v)
 		}
 
+		if rule != nil {
+			rule.PricePerByte = ask.PricePerByte
+			rule.UnsealPrice = ask.UnsealPrice
+			rule.PaymentInterval = ask.PaymentInterval
+			rule.PaymentIntervalIncrease = ask.PaymentIntervalIncrease
+			return api.MarketSetRetrievalAskRule(ctx, rule)
+		}
 		return api.MarketSetRetrievalAsk(ctx, mAddr, ask)
 	},
 }
@@ -279,6 +348,10 @@ var retrievalGetAskCmd = &cli.Command{
 		}
 
 		ask, err := api.MarketGetRetrievalAsk(ctx, mAddr)
+		if err != nil && err.Error() != "record not found" {
+			return err
+		}
+		rules, err := api.MarketListRetrievalAskRules(ctx, mAddr)
 		if err != nil {
 			return err
 		}
@@ -287,16 +360,40 @@ var retrievalGetAskCmd = &cli.Command{
 		fmt.Fprintf(w, "Price per Byte\tUnseal Price\tPayment Interval\tPayment Interval Increase\n")
 		if ask == nil {
 			fmt.Fprintf(w, "<miner does not have an retrieval ask set>\n")
-			return w.Flush()
+		} else {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+				types.FIL(ask.PricePerByte),
+				types.FIL(ask.UnsealPrice),
+				units.BytesSize(float64(ask.PaymentInterval)),
+				units.BytesSize(float64(ask.PaymentIntervalIncrease)),
+			)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if len(rules) == 0 {
+			return nil
 		}
 
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
-			types.FIL(ask.PricePerByte),
-			types.FIL(ask.UnsealPrice),
-			units.BytesSize(float64(ask.PaymentInterval)),
-			units.BytesSize(float64(ask.PaymentIntervalIncrease)),
-		)
+		fmt.Println()
+		fmt.Println("Rules:")
+		w = tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "Kind\tValue\tPrice per Byte\tUnseal Price\tPayment Interval\tPayment Interval Increase\n")
+		for _, kind := range mtypes.RetrievalAskRuleKinds {
+			for _, r := range rules {
+				if r.Kind != kind {
+					continue
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+					r.Kind,
+					r.Value,
+					types.FIL(r.PricePerByte),
+					types.FIL(r.UnsealPrice),
+					units.BytesSize(float64(r.PaymentInterval)),
+					units.BytesSize(float64(r.PaymentIntervalIncrease)),
+				)
+			}
+		}
 		return w.Flush()
-
 	},
 }
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+)
+
+var SignerCmd = &cli.Command{
+	Name:  "signer",
+	Usage: "inspect the signer backends",
+	Subcommands: []*cli.Command{
+		signerStatusCmd,
+	},
+}
+
+var signerStatusCmd = &cli.Command{
+	Name:  "status",
+	Usage: "print the health of the signer backends and the backend signing for every miner",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := NewMarketNode(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		status, err := api.SignerStatus(ctx)
+		if err != nil {
+			return xerrors.Errorf("getting signer status: %w", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintf(w, "Backend\tType\tHealthy\tLastCheck\tAddresses\tError\n")
+		for _, backend := range status.Backends {
+			lastCheck := "-"
+			if !backend.LastCheck.IsZero() {
+				lastCheck = backend.LastCheck.Format(time.RFC3339)
+			}
+			healthy := fmt.Sprintf("%t", backend.Healthy)
+			if backend.Unknown {
+				healthy = "unknown"
+			}
+			addrs := "*"
+			if len(backend.Addresses) > 0 {
+				strs := make([]string, 0, len(backend.Addresses))
+				for _, addr := range backend.Addresses {
+					strs = append(strs, addr.String())
+				}
+				addrs = strings.Join(strs, ",")
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				backend.Name, backend.Type, healthy, lastCheck, addrs, backend.LastError)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		fmt.Println()
+
+		w = tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintf(w, "Miner\tSigner\tBackend\tError\n")
+		for _, route := range status.Routes {
+			backend := route.Backend
+			if backend == "" && route.Error == "" {
+				backend = "none"
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", route.Miner, route.Addr, backend, route.Error)
+		}
+		return w.Flush()
+	},
+}
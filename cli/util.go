@@ -21,11 +21,11 @@ import (
 
 	datatransfer "github.com/filecoin-project/go-data-transfer"
 	"github.com/filecoin-project/go-jsonrpc"
+	"github.com/filecoin-project/venus-market/api"
 	"github.com/filecoin-project/venus-market/cli/tablewriter"
 	"github.com/filecoin-project/venus-market/config"
+	"github.com/filecoin-project/venus-market/utils"
 	v1api "github.com/filecoin-project/venus/venus-shared/api/chain/v1"
-	marketapi "github.com/filecoin-project/venus/venus-shared/api/market"
-	clientapi "github.com/filecoin-project/venus/venus-shared/api/market/client"
 	types "github.com/filecoin-project/venus/venus-shared/types/market"
 	"github.com/ipfs-force-community/venus-common-utils/apiinfo"
 )
@@ -70,7 +70,7 @@ var requiredMinerFlag = &cli.StringFlag{
 	Required: true,
 }
 
-func NewMarketNode(cctx *cli.Context) (marketapi.IMarket, jsonrpc.ClientCloser, error) {
+func NewMarketNode(cctx *cli.Context) (api.MarketFullNode, jsonrpc.ClientCloser, error) {
 	homePath, err := homedir.Expand(cctx.String("repo"))
 	if err != nil {
 		return nil, nil, err
@@ -91,10 +91,12 @@ func NewMarketNode(cctx *cli.Context) (marketapi.IMarket, jsonrpc.ClientCloser,
 		return nil, nil, err
 	}
 
-	return marketapi.NewIMarketRPC(cctx.Context, addr, apiInfo.AuthHeader())
+	var res api.MarketFullStruct
+	closer, err := jsonrpc.NewMergeClient(cctx.Context, addr, API_NAMESPACE_VENUS_MARKET, utils.GetInternalStructs(&res), apiInfo.AuthHeader())
+	return &res, closer, err
 }
 
-func NewMarketClientNode(cctx *cli.Context) (clientapi.IMarketClient, jsonrpc.ClientCloser, error) {
+func NewMarketClientNode(cctx *cli.Context) (api.MarketClientNode, jsonrpc.ClientCloser, error) {
 	homePath, err := homedir.Expand(cctx.String("repo"))
 	if err != nil {
 		return nil, nil, err
@@ -114,7 +116,9 @@ func NewMarketClientNode(cctx *cli.Context) (clientapi.IMarketClient, jsonrpc.Cl
 		return nil, nil, err
 	}
 
-	return clientapi.NewIMarketClientRPC(cctx.Context, addr, apiInfo.AuthHeader())
+	var res api.MarketClientStruct
+	closer, err := jsonrpc.NewMergeClient(cctx.Context, addr, API_NAMESPACE_MARKET_CLIENT, utils.GetInternalStructs(&res), apiInfo.AuthHeader())
+	return &res, closer, err
 }
 
 func NewFullNode(cctx *cli.Context) (v1api.FullNode, jsonrpc.ClientCloser, error) {
@@ -0,0 +1,179 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/crypto/ssh/terminal"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/venus-market/keystore"
+
+	types2 "github.com/filecoin-project/venus/venus-shared/types"
+)
+
+// KeystorePasswordEnv is the password of the local keystore, the password is asked on the terminal without it
+const KeystorePasswordEnv = "VENUS_MARKET_KEYSTORE_PASSWORD"
+
+var WalletCmd = &cli.Command{
+	Name:  "wallet",
+	Usage: "manage the keys of the local signer, the keystore of the repo is used directly so the market may be stopped",
+	Subcommands: []*cli.Command{
+		walletNewCmd,
+		walletListCmd,
+		walletImportCmd,
+		walletExportCmd,
+	},
+}
+
+// KeystorePassword reads the password of the local keystore from the flag when it is set, then from
+// KeystorePasswordEnv, then asks it on the terminal. The password is never read from the config
+func KeystorePassword(cctx *cli.Context, flag string) (string, error) {
+	if len(flag) > 0 && cctx.IsSet(flag) {
+		return cctx.String(flag), nil
+	}
+	if password := os.Getenv(KeystorePasswordEnv); len(password) > 0 {
+		return password, nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return "", xerrors.Errorf("set the keystore password with %s", KeystorePasswordEnv)
+	}
+	fmt.Fprint(os.Stderr, "keystore password: ")
+	password, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", xerrors.Errorf("read keystore password: %w", err)
+	}
+	return string(password), nil
+}
+
+// openKeyStore opens the keystore of the repo, it fails when the password does not open its keys
+func openKeyStore(cctx *cli.Context) (*keystore.KeyStore, error) {
+	homePath, err := homedir.Expand(cctx.String("repo"))
+	if err != nil {
+		return nil, err
+	}
+	password, err := KeystorePassword(cctx, "")
+	if err != nil {
+		return nil, err
+	}
+	return keystore.NewKeyStore(path.Join(homePath, keystore.DefaultDir), password)
+}
+
+var walletNewCmd = &cli.Command{
+	Name:      "new",
+	Usage:     "generate a key",
+	ArgsUsage: "[secp256k1 | bls]",
+	Action: func(cctx *cli.Context) error {
+		kt := types2.KTSecp256k1
+		if cctx.Args().Present() {
+			kt = types2.KeyType(cctx.Args().First())
+		}
+
+		ks, err := openKeyStore(cctx)
+		if err != nil {
+			return err
+		}
+		addr, err := ks.New(kt)
+		if err != nil {
+			return err
+		}
+		fmt.Println(addr.String())
+		return nil
+	},
+}
+
+var walletListCmd = &cli.Command{
+	Name:  "list",
+	Usage: "list the addresses of the keys",
+	Action: func(cctx *cli.Context) error {
+		ks, err := openKeyStore(cctx)
+		if err != nil {
+			return err
+		}
+		addrs, err := ks.List()
+		if err != nil {
+			return err
+		}
+		for _, addr := range addrs {
+			fmt.Println(addr.String())
+		}
+		return nil
+	},
+}
+
+var walletImportCmd = &cli.Command{
+	Name:      "import",
+	Usage:     "import a hex encoded key exported by `wallet export`, from the file or the standard input",
+	ArgsUsage: "[<path> (optional, will read from stdin if omitted)]",
+	Action: func(cctx *cli.Context) error {
+		var data []byte
+		var err error
+		if !cctx.Args().Present() || cctx.Args().First() == "-" {
+			data, err = bufio.NewReader(os.Stdin).ReadBytes('\n')
+			if err != nil && len(data) == 0 {
+				return xerrors.Errorf("read key from stdin: %w", err)
+			}
+		} else {
+			data, err = ioutil.ReadFile(cctx.Args().First())
+			if err != nil {
+				return err
+			}
+		}
+
+		ki, err := keystore.DecodeKey(strings.TrimSpace(string(data)))
+		if err != nil {
+			return err
+		}
+		ks, err := openKeyStore(cctx)
+		if err != nil {
+			return err
+		}
+		addr, err := ks.Import(ki)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("imported key %s successfully!\n", addr)
+		return nil
+	},
+}
+
+var walletExportCmd = &cli.Command{
+	Name:      "export",
+	Usage:     "print the hex encoded key of the address, which is not encrypted",
+	ArgsUsage: "<address>",
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return xerrors.New("must pass the address to export")
+		}
+		addr, err := address.NewFromString(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		ks, err := openKeyStore(cctx)
+		if err != nil {
+			return err
+		}
+		ki, err := ks.Export(addr)
+		if err != nil {
+			return err
+		}
+		encoded, err := keystore.EncodeKey(ki)
+		if err != nil {
+			return err
+		}
+		fmt.Println(encoded)
+		return nil
+	},
+}
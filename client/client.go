@@ -773,6 +773,10 @@ func (a *API) ClientRetrieve(ctx context.Context, params types.RetrievalOrder) (
 }
 
 func (a *API) doRetrieval(ctx context.Context, order types.RetrievalOrder, sel datamodel.Node) (retrievalmarket.DealID, error) {
+	return a.doRetrievalWithID(ctx, a.Retrieval.NextID(), order, sel)
+}
+
+func (a *API) doRetrievalWithID(ctx context.Context, id retrievalmarket.DealID, order types.RetrievalOrder, sel datamodel.Node) (retrievalmarket.DealID, error) {
 	if order.MinerPeer == nil || order.MinerPeer.ID == "" {
 		mi, err := a.Full.StateMinerInfo(ctx, order.Miner, vTypes.EmptyTSK)
 		if err != nil {
@@ -800,7 +804,6 @@ func (a *API) doRetrieval(ctx context.Context, order types.RetrievalOrder, sel d
 		return 0, xerrors.Errorf("Error in retrieval params: %s", err)
 	}
 
-	id := a.Retrieval.NextID()
 	id, err = a.Retrieval.Retrieve(
 		ctx,
 		id,
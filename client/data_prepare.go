@@ -0,0 +1,559 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ipfs/go-blockservice"
+	"github.com/ipfs/go-cid"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/go-merkledag"
+	uio "github.com/ipfs/go-unixfs/io"
+	"github.com/ipld/go-car"
+	"github.com/ipld/go-car/v2/blockstore"
+	basicnode "github.com/ipld/go-ipld-prime/node/basic"
+	"github.com/ipld/go-ipld-prime/traversal/selector"
+	"github.com/ipld/go-ipld-prime/traversal/selector/builder"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-commp-utils/writer"
+	"github.com/filecoin-project/go-state-types/abi"
+)
+
+const (
+	PrepareManifestName    = "manifest.json"
+	prepareManifestVersion = 1
+
+	// estimated bytes of dag nodes and car framing for every chunk and every file
+	chunkOverhead = 256
+	fileOverhead  = 1024
+	// space kept free in every car for the directory nodes and the car header
+	carReserved = 64 << 10
+)
+
+// PrepareParams describes a dataset preparation run
+type PrepareParams struct {
+	// InputPath is a file or a directory, directories are walked recursively
+	InputPath string
+	// OutputDir receives the cars and the manifest
+	OutputDir string
+	// TargetPieceSize is the padded piece size every car has to fit in
+	TargetPieceSize abi.PaddedPieceSize
+}
+
+// PreparedFile is a file, or a range of a file, packed into a car
+type PreparedFile struct {
+	// Path is relative to the input path, and is the path of the file inside the dag of the car
+	Path string
+	// Name is the name of the entry inside the dag, differs from Path when the file was split
+	Name   string
+	Offset int64
+	Length int64
+	// Size is the size of the whole source file
+	Size int64
+}
+
+// PreparedCar is a car generated by the preparation, the fields can be passed to an offline deal as-is
+type PreparedCar struct {
+	Index             int
+	CarPath           string
+	RootCid           cid.Cid
+	PieceCid          cid.Cid
+	PieceSize         abi.PaddedPieceSize
+	UnpaddedPieceSize abi.UnpaddedPieceSize
+	CarSize           int64
+	Files             []PreparedFile
+}
+
+// PrepareManifest maps the source files to the cars they were packed into
+type PrepareManifest struct {
+	Version         int
+	InputPath       string
+	TargetPieceSize abi.PaddedPieceSize
+	Cars            []PreparedCar
+}
+
+func (m *PrepareManifest) find(index int) *PreparedCar {
+	for i := range m.Cars {
+		if m.Cars[i].Index == index {
+			return &m.Cars[i]
+		}
+	}
+	return nil
+}
+
+type sourceFile struct {
+	path string
+	rel  string
+	size int64
+}
+
+// PrepareData packs the input files into cars fitting the target piece size, computes the commP of every car
+// and records the result in a manifest under the output dir. cars already recorded in the manifest are
+// skipped, so an interrupted run continues where it stopped when started again with the same parameters.
+func PrepareData(ctx context.Context, params PrepareParams, progress func(car *PreparedCar, skipped bool)) (*PrepareManifest, error) {
+	if params.TargetPieceSize.Validate() != nil || params.TargetPieceSize < 2<<20 {
+		return nil, xerrors.Errorf("invalid target piece size %d, must be a power of two and at least 2MiB", params.TargetPieceSize)
+	}
+
+	input, err := filepath.Abs(params.InputPath)
+	if err != nil {
+		return nil, err
+	}
+	output, err := filepath.Abs(params.OutputDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(output, 0755); err != nil {
+		return nil, xerrors.Errorf("create output dir: %w", err)
+	}
+
+	srcFiles, err := walkSourceFiles(input, output)
+	if err != nil {
+		return nil, err
+	}
+	if len(srcFiles) == 0 {
+		return nil, xerrors.Errorf("no file found in %s", input)
+	}
+
+	manifestPath := filepath.Join(params.OutputDir, PrepareManifestName)
+	manifest, err := loadPrepareManifest(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	if manifest == nil {
+		manifest = &PrepareManifest{
+			Version:         prepareManifestVersion,
+			InputPath:       input,
+			TargetPieceSize: params.TargetPieceSize,
+		}
+	} else if manifest.InputPath != input || manifest.TargetPieceSize != params.TargetPieceSize {
+		return nil, xerrors.Errorf("output dir was prepared from %s with target size %d, use another output dir",
+			manifest.InputPath, manifest.TargetPieceSize)
+	}
+
+	groups := packSourceFiles(srcFiles, carBudget(params.TargetPieceSize))
+	for idx, group := range groups {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if done := manifest.find(idx); done != nil {
+			if !sameFiles(done.Files, group) {
+				return nil, xerrors.Errorf("input changed since car %d was generated, use another output dir", idx)
+			}
+			if st, err := os.Stat(filepath.Join(params.OutputDir, done.CarPath)); err == nil && st.Size() == done.CarSize {
+				if progress != nil {
+					progress(done, true)
+				}
+				continue
+			}
+			// the car went missing, generate it again
+			manifest.Cars = removeCar(manifest.Cars, idx)
+		}
+
+		pc, err := prepareCar(ctx, input, params.OutputDir, idx, group, params.TargetPieceSize)
+		if err != nil {
+			return nil, xerrors.Errorf("generate car %d: %w", idx, err)
+		}
+		manifest.Cars = append(manifest.Cars, *pc)
+		sort.Slice(manifest.Cars, func(i, j int) bool {
+			return manifest.Cars[i].Index < manifest.Cars[j].Index
+		})
+		if err := savePrepareManifest(manifestPath, manifest); err != nil {
+			return nil, err
+		}
+		if progress != nil {
+			progress(pc, false)
+		}
+	}
+
+	return manifest, nil
+}
+
+// walkSourceFiles lists the regular files of the input, the output dir is skipped when it is inside the input so the cars
+// and the manifest of a previous run are not packed
+func walkSourceFiles(input, output string) ([]sourceFile, error) {
+	st, err := os.Stat(input)
+	if err != nil {
+		return nil, err
+	}
+	if !st.IsDir() {
+		return []sourceFile{{path: input, rel: filepath.Base(input), size: st.Size()}}, nil
+	}
+
+	var out []sourceFile
+	err = filepath.Walk(input, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && path == output {
+			return filepath.SkipDir
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(input, path)
+		if err != nil {
+			return err
+		}
+		out = append(out, sourceFile{path: path, rel: filepath.ToSlash(rel), size: info.Size()})
+		return nil
+	})
+	return out, err
+}
+
+// carBudget is the estimated payload a car can hold and still fit in the piece
+func carBudget(pieceSize abi.PaddedPieceSize) int64 {
+	return int64(pieceSize.Unpadded()) - carReserved
+}
+
+func estimateSize(length int64) int64 {
+	return length + (length/int64(UnixfsChunkSize)+1)*chunkOverhead + fileOverhead
+}
+
+// maxPartLength returns the longest range of a file fitting in the space, aligned to the unixfs chunk size
+func maxPartLength(space int64) int64 {
+	chunk := int64(UnixfsChunkSize)
+	l := (space - fileOverhead) * chunk / (chunk + chunkOverhead)
+	return l / chunk * chunk
+}
+
+// partPrefix returns the prefix of the names of the ranges of a split file, no other entry of the dag starts with it
+func partPrefix(rel string, taken []string) string {
+	prefix := rel
+	for {
+		collides := false
+		for _, name := range taken {
+			if strings.HasPrefix(name, prefix+".part") {
+				collides = true
+				break
+			}
+		}
+		if !collides {
+			return prefix + ".part"
+		}
+		prefix += "~"
+	}
+}
+
+// packSourceFiles groups the files in walking order, files too large for a car are split into ranges
+func packSourceFiles(srcFiles []sourceFile, budget int64) [][]PreparedFile {
+	var (
+		groups [][]PreparedFile
+		cur    []PreparedFile
+		used   int64
+	)
+	// the names of the dag entries, the ranges of split files are named after them
+	taken := make([]string, 0, len(srcFiles))
+	for _, f := range srcFiles {
+		taken = append(taken, f.rel)
+	}
+	closeGroup := func() {
+		if len(cur) > 0 {
+			groups = append(groups, cur)
+		}
+		cur, used = nil, 0
+	}
+
+	for _, f := range srcFiles {
+		est := estimateSize(f.size)
+		if used+est <= budget {
+			cur = append(cur, PreparedFile{Path: f.rel, Name: f.rel, Length: f.size, Size: f.size})
+			used += est
+			continue
+		}
+		if est <= budget {
+			closeGroup()
+			cur = append(cur, PreparedFile{Path: f.rel, Name: f.rel, Length: f.size, Size: f.size})
+			used = est
+			continue
+		}
+
+		// split the file, the first range fills what is left in the current car
+		prefix := partPrefix(f.rel, taken)
+		var offset int64
+		for part := 0; offset < f.size; part++ {
+			l := maxPartLength(budget - used)
+			if l < int64(UnixfsChunkSize) {
+				closeGroup()
+				l = maxPartLength(budget)
+			}
+			if l > f.size-offset {
+				l = f.size - offset
+			}
+			name := fmt.Sprintf("%s%04d", prefix, part)
+			taken = append(taken, name)
+			cur = append(cur, PreparedFile{
+				Path:   f.rel,
+				Name:   name,
+				Offset: offset,
+				Length: l,
+				Size:   f.size,
+			})
+			used += estimateSize(l)
+			offset += l
+		}
+	}
+	closeGroup()
+
+	return groups
+}
+
+func prepareCar(ctx context.Context, input, outDir string, idx int, files []PreparedFile, target abi.PaddedPieceSize) (*PreparedCar, error) {
+	carName := fmt.Sprintf("%06d.car", idx)
+	carPath := filepath.Join(outDir, carName)
+	tmpV2 := carPath + ".v2.tmp"
+	tmpV1 := carPath + ".tmp"
+	defer os.Remove(tmpV2) //nolint:errcheck
+	defer os.Remove(tmpV1) //nolint:errcheck
+
+	root, err := buildPrepareDag(ctx, input, files, tmpV2)
+	if err != nil {
+		return nil, err
+	}
+
+	// write a dense carv1 in traversal order, that is what gets dealt
+	bs, err := blockstore.OpenReadOnly(tmpV2, blockstore.UseWholeCIDs(true))
+	if err != nil {
+		return nil, xerrors.Errorf("open staging car: %w", err)
+	}
+	defer bs.Close() //nolint:errcheck
+
+	ssb := builder.NewSelectorSpecBuilder(basicnode.Prototype.Any)
+	allSelector := ssb.ExploreRecursive(
+		selector.RecursionLimitNone(),
+		ssb.ExploreAll(ssb.ExploreRecursiveEdge())).Node()
+	sc := car.NewSelectiveCar(ctx, bs, []car.Dag{{Root: root, Selector: allSelector}})
+
+	f, err := os.Create(tmpV1)
+	if err != nil {
+		return nil, err
+	}
+	w := &writer.Writer{}
+	if err = sc.Write(io.MultiWriter(f, w)); err != nil {
+		_ = f.Close()
+		return nil, xerrors.Errorf("write car: %w", err)
+	}
+	if err = f.Close(); err != nil {
+		return nil, err
+	}
+
+	commP, err := w.Sum()
+	if err != nil {
+		return nil, xerrors.Errorf("compute commP: %w", err)
+	}
+	if commP.PieceSize > target {
+		return nil, xerrors.Errorf("car of %d bytes needs a piece of %d, exceeds the target %d", commP.PayloadSize, commP.PieceSize, target)
+	}
+
+	if err = os.Rename(tmpV1, carPath); err != nil {
+		return nil, err
+	}
+
+	return &PreparedCar{
+		Index:             idx,
+		CarPath:           carName,
+		RootCid:           root,
+		PieceCid:          commP.PieceCID,
+		PieceSize:         commP.PieceSize,
+		UnpaddedPieceSize: commP.PieceSize.Unpadded(),
+		CarSize:           commP.PayloadSize,
+		Files:             files,
+	}, nil
+}
+
+type prepareDir struct {
+	files map[string]ipld.Node
+	dirs  map[string]*prepareDir
+}
+
+func newPrepareDir() *prepareDir {
+	return &prepareDir{files: map[string]ipld.Node{}, dirs: map[string]*prepareDir{}}
+}
+
+func (d *prepareDir) add(name string, nd ipld.Node) {
+	parts := strings.Split(name, "/")
+	cur := d
+	for _, p := range parts[:len(parts)-1] {
+		sub, ok := cur.dirs[p]
+		if !ok {
+			sub = newPrepareDir()
+			cur.dirs[p] = sub
+		}
+		cur = sub
+	}
+	cur.files[parts[len(parts)-1]] = nd
+}
+
+func (d *prepareDir) build(ctx context.Context, dags ipld.DAGService, b cid.Builder) (ipld.Node, error) {
+	dir := uio.NewDirectory(dags)
+	dir.SetCidBuilder(b)
+
+	names := make([]string, 0, len(d.files)+len(d.dirs))
+	for name := range d.files {
+		names = append(names, name)
+	}
+	for name := range d.dirs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		nd, ok := d.files[name]
+		if !ok {
+			var err error
+			if nd, err = d.dirs[name].build(ctx, dags, b); err != nil {
+				return nil, err
+			}
+		}
+		if err := dir.AddChild(ctx, name, nd); err != nil {
+			return nil, err
+		}
+	}
+
+	nd, err := dir.GetNode()
+	if err != nil {
+		return nil, err
+	}
+	return nd, dags.Add(ctx, nd)
+}
+
+// buildPrepareDag imports the files into a unixfs directory stored in a carv2 file
+func buildPrepareDag(ctx context.Context, input string, files []PreparedFile, dst string) (cid.Cid, error) {
+	b, err := unixFSCidBuilder()
+	if err != nil {
+		return cid.Undef, err
+	}
+	// the root is unknown before the dag is built, see ClientImportLocal
+	placeholderRoot, err := b.Sum(make([]byte, 256))
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to calculate placeholder root: %w", err)
+	}
+
+	_ = os.Remove(dst)
+	bs, err := blockstore.OpenReadWrite(dst, []cid.Cid{placeholderRoot}, blockstore.UseWholeCIDs(true))
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to create carv2 read/write blockstore: %w", err)
+	}
+	finalized := false
+	defer func() {
+		if !finalized {
+			// closes the file of the staging car, the caller removes it
+			_ = bs.Finalize()
+		}
+	}()
+	dags := merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs)))
+
+	st, err := os.Stat(input)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	tree := newPrepareDir()
+	for _, pf := range files {
+		src := input
+		if st.IsDir() {
+			src = filepath.Join(input, filepath.FromSlash(pf.Path))
+		}
+
+		fileRoot, err := importFileRange(ctx, src, pf.Offset, pf.Length, bs)
+		if err != nil {
+			return cid.Undef, xerrors.Errorf("import %s: %w", pf.Path, err)
+		}
+		nd, err := dags.Get(ctx, fileRoot)
+		if err != nil {
+			return cid.Undef, err
+		}
+		tree.add(pf.Name, nd)
+	}
+
+	rootNd, err := tree.build(ctx, dags, b)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("build directory: %w", err)
+	}
+
+	finalized = true
+	if err := bs.Finalize(); err != nil {
+		return cid.Undef, xerrors.Errorf("failed to finalize carv2 read/write blockstore: %w", err)
+	}
+	return rootNd.Cid(), nil
+}
+
+func importFileRange(ctx context.Context, path string, offset, length int64, bs *blockstore.ReadWrite) (cid.Cid, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return cid.Undef, err
+	}
+	defer f.Close() //nolint:errcheck
+
+	st, err := f.Stat()
+	if err != nil {
+		return cid.Undef, err
+	}
+	if st.Size() < offset+length {
+		return cid.Undef, xerrors.Errorf("file shrank to %d bytes, expect at least %d", st.Size(), offset+length)
+	}
+
+	return buildUnixFS(ctx, io.NewSectionReader(f, offset, length), bs, false)
+}
+
+func sameFiles(a, b []PreparedFile) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func removeCar(cars []PreparedCar, idx int) []PreparedCar {
+	out := cars[:0]
+	for _, c := range cars {
+		if c.Index != idx {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func loadPrepareManifest(path string) (*PrepareManifest, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var m PrepareManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, xerrors.Errorf("parse manifest %s: %w", path, err)
+	}
+	if m.Version != prepareManifestVersion {
+		return nil, xerrors.Errorf("unsupported manifest version %d", m.Version)
+	}
+	return &m, nil
+}
+
+func savePrepareManifest(path string, m *PrepareManifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
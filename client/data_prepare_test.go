@@ -0,0 +1,125 @@
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackSourceFiles(t *testing.T) {
+	chunk := int64(UnixfsChunkSize)
+	budget := carBudget(abi.PaddedPieceSize(8 << 20))
+
+	groups := packSourceFiles([]sourceFile{
+		{rel: "a", size: 100},
+		{rel: "b", size: 2 * chunk},
+		{rel: "big", size: 20 * chunk},
+		{rel: "c", size: 10},
+	}, budget)
+
+	covered := map[string]int64{}
+	for _, g := range groups {
+		var used int64
+		for _, f := range g {
+			used += estimateSize(f.Length)
+			covered[f.Path] += f.Length
+		}
+		require.LessOrEqual(t, used, budget)
+	}
+	require.Equal(t, map[string]int64{"a": 100, "b": 2 * chunk, "big": 20 * chunk, "c": 10}, covered)
+
+	// the first range of the large file fills the first car
+	require.Equal(t, "big.part0000", groups[0][2].Name)
+	require.Equal(t, int64(0), groups[0][2].Offset)
+	// the last car holds the tail of the large file and the small file after it
+	last := groups[len(groups)-1]
+	require.Equal(t, "c", last[len(last)-1].Name)
+
+	// the ranges are named apart from an input file named like them
+	groups = packSourceFiles([]sourceFile{
+		{rel: "big", size: 20 * chunk},
+		{rel: "big.part0001", size: 10},
+	}, budget)
+	names := map[string]struct{}{}
+	for _, g := range groups {
+		for _, f := range g {
+			_, dup := names[f.Name]
+			require.False(t, dup, "duplicated name %s", f.Name)
+			names[f.Name] = struct{}{}
+		}
+	}
+	require.Equal(t, "big~.part0000", groups[0][0].Name)
+}
+
+func TestPrepareData(t *testing.T) {
+	ctx := context.Background()
+	input := t.TempDir()
+	output := t.TempDir()
+
+	writeRand := func(name string, size int) {
+		path := filepath.Join(input, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		data := make([]byte, size)
+		rand.New(rand.NewSource(int64(size))).Read(data) //nolint:gosec
+		require.NoError(t, ioutil.WriteFile(path, data, 0644))
+	}
+	writeRand("a.txt", 1000)
+	writeRand("sub/b.bin", 300<<10)
+	writeRand("sub/deep/c.bin", 5<<20)
+
+	params := PrepareParams{
+		InputPath:       input,
+		OutputDir:       output,
+		TargetPieceSize: abi.PaddedPieceSize(2 << 20),
+	}
+
+	generated := 0
+	manifest, err := PrepareData(ctx, params, func(car *PreparedCar, skipped bool) {
+		require.False(t, skipped)
+		generated++
+	})
+	require.NoError(t, err)
+	require.Equal(t, len(manifest.Cars), generated)
+	require.Greater(t, generated, 2)
+
+	for _, c := range manifest.Cars {
+		require.LessOrEqual(t, c.PieceSize, params.TargetPieceSize)
+		st, err := os.Stat(filepath.Join(output, c.CarPath))
+		require.NoError(t, err)
+		require.Equal(t, c.CarSize, st.Size())
+	}
+
+	// a second run resumes from the manifest and generates nothing
+	skipped := 0
+	again, err := PrepareData(ctx, params, func(car *PreparedCar, s bool) {
+		require.True(t, s)
+		skipped++
+	})
+	require.NoError(t, err)
+	require.Equal(t, generated, skipped)
+	require.Equal(t, manifest, again)
+
+	// a missing car is generated again with the same piece cid
+	require.NoError(t, os.Remove(filepath.Join(output, manifest.Cars[1].CarPath)))
+	regen, err := PrepareData(ctx, params, nil)
+	require.NoError(t, err)
+	require.Equal(t, manifest.Cars[1].PieceCid, regen.Cars[1].PieceCid)
+
+	// an output dir inside the input is not packed
+	params.OutputDir = filepath.Join(input, "cars")
+	inside, err := PrepareData(ctx, params, nil)
+	require.NoError(t, err)
+	require.Equal(t, len(manifest.Cars), len(inside.Cars))
+	for _, c := range inside.Cars {
+		for _, f := range c.Files {
+			require.False(t, strings.HasPrefix(f.Path, "cars/"), f.Path)
+		}
+	}
+}
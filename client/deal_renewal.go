@@ -0,0 +1,374 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	logging "github.com/ipfs/go-log/v2"
+	"go.uber.org/fx"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/ipfs-force-community/venus-common-utils/metrics"
+
+	"github.com/filecoin-project/venus-market/config"
+	"github.com/filecoin-project/venus-market/models/badger"
+	"github.com/filecoin-project/venus-market/models/repo"
+	mtypes "github.com/filecoin-project/venus-market/types"
+
+	vTypes "github.com/filecoin-project/venus/venus-shared/types"
+	types "github.com/filecoin-project/venus/venus-shared/types/market/client"
+)
+
+var renewalLog = logging.Logger("deal-renewal")
+
+type dealRenewalStore struct {
+	ds badger.ClientDealRenewalDS
+}
+
+func (s *dealRenewalStore) get(ctx context.Context, proposalCid cid.Cid) (*mtypes.DealRenewal, error) {
+	data, err := s.ds.Get(ctx, datastore.NewKey(proposalCid.String()))
+	if err != nil {
+		if xerrors.Is(err, datastore.ErrNotFound) {
+			return nil, repo.ErrNotFound
+		}
+		return nil, err
+	}
+	var r mtypes.DealRenewal
+	if err = json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (s *dealRenewalStore) save(ctx context.Context, r *mtypes.DealRenewal) error {
+	r.UpdatedAt = time.Now()
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return s.ds.Put(ctx, datastore.NewKey(r.ProposalCid.String()), data)
+}
+
+func (s *dealRenewalStore) list(ctx context.Context) ([]mtypes.DealRenewal, error) {
+	res, err := s.ds.Query(ctx, dsq.Query{})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close() //nolint:errcheck
+
+	var out []mtypes.DealRenewal
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		var renewal mtypes.DealRenewal
+		if err := json.Unmarshal(r.Value, &renewal); err != nil {
+			return nil, err
+		}
+		out = append(out, renewal)
+	}
+	return out, nil
+}
+
+// DealRenewer watches the active deals of the client and proposes a replacement deal for the same piece
+// before the original one expires. every renewal is recorded against the proposal cid of the original deal.
+type DealRenewer struct {
+	api   *API
+	store *dealRenewalStore
+	cfg   config.DealRenewal
+	// startDeal proposes the replacement deal, replaced by the tests
+	startDeal func(ctx context.Context, params *types.StartDealParams) (*cid.Cid, error)
+
+	lk sync.Mutex
+}
+
+func NewDealRenewer(mctx metrics.MetricsCtx, lc fx.Lifecycle, api API, ds badger.ClientDealRenewalDS, cfg *config.MarketClientConfig) *DealRenewer {
+	r := newDealRenewer(&api, ds, cfg.DealRenewal)
+
+	if !r.cfg.Enable {
+		return r
+	}
+
+	ctx, cancel := context.WithCancel(metrics.LifecycleCtx(mctx, lc))
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go r.loop(ctx)
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			cancel()
+			return nil
+		},
+	})
+	return r
+}
+
+// newDealRenewer is used by the tests
+func newDealRenewer(api *API, ds badger.ClientDealRenewalDS, cfg config.DealRenewal) *DealRenewer {
+	return &DealRenewer{
+		api:   api,
+		store: &dealRenewalStore{ds: ds},
+		cfg:   cfg,
+		startDeal: func(ctx context.Context, params *types.StartDealParams) (*cid.Cid, error) {
+			return api.dealStarter(ctx, params, false)
+		},
+	}
+}
+
+func (r *DealRenewer) loop(ctx context.Context) {
+	interval := time.Duration(r.cfg.CheckInterval)
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.renewExpiringDeals(ctx); err != nil {
+			renewalLog.Errorf("renew expiring deals: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *DealRenewer) renewExpiringDeals(ctx context.Context) error {
+	head, err := r.api.Full.ChainHead(ctx)
+	if err != nil {
+		return xerrors.Errorf("get chain head: %w", err)
+	}
+
+	deals, err := r.api.SMDealClient.ListLocalDeals(ctx)
+	if err != nil {
+		return xerrors.Errorf("list local deals: %w", err)
+	}
+
+	for _, deal := range deals {
+		if deal.State != storagemarket.StorageDealActive || deal.DealID == 0 {
+			continue
+		}
+
+		remain := deal.Proposal.EndEpoch - head.Height()
+		if remain <= 0 || remain > abi.ChainEpoch(r.cfg.RenewBeforeEpochs) {
+			continue
+		}
+
+		renewal, err := r.renew(ctx, deal, false)
+		if err != nil {
+			renewalLog.Errorf("renew deal %s: %s", deal.ProposalCid, err)
+			continue
+		}
+		if renewal.State == mtypes.DealRenewalProposed {
+			renewalLog.Infof("deal %s(%d) renewed by %s on %s", deal.ProposalCid, deal.DealID, renewal.RenewedProposalCid, renewal.RenewedProvider)
+		}
+	}
+
+	return nil
+}
+
+// Renew proposes a replacement for the given active deal right away, regardless of the renewal window
+func (r *DealRenewer) Renew(ctx context.Context, proposalCid cid.Cid) (*mtypes.DealRenewal, error) {
+	deal, err := r.api.SMDealClient.GetLocalDeal(ctx, proposalCid)
+	if err != nil {
+		return nil, err
+	}
+	if deal.State != storagemarket.StorageDealActive {
+		return nil, xerrors.Errorf("deal %s is %s, only active deals can be renewed", proposalCid, storagemarket.DealStates[deal.State])
+	}
+	return r.renew(ctx, deal, true)
+}
+
+func (r *DealRenewer) List(ctx context.Context) ([]mtypes.DealRenewal, error) {
+	return r.store.list(ctx)
+}
+
+func (r *DealRenewer) renew(ctx context.Context, deal storagemarket.ClientDeal, force bool) (*mtypes.DealRenewal, error) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	renewal, err := r.store.get(ctx, deal.ProposalCid)
+	if err != nil {
+		if !xerrors.Is(err, repo.ErrNotFound) {
+			return nil, err
+		}
+		renewal = &mtypes.DealRenewal{
+			ProposalCid: deal.ProposalCid,
+			DealID:      deal.DealID,
+			PieceCID:    deal.Proposal.PieceCID,
+			Provider:    deal.Proposal.Provider,
+			EndEpoch:    deal.Proposal.EndEpoch,
+			State:       mtypes.DealRenewalPending,
+			CreatedAt:   time.Now(),
+		}
+		if deal.DataRef != nil {
+			renewal.PayloadCID = deal.DataRef.Root
+		}
+	}
+
+	if renewal.State == mtypes.DealRenewalProposed || (renewal.State == mtypes.DealRenewalFailed && !force) {
+		return renewal, nil
+	}
+
+	for _, c := range r.candidates(ctx, deal) {
+		attempt := r.tryRenew(ctx, deal, c)
+		renewal.Attempts = append(renewal.Attempts, attempt)
+		if len(attempt.Err) == 0 {
+			renewal.State = mtypes.DealRenewalProposed
+			renewal.RenewedProposalCid = attempt.ProposalCid
+			renewal.RenewedProvider = c.miner
+			break
+		}
+		renewalLog.Warnf("renew deal %s with %s: %s", deal.ProposalCid, c.miner, attempt.Err)
+	}
+
+	if renewal.State != mtypes.DealRenewalProposed {
+		renewal.Rounds++
+		if r.cfg.MaxAttempts > 0 && renewal.Rounds >= r.cfg.MaxAttempts {
+			renewal.State = mtypes.DealRenewalFailed
+		} else {
+			renewal.State = mtypes.DealRenewalPending
+		}
+	}
+
+	if err := r.store.save(ctx, renewal); err != nil {
+		return nil, xerrors.Errorf("save renewal of %s: %w", deal.ProposalCid, err)
+	}
+	return renewal, nil
+}
+
+type renewalCandidate struct {
+	miner address.Address
+	ask   *storagemarket.StorageAsk
+	// err is why the ask of the miner can not take the deal
+	err error
+}
+
+// candidates returns the providers to propose the replacement deal to. the original provider goes first, the miners
+// of the config follow from the cheapest ask for the deal, the ones whose ask can not take the deal go last
+func (r *DealRenewer) candidates(ctx context.Context, deal storagemarket.ClientDeal) []renewalCandidate {
+	var out []renewalCandidate
+	if !r.cfg.SkipOriginalProvider || len(r.cfg.Miners) == 0 {
+		ask, err := r.queryAsk(ctx, deal, deal.Proposal.Provider)
+		out = append(out, renewalCandidate{miner: deal.Proposal.Provider, ask: ask, err: err})
+	}
+
+	var others []renewalCandidate
+	for _, miner := range config.ConvertConfigAddress(r.cfg.Miners) {
+		if miner == deal.Proposal.Provider {
+			continue
+		}
+		ask, err := r.queryAsk(ctx, deal, miner)
+		others = append(others, renewalCandidate{miner: miner, ask: ask, err: err})
+	}
+	sort.SliceStable(others, func(i, j int) bool {
+		if others[i].err != nil || others[j].err != nil {
+			return others[j].err != nil && others[i].err == nil
+		}
+		return askPrice(deal, others[i].ask).LessThan(askPrice(deal, others[j].ask))
+	})
+	return append(out, others...)
+}
+
+func (r *DealRenewer) tryRenew(ctx context.Context, deal storagemarket.ClientDeal, c renewalCandidate) mtypes.RenewalAttempt {
+	attempt := mtypes.RenewalAttempt{
+		Provider:  c.miner,
+		CreatedAt: time.Now(),
+	}
+	if c.err != nil {
+		attempt.Err = c.err.Error()
+		return attempt
+	}
+
+	params := r.renewParams(deal, c.miner, c.ask)
+	attempt.TransferType = params.Data.TransferType
+
+	proposalCid, err := r.startDeal(ctx, params)
+	if err != nil {
+		attempt.Err = err.Error()
+		return attempt
+	}
+	attempt.ProposalCid = proposalCid
+	return attempt
+}
+
+// queryAsk returns the ask of the miner, it fails when the ask can not take the piece of the deal
+func (r *DealRenewer) queryAsk(ctx context.Context, deal storagemarket.ClientDeal, miner address.Address) (*storagemarket.StorageAsk, error) {
+	if deal.DataRef == nil {
+		return nil, xerrors.Errorf("deal has no data reference")
+	}
+
+	mi, err := r.api.Full.StateMinerInfo(ctx, miner, vTypes.EmptyTSK)
+	if err != nil {
+		return nil, xerrors.Errorf("get miner info: %w", err)
+	}
+	if mi.PeerId == nil {
+		return nil, xerrors.Errorf("miner has no peer id")
+	}
+
+	ask, err := r.api.ClientQueryAsk(ctx, *mi.PeerId, miner)
+	if err != nil {
+		return nil, xerrors.Errorf("query ask: %w", err)
+	}
+
+	pieceSize := deal.Proposal.PieceSize
+	if pieceSize < ask.MinPieceSize || pieceSize > ask.MaxPieceSize {
+		return nil, xerrors.Errorf("piece size %d out of the range of ask [%d, %d]", pieceSize, ask.MinPieceSize, ask.MaxPieceSize)
+	}
+	return ask, nil
+}
+
+// askPrice is the price per GiB per epoch the ask charges for the deal
+func askPrice(deal storagemarket.ClientDeal, ask *storagemarket.StorageAsk) abi.TokenAmount {
+	if deal.Proposal.VerifiedDeal {
+		return ask.VerifiedPrice
+	}
+	return ask.Price
+}
+
+func (r *DealRenewer) renewParams(deal storagemarket.ClientDeal, miner address.Address, ask *storagemarket.StorageAsk) *types.StartDealParams {
+	pieceSize := deal.Proposal.PieceSize
+	epochPrice := big.Div(big.Mul(askPrice(deal, ask), big.NewInt(int64(pieceSize))), big.NewInt(1<<30))
+
+	duration := r.cfg.DealDuration
+	if duration == 0 {
+		duration = uint64(deal.Proposal.Duration())
+	}
+
+	pieceCid := deal.Proposal.PieceCID
+	data := &storagemarket.DataRef{
+		Root:      deal.DataRef.Root,
+		PieceCid:  &pieceCid,
+		PieceSize: pieceSize.Unpadded(),
+	}
+	// the original provider already holds the data, no need to transfer it again
+	if miner == deal.Proposal.Provider {
+		data.TransferType = storagemarket.TTManual
+	} else {
+		data.TransferType = storagemarket.TTGraphsync
+	}
+
+	return &types.StartDealParams{
+		Data:               data,
+		Wallet:             deal.Proposal.Client,
+		Miner:              miner,
+		EpochPrice:         epochPrice,
+		MinBlocksDuration:  duration,
+		ProviderCollateral: big.Zero(),
+		FastRetrieval:      deal.FastRetrieval,
+		VerifiedDeal:       deal.Proposal.VerifiedDeal,
+	}
+}
@@ -0,0 +1,233 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blocksutil "github.com/ipfs/go-ipfs-blocksutil"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/test"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/venus-market/config"
+	mtypes "github.com/filecoin-project/venus-market/types"
+	"github.com/filecoin-project/venus-market/utils/test_helper"
+
+	"github.com/filecoin-project/venus/pkg/testhelpers"
+	"github.com/filecoin-project/venus/venus-shared/actors/builtin/miner"
+	v1api "github.com/filecoin-project/venus/venus-shared/api/chain/v1"
+	vTypes "github.com/filecoin-project/venus/venus-shared/types"
+	types "github.com/filecoin-project/venus/venus-shared/types/market/client"
+)
+
+type fakeRenewalFull struct {
+	v1api.FullNode
+	t       *testing.T
+	height  abi.ChainEpoch
+	headErr error
+	peer    peer.ID
+}
+
+func (f *fakeRenewalFull) ChainHead(context.Context) (*vTypes.TipSet, error) {
+	if f.headErr != nil {
+		return nil, f.headErr
+	}
+	blk := test_helper.MakeTestBlock(f.t)
+	blk.Height = f.height
+	return testhelpers.RequireNewTipSet(f.t, blk), nil
+}
+
+func (f *fakeRenewalFull) StateMinerInfo(context.Context, address.Address, vTypes.TipSetKey) (miner.MinerInfo, error) {
+	p := f.peer
+	return miner.MinerInfo{PeerId: &p}, nil
+}
+
+// fakeRenewalClient has the local deals of the client and the asks of the providers, a provider without ask fails
+type fakeRenewalClient struct {
+	storagemarket.StorageClient
+	deals []storagemarket.ClientDeal
+	asks  map[address.Address]*storagemarket.StorageAsk
+}
+
+func (c *fakeRenewalClient) ListLocalDeals(context.Context) ([]storagemarket.ClientDeal, error) {
+	return c.deals, nil
+}
+
+func (c *fakeRenewalClient) GetLocalDeal(_ context.Context, proposalCid cid.Cid) (storagemarket.ClientDeal, error) {
+	for _, deal := range c.deals {
+		if deal.ProposalCid == proposalCid {
+			return deal, nil
+		}
+	}
+	return storagemarket.ClientDeal{}, xerrors.Errorf("deal %s not found", proposalCid)
+}
+
+func (c *fakeRenewalClient) GetAsk(_ context.Context, info storagemarket.StorageProviderInfo) (*storagemarket.StorageAsk, error) {
+	ask, ok := c.asks[info.Address]
+	if !ok {
+		return nil, xerrors.Errorf("%s is offline", info.Address)
+	}
+	return ask, nil
+}
+
+func TestDealRenewer(t *testing.T) {
+	ctx := context.Background()
+	bgen := blocksutil.NewBlockGenerator()
+	clientAddr, _ := address.NewIDAddress(100)
+	provider, _ := address.NewIDAddress(1000)
+	other, _ := address.NewIDAddress(1001)
+
+	full := &fakeRenewalFull{t: t, height: 10_000, peer: test.RandPeerIDFatal(t)}
+	sc := &fakeRenewalClient{asks: map[address.Address]*storagemarket.StorageAsk{
+		provider: {Price: big.NewInt(1 << 30), VerifiedPrice: big.Zero(), MinPieceSize: 256, MaxPieceSize: 1 << 20},
+	}}
+	newDeal := func(dealID abi.DealID, state storagemarket.StorageDealStatus, end abi.ChainEpoch) storagemarket.ClientDeal {
+		deal := storagemarket.ClientDeal{
+			ProposalCid: bgen.Next().Cid(),
+			State:       state,
+			DealID:      dealID,
+			DataRef:     &storagemarket.DataRef{Root: bgen.Next().Cid()},
+		}
+		deal.Proposal.PieceCID = bgen.Next().Cid()
+		deal.Proposal.PieceSize = 2048
+		deal.Proposal.Client = clientAddr
+		deal.Proposal.Provider = provider
+		deal.Proposal.StartEpoch = end - 1000
+		deal.Proposal.EndEpoch = end
+		sc.deals = append(sc.deals, deal)
+		return deal
+	}
+
+	expiring := newDeal(1, storagemarket.StorageDealActive, full.height+50)
+	// out of the renewal window
+	newDeal(2, storagemarket.StorageDealActive, full.height+500)
+	// not active
+	newDeal(3, storagemarket.StorageDealSealing, full.height+50)
+	// not published
+	newDeal(0, storagemarket.StorageDealActive, full.height+50)
+	// expired already
+	newDeal(4, storagemarket.StorageDealActive, full.height-10)
+
+	var started []*types.StartDealParams
+	var startErr error
+	r := newDealRenewer(&API{Full: full, SMDealClient: sc}, dssync.MutexWrap(datastore.NewMapDatastore()), config.DealRenewal{
+		RenewBeforeEpochs: 100,
+		Miners:            []config.Address{config.Address(other)},
+		MaxAttempts:       2,
+	})
+	r.startDeal = func(_ context.Context, params *types.StartDealParams) (*cid.Cid, error) {
+		if startErr != nil {
+			return nil, startErr
+		}
+		started = append(started, params)
+		proposalCid := bgen.Next().Cid()
+		return &proposalCid, nil
+	}
+
+	// the deal about to expire is renewed with its provider, which holds the data already
+	require.NoError(t, r.renewExpiringDeals(ctx))
+	require.Len(t, started, 1)
+	require.Equal(t, provider, started[0].Miner)
+	require.Equal(t, clientAddr, started[0].Wallet)
+	require.Equal(t, storagemarket.TTManual, started[0].Data.TransferType)
+	require.Equal(t, expiring.Proposal.PieceCID, *started[0].Data.PieceCid)
+	require.Equal(t, big.NewInt(2048), started[0].EpochPrice)
+	require.Equal(t, uint64(1000), started[0].MinBlocksDuration)
+
+	renewals, err := r.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, renewals, 1)
+	require.Equal(t, expiring.ProposalCid, renewals[0].ProposalCid)
+	require.Equal(t, mtypes.DealRenewalProposed, renewals[0].State)
+	require.Equal(t, provider, renewals[0].RenewedProvider)
+
+	// a proposed renewal is not proposed again
+	require.NoError(t, r.renewExpiringDeals(ctx))
+	require.Len(t, started, 1)
+
+	// every provider fails, the renewal is tried again until it runs out of attempts
+	failing := newDeal(5, storagemarket.StorageDealActive, full.height+50)
+	startErr = xerrors.New("deal rejected")
+	for round := 1; round <= 3; round++ {
+		require.NoError(t, r.renewExpiringDeals(ctx))
+	}
+	renewal, err := r.store.get(ctx, failing.ProposalCid)
+	require.NoError(t, err)
+	require.Equal(t, mtypes.DealRenewalFailed, renewal.State)
+	require.Equal(t, 2, renewal.Rounds)
+	// the original provider and the other miner in every round
+	require.Len(t, renewal.Attempts, 4)
+	require.Equal(t, "deal rejected", renewal.Attempts[0].Err)
+	require.Equal(t, other, renewal.Attempts[1].Provider)
+	require.Contains(t, renewal.Attempts[1].Err, "offline")
+
+	// a manual renewal retries a failed one, the other miner gets the data transferred
+	startErr = nil
+	delete(sc.asks, provider)
+	sc.asks[other] = &storagemarket.StorageAsk{Price: big.Zero(), VerifiedPrice: big.Zero(), MinPieceSize: 256, MaxPieceSize: 1 << 20}
+	renewal, err = r.Renew(ctx, failing.ProposalCid)
+	require.NoError(t, err)
+	require.Equal(t, mtypes.DealRenewalProposed, renewal.State)
+	require.Equal(t, other, renewal.RenewedProvider)
+	require.Equal(t, storagemarket.TTGraphsync, started[1].Data.TransferType)
+
+	// only active deals are renewed by hand
+	_, err = r.Renew(ctx, sc.deals[2].ProposalCid)
+	require.Error(t, err)
+
+	// the chain head is needed to find the expiring deals
+	full.headErr = xerrors.New("daemon down")
+	require.Error(t, r.renewExpiringDeals(ctx))
+}
+
+func TestRenewalCandidates(t *testing.T) {
+	ctx := context.Background()
+	provider, _ := address.NewIDAddress(1000)
+	offline, _ := address.NewIDAddress(1001)
+	expensive, _ := address.NewIDAddress(1002)
+	cheap, _ := address.NewIDAddress(1003)
+	tooSmall, _ := address.NewIDAddress(1004)
+
+	ask := func(price, verifiedPrice int64, maxPieceSize abi.PaddedPieceSize) *storagemarket.StorageAsk {
+		return &storagemarket.StorageAsk{Price: big.NewInt(price), VerifiedPrice: big.NewInt(verifiedPrice), MinPieceSize: 256, MaxPieceSize: maxPieceSize}
+	}
+	sc := &fakeRenewalClient{asks: map[address.Address]*storagemarket.StorageAsk{
+		provider:  ask(300, 300, 1<<20),
+		expensive: ask(200, 0, 1<<20),
+		cheap:     ask(100, 10, 1<<20),
+		tooSmall:  ask(1, 1, 1024),
+	}}
+	full := &fakeRenewalFull{t: t, peer: test.RandPeerIDFatal(t)}
+	r := newDealRenewer(&API{Full: full, SMDealClient: sc}, dssync.MutexWrap(datastore.NewMapDatastore()), config.DealRenewal{
+		Miners: []config.Address{config.Address(offline), config.Address(tooSmall), config.Address(expensive), config.Address(cheap)},
+	})
+
+	deal := storagemarket.ClientDeal{DataRef: &storagemarket.DataRef{Root: blocksutil.NewBlockGenerator().Next().Cid()}}
+	deal.Proposal.PieceSize = 2048
+	deal.Proposal.Provider = provider
+	miners := func() []address.Address {
+		var out []address.Address
+		for _, c := range r.candidates(ctx, deal) {
+			out = append(out, c.miner)
+		}
+		return out
+	}
+
+	// the original provider goes first whatever its price, the others from the cheapest ask
+	require.Equal(t, []address.Address{provider, cheap, expensive, offline, tooSmall}, miners())
+
+	// a verified deal is ranked by the verified price
+	deal.Proposal.VerifiedDeal = true
+	require.Equal(t, []address.Address{provider, expensive, cheap, offline, tooSmall}, miners())
+
+	r.cfg.SkipOriginalProvider = true
+	require.Equal(t, []address.Address{expensive, cheap, offline, tooSmall}, miners())
+}
@@ -237,4 +237,6 @@ var MarketClientOpts = builder.Options(
 	builder.Override(new(retrievalmarket.BlockstoreAccessor), RetrievalBlockstoreAccessor),
 	builder.Override(new(retrievalmarket.RetrievalClient), RetrievalClient),
 	builder.Override(new(storagemarket.StorageClient), StorageClient),
+	builder.Override(new(*DealRenewer), NewDealRenewer),
+	builder.Override(new(*RetrievalFailover), NewRetrievalFailover),
 )
@@ -0,0 +1,378 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	gobig "math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	logging "github.com/ipfs/go-log/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/filecoin-project/go-state-types/big"
+
+	"github.com/filecoin-project/venus-market/config"
+	"github.com/filecoin-project/venus-market/models/badger"
+	"github.com/filecoin-project/venus-market/models/repo"
+	"github.com/filecoin-project/venus-market/retrievalprovider"
+	mtypes "github.com/filecoin-project/venus-market/types"
+
+	vTypes "github.com/filecoin-project/venus/venus-shared/types"
+	types "github.com/filecoin-project/venus/venus-shared/types/market/client"
+)
+
+var failoverLog = logging.Logger("retrieval-failover")
+
+var errRetrievalStalled = xerrors.New("retrieval stalled")
+
+// weights of the terms of the offer score
+const (
+	successRateWeight = 0.5
+	priceWeight       = 0.3
+	latencyWeight     = 0.2
+)
+
+type reputationStore struct {
+	ds badger.RetrievalReputationDS
+	lk sync.Mutex
+}
+
+func (s *reputationStore) get(ctx context.Context, provider address.Address) (mtypes.ProviderReputation, error) {
+	data, err := s.ds.Get(ctx, datastore.NewKey(provider.String()))
+	if err != nil {
+		if xerrors.Is(err, repo.ErrNotFound) {
+			return mtypes.ProviderReputation{Provider: provider}, nil
+		}
+		return mtypes.ProviderReputation{}, err
+	}
+	var r mtypes.ProviderReputation
+	err = json.Unmarshal(data, &r)
+	return r, err
+}
+
+func (s *reputationStore) update(ctx context.Context, provider address.Address, cb func(r *mtypes.ProviderReputation)) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	r, err := s.get(ctx, provider)
+	if err != nil {
+		return err
+	}
+	cb(&r)
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return s.ds.Put(ctx, datastore.NewKey(provider.String()), data)
+}
+
+func (s *reputationStore) list(ctx context.Context) ([]mtypes.ProviderReputation, error) {
+	res, err := s.ds.Query(ctx, dsq.Query{})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close() //nolint:errcheck
+
+	var out []mtypes.ProviderReputation
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		var rep mtypes.ProviderReputation
+		if err := json.Unmarshal(r.Value, &rep); err != nil {
+			return nil, err
+		}
+		out = append(out, rep)
+	}
+	return out, nil
+}
+
+// RetrievalFailover retrieves data from the best ranked provider, and fails over to the next one when the
+// provider errors or the transfer stalls. the blockstore of the provider taking over starts with the blocks received
+// by the failed attempts. the outcome of every attempt feeds the reputation of the provider.
+type RetrievalFailover struct {
+	api   *API
+	store *reputationStore
+	cfg   config.RetrievalFailover
+}
+
+func NewRetrievalFailover(api API, ds badger.RetrievalReputationDS, cfg *config.MarketClientConfig) *RetrievalFailover {
+	return &RetrievalFailover{
+		api:   &api,
+		store: &reputationStore{ds: ds},
+		cfg:   cfg.RetrievalFailover,
+	}
+}
+
+func (f *RetrievalFailover) ListReputations(ctx context.Context) ([]mtypes.ProviderReputation, error) {
+	return f.store.list(ctx)
+}
+
+type rankedOffer struct {
+	offer   types.QueryOffer
+	latency time.Duration
+	score   float64
+}
+
+func (f *RetrievalFailover) Retrieve(ctx context.Context, order mtypes.RetrievalFailoverOrder) (*mtypes.RetrievalFailoverResult, error) {
+	sel, err := getDataSelector(order.DataSelector, false)
+	if err != nil {
+		return nil, err
+	}
+
+	offers, err := f.queryOffers(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+	if len(offers) == 0 {
+		return nil, xerrors.Errorf("no provider offers %s within the max price", order.Root)
+	}
+
+	reputations := make(map[address.Address]mtypes.ProviderReputation, len(offers))
+	for _, o := range offers {
+		if reputations[o.offer.MinerPeer.Address], err = f.store.get(ctx, o.offer.MinerPeer.Address); err != nil {
+			return nil, err
+		}
+	}
+	offers = rankOffers(offers, reputations)
+	if f.cfg.MaxAttempts > 0 && len(offers) > f.cfg.MaxAttempts {
+		offers = offers[:f.cfg.MaxAttempts]
+	}
+
+	carAccessor, _ := f.api.RtvlBlockstoreAccessor.(*retrievalprovider.CARBlockstoreAccessor)
+
+	res := &mtypes.RetrievalFailoverResult{}
+	var prevDeals []retrievalmarket.DealID
+	for _, o := range offers {
+		provider := o.offer.MinerPeer.Address
+		attempt := mtypes.RetrievalFailoverAttempt{
+			Provider:     provider,
+			Price:        o.offer.MinPrice,
+			QueryLatency: o.latency,
+			Score:        o.score,
+		}
+
+		id := f.api.Retrieval.NextID()
+		attempt.DealID = id
+		if carAccessor != nil && len(prevDeals) > 0 {
+			carAccessor.Seed(id, prevDeals)
+		}
+
+		ro := o.offer.Order(order.Client)
+		ro.DataSelector = order.DataSelector
+		if _, err = f.api.doRetrievalWithID(ctx, id, ro, sel); err == nil {
+			attempt.BytesReceived, err = f.wait(ctx, id)
+		}
+
+		if rerr := f.store.update(ctx, provider, func(r *mtypes.ProviderReputation) {
+			r.BytesReceived += attempt.BytesReceived
+			if err == nil {
+				r.Successes++
+				r.LastSuccess = time.Now()
+			} else {
+				r.Failures++
+				r.LastFailure = time.Now()
+				r.LastError = err.Error()
+			}
+		}); rerr != nil {
+			failoverLog.Errorf("update reputation of %s: %s", provider, rerr)
+		}
+
+		if err == nil {
+			res.Attempts = append(res.Attempts, attempt)
+			res.DealID = id
+			res.Provider = provider
+			return res, nil
+		}
+
+		attempt.Err = err.Error()
+		res.Attempts = append(res.Attempts, attempt)
+		prevDeals = append(prevDeals, id)
+		failoverLog.Warnf("retrieve %s from %s failed, try next provider: %s", order.Root, provider, err)
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	// the error is carried in the result, an rpc error would drop the report of the attempts
+	res.Err = fmt.Sprintf("all %d providers failed to retrieve %s", len(res.Attempts), order.Root)
+	return res, nil
+}
+
+func (f *RetrievalFailover) queryOffers(ctx context.Context, order mtypes.RetrievalFailoverOrder) ([]rankedOffer, error) {
+	peers, err := f.api.RetDiscovery.GetPeers(order.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	providers := make([]address.Address, 0, len(peers)+len(order.Providers))
+	seen := map[address.Address]struct{}{}
+	for _, p := range peers {
+		if order.Piece != nil && (p.PieceCID == nil || !order.Piece.Equals(*p.PieceCID)) {
+			continue
+		}
+		if _, ok := seen[p.Address]; !ok {
+			seen[p.Address] = struct{}{}
+			providers = append(providers, p.Address)
+		}
+	}
+	for _, p := range order.Providers {
+		if _, ok := seen[p]; !ok {
+			seen[p] = struct{}{}
+			providers = append(providers, p)
+		}
+	}
+
+	var (
+		lk  sync.Mutex
+		wg  sync.WaitGroup
+		out []rankedOffer
+	)
+	for _, provider := range providers {
+		wg.Add(1)
+		go func(provider address.Address) {
+			defer wg.Done()
+
+			mi, err := f.api.Full.StateMinerInfo(ctx, provider, vTypes.EmptyTSK)
+			if err != nil || mi.PeerId == nil {
+				failoverLog.Warnf("get peer of %s: %v", provider, err)
+				return
+			}
+			rp := retrievalmarket.RetrievalPeer{Address: provider, ID: *mi.PeerId}
+
+			start := time.Now()
+			offer := f.api.makeRetrievalQuery(ctx, rp, order.Root, order.Piece, retrievalmarket.QueryParams{PieceCID: order.Piece})
+			latency := time.Since(start)
+			if offer.Err != "" {
+				failoverLog.Warnf("query %s for %s: %s", provider, order.Root, offer.Err)
+				return
+			}
+			if !order.MaxPrice.Nil() && !order.MaxPrice.IsZero() && offer.MinPrice.GreaterThan(order.MaxPrice) {
+				return
+			}
+
+			lk.Lock()
+			out = append(out, rankedOffer{offer: offer, latency: latency})
+			lk.Unlock()
+		}(provider)
+	}
+	wg.Wait()
+
+	return out, nil
+}
+
+// rankOffers scores the offers by the success rate of the provider, the price and the query latency,
+// and sorts them from the best to the worst
+func rankOffers(offers []rankedOffer, reputations map[address.Address]mtypes.ProviderReputation) []rankedOffer {
+	maxPrice := big.Zero()
+	var maxLatency time.Duration
+	for _, o := range offers {
+		if o.offer.MinPrice.GreaterThan(maxPrice) {
+			maxPrice = o.offer.MinPrice
+		}
+		if o.latency > maxLatency {
+			maxLatency = o.latency
+		}
+	}
+
+	for i := range offers {
+		o := &offers[i]
+		priceScore := 1.0
+		if !maxPrice.IsZero() {
+			priceScore = 1 - bigRatio(o.offer.MinPrice, maxPrice)
+		}
+		latencyScore := 1.0
+		if maxLatency > 0 {
+			latencyScore = 1 - float64(o.latency)/float64(maxLatency)
+		}
+		o.score = successRateWeight*reputations[o.offer.MinerPeer.Address].SuccessRate() +
+			priceWeight*priceScore +
+			latencyWeight*latencyScore
+	}
+
+	sort.SliceStable(offers, func(i, j int) bool {
+		return offers[i].score > offers[j].score
+	})
+	return offers
+}
+
+func bigRatio(a, b big.Int) float64 {
+	fa, _ := new(gobig.Float).SetInt(a.Int).Float64()
+	fb, _ := new(gobig.Float).SetInt(b.Int).Float64()
+	return fa / fb
+}
+
+// wait blocks until the retrieval completes, fails, or receives no data for the stall timeout
+func (f *RetrievalFailover) wait(ctx context.Context, id retrievalmarket.DealID) (uint64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stallTimeout := time.Duration(f.cfg.StallTimeout)
+	if stallTimeout <= 0 {
+		stallTimeout = 5 * time.Minute
+	}
+
+	events := make(chan retrievalmarket.ClientDealState, 16)
+	unsubscribe := f.api.Retrieval.SubscribeToEvents(func(_ retrievalmarket.ClientEvent, state retrievalmarket.ClientDealState) {
+		if state.ID != id {
+			return
+		}
+		select {
+		case <-ctx.Done():
+		case events <- state:
+		}
+	})
+	defer unsubscribe()
+
+	if state, err := f.api.Retrieval.GetDeal(id); err == nil {
+		select {
+		case events <- state:
+		default:
+		}
+	}
+
+	var received uint64
+	stall := time.NewTimer(stallTimeout)
+	defer stall.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return received, ctx.Err()
+		case <-stall.C:
+			if err := f.api.Retrieval.CancelDeal(id); err != nil {
+				failoverLog.Warnf("cancel stalled retrieval %d: %s", id, err)
+			}
+			return received, xerrors.Errorf("%w: no data for %s after %d bytes", errRetrievalStalled, stallTimeout, received)
+		case state := <-events:
+			if state.TotalReceived > received {
+				received = state.TotalReceived
+				if !stall.Stop() {
+					select {
+					case <-stall.C:
+					default:
+					}
+				}
+				stall.Reset(stallTimeout)
+			}
+
+			switch state.Status {
+			case retrievalmarket.DealStatusCompleted:
+				return received, nil
+			case retrievalmarket.DealStatusRejected:
+				return received, xerrors.Errorf("retrieval proposal rejected: %s", state.Message)
+			case retrievalmarket.DealStatusCancelled:
+				return received, xerrors.Errorf("retrieval was cancelled externally: %s", state.Message)
+			case retrievalmarket.DealStatusDealNotFound, retrievalmarket.DealStatusErrored:
+				return received, xerrors.Errorf("retrieval error: %s", state.Message)
+			}
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	blocksutil "github.com/ipfs/go-ipfs-blocksutil"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/test"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/venus-market/config"
+	"github.com/filecoin-project/venus-market/models"
+	"github.com/filecoin-project/venus-market/retrievalprovider"
+	mtypes "github.com/filecoin-project/venus-market/types"
+
+	"github.com/filecoin-project/venus/venus-shared/actors/builtin/miner"
+	v1api "github.com/filecoin-project/venus/venus-shared/api/chain/v1"
+	vTypes "github.com/filecoin-project/venus/venus-shared/types"
+	types "github.com/filecoin-project/venus/venus-shared/types/market/client"
+)
+
+func TestRankOffers(t *testing.T) {
+	newOffer := func(id uint64, price int64, latency time.Duration) rankedOffer {
+		addr, err := address.NewIDAddress(id)
+		require.NoError(t, err)
+		return rankedOffer{
+			offer: types.QueryOffer{
+				MinPrice:  big.NewInt(price),
+				MinerPeer: retrievalmarket.RetrievalPeer{Address: addr},
+			},
+			latency: latency,
+		}
+	}
+	addr := func(id uint64) address.Address {
+		a, _ := address.NewIDAddress(id)
+		return a
+	}
+
+	t.Run("without history the cheaper and faster offer wins", func(t *testing.T) {
+		offers := rankOffers([]rankedOffer{
+			newOffer(1, 100, time.Second),
+			newOffer(2, 50, time.Second),
+			newOffer(3, 50, 100*time.Millisecond),
+		}, map[address.Address]mtypes.ProviderReputation{})
+
+		require.Equal(t, addr(3), offers[0].offer.MinerPeer.Address)
+		require.Equal(t, addr(2), offers[1].offer.MinerPeer.Address)
+		require.Equal(t, addr(1), offers[2].offer.MinerPeer.Address)
+	})
+
+	t.Run("unreliable providers fall behind", func(t *testing.T) {
+		offers := rankOffers([]rankedOffer{
+			newOffer(1, 60, time.Second),
+			newOffer(2, 50, time.Second),
+		}, map[address.Address]mtypes.ProviderReputation{
+			addr(1): {Successes: 20},
+			addr(2): {Failures: 20},
+		})
+
+		require.Equal(t, addr(1), offers[0].offer.MinerPeer.Address)
+		require.Greater(t, offers[0].score, offers[1].score)
+	})
+}
+
+type fakeFailoverFull struct {
+	v1api.FullNode
+	peers map[address.Address]peer.ID
+}
+
+func (f *fakeFailoverFull) StateMinerInfo(_ context.Context, addr address.Address, _ vTypes.TipSetKey) (miner.MinerInfo, error) {
+	p := f.peers[addr]
+	return miner.MinerInfo{PeerId: &p}, nil
+}
+
+type fakePeerResolver struct {
+	peers []retrievalmarket.RetrievalPeer
+}
+
+func (f *fakePeerResolver) GetPeers(cid.Cid) ([]retrievalmarket.RetrievalPeer, error) {
+	return f.peers, nil
+}
+
+// fakeRetrievalClient serves the blocks into the blockstore of the accessor, a provider serving fewer blocks than the
+// payload has stalls
+type fakeRetrievalClient struct {
+	retrievalmarket.RetrievalClient
+	accessor *retrievalprovider.CARBlockstoreAccessor
+	blks     []blocks.Block
+	prices   map[address.Address]int64
+	serves   map[address.Address]int
+	// present are the blocks found in the blockstore of a deal when it starts
+	present map[retrievalmarket.DealID]int
+
+	lk     sync.Mutex
+	nextID retrievalmarket.DealID
+	states map[retrievalmarket.DealID]retrievalmarket.ClientDealState
+}
+
+func (f *fakeRetrievalClient) NextID() retrievalmarket.DealID {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	f.nextID++
+	return f.nextID
+}
+
+func (f *fakeRetrievalClient) Query(_ context.Context, p retrievalmarket.RetrievalPeer, _ cid.Cid, _ retrievalmarket.QueryParams) (retrievalmarket.QueryResponse, error) {
+	return retrievalmarket.QueryResponse{
+		Status:          retrievalmarket.QueryResponseAvailable,
+		Size:            uint64(len(f.blks)),
+		PaymentAddress:  p.Address,
+		MinPricePerByte: big.NewInt(f.prices[p.Address]),
+		UnsealPrice:     big.Zero(),
+	}, nil
+}
+
+func (f *fakeRetrievalClient) Retrieve(ctx context.Context, id retrievalmarket.DealID, payloadCID cid.Cid, _ retrievalmarket.Params, _ abi.TokenAmount, p retrievalmarket.RetrievalPeer, _ address.Address, _ address.Address) (retrievalmarket.DealID, error) {
+	bs, err := f.accessor.Get(id, payloadCID)
+	if err != nil {
+		return 0, err
+	}
+	present := 0
+	for _, blk := range f.blks {
+		if has, err := bs.Has(ctx, blk.Cid()); err == nil && has {
+			present++
+		}
+	}
+
+	state := retrievalmarket.ClientDealState{DealProposal: retrievalmarket.DealProposal{ID: id}, Status: retrievalmarket.DealStatusOngoing}
+	for _, blk := range f.blks[:f.serves[p.Address]] {
+		if err := bs.Put(ctx, blk); err != nil {
+			return 0, err
+		}
+		state.TotalReceived += uint64(len(blk.RawData()))
+	}
+	if f.serves[p.Address] == len(f.blks) {
+		state.Status = retrievalmarket.DealStatusCompleted
+	}
+
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	f.present[id] = present
+	f.states[id] = state
+	return id, nil
+}
+
+func (f *fakeRetrievalClient) SubscribeToEvents(retrievalmarket.ClientSubscriber) retrievalmarket.Unsubscribe {
+	return func() {}
+}
+
+func (f *fakeRetrievalClient) GetDeal(id retrievalmarket.DealID) (retrievalmarket.ClientDealState, error) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	state, ok := f.states[id]
+	if !ok {
+		return state, xerrors.Errorf("deal %d not found", id)
+	}
+	return state, nil
+}
+
+func (f *fakeRetrievalClient) CancelDeal(retrievalmarket.DealID) error {
+	return nil
+}
+
+func TestRetrievalFailover(t *testing.T) {
+	ctx := context.Background()
+	stalling, _ := address.NewIDAddress(1000)
+	completing, _ := address.NewIDAddress(1001)
+	clientAddr, _ := address.NewIDAddress(100)
+
+	bgen := blocksutil.NewBlockGenerator()
+	var blks []blocks.Block
+	for i := 0; i < 5; i++ {
+		blks = append(blks, bgen.Next())
+	}
+
+	accessor := retrievalprovider.NewCARBlockstoreAccessor(t.TempDir())
+	retrieval := &fakeRetrievalClient{
+		accessor: accessor,
+		blks:     blks,
+		// the stalling provider is cheaper so it is tried first
+		prices:  map[address.Address]int64{stalling: 1, completing: 100},
+		serves:  map[address.Address]int{stalling: 2, completing: len(blks)},
+		present: make(map[retrievalmarket.DealID]int),
+		states:  make(map[retrievalmarket.DealID]retrievalmarket.ClientDealState),
+	}
+	full := &fakeFailoverFull{peers: map[address.Address]peer.ID{
+		stalling:   test.RandPeerIDFatal(t),
+		completing: test.RandPeerIDFatal(t),
+	}}
+	resolver := &fakePeerResolver{peers: []retrievalmarket.RetrievalPeer{
+		{Address: stalling, ID: full.peers[stalling]},
+		{Address: completing, ID: full.peers[completing]},
+	}}
+
+	f := &RetrievalFailover{
+		api: &API{
+			Full:                   full,
+			RetDiscovery:           resolver,
+			Retrieval:              retrieval,
+			RtvlBlockstoreAccessor: accessor,
+		},
+		store: &reputationStore{ds: models.BadgerDB(t)},
+		cfg:   config.RetrievalFailover{StallTimeout: config.Duration(100 * time.Millisecond)},
+	}
+
+	res, err := f.Retrieve(ctx, mtypes.RetrievalFailoverOrder{Root: blks[0].Cid(), Client: clientAddr, MaxPrice: big.Zero()})
+	require.NoError(t, err)
+	require.Empty(t, res.Err)
+	require.Equal(t, completing, res.Provider)
+	require.Len(t, res.Attempts, 2)
+	require.Equal(t, stalling, res.Attempts[0].Provider)
+	require.Contains(t, res.Attempts[0].Err, errRetrievalStalled.Error())
+	require.Equal(t, uint64(len(blks[0].RawData())+len(blks[1].RawData())), res.Attempts[0].BytesReceived)
+
+	// the provider taking over starts with the blocks received from the stalled one
+	require.Equal(t, 0, retrieval.present[res.Attempts[0].DealID])
+	require.Equal(t, 2, retrieval.present[res.DealID])
+
+	reputations, err := f.ListReputations(ctx)
+	require.NoError(t, err)
+	require.Len(t, reputations, 2)
+
+	// every provider stalls, the report of the attempts comes with the error
+	retrieval.serves[completing] = 1
+	res, err = f.Retrieve(ctx, mtypes.RetrievalFailoverOrder{Root: blks[0].Cid(), Client: clientAddr, MaxPrice: big.Zero()})
+	require.NoError(t, err)
+	require.NotEmpty(t, res.Err)
+	require.Len(t, res.Attempts, 2)
+	for _, attempt := range res.Attempts {
+		require.NotEmpty(t, attempt.Err)
+	}
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/filecoin-project/venus-market/api"
 	cli2 "github.com/filecoin-project/venus-market/cli"
+	mtypes "github.com/filecoin-project/venus-market/types"
 	types2 "github.com/filecoin-project/venus/venus-shared/types"
 	"github.com/filecoin-project/venus/venus-shared/types/market/client"
 
@@ -69,6 +70,47 @@ func retrieve(ctx context.Context, cctx *cli.Context, fapi api.MarketClientNode,
 		}
 	}
 
+	// no local found, let the node pick the providers
+	if eref == nil && cctx.Bool("failover") && cctx.String("provider") == "" {
+		order := mtypes.RetrievalFailoverOrder{
+			Root:         file,
+			Piece:        pieceCid,
+			DataSelector: sel,
+			Client:       payer,
+			MaxPrice:     big.Zero(),
+		}
+		if cctx.String("maxPrice") != "" {
+			maxPrice, err := types2.ParseFIL(cctx.String("maxPrice"))
+			if err != nil {
+				return nil, xerrors.Errorf("parsing maxPrice: %w", err)
+			}
+			order.MaxPrice = big.Int(maxPrice)
+		}
+
+		res, err := fapi.ClientRetrieveWithFailover(ctx, order)
+		if res != nil {
+			for _, a := range res.Attempts {
+				state := "ok"
+				if a.Err != "" {
+					state = a.Err
+				}
+				printf("%s: score %.3f, price %s, recv %s, %s\n", a.Provider, a.Score, types2.FIL(a.Price),
+					types2.SizeStr(types2.NewInt(a.BytesReceived)), state)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if res.Err != "" {
+			return nil, xerrors.New(res.Err)
+		}
+
+		eref = &client.ExportRef{
+			Root:   file,
+			DealID: res.DealID,
+		}
+	}
+
 	// no local found, so make a retrieval
 	if eref == nil {
 		var offer client.QueryOffer
@@ -211,6 +253,10 @@ var retrFlagsCommon = []cli.Flag{
 		Name: "allow-local",
 		// todo: default to true?
 	},
+	&cli.BoolFlag{
+		Name:  "failover",
+		Usage: "rank the providers by price, latency and success rate, and fail over to the next one on error",
+	},
 }
 
 var clientRetrieveCmd = &cli.Command{
@@ -6,11 +6,15 @@ import (
 	"sort"
 	"strconv"
 
+	"github.com/docker/go-units"
 	"github.com/ipfs/go-cid"
 	"github.com/urfave/cli/v2"
 	"golang.org/x/xerrors"
 
+	"github.com/filecoin-project/go-state-types/abi"
+
 	cli2 "github.com/filecoin-project/venus-market/cli"
+	client2 "github.com/filecoin-project/venus-market/client"
 	"github.com/filecoin-project/venus/venus-shared/types"
 	"github.com/filecoin-project/venus/venus-shared/types/market/client"
 )
@@ -26,6 +30,7 @@ var dataCmd = &cli.Command{
 		dataStatCmd,
 		dataCommPCmd,
 		dataGenerateCarCmd,
+		dataPrepareCmd,
 	},
 }
 
@@ -261,3 +266,50 @@ var dataGenerateCarCmd = &cli.Command{
 		return nil
 	},
 }
+
+var dataPrepareCmd = &cli.Command{
+	Name:  "prepare",
+	Usage: "Pack files into cars fitting the target piece size, and write a manifest for offline deals",
+	Description: `Walk the input path and pack the files into cars, files larger than a car are split into ranges.
+The commP of every car is calculated, and the manifest.json in the output dir maps every file to its car,
+root cid, piece cid and piece size. Run the command again with the same arguments to resume an interrupted run.`,
+	ArgsUsage: "<inputPath> <outputDir>",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "target-size",
+			Usage: "padded piece size each car has to fit in, eg. 32GiB",
+			Value: "32GiB",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 2 {
+			return fmt.Errorf("usage: prepare <inputPath> <outputDir>")
+		}
+		ctx := cli2.ReqContext(cctx)
+
+		size, err := units.RAMInBytes(cctx.String("target-size"))
+		if err != nil {
+			return xerrors.Errorf("parse target size: %w", err)
+		}
+
+		params := client2.PrepareParams{
+			InputPath:       cctx.Args().Get(0),
+			OutputDir:       cctx.Args().Get(1),
+			TargetPieceSize: abi.PaddedPieceSize(size),
+		}
+		manifest, err := client2.PrepareData(ctx, params, func(car *client2.PreparedCar, skipped bool) {
+			state := "generated"
+			if skipped {
+				state = "exists"
+			}
+			fmt.Printf("%s %s: root %s, piece %s, piece size %d (%s), %d files\n", car.CarPath, state, car.RootCid,
+				car.PieceCid, car.UnpaddedPieceSize, types.SizeStr(types.NewInt(uint64(car.PieceSize))), len(car.Files))
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("prepared %d cars, manifest: %s\n", len(manifest.Cars), filepath.Join(params.OutputDir, client2.PrepareManifestName))
+		return nil
+	},
+}
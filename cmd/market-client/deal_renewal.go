@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/ipfs/go-cid"
+	"github.com/urfave/cli/v2"
+
+	"github.com/filecoin-project/go-address"
+
+	cli2 "github.com/filecoin-project/venus-market/cli"
+	"github.com/filecoin-project/venus-market/cli/tablewriter"
+	mtypes "github.com/filecoin-project/venus-market/types"
+)
+
+var storageDealsRenewalsCmd = &cli.Command{
+	Name:  "renewals",
+	Usage: "List the renewals of deals approaching their end epoch",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:    "verbose",
+			Aliases: []string{"v"},
+			Usage:   "print every renewal attempt",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := cli2.NewMarketClientNode(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := cli2.ReqContext(cctx)
+
+		renewals, err := api.ClientListDealRenewals(ctx)
+		if err != nil {
+			return err
+		}
+		sort.Slice(renewals, func(i, j int) bool {
+			return renewals[i].EndEpoch < renewals[j].EndEpoch
+		})
+
+		verbose := cctx.Bool("verbose")
+		w := tablewriter.New(tablewriter.Col("DealCid"),
+			tablewriter.Col("DealId"),
+			tablewriter.Col("Provider"),
+			tablewriter.Col("EndEpoch"),
+			tablewriter.Col("State"),
+			tablewriter.Col("Attempts"),
+			tablewriter.Col("RenewedBy"),
+			tablewriter.Col("NewProvider"),
+			tablewriter.NewLineCol("Error"))
+
+		for _, r := range renewals {
+			renewedBy := ""
+			if r.RenewedProposalCid != nil {
+				renewedBy = r.RenewedProposalCid.String()
+			}
+			newProvider := ""
+			if r.RenewedProvider != address.Undef {
+				newProvider = r.RenewedProvider.String()
+			}
+			lastErr := ""
+			if r.State != mtypes.DealRenewalProposed && len(r.Attempts) > 0 {
+				lastErr = r.Attempts[len(r.Attempts)-1].Err
+			}
+
+			w.Write(map[string]interface{}{
+				"DealCid":     ellipsis(r.ProposalCid.String(), 8),
+				"DealId":      r.DealID,
+				"Provider":    r.Provider,
+				"EndEpoch":    r.EndEpoch,
+				"State":       r.State,
+				"Attempts":    len(r.Attempts),
+				"RenewedBy":   renewedBy,
+				"NewProvider": newProvider,
+				"Error":       lastErr,
+			})
+		}
+		if err := w.Flush(os.Stdout); err != nil {
+			return err
+		}
+
+		if verbose {
+			for _, r := range renewals {
+				fmt.Printf("\n%s:\n", r.ProposalCid)
+				for _, a := range r.Attempts {
+					proposal := "-"
+					if a.ProposalCid != nil {
+						proposal = a.ProposalCid.String()
+					}
+					fmt.Printf("  %s\t%s\t%s\t%s\t%s\n", a.CreatedAt.Format("2006-01-02 15:04:05"), a.Provider, a.TransferType, proposal, a.Err)
+				}
+			}
+		}
+
+		return nil
+	},
+}
+
+var storageDealsRenewCmd = &cli.Command{
+	Name:      "renew",
+	Usage:     "Propose a replacement for an active deal right away",
+	ArgsUsage: "<proposal cid>",
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			return cli2.ShowHelp(cctx, fmt.Errorf("must specify the proposal cid of the deal"))
+		}
+
+		api, closer, err := cli2.NewMarketClientNode(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := cli2.ReqContext(cctx)
+
+		propCid, err := cid.Decode(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		renewal, err := api.ClientRenewDeal(ctx, propCid)
+		if err != nil {
+			return err
+		}
+
+		if renewal.State != mtypes.DealRenewalProposed {
+			last := renewal.Attempts[len(renewal.Attempts)-1]
+			return fmt.Errorf("renew deal failed, last attempt with %s: %s", last.Provider, last.Err)
+		}
+		fmt.Printf("deal %s renewed by %s on %s\n", propCid, renewal.RenewedProposalCid, renewal.RenewedProvider)
+		return nil
+	},
+}
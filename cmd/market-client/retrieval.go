@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
+	"sort"
 
 	tm "github.com/buger/goterm"
 	"github.com/docker/go-units"
@@ -30,6 +32,7 @@ var retrievalCmd = &cli.Command{
 		clientQueryRetrievalAskCmd,
 		retrievalCancelCmd,
 		retrievalListCmd,
+		retrievalReputationCmd,
 	},
 }
 
@@ -375,3 +378,42 @@ var clientQueryRetrievalAskCmd = &cli.Command{
 		return nil
 	},
 }
+
+var retrievalReputationCmd = &cli.Command{
+	Name:  "reputation",
+	Usage: "List the retrieval history of providers used to rank their offers",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := cli2.NewMarketClientNode(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := cli2.ReqContext(cctx)
+
+		reputations, err := api.ClientListRetrievalReputations(ctx)
+		if err != nil {
+			return err
+		}
+		sort.Slice(reputations, func(i, j int) bool {
+			return reputations[i].SuccessRate() > reputations[j].SuccessRate()
+		})
+
+		w := tablewriter.New(tablewriter.Col("Provider"),
+			tablewriter.Col("Success"),
+			tablewriter.Col("Failure"),
+			tablewriter.Col("Rate"),
+			tablewriter.Col("Received"),
+			tablewriter.NewLineCol("LastError"))
+		for _, r := range reputations {
+			w.Write(map[string]interface{}{
+				"Provider":  r.Provider,
+				"Success":   r.Successes,
+				"Failure":   r.Failures,
+				"Rate":      fmt.Sprintf("%.2f", r.SuccessRate()),
+				"Received":  units.BytesSize(float64(r.BytesReceived)),
+				"LastError": r.LastError,
+			})
+		}
+		return w.Flush(os.Stdout)
+	},
+}
@@ -378,6 +378,8 @@ var storageDealsCmd = &cli.Command{
 		storageDealsStatsCmd,
 		storageDealsGetCmd,
 		storageDealsInspectCmd,
+		storageDealsRenewalsCmd,
+		storageDealsRenewCmd,
 	},
 }
 
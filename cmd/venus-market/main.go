@@ -15,6 +15,7 @@ import (
 
 	"github.com/ipfs-force-community/venus-common-utils/builder"
 
+	"github.com/filecoin-project/venus-market/api/clients"
 	cli2 "github.com/filecoin-project/venus-market/cli"
 	"github.com/filecoin-project/venus-market/config"
 	_ "github.com/filecoin-project/venus-market/network"
@@ -70,12 +71,12 @@ var (
 
 	SignerTypeFlag = &cli.StringFlag{
 		Name:        "signer-type",
-		Usage:       "signer service type（wallet, gateway）",
+		Usage:       "signer service type（wallet, gateway, local）",
 		DefaultText: "wallet",
 	}
 	HidenSignerTypeFlag = &cli.StringFlag{
 		Name:        "signer-type",
-		Usage:       "signer service type（wallet, gateway）",
+		Usage:       "signer service type（wallet, gateway, local）",
 		DefaultText: "wallet",
 		Hidden:      true,
 	}
@@ -111,6 +112,12 @@ var (
 		Usage:   "auth token for connect wallet service",
 	}
 
+	KeystorePasswordFlag = &cli.StringFlag{
+		Name:    "keystore-password",
+		EnvVars: []string{cli2.KeystorePasswordEnv},
+		Usage:   "password of the local signer keystore, asked on the terminal when a local signer is configured without it",
+	}
+
 	PieceStorageFlag = &cli.StringFlag{
 		Name:  "piecestorage",
 		Usage: "config storage for piece  (eg  fs:/mnt/piece   s3:{access key}:{secret key}:{option token}@{region}host/{bucket}",
@@ -147,10 +154,14 @@ func main() {
 			cli2.RetrievalDealsCmd,
 			cli2.StorageDealsCmd,
 			cli2.ActorCmd,
+			cli2.FundsCmd,
+			cli2.SignerCmd,
+			cli2.WalletCmd,
 			cli2.NetCmd,
 			cli2.DataTransfersCmd,
 			cli2.DagstoreCmd,
 			cli2.MigrateCmd,
+			cli2.MessageCmd,
 		},
 	}
 
@@ -196,6 +207,18 @@ func prepare(cctx *cli.Context) (*config.MarketConfig, error) {
 	return cfg, nil
 }
 
+// keystorePassword reads the password of the keystore when the config has a local signer
+func keystorePassword(cctx *cli.Context, cfg *config.MarketConfig) (clients.KeystorePassword, error) {
+	if !clients.NeedsKeystorePassword(&cfg.Signer) {
+		return "", nil
+	}
+	password, err := cli2.KeystorePassword(cctx, KeystorePasswordFlag.Name)
+	if err != nil {
+		return "", err
+	}
+	return clients.KeystorePassword(password), nil
+}
+
 func flagData(cctx *cli.Context, cfg *config.MarketConfig) error {
 	if cctx.IsSet(NodeUrlFlag.Name) {
 		cfg.Node.Url = cctx.String(NodeUrlFlag.Name)
@@ -48,6 +48,7 @@ var poolRunCmd = &cli.Command{
 		HidenSignerTypeFlag,
 		GatewayUrlFlag,
 		GatewayTokenFlag,
+		KeystorePasswordFlag,
 		PieceStorageFlag,
 		MysqlDsnFlag,
 		MinerListFlag,
@@ -68,6 +69,10 @@ func poolDaemon(cctx *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	password, err := keystorePassword(cctx, cfg)
+	if err != nil {
+		return err
+	}
 
 	resAPI := &impl.MarketNodeImpl{}
 	shutdownChan := make(chan struct{})
@@ -82,6 +87,7 @@ func poolDaemon(cctx *cli.Context) error {
 			return metrics2.CtxScope(context.Background(), "venus-market")
 		}),
 		builder.Override(new(types2.ShutdownChan), shutdownChan),
+		builder.Override(new(clients.KeystorePassword), password),
 		//config
 		config.ConfigServerOpts(cfg),
 
@@ -44,6 +44,7 @@ var soloRunCmd = &cli.Command{
 		HidenSignerTypeFlag,
 		WalletUrlFlag,
 		WalletTokenFlag,
+		KeystorePasswordFlag,
 		PieceStorageFlag,
 		MysqlDsnFlag,
 		MinerListFlag,
@@ -56,15 +57,18 @@ func soloDaemon(cctx *cli.Context) error {
 	utils.SetupLogLevels()
 	ctx := cctx.Context
 
-	if !cctx.IsSet(HidenSignerTypeFlag.Name) {
-		if err := cctx.Set(HidenSignerTypeFlag.Name, "wallet"); err != nil {
-			return xerrors.Errorf("set %s with wallet failed %v", HidenSignerTypeFlag.Name, err)
-		}
-	}
 	cfg, err := prepare(cctx)
 	if err != nil {
 		return err
 	}
+	// the solo mode signs with a wallet unless the flag or the repo config chooses another signer
+	if len(cfg.Signer.SignerType) == 0 {
+		cfg.Signer.SignerType = "wallet"
+	}
+	password, err := keystorePassword(cctx, cfg)
+	if err != nil {
+		return err
+	}
 
 	resAPI := &impl.MarketNodeImpl{}
 	shutdownChan := make(chan struct{})
@@ -79,6 +83,7 @@ func soloDaemon(cctx *cli.Context) error {
 			return metrics2.CtxScope(context.Background(), "venus-market")
 		}),
 		builder.Override(new(types2.ShutdownChan), shutdownChan),
+		builder.Override(new(clients.KeystorePassword), password),
 		//config
 		config.ConfigServerOpts(cfg),
 
@@ -30,11 +30,37 @@ type Libp2p struct {
 	// Addresses to not announce
 	// Format: multiaddress
 	NoAnnounceAddresses []string
-	ProtectedPeers      []string
+	// Peers the connection manager never trims the connections of
+	ProtectedPeers []string
+
+	// The connection manager trims the connections down to ConnMgrLow when there are more than ConnMgrHigh,
+	// the connections younger than ConnMgrGrace are kept
+	ConnMgrLow   uint
+	ConnMgrHigh  uint
+	ConnMgrGrace Duration
+
+	// Peers and ip ranges refused to connect, the ranges are in cidr notation, eg. 10.0.0.0/8
+	DenyPeers     []string
+	DenyAddresses []string
+
+	Limits Libp2pLimits
 
 	PrivateKey string
 }
 
+// Libp2pLimits caps the inbound streams being handled with the libp2p resource manager, on top of its default limits,
+// 0 means no cap. A stream over a limit is reset.
+// The receive buffer of a stream is at most StreamWindow bytes, which bounds the memory of a peer
+// to MaxStreamsPerPeer * StreamWindow
+type Libp2pLimits struct {
+	MaxStreamsPerPeer     int
+	MaxStreamsPerProtocol int
+	// ProtocolStreams overrides MaxStreamsPerProtocol for the protocols it lists
+	ProtocolStreams map[string]int
+	// StreamWindow is the maximum receive window of a yamux stream, 0 keeps the yamux default
+	StreamWindow uint32
+}
+
 type ConnectConfig struct {
 	Url   string
 	Token string
@@ -51,9 +77,29 @@ type Common struct {
 }
 
 type Signer struct {
-	SignerType string `toml:"Type"` // wallet/gateway
+	SignerType string `toml:"Type"` // wallet/gateway/local
 	Url        string
 	Token      string
+
+	// Backends are more signers the addresses are routed to. The signer above is named default, it serves the
+	// addresses no backend routes and is the failover of every backend
+	Backends []SignerBackend
+	// HealthCheckInterval is how often the backends are checked, an unhealthy backend is skipped until it answers
+	// again
+	HealthCheckInterval Duration
+}
+
+type SignerBackend struct {
+	Name string
+	// Type is wallet, gateway, local or file. The local backend signs with the keystore of Path, the keystore dir
+	// of the repo when Path is empty, its password is never part of the config. The file backend signs with the keys
+	// of Path, one hex encoded key exported by `wallet export` per line, it is meant for development
+	Type  string
+	Url   string
+	Token string
+	Path  string
+	// Addresses are routed to the backend before the backends routing no address, which serve every address
+	Addresses []Address
 }
 
 type Mysql struct {
@@ -147,6 +193,23 @@ type DAGStoreConfig struct {
 	// representation, e.g. 1m, 5m, 1h.
 	// Default value: 1 minute.
 	GCInterval Duration
+
+	// The maximum total size in bytes of the unsealed deals kept in ./transients.
+	// When it is reached, the least recently used deals that are not being read
+	// are evicted. 0 means unlimited, the deals are then removed at every GC once
+	// they are not being read.
+	// Default value: 0 (unlimited).
+	MaxTransientsSize int64
+
+	// The maximum number of unsealed deals kept in ./transients, it is enforced
+	// like MaxTransientsSize. 0 means unlimited.
+	// Default value: 0 (unlimited).
+	MaxTransients int
+
+	// How long fetching a deal waits for the deals being read to be released
+	// when the transients quota is reached, before it fails.
+	// Default value: 1 minute.
+	TransientsQuotaWait Duration
 }
 
 type PieceStorage struct {
@@ -178,6 +241,16 @@ type User struct {
 	Account string
 }
 
+// MinerSource is a source of the miners served by the market
+type MinerSource struct {
+	// Type is venus-auth, file or http. The venus-auth source reads the users of AuthNode
+	Type string
+	// Path of the json file of a file source, the file is read again when it changes
+	Path string
+	// Url of a http source, it answers a GET with the json list of its miners
+	Url string
+}
+
 // StorageMiner is a miner config
 type MarketConfig struct {
 	Home `toml:"-"`
@@ -198,6 +271,14 @@ type MarketConfig struct {
 
 	StorageMiners           []User
 	RetrievalPaymentAddress User
+	// DisabledMiners take no new deals and publish no asks until they are enabled again, the deals accepted before
+	// go on. They are set by `actor disable`
+	DisabledMiners []Address
+	// MinerSources are merged after StorageMiners, a source listed earlier takes precedence for the account, the
+	// sector size and the tags of a miner. A miner is disabled when any of its sources disables it
+	MinerSources []MinerSource
+	// MinerSyncInterval is how often the miners are synced with their sources
+	MinerSyncInterval Duration
 
 	// When enabled, the miner can accept online deals
 	ConsiderOnlineStorageDeals bool
@@ -252,8 +333,193 @@ type MarketConfig struct {
 
 	RetrievalPricing *RetrievalPricing
 
-	MaxPublishDealsFee     types.FIL
+	MaxPublishDealsFee types.FIL
+	// MaxMarketBalanceAddFee caps the fee of the add balance messages. With 0 the daemon caps it at its own default
+	// max fee, which is much higher, and venus-messager applies the fee settings of the address
 	MaxMarketBalanceAddFee types.FIL
+
+	StorageAskSchedule StorageAskSchedule
+
+	PieceGC PieceGC
+
+	PieceVerify PieceVerify
+
+	ClientQuota ClientQuota
+
+	DealLease DealLease
+
+	DealPacking DealPacking
+
+	DealRisk DealRisk
+
+	FundPolicy FundPolicy
+}
+
+// StorageAskSchedule configures the storage asks that are priced by rules and signed again automatically
+type StorageAskSchedule struct {
+	// Enable turns on the ask scheduler
+	Enable bool
+	// CheckInterval is how often the rules are evaluated
+	CheckInterval Duration
+	Policies      []StorageAskPolicy
+}
+
+// PieceGC configures the removal of the shards and piece files of the pieces whose deals are all terminated
+type PieceGC struct {
+	// Enable runs the gc periodically, `pieces gc` works either way
+	Enable bool
+	// Interval is how often the gc runs, it first runs at start
+	Interval Duration
+	// GracePeriod is how long a piece is kept after its last deal terminated
+	GracePeriod Duration
+	// DryRun only logs the pieces the periodic gc would remove
+	DryRun bool
+}
+
+// PieceVerify configures the scrubber recomputing the commP of the piece files to find the corrupted or missing ones
+type PieceVerify struct {
+	// Enable runs the scrubber periodically, `pieces verify` works either way
+	Enable bool
+	// Interval is how often the scrubber looks for pieces to verify
+	Interval Duration
+	// MaxAge is how long a result is trusted, the scrubber skips the pieces verified more recently
+	MaxAge Duration
+	// Parallel is the number of pieces read at the same time
+	Parallel int
+	// MaxBytesPerSecond caps the read throughput of all the pieces being verified, 0 is unlimited
+	MaxBytesPerSecond uint64
+}
+
+// ClientQuota limits the deals proposed by every client address, the proposals over a limit are rejected
+type ClientQuota struct {
+	// Default applies to the clients without an override
+	Default   ClientLimits
+	Overrides []ClientQuotaOverride
+}
+
+// ClientLimits are the limits of a client, 0 is unlimited
+type ClientLimits struct {
+	// ProposalsPerMinute caps the proposals received in the last minute, the rejected ones included
+	ProposalsPerMinute int
+	// MaxPendingBytes caps the piece size of the live deals that are not active on chain yet
+	MaxPendingBytes uint64
+	// MaxBytesPerDay and MaxBytesPerMonth cap the piece size of the deals accepted in the last 24 hours and 30 days
+	MaxBytesPerDay   uint64
+	MaxBytesPerMonth uint64
+}
+
+type ClientQuotaOverride struct {
+	Client Address
+	Limits ClientLimits
+}
+
+// DealLease configures the leases of the deals assigned to sealers. A sealer renews the leases of the deals it is
+// packing, the deals of an expired lease are returned to the deals waiting to be assigned
+type DealLease struct {
+	// Duration is how long a lease lasts without a renewal, 0 keeps the assignments until the deals are packed
+	Duration Duration
+	// ReapInterval is how often the expired leases are released
+	ReapInterval Duration
+}
+
+// DealPacking selects how the deals waiting to be assigned are packed into the sectors of the sealers
+type DealPacking struct {
+	// Strategy applies to the miners without an override: default, urgency-first, best-fit or verified-first
+	Strategy string
+	Miners   []MinerDealPacking
+}
+
+type MinerDealPacking struct {
+	Miner    Address
+	Strategy string
+}
+
+// DealRisk configures the monitor of the published deals that are not packed nor precommitted as their start epoch
+// approaches. The slack of a deal is its start epoch minus the current epoch minus the expected seal duration
+type DealRisk struct {
+	// Enable runs the monitor periodically to record journal events and metrics, `storage-deals at-risk` works either way
+	Enable bool
+	// Interval is how often the monitor checks the deals
+	Interval Duration
+	// A deal with a slack below WarningSlack is at warning level, below CriticalSlack at critical level.
+	// The critical deals are handed to the sealers first
+	WarningSlack  Duration
+	CriticalSlack Duration
+}
+
+// FundPolicy keeps the available market escrow of the miners between a low and a high watermark, so that the deals
+// reserving their collateral seldom need an add balance message of their own
+type FundPolicy struct {
+	// LowWatermark and HighWatermark apply to the miners without an override. A reservation leaving less than
+	// LowWatermark available tops the escrow up to HighWatermark in one message, a zero HighWatermark only adds the
+	// shortfall of every reservation
+	LowWatermark  types.FIL
+	HighWatermark types.FIL
+	Miners        []MinerFundPolicy
+	// RefillInterval is how often the escrow of the miners is checked against the low watermark between deals, 0
+	// only tops it up on the reservations
+	RefillInterval Duration
+	// ReportInterval is how often the collateral utilization of the market addresses is recorded, 0 disables it
+	ReportInterval Duration
+}
+
+// MinerFundPolicy overrides the watermarks of a miner, a watermark left unset is the one of the policy
+type MinerFundPolicy struct {
+	Miner         Address
+	LowWatermark  types.FIL
+	HighWatermark types.FIL
+	// Wallet pays the refills of the miner, the wallet of its last reservation is used without it
+	Wallet Address
+}
+
+// StorageAskPolicy prices the ask of one miner. The price is the base price multiplied by the multiplier
+// of every matching rule, the ask is signed again when the price changes or the ask is about to expire
+type StorageAskPolicy struct {
+	Miner Address
+	// Base price per GiB/Epoch
+	Price         types.FIL
+	VerifiedPrice types.FIL
+	// Piece size range of the ask, zero keeps the range of the current ask
+	MinPieceSize uint64
+	MaxPieceSize uint64
+	// Duration is how long a signed ask is valid
+	Duration Duration
+	// ResignBefore is how long before the ask expires it is signed again
+	ResignBefore Duration
+
+	// The first window containing the local time applies
+	TimeOfDay []TimeOfDayPriceRule
+	// The rule with the highest threshold below the number of deals waiting to be assigned to a sector applies
+	PendingDeals []ThresholdPriceRule
+	// The rule with the highest threshold below the used fraction (0-1) of the filesystem of the piece storage applies
+	StagingUsage []ThresholdPriceRule
+	// The rule with the lowest threshold above the available market balance of the miner applies
+	LowBalance []BalancePriceRule
+	// Every rule whose tag the miner has in its sources applies
+	Tags []TagPriceRule
+}
+
+type TimeOfDayPriceRule struct {
+	// Start and end of the window in the local time, as 15:04. A window ending before it starts spans midnight
+	From string
+	To   string
+
+	Multiplier float64
+}
+
+type ThresholdPriceRule struct {
+	Above      float64
+	Multiplier float64
+}
+
+type TagPriceRule struct {
+	Tag        string
+	Multiplier float64
+}
+
+type BalancePriceRule struct {
+	Below      types.FIL
+	Multiplier float64
 }
 
 type MarketClientConfig struct {
@@ -268,6 +534,37 @@ type MarketClientConfig struct {
 	SimultaneousTransfersForRetrieval uint64
 	SimultaneousTransfersForStorage   uint64
 	DefaultMarketAddress              Address
+
+	DealRenewal       DealRenewal
+	RetrievalFailover RetrievalFailover
+}
+
+// RetrievalFailover configures the retrieval with automatic provider selection
+type RetrievalFailover struct {
+	// StallTimeout is how long a retrieval may receive no data before failing over to the next provider
+	StallTimeout Duration
+	// MaxAttempts limits the number of providers tried for one retrieval, zero means all of them
+	MaxAttempts int
+}
+
+// DealRenewal configures the automatic renewal of storage deals approaching their end epoch
+type DealRenewal struct {
+	// Enable turns on the renewal scheduler
+	Enable bool
+	// RenewBeforeEpochs is how many epochs before the end epoch of an active deal a replacement deal is proposed
+	RenewBeforeEpochs uint64
+	// CheckInterval is how often the local deals are scanned
+	CheckInterval Duration
+	// DealDuration is the duration of the replacement deal in epochs, zero means the duration of the original deal
+	DealDuration uint64
+	// Miners to propose the replacement deal to after the original provider, tried from the cheapest ask for the deal.
+	// The miners whose ask can not be queried or does not take the piece size are tried last.
+	// When empty only the original provider is used
+	Miners []Address
+	// SkipOriginalProvider only tries the providers in Miners
+	SkipOriginalProvider bool
+	// MaxAttempts is the number of failed renewal rounds after which a deal is given up
+	MaxAttempts int
 }
 
 var _ encoding.TextMarshaler = (*Duration)(nil)
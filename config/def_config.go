@@ -28,6 +28,17 @@ var DefaultMarketConfig = &MarketConfig{
 			},
 			AnnounceAddresses:   []string{},
 			NoAnnounceAddresses: []string{},
+			ProtectedPeers:      []string{},
+			ConnMgrLow:          150,
+			ConnMgrHigh:         180,
+			ConnMgrGrace:        Duration(20 * time.Second),
+			DenyPeers:           []string{},
+			DenyAddresses:       []string{},
+			Limits: Libp2pLimits{
+				MaxStreamsPerPeer:     256,
+				MaxStreamsPerProtocol: 4096,
+				ProtocolStreams:       map[string]int{},
+			},
 		},
 	},
 	Node: Node{
@@ -39,8 +50,9 @@ var DefaultMarketConfig = &MarketConfig{
 		Token: "",
 	},
 	Signer: Signer{
-		Url:   "", // /ip4/<ip>/tcp/5678
-		Token: "",
+		Url:                 "", // /ip4/<ip>/tcp/5678
+		Token:               "",
+		HealthCheckInterval: Duration(time.Minute),
 	},
 	Mysql: Mysql{
 		ConnectionString: "",
@@ -53,10 +65,15 @@ var DefaultMarketConfig = &MarketConfig{
 		Url:   "", // "http://<ip>:8989",
 		Token: "",
 	},
+	MinerSources: []MinerSource{
+		{Type: "venus-auth"},
+	},
+	MinerSyncInterval: Duration(time.Minute),
 	DAGStore: DAGStoreConfig{
 		MaxConcurrentIndex:         5,
 		MaxConcurrencyStorageCalls: 100,
 		GCInterval:                 Duration(1 * time.Minute),
+		TransientsQuotaWait:        Duration(1 * time.Minute),
 	},
 	Journal: Journal{Path: "journal"},
 	PieceStorage: PieceStorage{Fs: FsPieceStorage{
@@ -93,7 +110,51 @@ var DefaultMarketConfig = &MarketConfig{
 	},
 
 	MaxPublishDealsFee:     types.FIL(types.NewInt(0)),
-	MaxMarketBalanceAddFee: types.FIL(types.NewInt(0)),
+	MaxMarketBalanceAddFee: types.FIL(types.NewInt(7_000_000_000_000_000)), // 0.007 FIL
+
+	StorageAskSchedule: StorageAskSchedule{
+		Enable:        false,
+		CheckInterval: Duration(10 * time.Minute),
+		Policies:      []StorageAskPolicy{},
+	},
+
+	PieceGC: PieceGC{
+		Enable:      false,
+		Interval:    Duration(24 * time.Hour),
+		GracePeriod: Duration(7 * 24 * time.Hour),
+	},
+
+	PieceVerify: PieceVerify{
+		Enable:            false,
+		Interval:          Duration(time.Hour),
+		MaxAge:            Duration(30 * 24 * time.Hour),
+		Parallel:          2,
+		MaxBytesPerSecond: 64 << 20,
+	},
+
+	DealLease: DealLease{
+		Duration:     0,
+		ReapInterval: Duration(time.Minute),
+	},
+
+	DealPacking: DealPacking{
+		Strategy: "default",
+		Miners:   []MinerDealPacking{},
+	},
+
+	DealRisk: DealRisk{
+		Enable:        true,
+		Interval:      Duration(10 * time.Minute),
+		WarningSlack:  Duration(24 * time.Hour),
+		CriticalSlack: Duration(6 * time.Hour),
+	},
+
+	FundPolicy: FundPolicy{
+		LowWatermark:   types.FIL(types.NewInt(0)),
+		HighWatermark:  types.FIL(types.NewInt(0)),
+		RefillInterval: Duration(5 * time.Minute),
+		ReportInterval: Duration(10 * time.Minute),
+	},
 }
 
 var DefaultMarketClientConfig = &MarketClientConfig{
@@ -110,6 +171,17 @@ var DefaultMarketClientConfig = &MarketClientConfig{
 			},
 			AnnounceAddresses:   []string{},
 			NoAnnounceAddresses: []string{},
+			ProtectedPeers:      []string{},
+			ConnMgrLow:          150,
+			ConnMgrHigh:         180,
+			ConnMgrGrace:        Duration(20 * time.Second),
+			DenyPeers:           []string{},
+			DenyAddresses:       []string{},
+			Limits: Libp2pLimits{
+				MaxStreamsPerPeer:     256,
+				MaxStreamsPerProtocol: 4096,
+				ProtocolStreams:       map[string]int{},
+			},
 		},
 	},
 	Node: Node{
@@ -127,4 +199,15 @@ var DefaultMarketClientConfig = &MarketClientConfig{
 	DefaultMarketAddress:              Address(address.Undef),
 	SimultaneousTransfersForStorage:   DefaultSimultaneousTransfers,
 	SimultaneousTransfersForRetrieval: DefaultSimultaneousTransfers,
+	DealRenewal: DealRenewal{
+		Enable:            false,
+		RenewBeforeEpochs: 2 * 7 * 2880, // two weeks
+		CheckInterval:     Duration(time.Hour),
+		Miners:            []Address{},
+		MaxAttempts:       3,
+	},
+	RetrievalFailover: RetrievalFailover{
+		StallTimeout: Duration(5 * time.Minute),
+		MaxAttempts:  0,
+	},
 }
@@ -0,0 +1,97 @@
+package dagstore
+
+import (
+	"context"
+
+	"github.com/filecoin-project/dagstore/shard"
+	"github.com/ipfs/go-cid"
+	carindex "github.com/ipld/go-car/v2/index"
+	"github.com/multiformats/go-multihash"
+	"golang.org/x/xerrors"
+)
+
+// BlockIndexer records every block of a shard in the block index, so that the blocks can be retrieved by their own cid
+type BlockIndexer interface {
+	IndexBlocks(ctx context.Context, pieceCid cid.Cid) error
+	HasBlockIndex(ctx context.Context, pieceCid cid.Cid) (bool, error)
+}
+
+var _ BlockIndexer = (*Wrapper)(nil)
+
+// IndexBlocks reads the blocks from the car index the dagstore built for the shard, the shard must be initialized
+func (w *Wrapper) IndexBlocks(ctx context.Context, pieceCid cid.Cid) error {
+	if w.blockIndex == nil {
+		return xerrors.New("block index is not available")
+	}
+	idx, err := w.indexRepo.GetFullIndex(shard.KeyFromCID(pieceCid))
+	if err != nil {
+		return xerrors.Errorf("get index of shard %s: %w", pieceCid, err)
+	}
+	iterable, ok := idx.(carindex.IterableIndex)
+	if !ok {
+		return xerrors.Errorf("index of shard %s is a %T which cannot be iterated", pieceCid, idx)
+	}
+
+	var blocks []multihash.Multihash
+	err = iterable.ForEach(func(mh multihash.Multihash, _ uint64) error {
+		blocks = append(blocks, append(multihash.Multihash(nil), mh...))
+		return nil
+	})
+	if err != nil {
+		return xerrors.Errorf("iterate index of shard %s: %w", pieceCid, err)
+	}
+	if err := w.blockIndex.AddBlockIndex(ctx, pieceCid, blocks); err != nil {
+		return xerrors.Errorf("save block index of shard %s: %w", pieceCid, err)
+	}
+	log.Infow("indexed blocks of shard", "piece_cid", pieceCid, "blocks", len(blocks))
+	return nil
+}
+
+func (w *Wrapper) HasBlockIndex(ctx context.Context, pieceCid cid.Cid) (bool, error) {
+	if w.blockIndex == nil {
+		return false, xerrors.New("block index is not available")
+	}
+	return w.blockIndex.HasBlockIndex(ctx, pieceCid)
+}
+
+// queueBlockIndex schedules a shard that became available for block indexing, it never blocks the trace loop.
+// shards dropped here are picked up by `dagstore initialize-all`
+func (w *Wrapper) queueBlockIndex(key shard.Key) {
+	if w.blockIndex == nil {
+		return
+	}
+	select {
+	case w.blockIndexCh <- key:
+	default:
+		log.Warnw("block index queue is full, skip shard", "shard_key", key.String())
+	}
+}
+
+func (w *Wrapper) blockIndexLoop() {
+	defer w.backgroundWg.Done()
+
+	for {
+		select {
+		case key := <-w.blockIndexCh:
+			pieceCid, err := cid.Decode(key.String())
+			if err != nil {
+				log.Warnw("failed to decode shard key as piece cid", "shard_key", key.String(), "error", err)
+				continue
+			}
+			has, err := w.blockIndex.HasBlockIndex(w.ctx, pieceCid)
+			if err != nil {
+				log.Warnw("failed to check block index", "piece_cid", pieceCid, "error", err)
+				continue
+			}
+			if has {
+				continue
+			}
+			if err := w.IndexBlocks(w.ctx, pieceCid); err != nil {
+				log.Warnw("failed to index blocks", "piece_cid", pieceCid, "error", err)
+			}
+
+		case <-w.ctx.Done():
+			return
+		}
+	}
+}
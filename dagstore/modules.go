@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"time"
 
 	"github.com/filecoin-project/dagstore"
 	"github.com/filecoin-project/go-fil-markets/stores"
@@ -40,14 +41,26 @@ func NewMarketAPI(lc fx.Lifecycle, r *config.DAGStoreConfig, repo repo.Repo, pie
 	return mountApi, nil
 }
 
-// DAGStore constructs a DAG store using the supplied minerAPI, and the
-// user configuration. It returns both the DAGStore and the Wrapper suitable for
-// passing to markets.
-func NewWrapperDAGStore(lc fx.Lifecycle, homeDir *config.HomeDir, cfg *config.DAGStoreConfig, minerAPI MarketAPI) (*dagstore.DAGStore, stores.DAGStoreWrapper, error) {
-	// fall back to default root directory if not explicitly set in the config.
+// rootDir falls back to the default root directory if it is not explicitly set in the config.
+func rootDir(homeDir *config.HomeDir, cfg *config.DAGStoreConfig) string {
 	if cfg.RootDir == "" {
 		cfg.RootDir = filepath.Join(string(*homeDir), DefaultDAGStoreDir)
 	}
+	return cfg.RootDir
+}
+
+// NewTransientCacheFromConfig creates the cache of the unsealed deals the dagstore reads
+// from, within the quota of the configuration.
+func NewTransientCacheFromConfig(homeDir *config.HomeDir, cfg *config.DAGStoreConfig) (*TransientCache, error) {
+	dir := filepath.Join(rootDir(homeDir, cfg), "transients")
+	return NewTransientCache(dir, cfg.MaxTransientsSize, cfg.MaxTransients, time.Duration(cfg.TransientsQuotaWait))
+}
+
+// DAGStore constructs a DAG store using the supplied minerAPI, and the
+// user configuration. It returns the DAGStore, the Wrapper suitable for
+// passing to markets and the indexer of the blocks in the shards.
+func NewWrapperDAGStore(lc fx.Lifecycle, homeDir *config.HomeDir, cfg *config.DAGStoreConfig, minerAPI MarketAPI, repo repo.Repo, transients *TransientCache) (*dagstore.DAGStore, stores.DAGStoreWrapper, BlockIndexer, error) {
+	rootDir(homeDir, cfg)
 
 	v, ok := os.LookupEnv(EnvDAGStoreCopyConcurrency)
 	if ok {
@@ -57,9 +70,9 @@ func NewWrapperDAGStore(lc fx.Lifecycle, homeDir *config.HomeDir, cfg *config.DA
 		}
 	}
 
-	dagst, w, err := NewDAGStore(cfg, minerAPI)
+	dagst, w, err := NewDAGStore(cfg, minerAPI, repo.BlockIndexRepo(), transients)
 	if err != nil {
-		return nil, nil, xerrors.Errorf("failed to create DAG store: %w", err)
+		return nil, nil, nil, xerrors.Errorf("failed to create DAG store: %w", err)
 	}
 
 	lc.Append(fx.Hook{
@@ -71,10 +84,11 @@ func NewWrapperDAGStore(lc fx.Lifecycle, homeDir *config.HomeDir, cfg *config.DA
 		},
 	})
 
-	return dagst, w, nil
+	return dagst, w, w, nil
 }
 
 var DagstoreOpts = builder.Options(
 	builder.Override(new(MarketAPI), NewMarketAPI),
+	builder.Override(new(*TransientCache), NewTransientCacheFromConfig),
 	builder.Override(DAGStoreKey, NewWrapperDAGStore),
 )
@@ -23,8 +23,8 @@ var _ mount.Mount = (*LotusMount)(nil)
 // When the registry needs to deserialize a mount it clones the template then
 // calls Deserialize on the cloned instance, which will have a reference to the
 // lotus mount API supplied here.
-func mountTemplate(api MarketAPI) *LotusMount {
-	return &LotusMount{API: api}
+func mountTemplate(api MarketAPI, transients *TransientCache) *LotusMount {
+	return &LotusMount{API: api, Transients: transients}
 }
 
 // LotusMount is a DAGStore mount implementation that fetches deal data
@@ -32,6 +32,9 @@ func mountTemplate(api MarketAPI) *LotusMount {
 type LotusMount struct {
 	API      MarketAPI
 	PieceCid cid.Cid
+	// Transients keeps the fetched deal data within a quota, the mount is then
+	// read randomly and the dagstore does not make transients of its own.
+	Transients *TransientCache
 }
 
 func NewLotusMount(pieceCid cid.Cid, api MarketAPI) (*LotusMount, error) {
@@ -57,6 +60,24 @@ func (l *LotusMount) Deserialize(u *url.URL) error {
 }
 
 func (l *LotusMount) Fetch(ctx context.Context) (mount.Reader, error) {
+	if l.Transients != nil {
+		// the size is only needed when the piece is not cached
+		size := func(ctx context.Context) (int64, error) {
+			size, err := l.API.GetUnpaddedCARSize(ctx, l.PieceCid)
+			if err != nil {
+				return 0, xerrors.Errorf("failed to fetch piece size for piece %s: %w", l.PieceCid, err)
+			}
+			return int64(size), nil
+		}
+		return l.Transients.Fetch(ctx, l.PieceCid, size, func(ctx context.Context) (io.ReadCloser, error) {
+			r, err := l.API.FetchUnsealedPiece(ctx, l.PieceCid)
+			if err != nil {
+				return nil, xerrors.Errorf("failed to fetch unsealed piece %s: %w", l.PieceCid, err)
+			}
+			return r, nil
+		})
+	}
+
 	r, err := l.API.FetchUnsealedPiece(ctx, l.PieceCid)
 	if err != nil {
 		return nil, xerrors.Errorf("failed to fetch unsealed piece %s: %w", l.PieceCid, err)
@@ -65,6 +86,14 @@ func (l *LotusMount) Fetch(ctx context.Context) (mount.Reader, error) {
 }
 
 func (l *LotusMount) Info() mount.Info {
+	if l.Transients != nil {
+		return mount.Info{
+			Kind:             mount.KindRemote,
+			AccessSequential: true,
+			AccessSeek:       true,
+			AccessRandom:     true,
+		}
+	}
 	return mount.Info{
 		Kind:             mount.KindRemote,
 		AccessSequential: true,
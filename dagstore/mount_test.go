@@ -54,7 +54,7 @@ func TestLotusMount(t *testing.T) {
 	// serialize url then deserialize from mount template -> should get back
 	// the same mount
 	url := mnt.Serialize()
-	mnt2 := mountTemplate(mockLotusMountAPI)
+	mnt2 := mountTemplate(mockLotusMountAPI, nil)
 	err = mnt2.Deserialize(url)
 	require.NoError(t, err)
 
@@ -110,7 +110,7 @@ func TestLotusMountRegistration(t *testing.T) {
 
 	mockLotusMountAPI := mock_dagstore2.NewMockLotusAccessor(mockCtrl)
 	registry := mount.NewRegistry()
-	err = registry.Register(marketScheme, mountTemplate(mockLotusMountAPI))
+	err = registry.Register(marketScheme, mountTemplate(mockLotusMountAPI, nil))
 	require.NoError(t, err)
 
 	mnt, err := registry.Instantiate(u)
@@ -0,0 +1,368 @@
+package dagstore
+
+import (
+	"container/list"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/dagstore/mount"
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	mtypes "github.com/filecoin-project/venus-market/types"
+)
+
+const (
+	transientExt    = ".car"
+	transientTmpExt = ".tmp"
+)
+
+// ErrTransientQuota is returned when a piece cannot be fetched because the copies being read use the whole quota
+var ErrTransientQuota = xerrors.New("transient quota reached")
+
+// TransientCache keeps the unsealed copies of the pieces the dagstore reads the shards from, within a quota of bytes
+// and copies. The copies that are not being read are evicted, least recently used first, to make room for new ones.
+// When the copies being read use the whole quota, a fetch waits for one of them to be released and fails after a while
+type TransientCache struct {
+	dir      string
+	maxSize  int64
+	maxCount int
+	wait     time.Duration
+
+	lk       sync.Mutex
+	entries  map[cid.Cid]*transient
+	lru      *list.List // front is the most recently used
+	fetching map[cid.Cid]chan struct{}
+	// size and count include the reservations of the fetches in progress
+	size     int64
+	count    int
+	released chan struct{}
+
+	hits, misses, evictions, rejections uint64
+}
+
+type transient struct {
+	pieceCid cid.Cid
+	path     string
+	size     int64
+	readers  int
+	lastUsed time.Time
+	elem     *list.Element
+}
+
+// NewTransientCache opens the cache in dir, the copies left by a previous run are kept
+func NewTransientCache(dir string, maxSize int64, maxCount int, wait time.Duration) (*TransientCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, xerrors.Errorf("failed to create transients directory %s: %w", dir, err)
+	}
+	c := &TransientCache{
+		dir:      dir,
+		maxSize:  maxSize,
+		maxCount: maxCount,
+		wait:     wait,
+		entries:  make(map[cid.Cid]*transient),
+		lru:      list.New(),
+		fetching: make(map[cid.Cid]chan struct{}),
+		released: make(chan struct{}),
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read transients directory %s: %w", dir, err)
+	}
+	// the oldest copies go to the back of the lru
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() {
+			continue
+		}
+		if strings.HasSuffix(name, transientTmpExt) {
+			_ = os.Remove(filepath.Join(dir, name))
+			continue
+		}
+		pieceCid, err := cid.Decode(strings.TrimSuffix(name, transientExt))
+		if err != nil || !strings.HasSuffix(name, transientExt) {
+			// transients of the dagstore itself, it removes them on gc
+			continue
+		}
+		e := &transient{pieceCid: pieceCid, path: filepath.Join(dir, name), size: file.Size(), lastUsed: file.ModTime()}
+		e.elem = c.lru.PushFront(e)
+		c.entries[pieceCid] = e
+		c.size += e.size
+		c.count++
+	}
+	// the quota may have been lowered since the copies were made
+	if evicted := c.evictLocked(c.withinQuotaLocked); evicted > 0 {
+		log.Infow("evicted transients over the quota", "count", evicted)
+	}
+	return c, nil
+}
+
+// Limited is whether a quota is set, without quota the idle copies are removed at every dagstore gc
+func (c *TransientCache) Limited() bool {
+	return c.maxSize > 0 || c.maxCount > 0
+}
+
+// Fetch returns a reader of the copy of the piece. The piece is fetched into the cache first when there is no copy,
+// size returns the expected size of the piece, it is only called then and is reserved in the quota while fetching
+func (c *TransientCache) Fetch(ctx context.Context, pieceCid cid.Cid, size func(ctx context.Context) (int64, error), fetch func(ctx context.Context) (io.ReadCloser, error)) (mount.Reader, error) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	for {
+		if e, ok := c.entries[pieceCid]; ok {
+			f, err := os.Open(e.path)
+			if err != nil {
+				log.Warnw("failed to open transient, fetch it again", "piece_cid", pieceCid, "error", err)
+				c.removeLocked(e)
+				continue
+			}
+			c.hits++
+			return c.readLocked(e, f), nil
+		}
+
+		if done, ok := c.fetching[pieceCid]; ok {
+			if err := c.waitLocked(ctx, done, nil); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// the other fetches of the piece wait for this one
+		done := make(chan struct{})
+		c.fetching[pieceCid] = done
+		finish := func() {
+			delete(c.fetching, pieceCid)
+			close(done)
+		}
+
+		c.lk.Unlock()
+		expected, err := size(ctx)
+		c.lk.Lock()
+		if err != nil {
+			finish()
+			return nil, err
+		}
+		if err := c.reserveLocked(ctx, expected); err != nil {
+			finish()
+			return nil, err
+		}
+		c.misses++
+
+		c.lk.Unlock()
+		e, err := c.download(ctx, pieceCid, fetch)
+		c.lk.Lock()
+		finish()
+		c.unreserveLocked(expected)
+		if err != nil {
+			return nil, err
+		}
+
+		f, err := os.Open(e.path)
+		if err != nil {
+			_ = os.Remove(e.path)
+			return nil, xerrors.Errorf("failed to open transient of piece %s: %w", pieceCid, err)
+		}
+		e.elem = c.lru.PushFront(e)
+		c.entries[pieceCid] = e
+		c.size += e.size
+		c.count++
+		return c.readLocked(e, f), nil
+	}
+}
+
+func (c *TransientCache) download(ctx context.Context, pieceCid cid.Cid, fetch func(ctx context.Context) (io.ReadCloser, error)) (*transient, error) {
+	r, err := fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close() //nolint:errcheck
+
+	path := filepath.Join(c.dir, pieceCid.String()+transientExt)
+	tmp, err := os.Create(path + transientTmpExt)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create transient of piece %s: %w", pieceCid, err)
+	}
+	size, err := io.Copy(tmp, r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return nil, xerrors.Errorf("failed to write transient of piece %s: %w", pieceCid, err)
+	}
+	return &transient{pieceCid: pieceCid, path: path, size: size, lastUsed: time.Now()}, nil
+}
+
+func (c *TransientCache) readLocked(e *transient, f *os.File) mount.Reader {
+	e.readers++
+	e.lastUsed = time.Now()
+	c.lru.MoveToFront(e.elem)
+	return &transientReader{File: f, release: func() {
+		c.lk.Lock()
+		defer c.lk.Unlock()
+		e.readers--
+		e.lastUsed = time.Now()
+		c.notifyLocked()
+	}}
+}
+
+func (c *TransientCache) withinQuotaLocked() bool {
+	return (c.maxSize <= 0 || c.size <= c.maxSize) && (c.maxCount <= 0 || c.count <= c.maxCount)
+}
+
+func (c *TransientCache) fitsLocked(size int64) bool {
+	return (c.maxSize <= 0 || c.size+size <= c.maxSize) && (c.maxCount <= 0 || c.count+1 <= c.maxCount)
+}
+
+// reserveLocked makes room for a copy of size, evicting idle copies and waiting for the copies being read
+func (c *TransientCache) reserveLocked(ctx context.Context, size int64) error {
+	if c.maxSize > 0 && size > c.maxSize {
+		c.rejections++
+		return xerrors.Errorf("piece of %d bytes is larger than the quota of %d bytes: %w", size, c.maxSize, ErrTransientQuota)
+	}
+
+	timeout := time.NewTimer(c.wait)
+	defer timeout.Stop()
+	for {
+		c.evictLocked(func() bool { return c.fitsLocked(size) })
+		if c.fitsLocked(size) {
+			c.size += size
+			c.count++
+			return nil
+		}
+		if err := c.waitLocked(ctx, c.released, timeout.C); err != nil {
+			if xerrors.Is(err, ErrTransientQuota) {
+				c.rejections++
+				return xerrors.Errorf("%d bytes in %d transients are in use: %w", c.size, c.count, err)
+			}
+			return err
+		}
+	}
+}
+
+func (c *TransientCache) unreserveLocked(size int64) {
+	c.size -= size
+	c.count--
+	c.notifyLocked()
+}
+
+// waitLocked releases the lock until ch is closed
+func (c *TransientCache) waitLocked(ctx context.Context, ch <-chan struct{}, timeout <-chan time.Time) error {
+	c.lk.Unlock()
+	defer c.lk.Lock()
+	select {
+	case <-ch:
+		return nil
+	case <-timeout:
+		return ErrTransientQuota
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// notifyLocked wakes up the fetches waiting for room
+func (c *TransientCache) notifyLocked() {
+	close(c.released)
+	c.released = make(chan struct{})
+}
+
+// evictLocked removes idle copies, least recently used first, until enough returns true
+func (c *TransientCache) evictLocked(enough func() bool) int {
+	var evicted int
+	for elem := c.lru.Back(); elem != nil && !enough(); {
+		e := elem.Value.(*transient)
+		elem = elem.Prev()
+		if e.readers > 0 {
+			continue
+		}
+		c.removeLocked(e)
+		c.evictions++
+		evicted++
+	}
+	return evicted
+}
+
+func (c *TransientCache) removeLocked(e *transient) {
+	if err := os.Remove(e.path); err != nil && !os.IsNotExist(err) {
+		log.Warnw("failed to remove transient", "piece_cid", e.pieceCid, "error", err)
+	}
+	c.lru.Remove(e.elem)
+	delete(c.entries, e.pieceCid)
+	c.size -= e.size
+	c.count--
+}
+
+// EvictIdle removes all the copies that are not being read
+func (c *TransientCache) EvictIdle() int {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	return c.evictLocked(func() bool { return false })
+}
+
+// Drop removes the copy of the piece unless it is being read, a copy being read is left to the eviction
+func (c *TransientCache) Drop(pieceCid cid.Cid) bool {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	e, ok := c.entries[pieceCid]
+	if !ok || e.readers > 0 {
+		return false
+	}
+	c.removeLocked(e)
+	c.notifyLocked()
+	return true
+}
+
+func (c *TransientCache) Usage() *mtypes.DagstoreTransientUsage {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	usage := &mtypes.DagstoreTransientUsage{
+		Size:       c.size,
+		MaxSize:    c.maxSize,
+		Count:      c.count,
+		MaxCount:   c.maxCount,
+		Hits:       c.hits,
+		Misses:     c.misses,
+		Evictions:  c.evictions,
+		Rejections: c.rejections,
+		Transients: make([]mtypes.DagstoreTransient, 0, c.lru.Len()),
+	}
+	for elem := c.lru.Front(); elem != nil; elem = elem.Next() {
+		e := elem.Value.(*transient)
+		usage.Transients = append(usage.Transients, mtypes.DagstoreTransient{
+			PieceCID: e.pieceCid,
+			Size:     e.size,
+			Readers:  e.readers,
+			LastUsed: e.lastUsed,
+		})
+	}
+	return usage
+}
+
+type transientReader struct {
+	*os.File
+	once    sync.Once
+	release func()
+}
+
+var _ mount.Reader = (*transientReader)(nil)
+
+func (r *transientReader) Close() error {
+	err := r.File.Close()
+	r.once.Do(r.release)
+	return err
+}
@@ -0,0 +1,107 @@
+package dagstore
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	blocksutil "github.com/ipfs/go-ipfs-blocksutil"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+)
+
+func TestTransientCache(t *testing.T) {
+	ctx := context.Background()
+	bgen := blocksutil.NewBlockGenerator()
+	piece1, piece2, piece3 := bgen.Next().Cid(), bgen.Next().Cid(), bgen.Next().Cid()
+
+	var fetches int
+	fetch := func(data string) func(ctx context.Context) (io.ReadCloser, error) {
+		return func(ctx context.Context) (io.ReadCloser, error) {
+			fetches++
+			return ioutil.NopCloser(strings.NewReader(data)), nil
+		}
+	}
+	var sizes int
+	size := func(n int64) func(ctx context.Context) (int64, error) {
+		return func(ctx context.Context) (int64, error) {
+			sizes++
+			return n, nil
+		}
+	}
+	read := func(c *TransientCache, pieceCid cid.Cid, data string) io.Closer {
+		r, err := c.Fetch(ctx, pieceCid, size(int64(len(data))), fetch(data))
+		require.NoError(t, err)
+		bz, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, data, string(bz))
+		return r
+	}
+
+	// room for two pieces of 4 bytes
+	dir := t.TempDir()
+	c, err := NewTransientCache(dir, 8, 0, 10*time.Millisecond)
+	require.NoError(t, err)
+
+	r1 := read(c, piece1, "aaaa")
+	require.NoError(t, read(c, piece2, "bbbb").Close())
+	// a copy being read is not evicted, the idle one is
+	require.NoError(t, read(c, piece3, "cccc").Close())
+	require.Equal(t, 3, fetches)
+	usage := c.Usage()
+	require.Equal(t, int64(8), usage.Size)
+	require.Equal(t, uint64(1), usage.Evictions)
+	require.Equal(t, piece3, usage.Transients[0].PieceCID)
+	require.Equal(t, piece1, usage.Transients[1].PieceCID)
+	require.Equal(t, 1, usage.Transients[1].Readers)
+
+	// hits do not fetch again, nor ask for the size
+	require.NoError(t, read(c, piece1, "aaaa").Close())
+	require.Equal(t, 3, fetches)
+	require.Equal(t, 3, sizes)
+	require.NoError(t, r1.Close())
+
+	// when every copy is being read, fetching another piece waits and then fails
+	r1 = read(c, piece1, "aaaa")
+	r3 := read(c, piece3, "cccc")
+	_, err = c.Fetch(ctx, piece2, size(4), fetch("bbbb"))
+	require.True(t, xerrors.Is(err, ErrTransientQuota))
+
+	// and succeeds once a copy is released while waiting
+	c.wait = time.Minute
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		require.NoError(t, r3.Close())
+	}()
+	require.NoError(t, read(c, piece2, "bbbb").Close())
+	require.NoError(t, r1.Close())
+
+	// pieces larger than the quota are rejected right away
+	_, err = c.Fetch(ctx, bgen.Next().Cid(), size(9), fetch("too large"))
+	require.True(t, xerrors.Is(err, ErrTransientQuota))
+
+	usage = c.Usage()
+	require.Equal(t, uint64(3), usage.Hits)
+	require.Equal(t, uint64(4), usage.Misses)
+	require.Equal(t, uint64(2), usage.Rejections)
+
+	// the copies are kept across restarts
+	c, err = NewTransientCache(dir, 8, 0, 0)
+	require.NoError(t, err)
+	require.Equal(t, 2, c.Usage().Count)
+
+	// the copies over a lowered quota are evicted at start, the least recently used first
+	c, err = NewTransientCache(dir, 4, 0, 0)
+	require.NoError(t, err)
+	usage = c.Usage()
+	require.Equal(t, 1, usage.Count)
+	require.Equal(t, uint64(1), usage.Evictions)
+	require.Equal(t, piece2, usage.Transients[0].PieceCID)
+
+	require.Equal(t, 1, c.EvictIdle())
+	require.Equal(t, 0, c.Usage().Count)
+}
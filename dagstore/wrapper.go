@@ -18,6 +18,7 @@ import (
 	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/venus-market/config"
+	"github.com/filecoin-project/venus-market/models/repo"
 
 	"github.com/filecoin-project/go-statemachine/fsm"
 
@@ -34,6 +35,7 @@ import (
 const (
 	maxRecoverAttempts = 1
 	shardRegMarker     = ".shard-registration-complete"
+	blockIndexQueueLen = 256
 )
 
 var log = logging.Logger("dagstore")
@@ -49,14 +51,22 @@ type Wrapper struct {
 	failureCh  chan dagstore.ShardResult
 	traceCh    chan dagstore.Trace
 	gcInterval time.Duration
+	transients *TransientCache
+
+	indexRepo    index.FullIndexRepo
+	blockIndex   repo.IBlockIndexRepo
+	blockIndexCh chan shard.Key
 }
 
 var _ stores.DAGStoreWrapper = (*Wrapper)(nil)
 
-func NewDAGStore(cfg *config.DAGStoreConfig, marketApi MarketAPI) (*dagstore.DAGStore, *Wrapper, error) {
+// NewDAGStore creates the dagstore, the blocks of the shards are recorded in blockIndex once the shards are initialized
+// and the deal data is fetched into transients. blockIndex and transients may be nil, the dagstore then keeps transients
+// of its own without quota
+func NewDAGStore(cfg *config.DAGStoreConfig, marketApi MarketAPI, blockIndex repo.IBlockIndexRepo, transients *TransientCache) (*dagstore.DAGStore, *Wrapper, error) {
 	// construct the DAG Store.
 	registry := mount.NewRegistry()
-	if err := registry.Register(marketScheme, mountTemplate(marketApi)); err != nil {
+	if err := registry.Register(marketScheme, mountTemplate(marketApi, transients)); err != nil {
 		return nil, nil, xerrors.Errorf("failed to create registry: %w", err)
 	}
 
@@ -108,6 +118,11 @@ func NewDAGStore(cfg *config.DAGStoreConfig, marketApi MarketAPI) (*dagstore.DAG
 		failureCh:  failureCh,
 		traceCh:    traceCh,
 		gcInterval: time.Duration(cfg.GCInterval),
+		transients: transients,
+
+		indexRepo:    irepo,
+		blockIndex:   blockIndex,
+		blockIndexCh: make(chan shard.Key, blockIndexQueueLen),
 	}
 
 	return dagst, w, nil
@@ -147,6 +162,12 @@ func (w *Wrapper) Start(ctx context.Context) error {
 	w.backgroundWg.Add(1)
 	go w.traceLoop()
 
+	// run a go-routine to index the blocks of the initialized shards
+	if w.blockIndex != nil {
+		w.backgroundWg.Add(1)
+		go w.blockIndexLoop()
+	}
+
 	// Run a go-routine for shard recovery
 	if dss, ok := w.dagst.(*dagstore.DAGStore); ok {
 		w.backgroundWg.Add(1)
@@ -167,6 +188,9 @@ func (w *Wrapper) traceLoop() {
 				"shard-key", tr.Key.String(),
 				"op-type", tr.Op.String(),
 				"after", tr.After.String())
+			if tr.Op == dagstore.OpShardMakeAvailable {
+				w.queueBlockIndex(tr.Key)
+			}
 
 		case <-w.ctx.Done():
 			return
@@ -185,6 +209,10 @@ func (w *Wrapper) gcLoop() {
 		// GC the DAG store on every tick
 		case <-ticker.C:
 			_, _ = w.dagst.GC(w.ctx)
+			// without quota the transients are not kept once they are idle, as the dagstore does with its own
+			if w.transients != nil && !w.transients.Limited() {
+				w.transients.EvictIdle()
+			}
 
 		// Exit when the DAG store wrapper is shutdown
 		case <-w.ctx.Done():
@@ -254,6 +282,7 @@ func (w *Wrapper) RegisterShard(ctx context.Context, pieceCid cid.Cid, carPath s
 	if err != nil {
 		return xerrors.Errorf("failed to create lotus mount for piece CID %s: %w", pieceCid, err)
 	}
+	mt.Transients = w.transients
 
 	// Register the shard
 	opts := dagstore.RegisterOpts{
@@ -8,6 +8,8 @@ import (
 
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
 	"github.com/filecoin-project/venus-market/config"
+	"github.com/filecoin-project/venus-market/minermgr"
+	mtypes "github.com/filecoin-project/venus-market/types"
 	types "github.com/filecoin-project/venus/venus-shared/types/market"
 )
 
@@ -16,10 +18,15 @@ func CliStorageDealFilter(cmd string) config.StorageDealFilter {
 		d := struct {
 			storagemarket.MinerDeal
 			DealType string
+			// Miner is the account, the sector size and the tags of the provider in its sources
+			Miner *mtypes.MinerState `json:",omitempty"`
 		}{
 			MinerDeal: deal,
 			DealType:  "piecestorage",
 		}
+		if state, ok := minermgr.MinerStateFromContext(ctx); ok {
+			d.Miner = &state
+		}
 		return runDealFilter(ctx, cmd, d)
 	}
 }
@@ -3,9 +3,13 @@ package fundmgr
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/filecoin-project/venus-market/api/clients"
+	"github.com/filecoin-project/venus-market/config"
+	mtypes "github.com/filecoin-project/venus-market/types"
 	"github.com/filecoin-project/venus/venus-shared/actors"
 
 	"github.com/filecoin-project/venus-market/models/repo"
@@ -20,6 +24,7 @@ import (
 	types2 "github.com/filecoin-project/venus/venus-shared/types"
 	"github.com/ipfs/go-cid"
 	logging "github.com/ipfs/go-log/v2"
+	"go.opencensus.io/stats/view"
 	"go.uber.org/fx"
 	"golang.org/x/xerrors"
 )
@@ -34,6 +39,13 @@ type FundManagerAPI struct {
 	clients.IMixMessage
 }
 
+// FundManagerParams carry the fund policy and the fee cap of the add balance messages, the market client has none
+type FundManagerParams struct {
+	fx.In
+
+	Cfg *config.MarketConfig `optional:"true"`
+}
+
 // fundManagerAPI is the specific methods called by the FundManager
 // (used by the tests)
 type fundManagerAPI interface {
@@ -48,17 +60,35 @@ type FundManager struct {
 	ctx      context.Context
 	shutdown context.CancelFunc
 	api      fundManagerAPI
+	env      *fundManagerEnvironment
 	str      repo.FundRepo
+	policy   *config.FundPolicy
 
 	lk          sync.Mutex
 	fundedAddrs map[address.Address]*fundedAddress
 }
 
 // func NewFundManager(lc fx.Lifecycle, api FundManagerAPI, ds models.FundMgrDS, repo repo.Repo) *FundManager {
-func NewFundManager(lc fx.Lifecycle, api FundManagerAPI, repo repo.Repo) *FundManager {
-	fm := newFundManager(&api, repo.FundRepo())
+func NewFundManager(lc fx.Lifecycle, api FundManagerAPI, params FundManagerParams, repo repo.Repo) (*FundManager, error) {
+	fm := newFundManager(&api, repo.FundRepo(), params.Cfg)
+	if fm.policy != nil {
+		if err := validateFundPolicy(fm.policy); err != nil {
+			return nil, xerrors.Errorf("invalid fund policy: %w", err)
+		}
+		if fm.policy.ReportInterval > 0 {
+			if err := view.Register(escrowViews...); err != nil {
+				return nil, xerrors.Errorf("register escrow views: %w", err)
+			}
+		}
+	}
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
+			if fm.policy != nil && fm.policy.ReportInterval > 0 {
+				go fm.startReport(time.Duration(fm.policy.ReportInterval))
+			}
+			if fm.policy != nil && fm.policy.RefillInterval > 0 {
+				go fm.startRefill(time.Duration(fm.policy.RefillInterval))
+			}
 			return fm.Start(ctx)
 		},
 		OnStop: func(ctx context.Context) error {
@@ -66,20 +96,27 @@ func NewFundManager(lc fx.Lifecycle, api FundManagerAPI, repo repo.Repo) *FundMa
 			return nil
 		},
 	})
-	return fm
+	return fm, nil
 }
 
 // newFundManager is used by the tests
-func newFundManager(api fundManagerAPI, store repo.FundRepo) *FundManager {
+func newFundManager(api fundManagerAPI, store repo.FundRepo, cfg *config.MarketConfig) *FundManager {
 	ctx, cancel := context.WithCancel(context.Background())
-	return &FundManager{
+	fm := &FundManager{
 		ctx:         ctx,
 		shutdown:    cancel,
 		api:         api,
+		env:         &fundManagerEnvironment{api: api},
 		str:         store,
 		fundedAddrs: make(map[address.Address]*fundedAddress),
 		lk:          sync.Mutex{},
 	}
+	if cfg != nil {
+		fm.policy = &cfg.FundPolicy
+		fm.env.watermarks = fm.watermarks
+		fm.env.addBalanceSpec = &types2.MessageSendSpec{MaxFee: abi.TokenAmount(cfg.MaxMarketBalanceAddFee)}
+	}
+	return fm
 }
 
 func (fm *FundManager) Stop() {
@@ -146,6 +183,39 @@ func (fm *FundManager) GetReserved(addr address.Address) abi.TokenAmount {
 	return fm.getFundedAddress(addr).getReserved()
 }
 
+// Addresses returns the addresses the fund manager keeps track of
+func (fm *FundManager) Addresses() []address.Address {
+	fm.lk.Lock()
+	defer fm.lk.Unlock()
+
+	addrs := make([]address.Address, 0, len(fm.fundedAddrs))
+	for addr := range fm.fundedAddrs {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return addrs[i].String() < addrs[j].String()
+	})
+	return addrs
+}
+
+// FundState returns the market escrow of the address with the amount reserved, the message in progress and the
+// queued requests
+func (fm *FundManager) FundState(ctx context.Context, addr address.Address) (mtypes.FundState, error) {
+	bal, err := fm.api.StateMarketBalance(ctx, addr, types2.EmptyTSK)
+	if err != nil {
+		return mtypes.FundState{}, xerrors.Errorf("getting market balance of %s: %w", addr, err)
+	}
+
+	state := fm.getFundedAddress(addr).fundState()
+	state.Escrow = bal.Escrow
+	state.Locked = bal.Locked
+	state.Available = types2.BigSub(types2.BigSub(bal.Escrow, bal.Locked), state.Reserved)
+	if state.Available.LessThan(abi.NewTokenAmount(0)) {
+		state.Available = abi.NewTokenAmount(0)
+	}
+	return state, nil
+}
+
 // FundedAddressState keeps track of the state of an address with funds in the
 // datastore
 // type FundedAddressState struct {
@@ -166,6 +236,10 @@ type fundedAddress struct {
 
 	lk    sync.RWMutex
 	state *types.FundedAddressState
+	// msgKind is the kind of the message of state.MsgCid, it is not saved to store
+	msgKind string
+	// wallet of the last reservation, it pays the refills of the address
+	wallet address.Address
 
 	// Note: These request queues are ephemeral, they are not saved to store
 	reservations []*fundRequest
@@ -179,7 +253,7 @@ type fundedAddress struct {
 func newFundedAddress(fm *FundManager, addr address.Address) *fundedAddress {
 	return &fundedAddress{
 		ctx: fm.ctx,
-		env: &fundManagerEnvironment{api: fm.api},
+		env: fm.env,
 		str: fm.str,
 		state: &types.FundedAddressState{
 			Addr:        addr,
@@ -207,10 +281,51 @@ func (a *fundedAddress) getReserved() abi.TokenAmount {
 	return a.state.AmtReserved
 }
 
+func (a *fundedAddress) fundState() mtypes.FundState {
+	a.lk.RLock()
+	defer a.lk.RUnlock()
+
+	state := mtypes.FundState{
+		Addr:         a.state.Addr,
+		Reserved:     a.state.AmtReserved,
+		MsgKind:      a.msgKind,
+		Reservations: pendingReqs(a.reservations),
+		Releases:     pendingReqs(a.releases),
+		Withdrawals:  pendingReqs(a.withdrawals),
+	}
+	if a.state.MsgCid != nil {
+		msgCid := *a.state.MsgCid
+		state.MsgCid = &msgCid
+	}
+	return state
+}
+
+// pendingReqs lists the requests not completed yet
+func pendingReqs(reqs []*fundRequest) []mtypes.FundRequest {
+	pending := make([]mtypes.FundRequest, 0, len(reqs))
+	for _, req := range reqs {
+		if !req.Completed() {
+			pending = append(pending, mtypes.FundRequest{Wallet: req.Wallet, Amount: req.Amount()})
+		}
+	}
+	return pending
+}
+
 func (a *fundedAddress) reserve(ctx context.Context, wallet address.Address, amt abi.TokenAmount) (cid.Cid, error) {
+	a.lk.Lock()
+	a.wallet = wallet
+	a.lk.Unlock()
 	return a.requestAndWait(ctx, wallet, amt, &a.reservations)
 }
 
+// lastWallet returns the wallet of the last reservation, idle is false while a message or a request is in progress
+func (a *fundedAddress) lastWallet() (wallet address.Address, idle bool) {
+	a.lk.RLock()
+	defer a.lk.RUnlock()
+	idle = a.state.MsgCid == nil && len(a.reservations) == 0 && len(a.releases) == 0 && len(a.withdrawals) == 0
+	return a.wallet, idle
+}
+
 func (a *fundedAddress) release(amt abi.TokenAmount) error {
 	_, err := a.requestAndWait(context.Background(), address.Undef, amt, &a.releases)
 	return err
@@ -279,6 +394,9 @@ func (a *fundedAddress) process(ctx context.Context) {
 	if haveReservations {
 		res, err := a.processReservations(a.reservations, a.releases)
 		if err == nil {
+			if res.msgCid != nil {
+				a.msgKind = mtypes.FundMsgAddBalance
+			}
 			a.applyStateChange(ctx, res.msgCid, res.amtReserved)
 		}
 		a.reservations = filterOutProcessedReqs(a.reservations)
@@ -290,6 +408,7 @@ func (a *fundedAddress) process(ctx context.Context) {
 	if haveWithdrawals && a.state.MsgCid == nil && len(a.reservations) == 0 {
 		withdrawalCid, err := a.processWithdrawals(a.withdrawals)
 		if err == nil && withdrawalCid != cid.Undef {
+			a.msgKind = mtypes.FundMsgWithdrawBalance
 			a.applyStateChange(ctx, &withdrawalCid, types2.EmptyInt)
 		}
 		a.withdrawals = filterOutProcessedReqs(a.withdrawals)
@@ -328,6 +447,7 @@ func (a *fundedAddress) applyStateChange(ctx context.Context, msgCid *cid.Cid, a
 // Clear the pending message cid so that a new message can be sent
 func (a *fundedAddress) clearWaitState(ctx context.Context) {
 	a.state.MsgCid = nil
+	a.msgKind = ""
 	a.saveState(ctx)
 }
 
@@ -404,7 +524,9 @@ func (a *fundedAddress) processReservations(reservations []*fundRequest, release
 
 	// Work out the amount to add to the balance
 	amtToAdd := abi.NewTokenAmount(0)
-	if len(toAdd) > 0 && reserved.GreaterThan(abi.NewTokenAmount(0)) {
+	covered := true
+	// an address with watermarks is topped up when nothing is reserved as well
+	if len(toAdd) > 0 && (reserved.GreaterThan(abi.NewTokenAmount(0)) || a.env.watermarks != nil) {
 		// Get available funds for address
 		avail, err := a.env.AvailableFunds(a.ctx, a.state.Addr)
 		if err != nil {
@@ -413,7 +535,14 @@ func (a *fundedAddress) processReservations(reservations []*fundRequest, release
 
 		// amount to add = new reserved amount - available
 		amtToAdd = types2.BigSub(reserved, avail)
+		covered = amtToAdd.LessThanEqual(abi.NewTokenAmount(0))
 		a.debugf("reserved %d - avail %d = to add %d", reserved, avail, amtToAdd)
+
+		// If the reservation leaves less than the low watermark, top up to the high watermark
+		if topUp := a.env.TopUp(a.state.Addr, avail, reserved); topUp.GreaterThan(amtToAdd) {
+			amtToAdd = topUp
+			a.debugf("top up %d to the high watermark", topUp)
+		}
 	}
 
 	// If there's nothing to add to the balance, bail out
@@ -429,8 +558,13 @@ func (a *fundedAddress) processReservations(reservations []*fundRequest, release
 		return res, err
 	}
 
-	// Mark reservation requests as complete
-	res.added = toAdd
+	// Mark reservation requests as complete, the requests covered by the
+	// available funds don't wait for a top up
+	if covered {
+		res.covered = append(res.covered, toAdd...)
+	} else {
+		res.added = toAdd
+	}
 
 	// Save the message CID to state
 	res.msgCid = &addFundsCid
@@ -665,7 +799,29 @@ func (frp *fundRequest) Completed() bool {
 
 // fundManagerEnvironment simplifies some API calls
 type fundManagerEnvironment struct {
-	api fundManagerAPI
+	api            fundManagerAPI
+	addBalanceSpec *types2.MessageSendSpec
+	// watermarks returns the watermarks of the available funds of an address,
+	// nil when there is no fund policy
+	watermarks func(addr address.Address) (low, high abi.TokenAmount, ok bool)
+}
+
+// TopUp returns the amount to add to bring the funds left available by the
+// reservation back to the high watermark, zero unless they fall below the
+// low watermark
+func (env *fundManagerEnvironment) TopUp(addr address.Address, avail, reserved abi.TokenAmount) abi.TokenAmount {
+	if env.watermarks == nil {
+		return abi.NewTokenAmount(0)
+	}
+	low, high, ok := env.watermarks(addr)
+	if !ok {
+		return abi.NewTokenAmount(0)
+	}
+	left := types2.BigSub(avail, reserved)
+	if left.GreaterThanEqual(low) {
+		return abi.NewTokenAmount(0)
+	}
+	return types2.BigSub(high, left)
 }
 
 func (env *fundManagerEnvironment) AvailableFunds(ctx context.Context, addr address.Address) (abi.TokenAmount, error) {
@@ -694,7 +850,7 @@ func (env *fundManagerEnvironment) AddFunds(
 		Value:  amt,
 		Method: market.Methods.AddBalance,
 		Params: params,
-	}, nil)
+	}, env.addBalanceSpec)
 
 	if aerr != nil {
 		return cid.Undef, aerr
@@ -0,0 +1,242 @@
+package fundmgr
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/ipfs/go-cid"
+	blocksutil "github.com/ipfs/go-ipfs-blocksutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/venus-market/config"
+	mtypes "github.com/filecoin-project/venus-market/types"
+
+	types2 "github.com/filecoin-project/venus/venus-shared/types"
+	types "github.com/filecoin-project/venus/venus-shared/types/market"
+)
+
+type testingFundRepo struct {
+	lk     sync.Mutex
+	states map[address.Address]types.FundedAddressState
+}
+
+func (r *testingFundRepo) GetFundedAddressState(_ context.Context, addr address.Address) (*types.FundedAddressState, error) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	state := r.states[addr]
+	return &state, nil
+}
+
+func (r *testingFundRepo) SaveFundedAddressState(_ context.Context, fds *types.FundedAddressState) error {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	r.states[fds.Addr] = *fds
+	return nil
+}
+
+func (r *testingFundRepo) ListFundedAddressState(context.Context) ([]*types.FundedAddressState, error) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	states := make([]*types.FundedAddressState, 0, len(r.states))
+	for _, state := range r.states {
+		state := state
+		states = append(states, &state)
+	}
+	return states, nil
+}
+
+// testingFundAPI lands a pushed message for every value sent on landed
+type testingFundAPI struct {
+	bgen   *blocksutil.BlockGenerator
+	landed chan struct{}
+
+	lk      sync.Mutex
+	balance types2.MarketBalance
+	msgs    []*types2.Message
+	specs   []*types2.MessageSendSpec
+}
+
+func (a *testingFundAPI) StateMarketBalance(context.Context, address.Address, types2.TipSetKey) (types2.MarketBalance, error) {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	return a.balance, nil
+}
+
+func (a *testingFundAPI) PushMessage(_ context.Context, msg *types2.Message, spec *types2.MessageSendSpec) (cid.Cid, error) {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	a.msgs = append(a.msgs, msg)
+	a.specs = append(a.specs, spec)
+	return a.bgen.Next().Cid(), nil
+}
+
+func (a *testingFundAPI) WaitMsg(ctx context.Context, _ cid.Cid, _ uint64, _ abi.ChainEpoch, _ bool) (*types2.MsgLookup, error) {
+	select {
+	case <-a.landed:
+		return &types2.MsgLookup{}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestFundState(t *testing.T) {
+	ctx := context.Background()
+	wallet, _ := address.NewIDAddress(100)
+	miner, _ := address.NewIDAddress(1000)
+
+	api := &testingFundAPI{
+		bgen:   blocksutil.NewBlockGenerator(),
+		landed: make(chan struct{}),
+		balance: types2.MarketBalance{
+			Escrow: abi.NewTokenAmount(4),
+			Locked: abi.NewTokenAmount(1),
+		},
+	}
+	fm := newFundManager(api, &testingFundRepo{states: make(map[address.Address]types.FundedAddressState)}, nil)
+	defer fm.Stop()
+
+	// 3 of the escrow are available, reserving 10 adds 7
+	msgCid, err := fm.Reserve(ctx, wallet, miner, abi.NewTokenAmount(10))
+	require.NoError(t, err)
+	require.NotEqual(t, cid.Undef, msgCid)
+	require.Equal(t, []address.Address{miner}, fm.Addresses())
+
+	state, err := fm.FundState(ctx, miner)
+	require.NoError(t, err)
+	require.Equal(t, abi.NewTokenAmount(10), state.Reserved)
+	require.Equal(t, abi.NewTokenAmount(0), state.Available)
+	require.Equal(t, &msgCid, state.MsgCid)
+	require.Equal(t, mtypes.FundMsgAddBalance, state.MsgKind)
+
+	// the withdrawal waits for the add balance message to land
+	var withdrawCid cid.Cid
+	withdrawErr := make(chan error)
+	go func() {
+		var err error
+		withdrawCid, err = fm.Withdraw(ctx, wallet, miner, abi.NewTokenAmount(2))
+		withdrawErr <- err
+	}()
+	require.Eventually(t, func() bool {
+		state, err := fm.FundState(ctx, miner)
+		require.NoError(t, err)
+		return len(state.Withdrawals) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	state, err = fm.FundState(ctx, miner)
+	require.NoError(t, err)
+	require.Equal(t, []mtypes.FundRequest{{Wallet: wallet, Amount: abi.NewTokenAmount(2)}}, state.Withdrawals)
+	require.Empty(t, state.Reservations)
+
+	api.lk.Lock()
+	api.balance.Escrow = abi.NewTokenAmount(13)
+	api.lk.Unlock()
+	api.landed <- struct{}{}
+
+	require.NoError(t, <-withdrawErr)
+	require.Eventually(t, func() bool {
+		state, err := fm.FundState(ctx, miner)
+		require.NoError(t, err)
+		return len(state.Withdrawals) == 0 && state.MsgKind == mtypes.FundMsgWithdrawBalance
+	}, time.Second, 10*time.Millisecond)
+
+	state, err = fm.FundState(ctx, miner)
+	require.NoError(t, err)
+	require.Equal(t, &withdrawCid, state.MsgCid)
+	require.Equal(t, abi.NewTokenAmount(2), state.Available)
+}
+
+func TestFundPolicy(t *testing.T) {
+	ctx := context.Background()
+	wallet, _ := address.NewIDAddress(100)
+	miner, _ := address.NewIDAddress(1000)
+	other, _ := address.NewIDAddress(1001)
+	third, _ := address.NewIDAddress(1002)
+
+	cfg := &config.MarketConfig{
+		MaxMarketBalanceAddFee: types2.FIL(abi.NewTokenAmount(7)),
+		FundPolicy: config.FundPolicy{
+			LowWatermark:  types2.FIL(abi.NewTokenAmount(5)),
+			HighWatermark: types2.FIL(abi.NewTokenAmount(20)),
+			Miners: []config.MinerFundPolicy{{
+				Miner:         config.Address(other),
+				LowWatermark:  types2.FIL(abi.NewTokenAmount(0)),
+				HighWatermark: types2.FIL(abi.NewTokenAmount(0)),
+			}, {
+				// the high watermark is the one of the policy
+				Miner:        config.Address(third),
+				LowWatermark: types2.FIL(abi.NewTokenAmount(10)),
+				Wallet:       config.Address(wallet),
+			}},
+		},
+	}
+	require.NoError(t, validateFundPolicy(&cfg.FundPolicy))
+
+	api := &testingFundAPI{
+		bgen:   blocksutil.NewBlockGenerator(),
+		landed: make(chan struct{}),
+		balance: types2.MarketBalance{
+			Escrow: abi.NewTokenAmount(4),
+			Locked: abi.NewTokenAmount(1),
+		},
+	}
+	fm := newFundManager(api, &testingFundRepo{states: make(map[address.Address]types.FundedAddressState)}, cfg)
+	defer fm.Stop()
+
+	// 3 are available and cover the reservation, the 2 left are below the low watermark: the escrow is topped up to
+	// leave 20 available without the reservation waiting for it
+	msgCid, err := fm.Reserve(ctx, wallet, miner, abi.NewTokenAmount(1))
+	require.NoError(t, err)
+	require.Equal(t, cid.Undef, msgCid)
+	require.Len(t, api.msgs, 1)
+	require.Equal(t, abi.NewTokenAmount(18), api.msgs[0].Value)
+	require.Equal(t, abi.NewTokenAmount(7), api.specs[0].MaxFee)
+
+	state, err := fm.FundState(ctx, miner)
+	require.NoError(t, err)
+	require.Equal(t, mtypes.FundMsgAddBalance, state.MsgKind)
+
+	api.lk.Lock()
+	api.balance.Escrow = abi.NewTokenAmount(22)
+	api.lk.Unlock()
+	api.landed <- struct{}{}
+	require.Eventually(t, func() bool {
+		state, err := fm.FundState(ctx, miner)
+		require.NoError(t, err)
+		return state.MsgCid == nil
+	}, time.Second, 10*time.Millisecond)
+
+	// 18 are left above the low watermark
+	msgCid, err = fm.Reserve(ctx, wallet, miner, abi.NewTokenAmount(2))
+	require.NoError(t, err)
+	require.Equal(t, cid.Undef, msgCid)
+	require.Len(t, api.msgs, 1)
+
+	// the override disables the policy, only the shortfall is added
+	msgCid, err = fm.Reserve(ctx, wallet, other, abi.NewTokenAmount(30))
+	require.NoError(t, err)
+	require.NotEqual(t, cid.Undef, msgCid)
+	require.Len(t, api.msgs, 2)
+	require.Equal(t, abi.NewTokenAmount(9), api.msgs[1].Value)
+
+	// the deals lock more of the escrow, it is refilled between the deals: 4 are left for the miner, which is topped
+	// up to 20, and 7 for the miner that never reserved, below its own low watermark
+	api.lk.Lock()
+	api.balance.Locked = abi.NewTokenAmount(15)
+	api.lk.Unlock()
+	fm.refill(ctx)
+	require.Len(t, api.msgs, 4)
+	require.Equal(t, abi.NewTokenAmount(16), api.msgs[2].Value)
+	require.Equal(t, abi.NewTokenAmount(13), api.msgs[3].Value)
+	require.Equal(t, wallet, api.msgs[3].From)
+
+	// the refills in progress are not repeated
+	fm.refill(ctx)
+	require.Len(t, api.msgs, 4)
+
+	cfg.FundPolicy.LowWatermark = types2.FIL(abi.NewTokenAmount(30))
+	require.Error(t, validateFundPolicy(&cfg.FundPolicy))
+}
@@ -0,0 +1,196 @@
+package fundmgr
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/venus-market/config"
+
+	"github.com/filecoin-project/venus/pkg/constants"
+	types2 "github.com/filecoin-project/venus/venus-shared/types"
+)
+
+var (
+	escrowAddrKey, _ = tag.NewKey("address")
+
+	escrowBalance     = stats.Float64("market/escrow_balance", "Market escrow of the address in FIL", "FIL")
+	escrowLocked      = stats.Float64("market/escrow_locked", "Market escrow locked by the active deals in FIL", "FIL")
+	escrowReserved    = stats.Float64("market/escrow_reserved", "Market escrow reserved by the deals being published in FIL", "FIL")
+	escrowAvailable   = stats.Float64("market/escrow_available", "Market escrow neither locked nor reserved in FIL", "FIL")
+	escrowUtilization = stats.Float64("market/escrow_utilization", "Share of the market escrow locked or reserved", stats.UnitDimensionless)
+
+	escrowViews = func() []*view.View {
+		var views []*view.View
+		for _, m := range []*stats.Float64Measure{escrowBalance, escrowLocked, escrowReserved, escrowAvailable, escrowUtilization} {
+			views = append(views, &view.View{
+				Measure:     m,
+				Aggregation: view.LastValue(),
+				TagKeys:     []tag.Key{escrowAddrKey},
+			})
+		}
+		return views
+	}()
+)
+
+func validateFundPolicy(policy *config.FundPolicy) error {
+	check := func(low, high types2.FIL) error {
+		if high.Int == nil || high.IsZero() {
+			return nil
+		}
+		if low.Int != nil && abi.TokenAmount(low).GreaterThan(abi.TokenAmount(high)) {
+			return xerrors.Errorf("low watermark %s above the high watermark %s", low, high)
+		}
+		return nil
+	}
+	if err := check(policy.LowWatermark, policy.HighWatermark); err != nil {
+		return err
+	}
+	for _, miner := range policy.Miners {
+		if err := check(miner.LowWatermark, miner.HighWatermark); err != nil {
+			return xerrors.Errorf("miner %s: %w", address.Address(miner.Miner), err)
+		}
+	}
+	return nil
+}
+
+// watermarks returns the watermarks of the available funds of the address, ok is false when its high watermark is
+// zero. a watermark the override of the miner leaves unset is the one of the policy
+func (fm *FundManager) watermarks(addr address.Address) (low, high abi.TokenAmount, ok bool) {
+	lowFIL, highFIL := fm.policy.LowWatermark, fm.policy.HighWatermark
+	if miner := fm.minerPolicy(addr); miner != nil {
+		if miner.LowWatermark.Int != nil {
+			lowFIL = miner.LowWatermark
+		}
+		if miner.HighWatermark.Int != nil {
+			highFIL = miner.HighWatermark
+		}
+	}
+	if highFIL.Int == nil || highFIL.IsZero() {
+		return abi.NewTokenAmount(0), abi.NewTokenAmount(0), false
+	}
+	low = abi.NewTokenAmount(0)
+	if lowFIL.Int != nil {
+		low = abi.TokenAmount(lowFIL)
+	}
+	return low, abi.TokenAmount(highFIL), true
+}
+
+func (fm *FundManager) minerPolicy(addr address.Address) *config.MinerFundPolicy {
+	for i := range fm.policy.Miners {
+		if address.Address(fm.policy.Miners[i].Miner) == addr {
+			return &fm.policy.Miners[i]
+		}
+	}
+	return nil
+}
+
+// startRefill tops up the escrow of the addresses below their low watermark every interval, so that the escrow is
+// refilled between deals and not only by the next reservation
+func (fm *FundManager) startRefill(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			fm.refill(fm.ctx)
+		case <-fm.ctx.Done():
+			log.Warnf("exit fund refill by context")
+			return
+		}
+	}
+}
+
+// refill reserves nothing for every address with watermarks and a wallet, the reservation adds the funds to bring
+// the escrow back to the high watermark when it is below the low one. the addresses with a message or a request in
+// progress are skipped, they are checked again by their next reservation
+func (fm *FundManager) refill(ctx context.Context) {
+	addrs := fm.Addresses()
+	for _, miner := range fm.policy.Miners {
+		if addr := address.Address(miner.Miner); addr != address.Undef && address.Address(miner.Wallet) != address.Undef {
+			addrs = append(addrs, addr)
+		}
+	}
+
+	seen := make(map[address.Address]struct{}, len(addrs))
+	for _, addr := range addrs {
+		if _, ok := seen[addr]; ok {
+			continue
+		}
+		seen[addr] = struct{}{}
+		if _, _, ok := fm.watermarks(addr); !ok {
+			continue
+		}
+
+		fa := fm.getFundedAddress(addr)
+		wallet, idle := fa.lastWallet()
+		if !idle {
+			continue
+		}
+		if miner := fm.minerPolicy(addr); miner != nil && address.Address(miner.Wallet) != address.Undef {
+			wallet = address.Address(miner.Wallet)
+		}
+		if wallet == address.Undef {
+			continue
+		}
+		if _, err := fa.reserve(ctx, wallet, abi.NewTokenAmount(0)); err != nil {
+			log.Errorf("refill funds of %s from %s: %v", addr, wallet, err)
+		}
+	}
+}
+
+// startReport records the collateral utilization of the addresses every interval until the fund manager stops
+func (fm *FundManager) startReport(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			fm.report(fm.ctx)
+		case <-fm.ctx.Done():
+			log.Warnf("exit fund report by context")
+			return
+		}
+	}
+}
+
+func (fm *FundManager) report(ctx context.Context) {
+	for _, addr := range fm.Addresses() {
+		state, err := fm.FundState(ctx, addr)
+		if err != nil {
+			log.Errorf("report funds of %s: %v", addr, err)
+			continue
+		}
+
+		utilization := 0.0
+		if !state.Escrow.IsZero() {
+			utilization = toFIL(types2.BigAdd(state.Locked, state.Reserved)) / toFIL(state.Escrow)
+		}
+		log.Infow("collateral utilization", "address", addr, "escrow", types2.FIL(state.Escrow),
+			"locked", types2.FIL(state.Locked), "reserved", types2.FIL(state.Reserved),
+			"available", types2.FIL(state.Available), "utilization", utilization)
+
+		if err := stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(escrowAddrKey, addr.String())},
+			escrowBalance.M(toFIL(state.Escrow)),
+			escrowLocked.M(toFIL(state.Locked)),
+			escrowReserved.M(toFIL(state.Reserved)),
+			escrowAvailable.M(toFIL(state.Available)),
+			escrowUtilization.M(utilization),
+		); err != nil {
+			log.Errorf("record funds of %s: %v", addr, err)
+		}
+	}
+}
+
+func toFIL(amt abi.TokenAmount) float64 {
+	fil, _ := new(big.Float).Quo(new(big.Float).SetInt(amt.Int), new(big.Float).SetUint64(constants.FilecoinPrecision)).Float64()
+	return fil
+}
@@ -0,0 +1,351 @@
+package keystore
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/crypto"
+	logging "github.com/ipfs/go-log/v2"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/xerrors"
+
+	vCrypto "github.com/filecoin-project/venus/pkg/crypto"
+	_ "github.com/filecoin-project/venus/pkg/crypto/bls"
+	_ "github.com/filecoin-project/venus/pkg/crypto/secp"
+	types2 "github.com/filecoin-project/venus/venus-shared/types"
+)
+
+var log = logging.Logger("keystore")
+
+// DefaultDir is the directory of the keystore in the repo home
+const DefaultDir = "keystore"
+
+const (
+	keyExt         = ".key"
+	encryptVersion = 1
+
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+	keyLen  = 32
+)
+
+var ErrKeyNotFound = xerrors.New("key not found")
+
+// encryptedKey is the file of a key, the key info is sealed with AES-GCM under a key derived from the passphrase by
+// scrypt, the address is the additional data so a file renamed to another address does not open
+type encryptedKey struct {
+	Version    int
+	Salt       []byte
+	Nonce      []byte
+	Ciphertext []byte
+}
+
+// KeyStore keeps the secp256k1 and BLS keys encrypted in a directory, one file per address. The keys are read on
+// demand so the keys added by `wallet` commands while the market runs are found, a key is kept decrypted in memory
+// once used.
+type KeyStore struct {
+	dir        string
+	passphrase []byte
+
+	lk   sync.Mutex
+	keys map[address.Address]*types2.KeyInfo
+}
+
+func NewKeyStore(dir string, passphrase string) (*KeyStore, error) {
+	if len(passphrase) == 0 {
+		return nil, xerrors.New("keystore needs a password")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, xerrors.Errorf("create keystore dir %s: %w", dir, err)
+	}
+	ks := &KeyStore{
+		dir:        dir,
+		passphrase: []byte(passphrase),
+		keys:       make(map[address.Address]*types2.KeyInfo),
+	}
+	if err := ks.checkPassphrase(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// checkPassphrase decrypts one of the keys so a wrong password fails at open instead of at the first signature
+func (ks *KeyStore) checkPassphrase() error {
+	addrs, err := ks.List()
+	if err != nil {
+		return xerrors.Errorf("list keystore dir %s: %w", ks.dir, err)
+	}
+	if len(addrs) == 0 {
+		return nil
+	}
+	ks.lk.Lock()
+	defer ks.lk.Unlock()
+	if _, err := ks.get(addrs[0]); err != nil {
+		return xerrors.Errorf("open keystore %s: %w", ks.dir, err)
+	}
+	return nil
+}
+
+// SigType returns the signature type of the keys of type kt
+func SigType(kt types2.KeyType) (crypto.SigType, error) {
+	switch kt {
+	case types2.KTSecp256k1:
+		return crypto.SigTypeSecp256k1, nil
+	case types2.KTBLS:
+		return crypto.SigTypeBLS, nil
+	default:
+		return crypto.SigTypeUnknown, xerrors.Errorf("unsupported key type %s", kt)
+	}
+}
+
+// KeyAddress returns the address of the public key of the key
+func KeyAddress(ki *types2.KeyInfo) (address.Address, error) {
+	sigType, err := SigType(ki.Type)
+	if err != nil {
+		return address.Undef, err
+	}
+	pub, err := vCrypto.ToPublic(sigType, ki.PrivateKey)
+	if err != nil {
+		return address.Undef, err
+	}
+	if sigType == crypto.SigTypeBLS {
+		return address.NewBLSAddress(pub)
+	}
+	return address.NewSecp256k1Address(pub)
+}
+
+// EncodeKey encodes the key the way `wallet export` prints it, the hex of its json
+func EncodeKey(ki *types2.KeyInfo) (string, error) {
+	data, err := json.Marshal(ki)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
+
+// DecodeKey decodes a key printed by `wallet export`
+func DecodeKey(s string) (*types2.KeyInfo, error) {
+	data, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, xerrors.Errorf("decode hex key: %w", err)
+	}
+	var ki types2.KeyInfo
+	if err := json.Unmarshal(data, &ki); err != nil {
+		return nil, xerrors.Errorf("unmarshal key: %w", err)
+	}
+	return &ki, nil
+}
+
+func (ks *KeyStore) keyPath(addr address.Address) string {
+	return filepath.Join(ks.dir, hex.EncodeToString(addr.Bytes())+keyExt)
+}
+
+// New generates a key of type kt and returns its address
+func (ks *KeyStore) New(kt types2.KeyType) (address.Address, error) {
+	sigType, err := SigType(kt)
+	if err != nil {
+		return address.Undef, err
+	}
+	priv, err := vCrypto.Generate(sigType)
+	if err != nil {
+		return address.Undef, xerrors.Errorf("generate %s key: %w", kt, err)
+	}
+	return ks.Import(&types2.KeyInfo{Type: kt, PrivateKey: priv})
+}
+
+// Import stores the key and returns its address, importing a key twice fails
+func (ks *KeyStore) Import(ki *types2.KeyInfo) (address.Address, error) {
+	addr, err := KeyAddress(ki)
+	if err != nil {
+		return address.Undef, err
+	}
+
+	ks.lk.Lock()
+	defer ks.lk.Unlock()
+
+	path := ks.keyPath(addr)
+	if _, err := os.Stat(path); err == nil {
+		return address.Undef, xerrors.Errorf("key of %s already exists", addr)
+	} else if !os.IsNotExist(err) {
+		return address.Undef, err
+	}
+
+	data, err := ks.encrypt(addr, ki)
+	if err != nil {
+		return address.Undef, xerrors.Errorf("encrypt key of %s: %w", addr, err)
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return address.Undef, xerrors.Errorf("write key of %s: %w", addr, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return address.Undef, xerrors.Errorf("write key of %s: %w", addr, err)
+	}
+	ks.keys[addr] = ki
+	return addr, nil
+}
+
+// List returns the addresses of the keys
+func (ks *KeyStore) List() ([]address.Address, error) {
+	entries, err := ioutil.ReadDir(ks.dir)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]address.Address, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, keyExt) {
+			continue
+		}
+		raw, err := hex.DecodeString(strings.TrimSuffix(name, keyExt))
+		if err != nil {
+			log.Warnf("skip keystore file %s: %v", name, err)
+			continue
+		}
+		addr, err := address.NewFromBytes(raw)
+		if err != nil {
+			log.Warnf("skip keystore file %s: %v", name, err)
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return addrs[i].String() < addrs[j].String()
+	})
+	return addrs, nil
+}
+
+// Export returns a copy of the decrypted key of addr
+func (ks *KeyStore) Export(addr address.Address) (*types2.KeyInfo, error) {
+	ks.lk.Lock()
+	defer ks.lk.Unlock()
+	ki, err := ks.get(addr)
+	if err != nil {
+		return nil, err
+	}
+	return &types2.KeyInfo{
+		Type:       ki.Type,
+		PrivateKey: append([]byte(nil), ki.PrivateKey...),
+	}, nil
+}
+
+func (ks *KeyStore) get(addr address.Address) (*types2.KeyInfo, error) {
+	if ki, ok := ks.keys[addr]; ok {
+		return ki, nil
+	}
+	data, err := ioutil.ReadFile(ks.keyPath(addr))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, xerrors.Errorf("%s: %w", addr, ErrKeyNotFound)
+		}
+		return nil, err
+	}
+	ki, err := ks.decrypt(addr, data)
+	if err != nil {
+		return nil, xerrors.Errorf("decrypt key of %s: %w", addr, err)
+	}
+	ks.keys[addr] = ki
+	return ki, nil
+}
+
+// WalletHas decrypts the key of addr, a key the password does not open is an error
+func (ks *KeyStore) WalletHas(_ context.Context, addr address.Address) (bool, error) {
+	ks.lk.Lock()
+	defer ks.lk.Unlock()
+	if _, err := ks.get(addr); err != nil {
+		if xerrors.Is(err, ErrKeyNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (ks *KeyStore) WalletSign(_ context.Context, addr address.Address, msg []byte, _ types2.MsgMeta) (*vCrypto.Signature, error) {
+	ks.lk.Lock()
+	ki, err := ks.get(addr)
+	ks.lk.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	sigType, err := SigType(ki.Type)
+	if err != nil {
+		return nil, err
+	}
+	return vCrypto.Sign(msg, ki.PrivateKey, sigType)
+}
+
+func (ks *KeyStore) aead(salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(ks.passphrase, salt, scryptN, scryptR, scryptP, keyLen)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (ks *KeyStore) encrypt(addr address.Address, ki *types2.KeyInfo) ([]byte, error) {
+	plain, err := json.Marshal(ki)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := ks.aead(salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return json.Marshal(&encryptedKey{
+		Version:    encryptVersion,
+		Salt:       salt,
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, plain, addr.Bytes()),
+	})
+}
+
+func (ks *KeyStore) decrypt(addr address.Address, data []byte) (*types2.KeyInfo, error) {
+	var ek encryptedKey
+	if err := json.Unmarshal(data, &ek); err != nil {
+		return nil, err
+	}
+	if ek.Version != encryptVersion {
+		return nil, xerrors.Errorf("unsupported key version %d", ek.Version)
+	}
+	aead, err := ks.aead(ek.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(ek.Nonce) != aead.NonceSize() {
+		return nil, xerrors.New("invalid nonce")
+	}
+	plain, err := aead.Open(nil, ek.Nonce, ek.Ciphertext, addr.Bytes())
+	if err != nil {
+		return nil, xerrors.New("wrong password or corrupted key")
+	}
+	var ki types2.KeyInfo
+	if err := json.Unmarshal(plain, &ki); err != nil {
+		return nil, err
+	}
+	return &ki, nil
+}
@@ -0,0 +1,76 @@
+package keystore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	vCrypto "github.com/filecoin-project/venus/pkg/crypto"
+	types2 "github.com/filecoin-project/venus/venus-shared/types"
+)
+
+func TestKeyStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	ks, err := NewKeyStore(dir, "password")
+	require.NoError(t, err)
+
+	secpAddr, err := ks.New(types2.KTSecp256k1)
+	require.NoError(t, err)
+	blsAddr, err := ks.New(types2.KTBLS)
+	require.NoError(t, err)
+
+	addrs, err := ks.List()
+	require.NoError(t, err)
+	require.ElementsMatch(t, addrs, []address.Address{secpAddr, blsAddr})
+
+	// a new keystore on the same dir decrypts the keys from the files
+	ks2, err := NewKeyStore(dir, "password")
+	require.NoError(t, err)
+	for _, addr := range addrs {
+		has, err := ks2.WalletHas(ctx, addr)
+		require.NoError(t, err)
+		require.True(t, has)
+
+		sig, err := ks2.WalletSign(ctx, addr, []byte("msg"), types2.MsgMeta{})
+		require.NoError(t, err)
+		require.NoError(t, vCrypto.Verify(sig, addr, []byte("msg")))
+	}
+
+	// export then import into another keystore
+	ki, err := ks2.Export(blsAddr)
+	require.NoError(t, err)
+	encoded, err := EncodeKey(ki)
+	require.NoError(t, err)
+	decoded, err := DecodeKey(encoded)
+	require.NoError(t, err)
+	other, err := NewKeyStore(t.TempDir(), "other")
+	require.NoError(t, err)
+	addr, err := other.Import(decoded)
+	require.NoError(t, err)
+	require.Equal(t, blsAddr, addr)
+	_, err = other.Import(decoded)
+	require.Error(t, err)
+
+	// the exported key is a copy
+	ki.PrivateKey[0]++
+	again, err := ks2.Export(blsAddr)
+	require.NoError(t, err)
+	require.NotEqual(t, ki.PrivateKey, again.PrivateKey)
+
+	// the keystore does not open with another password
+	_, err = NewKeyStore(dir, "wrong")
+	require.Error(t, err)
+
+	has, err := other.WalletHas(ctx, secpAddr)
+	require.NoError(t, err)
+	require.False(t, has)
+	_, err = other.WalletSign(ctx, secpAddr, []byte("msg"), types2.MsgMeta{})
+	require.True(t, xerrors.Is(err, ErrKeyNotFound))
+
+	_, err = NewKeyStore(t.TempDir(), "")
+	require.Error(t, err)
+}
@@ -3,22 +3,20 @@ package minermgr
 import (
 	"bytes"
 	"context"
-	"encoding/json"
-	"fmt"
-	"net/http"
 	"sync"
 	"time"
 
 	"github.com/filecoin-project/venus/venus-shared/actors/builtin"
 	v1api "github.com/filecoin-project/venus/venus-shared/api/chain/v1"
 	"github.com/ipfs-force-community/venus-common-utils/metrics"
+	"go.uber.org/multierr"
 	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/go-address"
-	"github.com/go-resty/resty/v2"
 	logging "github.com/ipfs/go-log/v2"
 
 	"github.com/filecoin-project/venus-market/config"
+	mtypes "github.com/filecoin-project/venus-market/types"
 	vTypes "github.com/filecoin-project/venus/venus-shared/types"
 	types "github.com/filecoin-project/venus/venus-shared/types/market"
 )
@@ -27,19 +25,51 @@ const CoMinersLimit = 200
 
 var log = logging.Logger("address-manager")
 
+// ErrMinerNotFound is returned when an address is not served by the market
+var ErrMinerNotFound = xerrors.New("miner not found")
+
 type IAddrMgr interface {
 	ActorAddress(ctx context.Context) ([]address.Address, error)
 	ActorList(ctx context.Context) ([]types.User, error)
 	Has(ctx context.Context, addr address.Address) bool
 	GetMiners(ctx context.Context) ([]types.User, error)
 	GetAccount(ctx context.Context, addr address.Address) (string, error)
+	// AddAddress adds the miner to the storage miners of the config file
 	AddAddress(ctx context.Context, user types.User) error
+	// RemoveAddress removes the miner from the storage miners of the config file, with the addresses of its actor
+	RemoveAddress(ctx context.Context, addr address.Address) error
+	// SetDisabled disables or enables the miner again, a disabled miner takes no new deals and publishes no asks
+	SetDisabled(ctx context.Context, addr address.Address, disabled bool) error
+	IsDisabled(ctx context.Context, addr address.Address) bool
+	MinerStates(ctx context.Context) ([]mtypes.MinerState, error)
+	// MinerState returns the merged metadata of the sources of a miner
+	MinerState(ctx context.Context, addr address.Address) (mtypes.MinerState, error)
 }
 
-type UserMgrImpl struct {
-	authCfg  config.AuthNode
-	fullNode v1api.FullNode
+// minerEntry is an address listed by the sources, with the addresses of its actor when it is a storage miner
+type minerEntry struct {
+	addr address.Address
+	// metas are the miner as described by each source listing it, by source name
+	metas map[string]mtypes.MinerMeta
+	// keys are the account keys of the worker, owner and control addresses of a storage miner actor
+	keys []address.Address
+}
 
+type UserMgrImpl struct {
+	cfg        *config.MarketConfig
+	fullNode   v1api.FullNode
+	saveConfig func() error
+	// cfgLk guards the miners of the config, it is taken before lk
+	cfgLk sync.Mutex
+
+	// sources by precedence, the config comes first
+	sources []IMinerSource
+	// syncLk serializes the syncs, the miners a sync resolves can not be removed before it adds them
+	syncLk sync.Mutex
+
+	// entries are in the order they were added
+	entries []*minerEntry
+	// miners are the addresses of the entries followed by their keys, rebuilt on every change
 	miners []types.User
 	lk     sync.Mutex
 }
@@ -47,32 +77,36 @@ type UserMgrImpl struct {
 var _ IAddrMgr = (*UserMgrImpl)(nil)
 
 func NeAddrMgrImpl(ctx metrics.MetricsCtx, fullNode v1api.FullNode, cfg *config.MarketConfig) (IAddrMgr, error) {
-	m := &UserMgrImpl{authCfg: cfg.AuthNode, fullNode: fullNode}
-
-	err := m.distAddress(ctx, convertConfigAddress(cfg.StorageMiners)...)
-	if err != nil {
-		return nil, err
+	m := newUserMgrImpl(fullNode, cfg)
+	for _, sourceCfg := range cfg.MinerSources {
+		source, err := NewMinerSource(sourceCfg, cfg.AuthNode)
+		if err != nil {
+			return nil, err
+		}
+		m.sources = append(m.sources, source)
 	}
 
-	err = m.distAddress(ctx, types.User{
-		Addr:    address.Address(cfg.RetrievalPaymentAddress.Addr),
-		Account: cfg.RetrievalPaymentAddress.Account,
-	})
-	if err != nil {
-		return nil, err
+	// a source failing at startup does not stop the market, the refresh retries it and the miners of the other
+	// sources are served meanwhile
+	for _, source := range m.sources {
+		if err := m.syncSource(ctx, source); err != nil {
+			log.Errorf("unable to sync miners of %s, retry at the next refresh: %s", source.Name(), err)
+		}
 	}
+	go m.refreshUsers(ctx, time.Duration(cfg.MinerSyncInterval))
+	return m, nil
+}
 
-	err = m.distAddress(ctx, convertConfigAddress(cfg.AddressConfig.DealPublishControl)...)
-	if err != nil {
-		return nil, err
+func newUserMgrImpl(fullNode v1api.FullNode, cfg *config.MarketConfig) *UserMgrImpl {
+	m := &UserMgrImpl{
+		cfg:      cfg,
+		fullNode: fullNode,
+		saveConfig: func() error {
+			return config.SaveConfig(cfg)
+		},
 	}
-	miners, err := m.getMinerFromVenusAuth(context.TODO(), 0, 0)
-	if err != nil {
-		return nil, err
-	}
-	defer func() { go m.refreshUsers(ctx) }()
-	return m, m.distAddress(ctx, miners...)
-
+	m.sources = []IMinerSource{&configSource{cfg: cfg, lk: &m.cfgLk}}
+	return m
 }
 
 func (m *UserMgrImpl) ActorAddress(ctx context.Context) ([]address.Address, error) {
@@ -135,152 +169,337 @@ func (m *UserMgrImpl) GetAccount(ctx context.Context, addr address.Address) (str
 	return account, nil
 }
 
-func (m *UserMgrImpl) getMinerFromVenusAuth(ctx context.Context, skip, limit int64) ([]types.User, error) {
-	log.Infof("request miners from auth: %v ...", m.authCfg)
-	if len(m.authCfg.Url) == 0 {
-		return nil, nil
+func (m *UserMgrImpl) AddAddress(ctx context.Context, user types.User) error {
+	if user.Addr == address.Undef {
+		return xerrors.New("miner address is undef")
+	}
+
+	m.cfgLk.Lock()
+	storageMiners := m.cfg.StorageMiners
+	exist := false
+	for _, miner := range storageMiners {
+		exist = exist || address.Address(miner.Addr) == user.Addr
 	}
-	if limit == 0 {
-		limit = CoMinersLimit
+	if !exist {
+		m.cfg.StorageMiners = append(storageMiners[:len(storageMiners):len(storageMiners)], config.User{
+			Addr:    config.Address(user.Addr),
+			Account: user.Account,
+		})
+		if err := m.saveConfig(); err != nil {
+			m.cfg.StorageMiners = storageMiners
+			m.cfgLk.Unlock()
+			return xerrors.Errorf("save config: %w", err)
+		}
 	}
-	cli := resty.New().SetHostURL(m.authCfg.Url).SetHeader("Accept", "application/json")
-	response, err := cli.R().SetQueryParams(map[string]string{
-		"token": m.authCfg.Token,
-		"skip":  fmt.Sprintf("%d", skip),
-		"limit": fmt.Sprintf("%d", limit),
-	}).Get("/user/list")
+	m.cfgLk.Unlock()
+
+	return m.syncSource(ctx, m.sources[0])
+}
+
+func (m *UserMgrImpl) RemoveAddress(ctx context.Context, addr address.Address) error {
+	state, err := m.MinerState(ctx, addr)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	switch response.StatusCode() {
-	case http.StatusOK:
-		var res []AuthUser
-		err = json.Unmarshal(response.Body(), &res)
-		if err != nil {
-			return nil, err
+	m.cfgLk.Lock()
+	if address.Address(m.cfg.RetrievalPaymentAddress.Addr) == addr {
+		m.cfgLk.Unlock()
+		return xerrors.Errorf("%s is the retrieval payment address", addr)
+	}
+	for _, ctl := range m.cfg.AddressConfig.DealPublishControl {
+		if address.Address(ctl.Addr) == addr {
+			m.cfgLk.Unlock()
+			return xerrors.Errorf("%s is a deal publish control address", addr)
 		}
-
-		m.lk.Lock()
-		var miners []types.User
-		for _, val := range res {
-			if len(val.Miner) > 0 && val.State == 1 {
-				addr, err := address.NewFromString(val.Miner)
-				if err == nil && addr != address.Undef {
-					miners = append(miners, types.User{
-						Addr:    addr,
-						Account: val.Name,
-					})
-				} else {
-					log.Warnf("miner [%s] is error", val.Miner)
-				}
-			}
+	}
+	storageMiners, disabledMiners := m.cfg.StorageMiners, m.cfg.DisabledMiners
+	m.cfg.StorageMiners = make([]config.User, 0, len(storageMiners))
+	for _, miner := range storageMiners {
+		if address.Address(miner.Addr) != addr {
+			m.cfg.StorageMiners = append(m.cfg.StorageMiners, miner)
 		}
-		m.lk.Unlock()
-		return miners, err
-	default:
-		response.Result()
-		return nil, fmt.Errorf("response code is : %d, msg:%s", response.StatusCode(), response.Body())
 	}
+	if len(m.cfg.StorageMiners) == len(storageMiners) {
+		m.cfg.StorageMiners = storageMiners
+		m.cfgLk.Unlock()
+		return xerrors.Errorf("miner %s comes from %v, remove it there", addr, state.Sources)
+	}
+	m.cfg.DisabledMiners = removeConfigAddress(disabledMiners, addr)
+	if err := m.saveConfig(); err != nil {
+		m.cfg.StorageMiners, m.cfg.DisabledMiners = storageMiners, disabledMiners
+		m.cfgLk.Unlock()
+		return xerrors.Errorf("save config: %w", err)
+	}
+	m.cfgLk.Unlock()
+
+	if err := m.syncSource(ctx, m.sources[0]); err != nil {
+		return err
+	}
+	if m.Has(ctx, addr) {
+		log.Warnf("miner %s is still listed by other sources", addr)
+	}
+	return nil
 }
 
-func (m *UserMgrImpl) AddAddress(ctx context.Context, user types.User) error {
-	return m.distAddress(ctx, user)
+func (m *UserMgrImpl) SetDisabled(ctx context.Context, addr address.Address, disabled bool) error {
+	m.cfgLk.Lock()
+	defer m.cfgLk.Unlock()
+
+	m.lk.Lock()
+	entry := m.entry(addr)
+	m.lk.Unlock()
+	if entry == nil {
+		return xerrors.Errorf("%s: %w", addr, ErrMinerNotFound)
+	}
+	if m.disabledByOperator(addr) == disabled {
+		return nil
+	}
+
+	disabledMiners := m.cfg.DisabledMiners
+	if disabled {
+		m.cfg.DisabledMiners = append(disabledMiners[:len(disabledMiners):len(disabledMiners)], config.Address(addr))
+	} else {
+		m.cfg.DisabledMiners = removeConfigAddress(disabledMiners, addr)
+	}
+	if err := m.saveConfig(); err != nil {
+		m.cfg.DisabledMiners = disabledMiners
+		return xerrors.Errorf("save config: %w", err)
+	}
+	log.Infow("miner disabled state changed", "miner", addr, "disabled", disabled)
+	return nil
+}
+
+func (m *UserMgrImpl) IsDisabled(ctx context.Context, addr address.Address) bool {
+	state, err := m.MinerState(ctx, addr)
+	return err == nil && state.Disabled()
 }
 
-func (m *UserMgrImpl) distAddress(ctx context.Context, addrs ...types.User) error {
+func (m *UserMgrImpl) MinerStates(ctx context.Context) ([]mtypes.MinerState, error) {
+	m.cfgLk.Lock()
+	defer m.cfgLk.Unlock()
 	m.lk.Lock()
 	defer m.lk.Unlock()
-	filter := make(map[address.Address]struct{}, len(m.miners))
-	for _, miner := range m.miners {
-		filter[miner.Addr] = struct{}{}
+
+	states := make([]mtypes.MinerState, 0, len(m.entries))
+	for _, entry := range m.entries {
+		states = append(states, m.state(entry))
+	}
+	return states, nil
+}
+
+func (m *UserMgrImpl) MinerState(ctx context.Context, addr address.Address) (mtypes.MinerState, error) {
+	m.cfgLk.Lock()
+	defer m.cfgLk.Unlock()
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	entry := m.entry(addr)
+	if entry == nil {
+		return mtypes.MinerState{}, xerrors.Errorf("%s: %w", addr, ErrMinerNotFound)
 	}
+	return m.state(entry), nil
+}
 
-	for _, usr := range addrs {
-		if usr.Addr == address.Undef {
+// state merges the metas of the sources by precedence, it must be called with both locks held
+func (m *UserMgrImpl) state(entry *minerEntry) mtypes.MinerState {
+	state := mtypes.MinerState{
+		Addr:       entry.addr,
+		Sources:    make([]string, 0, len(entry.metas)),
+		DisabledBy: []string{},
+	}
+	if m.disabledByOperator(entry.addr) {
+		state.DisabledBy = append(state.DisabledBy, mtypes.MinerDisabledByOperator)
+	}
+	for _, source := range m.sources {
+		meta, ok := entry.metas[source.Name()]
+		if !ok {
 			continue
 		}
-		if _, ok := filter[usr.Addr]; !ok {
-			filter[usr.Addr] = struct{}{}
-			m.miners = append(m.miners, usr)
+		state.Sources = append(state.Sources, source.Name())
+		if state.Account == "" {
+			state.Account = meta.Account
 		}
-		actor, err := m.fullNode.StateGetActor(ctx, usr.Addr, vTypes.EmptyTSK)
-		if err != nil {
-			return err
+		if state.SectorSize == 0 {
+			state.SectorSize = meta.SectorSize
 		}
+		if state.Tags == nil {
+			state.Tags = meta.Tags
+		}
+		if !meta.Enabled {
+			state.DisabledBy = append(state.DisabledBy, source.Name())
+		}
+	}
+	return state
+}
 
-		if builtin.IsStorageMinerActor(actor.Code) {
-			// add owner/worker/controladdress for this miner
-			minerInfo, err := m.fullNode.StateMinerInfo(ctx, usr.Addr, vTypes.EmptyTSK)
-			if err != nil {
-				return err
-			}
+// entry must be called with the lock held
+func (m *UserMgrImpl) entry(addr address.Address) *minerEntry {
+	for _, entry := range m.entries {
+		if entry.addr == addr {
+			return entry
+		}
+	}
+	return nil
+}
 
-			workerKey, err := m.fullNode.StateAccountKey(ctx, minerInfo.Worker, vTypes.EmptyTSK)
-			if err != nil {
-				return err
-			}
-			if _, ok := filter[workerKey]; !ok {
-				filter[workerKey] = struct{}{}
-				m.miners = append(m.miners, types.User{
-					Addr:    workerKey,
-					Account: usr.Account,
-				})
-			}
+// disabledByOperator must be called with cfgLk held
+func (m *UserMgrImpl) disabledByOperator(addr address.Address) bool {
+	for _, disabled := range m.cfg.DisabledMiners {
+		if address.Address(disabled) == addr {
+			return true
+		}
+	}
+	return false
+}
 
-			ownerKey, err := m.fullNode.StateAccountKey(ctx, minerInfo.Owner, vTypes.EmptyTSK)
-			if err != nil {
-				return err
-			}
-			if _, ok := filter[ownerKey]; !ok {
-				filter[ownerKey] = struct{}{}
-				m.miners = append(m.miners, types.User{
-					Addr:    ownerKey,
-					Account: usr.Account,
-				})
-			}
+// syncSource reconciles the miners with the source, the addresses of the actors are resolved for the new miners only
+func (m *UserMgrImpl) syncSource(ctx context.Context, source IMinerSource) error {
+	m.syncLk.Lock()
+	defer m.syncLk.Unlock()
 
-			for _, ctlAddr := range minerInfo.ControlAddresses {
-				ctlKey, err := m.fullNode.StateAccountKey(ctx, ctlAddr, vTypes.EmptyTSK)
-				if err != nil {
-					return err
-				}
-				if _, ok := filter[ctlKey]; !ok {
-					filter[ctlKey] = struct{}{}
-					m.miners = append(m.miners, types.User{
-						Addr:    ctlKey,
-						Account: usr.Account,
-					})
-				}
-			}
+	metas, err := source.Miners(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.lk.Lock()
+	var added []address.Address
+	for _, meta := range metas {
+		if meta.Addr != address.Undef && m.entry(meta.Addr) == nil {
+			added = append(added, meta.Addr)
+		}
+	}
+	m.lk.Unlock()
+
+	// the chain is not queried with the lock held, a miner whose actor does not resolve is added by a next sync
+	keys := make(map[address.Address][]address.Address, len(added))
+	unresolved := make(map[address.Address]struct{})
+	var errs error
+	for _, addr := range added {
+		if keys[addr], err = m.actorKeys(ctx, addr); err != nil {
+			unresolved[addr] = struct{}{}
+			errs = multierr.Append(errs, xerrors.Errorf("resolve addresses of actor %s: %w", addr, err))
+		}
+	}
+
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	name := source.Name()
+	listed := make(map[address.Address]struct{}, len(metas))
+	for _, meta := range metas {
+		if _, ok := unresolved[meta.Addr]; ok || meta.Addr == address.Undef {
+			continue
 		}
+		listed[meta.Addr] = struct{}{}
+		entry := m.entry(meta.Addr)
+		if entry == nil {
+			entry = &minerEntry{addr: meta.Addr, metas: make(map[string]mtypes.MinerMeta), keys: keys[meta.Addr]}
+			m.entries = append(m.entries, entry)
+			log.Infow("add miner", "miner", meta.Addr, "account", meta.Account, "source", name)
+		}
+		if prev, ok := entry.metas[name]; ok && prev.Enabled != meta.Enabled {
+			log.Infow("miner enabled state changed", "miner", meta.Addr, "source", name, "enabled", meta.Enabled)
+		}
+		entry.metas[name] = meta
+	}
 
+	entries := m.entries[:0]
+	for _, entry := range m.entries {
+		if _, ok := listed[entry.addr]; !ok {
+			delete(entry.metas, name)
+		}
+		if len(entry.metas) == 0 {
+			log.Infow("remove miner", "miner", entry.addr, "source", name)
+			continue
+		}
+		entries = append(entries, entry)
 	}
-	return nil
+	m.entries = entries
+	m.rebuild()
+	return errs
 }
 
-func (m *UserMgrImpl) refreshUsers(ctx context.Context) {
-	tm := time.NewTicker(time.Minute)
-	defer tm.Stop()
-	for range tm.C {
-		miners, err := m.getMinerFromVenusAuth(context.TODO(), 0, 0)
+// actorKeys returns the owner, worker and control addresses of a storage miner actor
+func (m *UserMgrImpl) actorKeys(ctx context.Context, addr address.Address) ([]address.Address, error) {
+	actor, err := m.fullNode.StateGetActor(ctx, addr, vTypes.EmptyTSK)
+	if err != nil {
+		return nil, err
+	}
+	if !builtin.IsStorageMinerActor(actor.Code) {
+		return nil, nil
+	}
+
+	minerInfo, err := m.fullNode.StateMinerInfo(ctx, addr, vTypes.EmptyTSK)
+	if err != nil {
+		return nil, err
+	}
+	var keys []address.Address
+	for _, ctlAddr := range append([]address.Address{minerInfo.Worker, minerInfo.Owner}, minerInfo.ControlAddresses...) {
+		key, err := m.fullNode.StateAccountKey(ctx, ctlAddr, vTypes.EmptyTSK)
 		if err != nil {
-			log.Errorf("unable to get venus miner from venus auth %s", err)
+			return nil, err
 		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
 
-		err = m.distAddress(ctx, miners...)
-		if err != nil {
-			log.Errorf("unable to append new user to address manager %s", err)
+// rebuild must be called with the lock held
+func (m *UserMgrImpl) rebuild() {
+	filter := make(map[address.Address]struct{}, len(m.entries))
+	miners := make([]types.User, 0, len(m.entries))
+	for _, entry := range m.entries {
+		// the account of the source with the highest precedence
+		var account string
+		for _, source := range m.sources {
+			if meta, ok := entry.metas[source.Name()]; ok && meta.Account != "" {
+				account = meta.Account
+				break
+			}
+		}
+		for _, addr := range append([]address.Address{entry.addr}, entry.keys...) {
+			if _, ok := filter[addr]; ok {
+				continue
+			}
+			filter[addr] = struct{}{}
+			miners = append(miners, types.User{
+				Addr:    addr,
+				Account: account,
+			})
+		}
+	}
+	m.miners = miners
+}
+
+func (m *UserMgrImpl) refreshUsers(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	tm := time.NewTicker(interval)
+	defer tm.Stop()
+	for {
+		select {
+		case <-tm.C:
+			// the config is synced again too, for the miners whose actor did not resolve
+			for _, source := range m.sources {
+				if err := m.syncSource(ctx, source); err != nil {
+					log.Errorf("unable to sync miners of %s: %s", source.Name(), err)
+				}
+			}
+		case <-ctx.Done():
+			log.Warnf("exit address manager refresh by context")
+			return
 		}
 	}
 }
-func convertConfigAddress(addrs []config.User) []types.User {
-	addrs2 := make([]types.User, len(addrs))
-	for index, miner := range addrs {
-		addrs2[index] = types.User{
-			Addr:    address.Address(miner.Addr),
-			Account: miner.Account,
+
+func removeConfigAddress(addrs []config.Address, addr address.Address) []config.Address {
+	out := make([]config.Address, 0, len(addrs))
+	for _, a := range addrs {
+		if address.Address(a) != addr {
+			out = append(out, a)
 		}
 	}
-	return addrs2
+	return out
 }
@@ -0,0 +1,221 @@
+package minermgr
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/venus-market/config"
+	mtypes "github.com/filecoin-project/venus-market/types"
+
+	v1api "github.com/filecoin-project/venus/venus-shared/api/chain/v1"
+	vTypes "github.com/filecoin-project/venus/venus-shared/types"
+	types "github.com/filecoin-project/venus/venus-shared/types/market"
+)
+
+// fakeFullNode knows account actors only, they have no worker, owner or control address
+type fakeFullNode struct {
+	v1api.FullNode
+}
+
+func (f *fakeFullNode) StateGetActor(context.Context, address.Address, vTypes.TipSetKey) (*vTypes.Actor, error) {
+	code, err := cid.V1Builder{Codec: cid.Raw, MhType: multihash.IDENTITY}.Sum([]byte("fil/account"))
+	if err != nil {
+		return nil, err
+	}
+	return &vTypes.Actor{Code: code}, nil
+}
+
+type fakeVenusAuth struct {
+	lk    sync.Mutex
+	users []AuthUser
+}
+
+func (f *fakeVenusAuth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	skip, _ := strconv.Atoi(r.URL.Query().Get("skip"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	users := []AuthUser{}
+	if skip < len(f.users) {
+		users = f.users[skip:]
+	}
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	_ = json.NewEncoder(w).Encode(users)
+}
+
+func (f *fakeVenusAuth) setUsers(users ...AuthUser) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	f.users = users
+}
+
+func TestUserMgrSync(t *testing.T) {
+	ctx := context.Background()
+	configMiner, _ := address.NewIDAddress(1000)
+	authMiner, _ := address.NewIDAddress(2000)
+	disabledMiner, _ := address.NewIDAddress(3000)
+
+	auth := &fakeVenusAuth{}
+	auth.setUsers(
+		AuthUser{Name: "bob", Miner: authMiner.String(), State: 1},
+		AuthUser{Name: "carol", Miner: disabledMiner.String(), State: 2},
+	)
+	srv := httptest.NewServer(auth)
+	defer srv.Close()
+
+	cfg := &config.MarketConfig{
+		AuthNode:      config.AuthNode{Url: srv.URL},
+		StorageMiners: []config.User{{Addr: config.Address(configMiner), Account: "alice"}},
+	}
+	m := newUserMgrImpl(&fakeFullNode{}, cfg)
+	saved := 0
+	m.saveConfig = func() error {
+		saved++
+		return nil
+	}
+	authSource := &venusAuthSource{authCfg: cfg.AuthNode}
+	m.sources = append(m.sources, authSource)
+	require.NoError(t, m.syncSource(ctx, m.sources[0]))
+	require.NoError(t, m.syncSource(ctx, authSource))
+
+	for _, miner := range []address.Address{configMiner, authMiner, disabledMiner} {
+		require.True(t, m.Has(ctx, miner), miner)
+	}
+	require.False(t, m.IsDisabled(ctx, authMiner))
+	require.True(t, m.IsDisabled(ctx, disabledMiner))
+
+	// the operator disables a miner of venus-auth
+	require.NoError(t, m.SetDisabled(ctx, authMiner, true))
+	require.True(t, m.IsDisabled(ctx, authMiner))
+	require.Equal(t, []config.Address{config.Address(authMiner)}, cfg.DisabledMiners)
+	states, err := m.MinerStates(ctx)
+	require.NoError(t, err)
+	require.Len(t, states, 3)
+	require.Equal(t, []string{mtypes.MinerDisabledByOperator}, states[1].DisabledBy)
+	require.Equal(t, []string{mtypes.MinerSourceVenusAuth}, states[1].Sources)
+	require.Equal(t, []string{mtypes.MinerDisabledByVenusAuth}, states[2].DisabledBy)
+
+	// venus-auth removes a user and enables another
+	auth.setUsers(AuthUser{Name: "carol", Miner: disabledMiner.String(), State: 1})
+	require.NoError(t, m.syncSource(ctx, authSource))
+	require.False(t, m.Has(ctx, authMiner))
+	require.False(t, m.IsDisabled(ctx, disabledMiner))
+
+	// an unreachable venus-auth keeps its miners
+	srv.Close()
+	require.Error(t, m.syncSource(ctx, authSource))
+	require.True(t, m.Has(ctx, disabledMiner))
+
+	require.Error(t, m.RemoveAddress(ctx, disabledMiner))
+	require.NoError(t, m.RemoveAddress(ctx, configMiner))
+	require.False(t, m.Has(ctx, configMiner))
+	require.Empty(t, cfg.StorageMiners)
+	require.ErrorIs(t, m.RemoveAddress(ctx, configMiner), ErrMinerNotFound)
+
+	newMiner, _ := address.NewIDAddress(4000)
+	require.NoError(t, m.AddAddress(ctx, types.User{Addr: newMiner, Account: "dave"}))
+	account, err := m.GetAccount(ctx, newMiner)
+	require.NoError(t, err)
+	require.Equal(t, "dave", account)
+	require.Equal(t, []config.User{{Addr: config.Address(newMiner), Account: "dave"}}, cfg.StorageMiners)
+	require.Equal(t, 3, saved)
+}
+
+func TestMinerSources(t *testing.T) {
+	ctx := context.Background()
+	miner, _ := address.NewIDAddress(1000)
+
+	// venus-auth is paged through
+	auth := &fakeVenusAuth{}
+	var users []AuthUser
+	for i := 0; i < CoMinersLimit+50; i++ {
+		addr, _ := address.NewIDAddress(uint64(10000 + i))
+		users = append(users, AuthUser{Name: "user", Miner: addr.String(), State: 1})
+	}
+	auth.setUsers(users...)
+	authSrv := httptest.NewServer(auth)
+	defer authSrv.Close()
+	authMiners, err := (&venusAuthSource{authCfg: config.AuthNode{Url: authSrv.URL}}).Miners(ctx)
+	require.NoError(t, err)
+	require.Len(t, authMiners, CoMinersLimit+50)
+
+	path := filepath.Join(t.TempDir(), "miners.json")
+	writeMiners := func(content string, modTime time.Time) {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+	writeMiners(`[{"miner": "`+miner.String()+`", "account": "alice", "sectorSize": 34359738368, "tags": ["fast"]}]`, time.Now().Add(-time.Hour))
+
+	httpSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"miner": "` + miner.String() + `", "account": "bob", "enabled": false, "tags": ["slow"]}]`))
+	}))
+	defer httpSrv.Close()
+
+	cfg := &config.MarketConfig{}
+	m := newUserMgrImpl(&fakeFullNode{}, cfg)
+	for _, sourceCfg := range []config.MinerSource{
+		{Type: mtypes.MinerSourceFile, Path: path},
+		{Type: mtypes.MinerSourceHTTP, Url: httpSrv.URL},
+	} {
+		source, err := NewMinerSource(sourceCfg, cfg.AuthNode)
+		require.NoError(t, err)
+		m.sources = append(m.sources, source)
+		require.NoError(t, m.syncSource(ctx, source))
+	}
+	_, err = NewMinerSource(config.MinerSource{Type: "ldap"}, cfg.AuthNode)
+	require.Error(t, err)
+
+	// the file comes before the http endpoint, which disables the miner anyway
+	state, err := m.MinerState(ctx, miner)
+	require.NoError(t, err)
+	require.Equal(t, "alice", state.Account)
+	require.Equal(t, abi.SectorSize(32<<30), state.SectorSize)
+	require.True(t, state.HasTag("fast"))
+	require.False(t, state.HasTag("slow"))
+	require.Equal(t, []string{"file:" + path, "http:" + httpSrv.URL}, state.Sources)
+	require.Equal(t, []string{"http:" + httpSrv.URL}, state.DisabledBy)
+	account, err := m.GetAccount(ctx, miner)
+	require.NoError(t, err)
+	require.Equal(t, "alice", account)
+
+	// the file is read again once changed
+	writeMiners(`[]`, time.Now())
+	require.NoError(t, m.syncSource(ctx, m.sources[1]))
+	state, err = m.MinerState(ctx, miner)
+	require.NoError(t, err)
+	require.Equal(t, "bob", state.Account)
+	require.Equal(t, []string{"http:" + httpSrv.URL}, state.Sources)
+}
+
+func TestUserMgrStartup(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	configMiner, _ := address.NewIDAddress(1000)
+
+	// the market starts with the miners of the config while a source is unreachable
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	cfg := &config.MarketConfig{
+		StorageMiners: []config.User{{Addr: config.Address(configMiner), Account: "alice"}},
+		MinerSources:  []config.MinerSource{{Type: mtypes.MinerSourceHTTP, Url: srv.URL}},
+	}
+	m, err := NeAddrMgrImpl(ctx, &fakeFullNode{}, cfg)
+	require.NoError(t, err)
+	require.True(t, m.Has(ctx, configMiner))
+}
@@ -0,0 +1,243 @@
+package minermgr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/go-resty/resty/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/venus-market/config"
+	mtypes "github.com/filecoin-project/venus-market/types"
+)
+
+// IMinerSource lists the miners the market serves. The market merges the sources, a source dropping a miner
+// removes it unless another source still lists it
+type IMinerSource interface {
+	// Name identifies the source in the states of the miners
+	Name() string
+	// Miners returns all the miners of the source, an error keeps the miners of the previous sync
+	Miners(ctx context.Context) ([]mtypes.MinerMeta, error)
+}
+
+// NewMinerSource returns the source of the config, the venus-auth source reads the users of authCfg
+func NewMinerSource(cfg config.MinerSource, authCfg config.AuthNode) (IMinerSource, error) {
+	switch cfg.Type {
+	case mtypes.MinerSourceVenusAuth:
+		return &venusAuthSource{authCfg: authCfg}, nil
+	case mtypes.MinerSourceFile:
+		if cfg.Path == "" {
+			return nil, xerrors.New("file miner source needs a path")
+		}
+		return &fileSource{path: cfg.Path}, nil
+	case mtypes.MinerSourceHTTP:
+		if cfg.Url == "" {
+			return nil, xerrors.New("http miner source needs an url")
+		}
+		return &httpSource{url: cfg.Url}, nil
+	default:
+		return nil, xerrors.Errorf("unknown miner source type %q", cfg.Type)
+	}
+}
+
+// configSource lists the storage miners, the retrieval payment address and the deal publish control addresses of
+// the config. lk guards the config against `actor add` and `actor remove`
+type configSource struct {
+	cfg *config.MarketConfig
+	lk  *sync.Mutex
+}
+
+func (s *configSource) Name() string {
+	return mtypes.MinerSourceConfig
+}
+
+func (s *configSource) Miners(ctx context.Context) ([]mtypes.MinerMeta, error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	users := append(append([]config.User{}, s.cfg.StorageMiners...), s.cfg.RetrievalPaymentAddress)
+	users = append(users, s.cfg.AddressConfig.DealPublishControl...)
+	miners := make([]mtypes.MinerMeta, 0, len(users))
+	for _, user := range users {
+		if address.Address(user.Addr) == address.Undef {
+			continue
+		}
+		miners = append(miners, mtypes.MinerMeta{
+			Addr:    address.Address(user.Addr),
+			Account: user.Account,
+			Enabled: true,
+		})
+	}
+	return miners, nil
+}
+
+// venusAuthSource lists the miners of the users of venus-auth, the miners of the disabled users are disabled
+type venusAuthSource struct {
+	authCfg config.AuthNode
+}
+
+func (s *venusAuthSource) Name() string {
+	return mtypes.MinerSourceVenusAuth
+}
+
+func (s *venusAuthSource) Miners(ctx context.Context) ([]mtypes.MinerMeta, error) {
+	if len(s.authCfg.Url) == 0 {
+		return nil, nil
+	}
+
+	var miners []mtypes.MinerMeta
+	for skip := int64(0); ; skip += CoMinersLimit {
+		users, err := s.listUsers(ctx, skip, CoMinersLimit)
+		if err != nil {
+			return nil, err
+		}
+		for _, val := range users {
+			if len(val.Miner) == 0 {
+				continue
+			}
+			addr, err := address.NewFromString(val.Miner)
+			if err != nil || addr == address.Undef {
+				log.Warnf("miner [%s] is error", val.Miner)
+				continue
+			}
+			// the miners of the disabled users are kept so that their deals go on
+			miners = append(miners, mtypes.MinerMeta{
+				Addr:    addr,
+				Account: val.Name,
+				Enabled: val.State == 1,
+			})
+		}
+		if len(users) < CoMinersLimit {
+			return miners, nil
+		}
+	}
+}
+
+func (s *venusAuthSource) listUsers(ctx context.Context, skip, limit int64) ([]AuthUser, error) {
+	log.Debugf("request miners from auth: %s skip %d", s.authCfg.Url, skip)
+	cli := resty.New().SetHostURL(s.authCfg.Url).SetHeader("Accept", "application/json")
+	response, err := cli.R().SetContext(ctx).SetQueryParams(map[string]string{
+		"token": s.authCfg.Token,
+		"skip":  fmt.Sprintf("%d", skip),
+		"limit": fmt.Sprintf("%d", limit),
+	}).Get("/user/list")
+	if err != nil {
+		return nil, err
+	}
+
+	switch response.StatusCode() {
+	case http.StatusOK:
+		var res []AuthUser
+		if err := json.Unmarshal(response.Body(), &res); err != nil {
+			return nil, err
+		}
+		return res, nil
+	default:
+		return nil, fmt.Errorf("response code is : %d, msg:%s", response.StatusCode(), response.Body())
+	}
+}
+
+// sourceMiner is a miner of the json of the file and http sources
+type sourceMiner struct {
+	Miner      string   `json:"miner"`
+	Account    string   `json:"account"`
+	SectorSize uint64   `json:"sectorSize"`
+	Enabled    *bool    `json:"enabled"` // enabled when missing
+	Tags       []string `json:"tags"`
+}
+
+func decodeSourceMiners(data []byte) ([]mtypes.MinerMeta, error) {
+	var res []sourceMiner
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, err
+	}
+	miners := make([]mtypes.MinerMeta, 0, len(res))
+	for _, val := range res {
+		addr, err := address.NewFromString(val.Miner)
+		if err != nil {
+			return nil, xerrors.Errorf("parse miner %s: %w", val.Miner, err)
+		}
+		miners = append(miners, mtypes.MinerMeta{
+			Addr:       addr,
+			Account:    val.Account,
+			SectorSize: abi.SectorSize(val.SectorSize),
+			Enabled:    val.Enabled == nil || *val.Enabled,
+			Tags:       val.Tags,
+		})
+	}
+	return miners, nil
+}
+
+// fileSource lists the miners of a json file, it is decoded again when its modification time changes
+type fileSource struct {
+	path string
+
+	modTime time.Time
+	miners  []mtypes.MinerMeta
+}
+
+func (s *fileSource) Name() string {
+	return mtypes.MinerSourceFile + ":" + s.path
+}
+
+func (s *fileSource) Miners(ctx context.Context) ([]mtypes.MinerMeta, error) {
+	st, err := os.Stat(s.path)
+	if err != nil {
+		return nil, err
+	}
+	if st.ModTime().Equal(s.modTime) {
+		return s.miners, nil
+	}
+
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	miners, err := decodeSourceMiners(data)
+	if err != nil {
+		return nil, xerrors.Errorf("decode %s: %w", s.path, err)
+	}
+	log.Infof("miners of %s reloaded", s.path)
+	s.modTime, s.miners = st.ModTime(), miners
+	return miners, nil
+}
+
+// httpSource lists the miners a json http endpoint answers a GET with
+type httpSource struct {
+	url string
+}
+
+func (s *httpSource) Name() string {
+	return mtypes.MinerSourceHTTP + ":" + s.url
+}
+
+func (s *httpSource) Miners(ctx context.Context) ([]mtypes.MinerMeta, error) {
+	response, err := resty.New().SetHeader("Accept", "application/json").R().SetContext(ctx).Get(s.url)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode() != http.StatusOK {
+		return nil, fmt.Errorf("response code is : %d, msg:%s", response.StatusCode(), response.Body())
+	}
+	return decodeSourceMiners(response.Body())
+}
+
+type minerStateKey struct{}
+
+// WithMinerState hands the state of the provider of a deal to the deal filters
+func WithMinerState(ctx context.Context, state mtypes.MinerState) context.Context {
+	return context.WithValue(ctx, minerStateKey{}, state)
+}
+
+func MinerStateFromContext(ctx context.Context) (mtypes.MinerState, bool) {
+	state, ok := ctx.Value(minerStateKey{}).(mtypes.MinerState)
+	return state, ok
+}
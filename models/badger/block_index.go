@@ -0,0 +1,127 @@
+package badger
+
+import (
+	"context"
+	"encoding/binary"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	"github.com/multiformats/go-base32"
+	"github.com/multiformats/go-multihash"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/venus-market/models/repo"
+)
+
+const (
+	blockIndexBlockPrefix      = "/blocks"
+	blockIndexPiecePrefix      = "/pieces"
+	blockIndexPieceBlockPrefix = "/piece-blocks"
+)
+
+// blockIndexRepo keeps one empty entry per block and piece, keyed by /blocks/<multihash>/<piece>, so that the pieces of a
+// block are found with a prefix query. /pieces/<piece> holds the number of blocks of the pieces that are indexed and
+// /piece-blocks/<piece>/<multihash> are the reverse entries the index of a piece is removed with
+type blockIndexRepo struct {
+	ds datastore.Batching
+}
+
+var _ repo.IBlockIndexRepo = (*blockIndexRepo)(nil)
+
+func NewBlockIndexRepo(ds BlockIndexDS) *blockIndexRepo {
+	return &blockIndexRepo{ds: ds}
+}
+
+func blockIndexPrefix(block multihash.Multihash) datastore.Key {
+	return datastore.NewKey(blockIndexBlockPrefix).ChildString(base32.RawStdEncoding.EncodeToString(block))
+}
+
+func blockIndexPieceKey(pieceCID cid.Cid) datastore.Key {
+	return datastore.NewKey(blockIndexPiecePrefix).ChildString(pieceCID.String())
+}
+
+func blockIndexPieceBlocksPrefix(pieceCID cid.Cid) datastore.Key {
+	return datastore.NewKey(blockIndexPieceBlockPrefix).ChildString(pieceCID.String())
+}
+
+func (r *blockIndexRepo) AddBlockIndex(ctx context.Context, pieceCID cid.Cid, blocks []multihash.Multihash) error {
+	batch, err := r.ds.Batch(ctx)
+	if err != nil {
+		return err
+	}
+	reverse := blockIndexPieceBlocksPrefix(pieceCID)
+	for _, block := range blocks {
+		if err := batch.Put(ctx, blockIndexPrefix(block).ChildString(pieceCID.String()), nil); err != nil {
+			return err
+		}
+		if err := batch.Put(ctx, reverse.ChildString(base32.RawStdEncoding.EncodeToString(block)), nil); err != nil {
+			return err
+		}
+	}
+	count := make([]byte, binary.MaxVarintLen64)
+	count = count[:binary.PutUvarint(count, uint64(len(blocks)))]
+	if err := batch.Put(ctx, blockIndexPieceKey(pieceCID), count); err != nil {
+		return err
+	}
+	return batch.Commit(ctx)
+}
+
+func (r *blockIndexRepo) GetPiecesByBlock(ctx context.Context, block multihash.Multihash) ([]cid.Cid, error) {
+	res, err := r.ds.Query(ctx, dsq.Query{Prefix: blockIndexPrefix(block).String(), KeysOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close() //nolint:errcheck
+
+	var pieces []cid.Cid
+	for e := range res.Next() {
+		if e.Error != nil {
+			return nil, e.Error
+		}
+		pieceCID, err := cid.Decode(datastore.RawKey(e.Key).BaseNamespace())
+		if err != nil {
+			return nil, xerrors.Errorf("parse block index key %s: %w", e.Key, err)
+		}
+		pieces = append(pieces, pieceCID)
+	}
+	if len(pieces) == 0 {
+		return nil, repo.ErrNotFound
+	}
+	return pieces, nil
+}
+
+func (r *blockIndexRepo) HasBlockIndex(ctx context.Context, pieceCID cid.Cid) (bool, error) {
+	return r.ds.Has(ctx, blockIndexPieceKey(pieceCID))
+}
+
+// RemoveBlockIndex deletes the entries of the piece found by its reverse entries
+func (r *blockIndexRepo) RemoveBlockIndex(ctx context.Context, pieceCID cid.Cid) error {
+	res, err := r.ds.Query(ctx, dsq.Query{Prefix: blockIndexPieceBlocksPrefix(pieceCID).String(), KeysOnly: true})
+	if err != nil {
+		return err
+	}
+	defer res.Close() //nolint:errcheck
+
+	batch, err := r.ds.Batch(ctx)
+	if err != nil {
+		return err
+	}
+	piece := pieceCID.String()
+	for e := range res.Next() {
+		if e.Error != nil {
+			return e.Error
+		}
+		key := datastore.RawKey(e.Key)
+		if err := batch.Delete(ctx, datastore.NewKey(blockIndexBlockPrefix).ChildString(key.BaseNamespace()).ChildString(piece)); err != nil {
+			return err
+		}
+		if err := batch.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	if err := batch.Delete(ctx, blockIndexPieceKey(pieceCID)); err != nil {
+		return err
+	}
+	return batch.Commit(ctx)
+}
@@ -3,6 +3,7 @@ package badger
 import (
 	"context"
 	"path"
+	"sync"
 
 	"github.com/filecoin-project/venus-market/blockstore"
 	"github.com/filecoin-project/venus-market/config"
@@ -22,16 +23,27 @@ const (
 	cidinfo           = "/cid-infos"
 	retrievalProvider = "/retrievals/provider"
 	retrievalAsk      = "/retrieval-ask"
+	retrievalAskRule  = "/retrieval-ask-rules"
+	retrievalWallet   = "/client-wallets"
 	retrievalDeals    = "/deals"
 	storageProvider   = "/storage/provider"
 	storageDeals      = "/deals"
 	storageAsk        = "/storage-ask"
+	storageAskHistory = "/storage-ask-history"
 	paych             = "/paych/"
+	nonceLedger       = "/nonce-ledger"
+	blockIndex        = "/block-index"
+	connGater         = "/conngater"
+	pieceVerify       = "/piece-verify"
+	dealLease         = "/deal-lease"
+	dealFailure       = "/deal-failure"
 
 	// client
 	dealClient      = "/deals/client"
 	dealLocal       = "/deals/local"
 	retrievalClient = "/retrievals/client"
+	dealRenewal     = "/deals/renewal"
+	retrievalRepute = "/retrievals/reputation"
 	clientTransfer  = "/datatransfer/client/transfers"
 )
 
@@ -59,6 +71,12 @@ type RetrievalDealsDS datastore.Batching
 // /metadata/retrievals/provider/retrieval-ask
 type RetrievalAskDS datastore.Batching //key = latest
 
+// /metadata/retrievals/provider/retrieval-ask-rules
+type RetrievalAskRuleDS datastore.Batching
+
+// /metadata/retrievals/provider/client-wallets
+type RetrievalClientWalletDS datastore.Batching
+
 // /metadata/datatransfer/provider/transfers
 type DagTransferDS datastore.Batching
 
@@ -71,9 +89,30 @@ type StorageDealsDS datastore.Batching
 // /metadata/storage/provider/storage-ask
 type StorageAskDS datastore.Batching //key = latest
 
+// /metadata/storage/provider/storage-ask-history
+type StorageAskHistoryDS datastore.Batching
+
 // /metadata/paych/
 type PayChanDS datastore.Batching
 
+// /metadata/nonce-ledger
+type NonceLedgerDS datastore.Batching
+
+// /metadata/block-index
+type BlockIndexDS datastore.Batching
+
+// /metadata/conngater
+type ConnGaterDS datastore.Batching
+
+// /metadata/piece-verify
+type PieceVerifyDS datastore.Batching
+
+// /metadata/deal-lease
+type DealLeaseDS datastore.Batching
+
+// /metadata/deal-failure
+type DealFailureDS datastore.Batching
+
 //*********************************client
 // /metadata/deals/client
 type ClientDatastore datastore.Batching
@@ -90,6 +129,12 @@ type ImportClientDS datastore.Batching
 // /metadata/datatransfer/client/transfers
 type ClientTransferDS datastore.Batching
 
+// /metadata/deals/renewal
+type ClientDealRenewalDS datastore.Batching
+
+// /metadata/retrievals/reputation
+type RetrievalReputationDS datastore.Batching
+
 func NewMetadataDS(mctx metrics.MetricsCtx, lc fx.Lifecycle, homeDir *config.HomeDir) (MetadataDS, error) {
 	datastore.ErrNotFound = repo.ErrNotFound
 	db, err := badger.NewDatastore(path.Join(string(*homeDir), metadata), &badger.DefaultOptions)
@@ -129,6 +174,14 @@ func NewRetrievalAskDS(ds RetrievalProviderDS) RetrievalAskDS {
 	return namespace.Wrap(ds, datastore.NewKey(retrievalAsk))
 }
 
+func NewRetrievalAskRuleDS(ds RetrievalProviderDS) RetrievalAskRuleDS {
+	return namespace.Wrap(ds, datastore.NewKey(retrievalAskRule))
+}
+
+func NewRetrievalClientWalletDS(ds RetrievalProviderDS) RetrievalClientWalletDS {
+	return namespace.Wrap(ds, datastore.NewKey(retrievalWallet))
+}
+
 func NewStorageProviderDS(ds MetadataDS) StorageProviderDS {
 	return namespace.Wrap(ds, datastore.NewKey(storageProvider))
 }
@@ -141,10 +194,38 @@ func NewStorageAskDS(ds StorageProviderDS) StorageAskDS {
 	return namespace.Wrap(ds, datastore.NewKey(storageAsk))
 }
 
+func NewStorageAskHistoryDS(ds StorageProviderDS) StorageAskHistoryDS {
+	return namespace.Wrap(ds, datastore.NewKey(storageAskHistory))
+}
+
 func NewPayChanDS(ds MetadataDS) PayChanDS {
 	return namespace.Wrap(ds, datastore.NewKey(paych))
 }
 
+func NewNonceLedgerDS(ds MetadataDS) NonceLedgerDS {
+	return namespace.Wrap(ds, datastore.NewKey(nonceLedger))
+}
+
+func NewBlockIndexDS(ds MetadataDS) BlockIndexDS {
+	return namespace.Wrap(ds, datastore.NewKey(blockIndex))
+}
+
+func NewConnGaterDS(ds MetadataDS) ConnGaterDS {
+	return namespace.Wrap(ds, datastore.NewKey(connGater))
+}
+
+func NewPieceVerifyDS(ds MetadataDS) PieceVerifyDS {
+	return namespace.Wrap(ds, datastore.NewKey(pieceVerify))
+}
+
+func NewDealLeaseDS(ds MetadataDS) DealLeaseDS {
+	return namespace.Wrap(ds, datastore.NewKey(dealLease))
+}
+
+func NewDealFailureDS(ds MetadataDS) DealFailureDS {
+	return namespace.Wrap(ds, datastore.NewKey(dealFailure))
+}
+
 // NewClientDatastore creates a datastore for the client to store its deals
 func NewClientDatastore(ds MetadataDS) ClientDatastore {
 	return namespace.Wrap(ds, datastore.NewKey(dealClient))
@@ -163,19 +244,38 @@ func NewClientTransferDS(ds MetadataDS) ClientTransferDS {
 	return namespace.Wrap(ds, datastore.NewKey(clientTransfer))
 }
 
+func NewClientDealRenewalDS(ds MetadataDS) ClientDealRenewalDS {
+	return namespace.Wrap(ds, datastore.NewKey(dealRenewal))
+}
+
+func NewRetrievalReputationDS(ds MetadataDS) RetrievalReputationDS {
+	return namespace.Wrap(ds, datastore.NewKey(retrievalRepute))
+}
+
 type BadgerRepo struct {
 	dsParams *BadgerDSParams
+
+	// badger has no transaction across the datastores, the transactions are serialized instead
+	txLk sync.Mutex
 }
 
 type BadgerDSParams struct {
 	fx.In
-	FundDS           FundMgrDS        `optional:"true"`
-	StorageDealsDS   StorageDealsDS   `optional:"true"`
-	PaychDS          PayChanDS        `optional:"true"`
-	AskDS            StorageAskDS     `optional:"true"`
-	RetrAskDs        RetrievalAskDS   `optional:"true"`
-	CidInfoDs        CIDInfoDS        `optional:"true"`
-	RetrievalDealsDs RetrievalDealsDS `optional:"true"`
+	FundDS           FundMgrDS               `optional:"true"`
+	StorageDealsDS   StorageDealsDS          `optional:"true"`
+	PaychDS          PayChanDS               `optional:"true"`
+	AskDS            StorageAskDS            `optional:"true"`
+	AskHistoryDS     StorageAskHistoryDS     `optional:"true"`
+	RetrAskDs        RetrievalAskDS          `optional:"true"`
+	RetrAskRuleDs    RetrievalAskRuleDS      `optional:"true"`
+	RetrWalletDs     RetrievalClientWalletDS `optional:"true"`
+	CidInfoDs        CIDInfoDS               `optional:"true"`
+	RetrievalDealsDs RetrievalDealsDS        `optional:"true"`
+	NonceLedgerDS    NonceLedgerDS           `optional:"true"`
+	BlockIndexDS     BlockIndexDS            `optional:"true"`
+	PieceVerifyDS    PieceVerifyDS           `optional:"true"`
+	DealLeaseDS      DealLeaseDS             `optional:"true"`
+	DealFailureDS    DealFailureDS           `optional:"true"`
 }
 
 func NewBadgerRepo(params BadgerDSParams) repo.Repo {
@@ -201,11 +301,11 @@ func (r *BadgerRepo) PaychChannelInfoRepo() repo.PaychChannelInfoRepo {
 }
 
 func (r *BadgerRepo) StorageAskRepo() repo.IStorageAskRepo {
-	return NewStorageAskRepo(r.dsParams.AskDS)
+	return NewStorageAskRepo(r.dsParams.AskDS, r.dsParams.AskHistoryDS)
 }
 
 func (r *BadgerRepo) RetrievalAskRepo() repo.IRetrievalAskRepo {
-	return NewRetrievalAskRepo(r.dsParams.RetrAskDs)
+	return NewRetrievalAskRepo(r.dsParams.RetrAskDs, r.dsParams.RetrAskRuleDs, r.dsParams.RetrWalletDs)
 }
 
 func (r *BadgerRepo) CidInfoRepo() repo.ICidInfoRepo {
@@ -216,6 +316,26 @@ func (r *BadgerRepo) RetrievalDealRepo() repo.IRetrievalDealRepo {
 	return NewRetrievalDealRepo(r.dsParams.RetrievalDealsDs)
 }
 
+func (r *BadgerRepo) NonceRepo() repo.INonceRepo {
+	return NewNonceRepo(r.dsParams.NonceLedgerDS)
+}
+
+func (r *BadgerRepo) BlockIndexRepo() repo.IBlockIndexRepo {
+	return NewBlockIndexRepo(r.dsParams.BlockIndexDS)
+}
+
+func (r *BadgerRepo) PieceVerifyRepo() repo.IPieceVerifyRepo {
+	return NewPieceVerifyRepo(r.dsParams.PieceVerifyDS)
+}
+
+func (r *BadgerRepo) DealLeaseRepo() repo.IDealLeaseRepo {
+	return NewDealLeaseRepo(r.dsParams.DealLeaseDS)
+}
+
+func (r *BadgerRepo) DealFailureRepo() repo.IDealFailureRepo {
+	return NewDealFailureRepo(r.dsParams.DealFailureDS)
+}
+
 func (r *BadgerRepo) Close() error {
 	// todo: to implement
 	return nil
@@ -227,6 +347,8 @@ func (r *BadgerRepo) Migrate() error {
 
 // Not a real transaction
 func (r *BadgerRepo) Transaction(cb func(txRepo repo.TxRepo) error) error {
+	r.txLk.Lock()
+	defer r.txLk.Unlock()
 	return cb(&txRepo{dsParams: r.dsParams})
 }
 
@@ -238,6 +360,10 @@ func (r txRepo) StorageDealRepo() repo.StorageDealRepo {
 	return NewStorageDealRepo(r.dsParams.StorageDealsDS)
 }
 
+func (r txRepo) DealLeaseRepo() repo.IDealLeaseRepo {
+	return NewDealLeaseRepo(r.dsParams.DealLeaseDS)
+}
+
 //not metadata, just raw data between file transfer
 
 const (
@@ -0,0 +1,66 @@
+package badger
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/venus-market/models/repo"
+)
+
+type dealFailureRepo struct {
+	ds datastore.Batching
+}
+
+var _ repo.IDealFailureRepo = (*dealFailureRepo)(nil)
+
+func NewDealFailureRepo(ds DealFailureDS) *dealFailureRepo {
+	return &dealFailureRepo{ds: ds}
+}
+
+func (r *dealFailureRepo) SaveDealFailure(ctx context.Context, proposalCid cid.Cid, failedAt time.Time) error {
+	key := datastore.NewKey(proposalCid.String())
+	has, err := r.ds.Has(ctx, key)
+	if err != nil || has {
+		return err
+	}
+	data, err := json.Marshal(failedAt)
+	if err != nil {
+		return err
+	}
+	return r.ds.Put(ctx, key, data)
+}
+
+func (r *dealFailureRepo) ListDealFailures(ctx context.Context) (map[cid.Cid]time.Time, error) {
+	res, err := r.ds.Query(ctx, dsq.Query{})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close() //nolint:errcheck
+
+	failures := make(map[cid.Cid]time.Time)
+	for e := range res.Next() {
+		if e.Error != nil {
+			return nil, e.Error
+		}
+		proposalCid, err := cid.Decode(datastore.RawKey(e.Key).BaseNamespace())
+		if err != nil {
+			return nil, xerrors.Errorf("decode deal failure key %s: %w", e.Key, err)
+		}
+		var failedAt time.Time
+		if err := json.Unmarshal(e.Value, &failedAt); err != nil {
+			return nil, xerrors.Errorf("unmarshal deal failure %s: %w", e.Key, err)
+		}
+		failures[proposalCid] = failedAt
+	}
+	return failures, nil
+}
+
+func (r *dealFailureRepo) RemoveDealFailure(ctx context.Context, proposalCid cid.Cid) error {
+	return r.ds.Delete(ctx, datastore.NewKey(proposalCid.String()))
+}
@@ -0,0 +1,110 @@
+package badger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/venus-market/models/repo"
+	"github.com/filecoin-project/venus-market/types"
+)
+
+type dealLeaseRepo struct {
+	ds datastore.Batching
+}
+
+var _ repo.IDealLeaseRepo = (*dealLeaseRepo)(nil)
+
+func NewDealLeaseRepo(ds DealLeaseDS) *dealLeaseRepo {
+	return &dealLeaseRepo{ds: ds}
+}
+
+// dealLeaseKey pads the deal id so that the leases of a miner are ordered by deal id
+func dealLeaseKey(miner address.Address, dealID abi.DealID) datastore.Key {
+	return datastore.NewKey(miner.String()).ChildString(fmt.Sprintf("%020d", dealID))
+}
+
+func (r *dealLeaseRepo) SaveDealLease(ctx context.Context, lease *types.DealLease) error {
+	data, err := json.Marshal(lease)
+	if err != nil {
+		return err
+	}
+	return r.ds.Put(ctx, dealLeaseKey(lease.Miner, lease.DealID), data)
+}
+
+func (r *dealLeaseRepo) GetDealLease(ctx context.Context, miner address.Address, dealID abi.DealID) (*types.DealLease, error) {
+	data, err := r.ds.Get(ctx, dealLeaseKey(miner, dealID))
+	if err != nil {
+		return nil, err
+	}
+	var lease types.DealLease
+	if err := json.Unmarshal(data, &lease); err != nil {
+		return nil, xerrors.Errorf("unmarshal lease of deal %d: %w", dealID, err)
+	}
+	return &lease, nil
+}
+
+func (r *dealLeaseRepo) RemoveDealLease(ctx context.Context, miner address.Address, dealID abi.DealID) error {
+	return r.ds.Delete(ctx, dealLeaseKey(miner, dealID))
+}
+
+func (r *dealLeaseRepo) RenewDealLease(ctx context.Context, miner address.Address, dealID abi.DealID, owner string, expiry time.Time) (bool, error) {
+	lease, err := r.GetDealLease(ctx, miner, dealID)
+	if err != nil {
+		if xerrors.Is(err, repo.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if lease.Owner != owner {
+		return false, nil
+	}
+	lease.Expiry = expiry
+	return true, r.SaveDealLease(ctx, lease)
+}
+
+func (r *dealLeaseRepo) RemoveExpiredDealLease(ctx context.Context, miner address.Address, dealID abi.DealID, owner string, now time.Time) (bool, error) {
+	lease, err := r.GetDealLease(ctx, miner, dealID)
+	if err != nil {
+		if xerrors.Is(err, repo.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if lease.Owner != owner || lease.Expiry.IsZero() || !lease.Expiry.Before(now) {
+		return false, nil
+	}
+	return true, r.RemoveDealLease(ctx, miner, dealID)
+}
+
+func (r *dealLeaseRepo) ListDealLeases(ctx context.Context, miner address.Address) ([]*types.DealLease, error) {
+	q := dsq.Query{Orders: []dsq.Order{dsq.OrderByKey{}}}
+	if miner != address.Undef {
+		q.Prefix = datastore.NewKey(miner.String()).String()
+	}
+	res, err := r.ds.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close() //nolint:errcheck
+
+	var leases []*types.DealLease
+	for e := range res.Next() {
+		if e.Error != nil {
+			return nil, e.Error
+		}
+		var lease types.DealLease
+		if err := json.Unmarshal(e.Value, &lease); err != nil {
+			return nil, xerrors.Errorf("unmarshal deal lease %s: %w", e.Key, err)
+		}
+		leases = append(leases, &lease)
+	}
+	return leases, nil
+}
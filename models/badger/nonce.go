@@ -0,0 +1,199 @@
+package badger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/venus-market/models/repo"
+	"github.com/filecoin-project/venus-market/types"
+)
+
+const (
+	nonceNextPrefix   = "/next"
+	nonceMsgPrefix    = "/msgs"
+	nonceMsgCidPrefix = "/msg-cids"
+)
+
+type nonceRepo struct {
+	ds datastore.Batching
+}
+
+var _ repo.INonceRepo = (*nonceRepo)(nil)
+
+func NewNonceRepo(ds NonceLedgerDS) *nonceRepo {
+	return &nonceRepo{ds: ds}
+}
+
+func nonceNextKey(addr address.Address) datastore.Key {
+	return datastore.NewKey(nonceNextPrefix).ChildString(addr.String())
+}
+
+// message keys are /msgs/<addr>/<nonce>, the nonce is padded so that the messages sort by nonce
+func nonceMsgKey(addr address.Address, nonce uint64) datastore.Key {
+	return datastore.NewKey(nonceMsgPrefix).ChildString(addr.String()).ChildString(fmt.Sprintf("%020d", nonce))
+}
+
+// /msg-cids/<cid> holds the key of the message first or last pushed with the cid
+func nonceMsgCidKey(c cid.Cid) datastore.Key {
+	return datastore.NewKey(nonceMsgCidPrefix).ChildString(c.String())
+}
+
+func (r *nonceRepo) GetNextNonce(ctx context.Context, addr address.Address) (uint64, error) {
+	data, err := r.ds.Get(ctx, nonceNextKey(addr))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(string(data), 10, 64)
+}
+
+func (r *nonceRepo) SetNextNonce(ctx context.Context, addr address.Address, nonce uint64) error {
+	return r.ds.Put(ctx, nonceNextKey(addr), []byte(strconv.FormatUint(nonce, 10)))
+}
+
+func (r *nonceRepo) ListNonceAddrs(ctx context.Context) ([]address.Address, error) {
+	res, err := r.ds.Query(ctx, dsq.Query{Prefix: nonceNextPrefix, KeysOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close() //nolint:errcheck
+
+	var addrs []address.Address
+	for e := range res.Next() {
+		if e.Error != nil {
+			return nil, e.Error
+		}
+		addr, err := address.NewFromString(datastore.RawKey(e.Key).BaseNamespace())
+		if err != nil {
+			return nil, xerrors.Errorf("parse nonce ledger key %s: %w", e.Key, err)
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+func (r *nonceRepo) SaveNonceMessage(ctx context.Context, msg *types.NonceMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	key := nonceMsgKey(msg.From, msg.Nonce)
+	batch, err := r.ds.Batch(ctx)
+	if err != nil {
+		return err
+	}
+	// the cid a message was replaced with is not kept once it is replaced again
+	prev, err := r.getNonceMessage(ctx, key)
+	if err != nil && !xerrors.Is(err, repo.ErrNotFound) {
+		return err
+	}
+	if prev != nil {
+		for _, c := range []cid.Cid{prev.Cid, prev.OriginCid} {
+			if c.Defined() && c != msg.Cid && c != msg.OriginCid {
+				if err := batch.Delete(ctx, nonceMsgCidKey(c)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for _, c := range []cid.Cid{msg.Cid, msg.OriginCid} {
+		if c.Defined() {
+			if err := batch.Put(ctx, nonceMsgCidKey(c), key.Bytes()); err != nil {
+				return err
+			}
+		}
+	}
+	if err := batch.Put(ctx, key, data); err != nil {
+		return err
+	}
+	return batch.Commit(ctx)
+}
+
+func (r *nonceRepo) getNonceMessage(ctx context.Context, key datastore.Key) (*types.NonceMessage, error) {
+	data, err := r.ds.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	msg := &types.NonceMessage{}
+	if err := json.Unmarshal(data, msg); err != nil {
+		return nil, xerrors.Errorf("unmarshal nonce message %s: %w", key, err)
+	}
+	return msg, nil
+}
+
+func (r *nonceRepo) GetNonceMessageByCid(ctx context.Context, c cid.Cid) (*types.NonceMessage, error) {
+	key, err := r.ds.Get(ctx, nonceMsgCidKey(c))
+	if err != nil {
+		return nil, err
+	}
+	msg, err := r.getNonceMessage(ctx, datastore.RawKey(string(key)))
+	if err != nil {
+		return nil, err
+	}
+	if msg.Cid != c && msg.OriginCid != c {
+		return nil, repo.ErrNotFound
+	}
+	return msg, nil
+}
+
+func (r *nonceRepo) ListNonceMessages(ctx context.Context, addr address.Address) ([]*types.NonceMessage, error) {
+	prefix := datastore.NewKey(nonceMsgPrefix)
+	if addr != address.Undef {
+		prefix = prefix.ChildString(addr.String())
+	}
+	res, err := r.ds.Query(ctx, dsq.Query{
+		Prefix: prefix.String(),
+		Orders: []dsq.Order{dsq.OrderByKey{}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close() //nolint:errcheck
+
+	var msgs []*types.NonceMessage
+	for e := range res.Next() {
+		if e.Error != nil {
+			return nil, e.Error
+		}
+		msg := &types.NonceMessage{}
+		if err := json.Unmarshal(e.Value, msg); err != nil {
+			return nil, xerrors.Errorf("unmarshal nonce message %s: %w", e.Key, err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+func (r *nonceRepo) RemoveNonceMessages(ctx context.Context, addr address.Address, belowNonce uint64) error {
+	msgs, err := r.ListNonceMessages(ctx, addr)
+	if err != nil {
+		return err
+	}
+	batch, err := r.ds.Batch(ctx)
+	if err != nil {
+		return err
+	}
+	for _, msg := range msgs {
+		if msg.Nonce >= belowNonce {
+			break
+		}
+		if err := batch.Delete(ctx, nonceMsgKey(addr, msg.Nonce)); err != nil {
+			return err
+		}
+		for _, c := range []cid.Cid{msg.Cid, msg.OriginCid} {
+			if c.Defined() {
+				if err := batch.Delete(ctx, nonceMsgCidKey(c)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return batch.Commit(ctx)
+}
@@ -0,0 +1,69 @@
+package badger
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/venus-market/models/repo"
+	"github.com/filecoin-project/venus-market/types"
+)
+
+type pieceVerifyRepo struct {
+	ds datastore.Batching
+}
+
+var _ repo.IPieceVerifyRepo = (*pieceVerifyRepo)(nil)
+
+func NewPieceVerifyRepo(ds PieceVerifyDS) *pieceVerifyRepo {
+	return &pieceVerifyRepo{ds: ds}
+}
+
+func pieceVerifyKey(pieceCID cid.Cid) datastore.Key {
+	return datastore.NewKey(pieceCID.String())
+}
+
+func (r *pieceVerifyRepo) SavePieceVerify(ctx context.Context, res *types.PieceVerifyResult) error {
+	data, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	return r.ds.Put(ctx, pieceVerifyKey(res.PieceCID), data)
+}
+
+func (r *pieceVerifyRepo) GetPieceVerify(ctx context.Context, pieceCID cid.Cid) (*types.PieceVerifyResult, error) {
+	data, err := r.ds.Get(ctx, pieceVerifyKey(pieceCID))
+	if err != nil {
+		return nil, err
+	}
+	var res types.PieceVerifyResult
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, xerrors.Errorf("unmarshal verify result of %s: %w", pieceCID, err)
+	}
+	return &res, nil
+}
+
+func (r *pieceVerifyRepo) ListPieceVerify(ctx context.Context) ([]*types.PieceVerifyResult, error) {
+	res, err := r.ds.Query(ctx, dsq.Query{Orders: []dsq.Order{dsq.OrderByKey{}}})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close() //nolint:errcheck
+
+	var results []*types.PieceVerifyResult
+	for e := range res.Next() {
+		if e.Error != nil {
+			return nil, e.Error
+		}
+		var verify types.PieceVerifyResult
+		if err := json.Unmarshal(e.Value, &verify); err != nil {
+			return nil, xerrors.Errorf("unmarshal verify result %s: %w", e.Key, err)
+		}
+		results = append(results, &verify)
+	}
+	return results, nil
+}
@@ -3,21 +3,31 @@ package badger
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"time"
 
 	"github.com/filecoin-project/go-address"
 	cborrpc "github.com/filecoin-project/go-cbor-util"
 	"github.com/filecoin-project/venus-market/models/repo"
+	mtypes "github.com/filecoin-project/venus-market/types"
 	types "github.com/filecoin-project/venus/venus-shared/types/market"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"golang.org/x/xerrors"
 )
 
 type retrievalAskRepo struct {
-	ds RetrievalAskDS
+	ds       RetrievalAskDS
+	rulesDs  RetrievalAskRuleDS
+	walletDs RetrievalClientWalletDS
 }
 
 var _ repo.IRetrievalAskRepo = (*retrievalAskRepo)(nil)
 
-func NewRetrievalAskRepo(ds RetrievalAskDS) repo.IRetrievalAskRepo {
-	return &retrievalAskRepo{ds: ds}
+func NewRetrievalAskRepo(ds RetrievalAskDS, rulesDs RetrievalAskRuleDS, walletDs RetrievalClientWalletDS) repo.IRetrievalAskRepo {
+	return &retrievalAskRepo{ds: ds, rulesDs: rulesDs, walletDs: walletDs}
 }
 
 func (r *retrievalAskRepo) HasAsk(ctx context.Context, addr address.Address) (bool, error) {
@@ -56,3 +66,69 @@ func (r *retrievalAskRepo) ListAsk(ctx context.Context) ([]*types.RetrievalAsk,
 	}
 	return results, nil
 }
+
+// rule keys are /<miner>/<kind>/<value>, the value is encoded as it may contain anything a label pattern does
+func askRuleKey(miner address.Address, kind mtypes.RetrievalAskRuleKind, value string) datastore.Key {
+	return datastore.KeyWithNamespaces([]string{miner.String(), string(kind), base64.RawURLEncoding.EncodeToString([]byte(value))})
+}
+
+func (r *retrievalAskRepo) SetAskRule(ctx context.Context, rule *mtypes.RetrievalAskRule) error {
+	if rule == nil {
+		return xerrors.Errorf("param is nil")
+	}
+	rule.UpdatedAt = time.Now()
+	data, err := json.Marshal(rule)
+	if err != nil {
+		return err
+	}
+	return r.rulesDs.Put(ctx, askRuleKey(rule.Miner, rule.Kind, rule.Value), data)
+}
+
+func (r *retrievalAskRepo) RemoveAskRule(ctx context.Context, miner address.Address, kind mtypes.RetrievalAskRuleKind, value string) error {
+	key := askRuleKey(miner, kind, value)
+	has, err := r.rulesDs.Has(ctx, key)
+	if err != nil {
+		return err
+	}
+	if !has {
+		return repo.ErrNotFound
+	}
+	return r.rulesDs.Delete(ctx, key)
+}
+
+func (r *retrievalAskRepo) ListAskRules(ctx context.Context, miner address.Address) ([]*mtypes.RetrievalAskRule, error) {
+	q := query.Query{}
+	if miner != address.Undef {
+		q.Prefix = "/" + miner.String()
+	}
+	res, err := r.rulesDs.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close() //nolint:errcheck
+
+	var rules []*mtypes.RetrievalAskRule
+	for e := range res.Next() {
+		if e.Error != nil {
+			return nil, e.Error
+		}
+		rule := &mtypes.RetrievalAskRule{}
+		if err := json.Unmarshal(e.Value, rule); err != nil {
+			return nil, xerrors.Errorf("unmarshal retrieval ask rule %s failed:%w", e.Key, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (r *retrievalAskRepo) SetClientWallet(ctx context.Context, client peer.ID, wallet address.Address) error {
+	return r.walletDs.Put(ctx, datastore.NewKey(client.String()), wallet.Bytes())
+}
+
+func (r *retrievalAskRepo) GetClientWallet(ctx context.Context, client peer.ID) (address.Address, error) {
+	data, err := r.walletDs.Get(ctx, datastore.NewKey(client.String()))
+	if err != nil {
+		return address.Undef, err
+	}
+	return address.NewFromBytes(data)
+}
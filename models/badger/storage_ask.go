@@ -3,21 +3,28 @@ package badger
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
 	"github.com/filecoin-project/go-address"
 	cborrpc "github.com/filecoin-project/go-cbor-util"
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
 	"github.com/filecoin-project/go-statestore"
 	"github.com/filecoin-project/venus-market/models/repo"
+	"github.com/filecoin-project/venus-market/types"
 	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
 	"golang.org/x/xerrors"
 )
 
 type storageAskRepo struct {
-	ds datastore.Batching
+	ds        datastore.Batching
+	historyDs datastore.Batching
 }
 
-func NewStorageAskRepo(ds StorageAskDS) *storageAskRepo {
-	return &storageAskRepo{ds: ds}
+func NewStorageAskRepo(ds StorageAskDS, historyDs StorageAskHistoryDS) *storageAskRepo {
+	return &storageAskRepo{ds: ds, historyDs: historyDs}
 }
 
 func (ar *storageAskRepo) GetAsk(ctx context.Context, miner address.Address) (*storagemarket.SignedStorageAsk, error) {
@@ -61,3 +68,50 @@ func (ar *storageAskRepo) ListAsk(ctx context.Context) ([]*storagemarket.SignedS
 	}
 	return results, nil
 }
+
+// history keys are /<miner>/<created at in nanoseconds>, so that the changes of a miner sort by time
+func askHistoryKey(change *types.StorageAskChange) datastore.Key {
+	return datastore.KeyWithNamespaces([]string{change.Miner.String(), fmt.Sprintf("%020d", change.CreatedAt.UnixNano())})
+}
+
+func (ar *storageAskRepo) AddAskHistory(ctx context.Context, change *types.StorageAskChange) error {
+	if change == nil {
+		return xerrors.Errorf("param is nil")
+	}
+	if change.CreatedAt.IsZero() {
+		change.CreatedAt = time.Now()
+	}
+	b, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	return ar.historyDs.Put(ctx, askHistoryKey(change), b)
+}
+
+func (ar *storageAskRepo) ListAskHistory(ctx context.Context, miner address.Address, limit int) ([]*types.StorageAskChange, error) {
+	q := query.Query{
+		Prefix: "/" + miner.String(),
+		Orders: []query.Order{query.OrderByKeyDescending{}},
+	}
+	if limit > 0 {
+		q.Limit = limit
+	}
+	res, err := ar.historyDs.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close() //nolint:errcheck
+
+	var changes []*types.StorageAskChange
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		change := &types.StorageAskChange{}
+		if err := json.Unmarshal(r.Value, change); err != nil {
+			return nil, xerrors.Errorf("unmarshal storage ask change %s failed:%w", r.Key, err)
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
@@ -156,6 +156,19 @@ func (sdr *storageDealRepo) ListDealByAddr(ctx context.Context, miner address.Ad
 	return storageDeals, nil
 }
 
+func (sdr *storageDealRepo) ListDealByClient(ctx context.Context, client address.Address) ([]*types.MinerDeal, error) {
+	storageDeals := make([]*types.MinerDeal, 0)
+	if err := travelDeals(ctx, sdr.ds, func(deal *types.MinerDeal) (bool, error) {
+		if deal.ClientDealProposal.Proposal.Client == client {
+			storageDeals = append(storageDeals, deal)
+		}
+		return false, nil
+	}); err != nil {
+		return nil, err
+	}
+	return storageDeals, nil
+}
+
 func (sdr *storageDealRepo) ListDeal(ctx context.Context) ([]*types.MinerDeal, error) {
 	storageDeals := make([]*types.MinerDeal, 0)
 	if err := travelDeals(ctx, sdr.ds, func(deal *types.MinerDeal) (bool, error) {
@@ -231,6 +244,22 @@ func (dsr *storageDealRepo) GetDealByDealID(ctx context.Context, mAddr address.A
 	return deal, err
 }
 
+// CASPieceStatus is atomic inside BadgerRepo.Transaction only, which serializes the transactions
+func (dsr *storageDealRepo) CASPieceStatus(ctx context.Context, mAddr address.Address, dealID abi.DealID, from, to string) (bool, error) {
+	deal, err := dsr.GetDealByDealID(ctx, mAddr, dealID)
+	if err != nil {
+		return false, err
+	}
+	if deal.PieceStatus != from {
+		return false, nil
+	}
+	deal.PieceStatus = to
+	if err := dsr.SaveDeal(ctx, deal); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 func (dsr *storageDealRepo) GetDealsByPieceStatusV0(ctx context.Context, mAddr address.Address, pieceStatus string) ([]*types.MinerDeal, error) {
 	var deals []*types.MinerDeal
 	var err error
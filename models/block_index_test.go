@@ -0,0 +1,61 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/venus-market/models/badger"
+	"github.com/filecoin-project/venus-market/models/repo"
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockIndex(t *testing.T) {
+	t.Run("mysql", func(t *testing.T) {
+		repo := MysqlDB(t)
+		defer func() { require.NoError(t, repo.Close()) }()
+		testBlockIndex(t, repo.BlockIndexRepo())
+	})
+	t.Run("badger", func(t *testing.T) {
+		testBlockIndex(t, badger.NewBlockIndexRepo(BadgerDB(t)))
+	})
+}
+
+func testBlockIndex(t *testing.T, blockIndexRepo repo.IBlockIndexRepo) {
+	ctx := context.Background()
+	piece1, piece2 := randCid(t), randCid(t)
+	shared, only1, only2 := randCid(t).Hash(), randCid(t).Hash(), randCid(t).Hash()
+
+	has, err := blockIndexRepo.HasBlockIndex(ctx, piece1)
+	require.NoError(t, err)
+	require.False(t, has)
+
+	require.NoError(t, blockIndexRepo.AddBlockIndex(ctx, piece1, []multihash.Multihash{shared, only1}))
+	require.NoError(t, blockIndexRepo.AddBlockIndex(ctx, piece2, []multihash.Multihash{shared, only2}))
+	// indexing a piece again keeps a single entry per block
+	require.NoError(t, blockIndexRepo.AddBlockIndex(ctx, piece1, []multihash.Multihash{shared, only1}))
+
+	has, err = blockIndexRepo.HasBlockIndex(ctx, piece1)
+	require.NoError(t, err)
+	require.True(t, has)
+
+	pieces, err := blockIndexRepo.GetPiecesByBlock(ctx, shared)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []cid.Cid{piece1, piece2}, pieces)
+	pieces, err = blockIndexRepo.GetPiecesByBlock(ctx, only2)
+	require.NoError(t, err)
+	require.Equal(t, []cid.Cid{piece2}, pieces)
+	_, err = blockIndexRepo.GetPiecesByBlock(ctx, randCid(t).Hash())
+	require.ErrorIs(t, err, repo.ErrNotFound)
+
+	require.NoError(t, blockIndexRepo.RemoveBlockIndex(ctx, piece1))
+	has, err = blockIndexRepo.HasBlockIndex(ctx, piece1)
+	require.NoError(t, err)
+	require.False(t, has)
+	pieces, err = blockIndexRepo.GetPiecesByBlock(ctx, shared)
+	require.NoError(t, err)
+	require.Equal(t, []cid.Cid{piece2}, pieces)
+	_, err = blockIndexRepo.GetPiecesByBlock(ctx, only1)
+	require.ErrorIs(t, err, repo.ErrNotFound)
+}
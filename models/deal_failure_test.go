@@ -0,0 +1,45 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/venus-market/models/badger"
+	"github.com/filecoin-project/venus-market/models/repo"
+)
+
+func TestDealFailure(t *testing.T) {
+	t.Run("mysql", func(t *testing.T) {
+		repo := MysqlDB(t)
+		defer func() { require.NoError(t, repo.Close()) }()
+		testDealFailure(t, repo.DealFailureRepo())
+	})
+	t.Run("badger", func(t *testing.T) {
+		testDealFailure(t, badger.NewDealFailureRepo(BadgerDB(t)))
+	})
+}
+
+func testDealFailure(t *testing.T, failureRepo repo.IDealFailureRepo) {
+	ctx := context.Background()
+	deal1, deal2 := randCid(t), randCid(t)
+	failedAt := time.Now().Add(-time.Hour)
+
+	require.NoError(t, failureRepo.SaveDealFailure(ctx, deal1, failedAt))
+	require.NoError(t, failureRepo.SaveDealFailure(ctx, deal2, time.Now()))
+	// the first time the deal was seen failed is kept
+	require.NoError(t, failureRepo.SaveDealFailure(ctx, deal1, time.Now()))
+
+	failures, err := failureRepo.ListDealFailures(ctx)
+	require.NoError(t, err)
+	require.Len(t, failures, 2)
+	require.Equal(t, failedAt.Unix(), failures[deal1].Unix())
+
+	require.NoError(t, failureRepo.RemoveDealFailure(ctx, deal2))
+	failures, err = failureRepo.ListDealFailures(ctx)
+	require.NoError(t, err)
+	require.Len(t, failures, 1)
+	require.Contains(t, failures, deal1)
+}
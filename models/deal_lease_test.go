@@ -0,0 +1,74 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/venus-market/models/badger"
+	"github.com/filecoin-project/venus-market/models/repo"
+	"github.com/filecoin-project/venus-market/types"
+)
+
+func TestDealLease(t *testing.T) {
+	t.Run("mysql", func(t *testing.T) {
+		repo := MysqlDB(t)
+		defer func() { require.NoError(t, repo.Close()) }()
+		testDealLease(t, repo.DealLeaseRepo())
+	})
+	t.Run("badger", func(t *testing.T) {
+		testDealLease(t, badger.NewDealLeaseRepo(BadgerDB(t)))
+	})
+}
+
+func testDealLease(t *testing.T, leaseRepo repo.IDealLeaseRepo) {
+	ctx := context.Background()
+	miner1, miner2 := randAddress(t), randAddress(t)
+	now := time.Unix(time.Now().Unix(), 0)
+
+	_, err := leaseRepo.GetDealLease(ctx, miner1, 1)
+	require.ErrorIs(t, err, repo.ErrNotFound)
+
+	for _, lease := range []*types.DealLease{
+		{Miner: miner1, DealID: 10, Owner: "sealer-a", AssignedAt: now, Expiry: now.Add(time.Minute), StartEpoch: 1000},
+		{Miner: miner1, DealID: 2, Owner: "sealer-b", AssignedAt: now, StartEpoch: 2000},
+		{Miner: miner2, DealID: 5, Owner: "sealer-a", AssignedAt: now, Expiry: now.Add(time.Hour), StartEpoch: 3000},
+	} {
+		require.NoError(t, leaseRepo.SaveDealLease(ctx, lease))
+	}
+
+	lease, err := leaseRepo.GetDealLease(ctx, miner1, 2)
+	require.NoError(t, err)
+	require.Equal(t, "sealer-b", lease.Owner)
+	require.True(t, lease.Expiry.IsZero())
+	require.Equal(t, abi.ChainEpoch(2000), lease.StartEpoch)
+
+	// a renewal replaces the lease
+	require.NoError(t, leaseRepo.SaveDealLease(ctx, &types.DealLease{Miner: miner1, DealID: 10, Owner: "sealer-a", AssignedAt: now, Expiry: now.Add(time.Hour), StartEpoch: 1000}))
+	lease, err = leaseRepo.GetDealLease(ctx, miner1, 10)
+	require.NoError(t, err)
+	require.Equal(t, now.Add(time.Hour).Unix(), lease.Expiry.Unix())
+
+	leases, err := leaseRepo.ListDealLeases(ctx, miner1)
+	require.NoError(t, err)
+	require.Len(t, leases, 2)
+	require.Equal(t, abi.DealID(2), leases[0].DealID)
+	require.Equal(t, abi.DealID(10), leases[1].DealID)
+
+	all, err := leaseRepo.ListDealLeases(ctx, address.Undef)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(all), 3)
+
+	require.NoError(t, leaseRepo.RemoveDealLease(ctx, miner1, 10))
+	require.NoError(t, leaseRepo.RemoveDealLease(ctx, miner1, 10))
+	_, err = leaseRepo.GetDealLease(ctx, miner1, 10)
+	require.ErrorIs(t, err, repo.ErrNotFound)
+	leases, err = leaseRepo.ListDealLeases(ctx, miner2)
+	require.NoError(t, err)
+	require.Len(t, leases, 1)
+	require.Equal(t, miner2, leases[0].Miner)
+}
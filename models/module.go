@@ -16,6 +16,7 @@ var invokeDataMigrate = builder.NextInvoke()
 var DBOptions = func(server bool, mysqlCfg *config.Mysql) builder.Option {
 	return builder.Options(
 		builder.Override(new(badger2.MetadataDS), badger2.NewMetadataDS),
+		builder.Override(new(badger2.ConnGaterDS), badger2.NewConnGaterDS),
 		builder.Override(invokeDataMigrate, func(r repo.Repo) error { return r.Migrate() }),
 		builder.ApplyIfElse(func(s *builder.Settings) bool {
 			return server
@@ -35,12 +36,20 @@ var DBOptions = func(server bool, mysqlCfg *config.Mysql) builder.Option {
 					builder.Override(new(badger2.CIDInfoDS), badger2.NewCidInfoDs),
 					builder.Override(new(badger2.RetrievalProviderDS), badger2.NewRetrievalProviderDS),
 					builder.Override(new(badger2.RetrievalAskDS), badger2.NewRetrievalAskDS),
+					builder.Override(new(badger2.RetrievalAskRuleDS), badger2.NewRetrievalAskRuleDS),
+					builder.Override(new(badger2.RetrievalClientWalletDS), badger2.NewRetrievalClientWalletDS),
 					builder.Override(new(badger2.StorageProviderDS), badger2.NewStorageProviderDS),
 					builder.Override(new(badger2.StorageDealsDS), badger2.NewStorageDealsDS),
 					builder.Override(new(badger2.StorageAskDS), badger2.NewStorageAskDS),
+					builder.Override(new(badger2.StorageAskHistoryDS), badger2.NewStorageAskHistoryDS),
 					builder.Override(new(badger2.PayChanDS), badger2.NewPayChanDS),
 					builder.Override(new(badger2.FundMgrDS), badger2.NewFundMgrDS),
 					builder.Override(new(badger2.RetrievalDealsDS), badger2.NewRetrievalDealsDS),
+					builder.Override(new(badger2.NonceLedgerDS), badger2.NewNonceLedgerDS),
+					builder.Override(new(badger2.BlockIndexDS), badger2.NewBlockIndexDS),
+					builder.Override(new(badger2.PieceVerifyDS), badger2.NewPieceVerifyDS),
+					builder.Override(new(badger2.DealLeaseDS), badger2.NewDealLeaseDS),
+					builder.Override(new(badger2.DealFailureDS), badger2.NewDealFailureDS),
 
 					builder.Override(new(repo.Repo), badger2.NewBadgerRepo),
 				),
@@ -57,6 +66,9 @@ var DBOptions = func(server bool, mysqlCfg *config.Mysql) builder.Option {
 				builder.Override(new(badger2.RetrievalClientDS), badger2.NewRetrievalClientDS),
 				builder.Override(new(badger2.ImportClientDS), badger2.NewImportClientDS),
 				builder.Override(new(badger2.ClientTransferDS), badger2.NewClientTransferDS),
+				builder.Override(new(badger2.ClientDealRenewalDS), badger2.NewClientDealRenewalDS),
+				builder.Override(new(badger2.RetrievalReputationDS), badger2.NewRetrievalReputationDS),
+				builder.Override(new(badger2.NonceLedgerDS), badger2.NewNonceLedgerDS),
 
 				builder.Override(new(repo.Repo), badger2.NewBadgerRepo),
 			),
@@ -0,0 +1,105 @@
+package mysql
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/filecoin-project/venus-market/models/repo"
+)
+
+const (
+	blockIndexTableName      = "block_indexes"
+	blockIndexPieceTableName = "block_index_pieces"
+
+	blockIndexBatchSize = 1000
+)
+
+// blockIndex keeps the raw multihash of the block, a piece holds up to millions of blocks
+type blockIndex struct {
+	Block    []byte `gorm:"column:block;primaryKey;type:varbinary(128);"`
+	PieceCid DBCid  `gorm:"column:piece_cid;primaryKey;type:varchar(256);index"`
+}
+
+func (b *blockIndex) TableName() string {
+	return blockIndexTableName
+}
+
+type blockIndexPiece struct {
+	PieceCid DBCid  `gorm:"column:piece_cid;primaryKey;type:varchar(256);"`
+	Blocks   uint64 `gorm:"column:blocks;type:bigint unsigned;"`
+	TimeStampOrm
+}
+
+func (p *blockIndexPiece) TableName() string {
+	return blockIndexPieceTableName
+}
+
+type blockIndexRepo struct {
+	*gorm.DB
+}
+
+var _ repo.IBlockIndexRepo = (*blockIndexRepo)(nil)
+
+func NewBlockIndexRepo(db *gorm.DB) *blockIndexRepo {
+	return &blockIndexRepo{db}
+}
+
+func (r *blockIndexRepo) AddBlockIndex(ctx context.Context, pieceCID cid.Cid, blocks []multihash.Multihash) error {
+	rows := make([]blockIndex, len(blocks))
+	for index, block := range blocks {
+		rows[index] = blockIndex{Block: block, PieceCid: DBCid(pieceCID)}
+	}
+	now := uint64(time.Now().Unix())
+	return r.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(rows) > 0 {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(rows, blockIndexBatchSize).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "piece_cid"}},
+			DoUpdates: clause.AssignmentColumns([]string{"blocks", "updated_at"}),
+		}).Create(&blockIndexPiece{
+			PieceCid:     DBCid(pieceCID),
+			Blocks:       uint64(len(blocks)),
+			TimeStampOrm: TimeStampOrm{CreatedAt: now, UpdatedAt: now},
+		}).Error
+	})
+}
+
+func (r *blockIndexRepo) GetPiecesByBlock(ctx context.Context, block multihash.Multihash) ([]cid.Cid, error) {
+	var rows []blockIndex
+	if err := r.WithContext(ctx).Table(blockIndexTableName).Find(&rows, "block = ?", []byte(block)).Error; err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, repo.ErrNotFound
+	}
+	pieces := make([]cid.Cid, len(rows))
+	for index, row := range rows {
+		pieces[index] = row.PieceCid.cid()
+	}
+	return pieces, nil
+}
+
+func (r *blockIndexRepo) HasBlockIndex(ctx context.Context, pieceCID cid.Cid) (bool, error) {
+	var count int64
+	if err := r.WithContext(ctx).Table(blockIndexPieceTableName).Where("piece_cid = ?", pieceCID.String()).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *blockIndexRepo) RemoveBlockIndex(ctx context.Context, pieceCID cid.Cid) error {
+	return r.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("piece_cid = ?", pieceCID.String()).Delete(&blockIndex{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("piece_cid = ?", pieceCID.String()).Delete(&blockIndexPiece{}).Error
+	})
+}
@@ -60,6 +60,26 @@ func (r MysqlRepo) RetrievalDealRepo() repo.IRetrievalDealRepo {
 	return NewRetrievalDealRepo(r.GetDb())
 }
 
+func (r MysqlRepo) NonceRepo() repo.INonceRepo {
+	return NewNonceRepo(r.GetDb())
+}
+
+func (r MysqlRepo) BlockIndexRepo() repo.IBlockIndexRepo {
+	return NewBlockIndexRepo(r.GetDb())
+}
+
+func (r MysqlRepo) PieceVerifyRepo() repo.IPieceVerifyRepo {
+	return NewPieceVerifyRepo(r.GetDb())
+}
+
+func (r MysqlRepo) DealLeaseRepo() repo.IDealLeaseRepo {
+	return NewDealLeaseRepo(r.GetDb())
+}
+
+func (r MysqlRepo) DealFailureRepo() repo.IDealFailureRepo {
+	return NewDealFailureRepo(r.GetDb())
+}
+
 func (r MysqlRepo) Close() error {
 	db, err := r.DB.DB()
 	if err != nil {
@@ -89,6 +109,16 @@ func (r MysqlRepo) Migrate() error {
 		return err
 	}
 
+	err = r.GetDb().AutoMigrate(retrievalAskRule{})
+	if err != nil {
+		return err
+	}
+
+	err = r.GetDb().AutoMigrate(retrievalClientWallet{})
+	if err != nil {
+		return err
+	}
+
 	err = r.GetDb().AutoMigrate(retrievalDeal{})
 	if err != nil {
 		return err
@@ -103,6 +133,36 @@ func (r MysqlRepo) Migrate() error {
 	if err != nil {
 		return err
 	}
+
+	err = r.GetDb().AutoMigrate(storageAskHistory{})
+	if err != nil {
+		return err
+	}
+
+	err = r.GetDb().AutoMigrate(nonceLedger{}, nonceMessage{})
+	if err != nil {
+		return err
+	}
+
+	err = r.GetDb().AutoMigrate(blockIndex{}, blockIndexPiece{})
+	if err != nil {
+		return err
+	}
+
+	err = r.GetDb().AutoMigrate(pieceVerify{})
+	if err != nil {
+		return err
+	}
+
+	err = r.GetDb().AutoMigrate(dealLease{})
+	if err != nil {
+		return err
+	}
+
+	err = r.GetDb().AutoMigrate(dealFailure{})
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -120,6 +180,10 @@ func (r txRepo) StorageDealRepo() repo.StorageDealRepo {
 	return NewStorageDealRepo(r.DB)
 }
 
+func (r txRepo) DealLeaseRepo() repo.IDealLeaseRepo {
+	return NewDealLeaseRepo(r.DB)
+}
+
 func InitMysql(cfg *config.Mysql) (repo.Repo, error) {
 	gorm.ErrRecordNotFound = repo.ErrNotFound
 	db, err := gorm.Open(mysql.Open(cfg.ConnectionString))
@@ -147,7 +211,7 @@ func InitMysql(cfg *config.Mysql) (repo.Repo, error) {
 
 	r := &MysqlRepo{DB: db}
 
-	return r, r.AutoMigrate(retrievalAsk{}, cidInfo{}, storageAsk{}, fundedAddressState{}, storageDeal{}, channelInfo{}, msgInfo{})
+	return r, r.AutoMigrate(retrievalAsk{}, retrievalAskRule{}, cidInfo{}, storageAsk{}, storageAskHistory{}, fundedAddressState{}, storageDeal{}, channelInfo{}, msgInfo{}, nonceLedger{}, nonceMessage{}, blockIndex{}, blockIndexPiece{}, pieceVerify{}, dealLease{}, dealFailure{})
 }
 
 type DBCid cid.Cid
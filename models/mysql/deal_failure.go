@@ -0,0 +1,56 @@
+package mysql
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/filecoin-project/venus-market/models/repo"
+)
+
+const dealFailureTableName = "deal_failures"
+
+type dealFailure struct {
+	ProposalCid DBCid `gorm:"column:proposal_cid;primaryKey;type:varchar(256);"`
+	FailedAt    int64 `gorm:"column:failed_at;type:bigint;"`
+}
+
+func (f *dealFailure) TableName() string {
+	return dealFailureTableName
+}
+
+type dealFailureRepo struct {
+	*gorm.DB
+}
+
+var _ repo.IDealFailureRepo = (*dealFailureRepo)(nil)
+
+func NewDealFailureRepo(db *gorm.DB) *dealFailureRepo {
+	return &dealFailureRepo{db}
+}
+
+func (r *dealFailureRepo) SaveDealFailure(ctx context.Context, proposalCid cid.Cid, failedAt time.Time) error {
+	return r.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&dealFailure{
+		ProposalCid: DBCid(proposalCid),
+		FailedAt:    failedAt.Unix(),
+	}).Error
+}
+
+func (r *dealFailureRepo) ListDealFailures(ctx context.Context) (map[cid.Cid]time.Time, error) {
+	var rows []dealFailure
+	if err := r.WithContext(ctx).Table(dealFailureTableName).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	failures := make(map[cid.Cid]time.Time, len(rows))
+	for _, row := range rows {
+		failures[row.ProposalCid.cid()] = time.Unix(row.FailedAt, 0)
+	}
+	return failures, nil
+}
+
+func (r *dealFailureRepo) RemoveDealFailure(ctx context.Context, proposalCid cid.Cid) error {
+	return r.WithContext(ctx).Where("proposal_cid = ?", proposalCid.String()).Delete(&dealFailure{}).Error
+}
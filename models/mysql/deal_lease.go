@@ -0,0 +1,133 @@
+package mysql
+
+import (
+	"context"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/filecoin-project/venus-market/models/repo"
+	"github.com/filecoin-project/venus-market/types"
+)
+
+const dealLeaseTableName = "deal_leases"
+
+type dealLease struct {
+	Miner      DBAddress `gorm:"column:miner;primaryKey;type:varchar(256);"`
+	DealID     uint64    `gorm:"column:deal_id;primaryKey;type:bigint unsigned;"`
+	Owner      string    `gorm:"column:owner;type:varchar(256);"`
+	AssignedAt int64     `gorm:"column:assigned_at;type:bigint;"`
+	// Expiry is 0 when the lease does not expire
+	Expiry     int64 `gorm:"column:expiry;type:bigint;index"`
+	StartEpoch int64 `gorm:"column:start_epoch;type:bigint;"`
+}
+
+func (l *dealLease) TableName() string {
+	return dealLeaseTableName
+}
+
+func fromDealLease(lease *types.DealLease) *dealLease {
+	row := &dealLease{
+		Miner:      DBAddress(lease.Miner),
+		DealID:     uint64(lease.DealID),
+		Owner:      lease.Owner,
+		AssignedAt: lease.AssignedAt.Unix(),
+		StartEpoch: int64(lease.StartEpoch),
+	}
+	if !lease.Expiry.IsZero() {
+		row.Expiry = lease.Expiry.Unix()
+	}
+	return row
+}
+
+func (l *dealLease) toDealLease() *types.DealLease {
+	lease := &types.DealLease{
+		Miner:      l.Miner.addr(),
+		DealID:     abi.DealID(l.DealID),
+		Owner:      l.Owner,
+		AssignedAt: time.Unix(l.AssignedAt, 0),
+		StartEpoch: abi.ChainEpoch(l.StartEpoch),
+	}
+	if l.Expiry != 0 {
+		lease.Expiry = time.Unix(l.Expiry, 0)
+	}
+	return lease
+}
+
+type dealLeaseRepo struct {
+	*gorm.DB
+}
+
+var _ repo.IDealLeaseRepo = (*dealLeaseRepo)(nil)
+
+func NewDealLeaseRepo(db *gorm.DB) *dealLeaseRepo {
+	return &dealLeaseRepo{db}
+}
+
+func (r *dealLeaseRepo) SaveDealLease(ctx context.Context, lease *types.DealLease) error {
+	return r.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "miner"}, {Name: "deal_id"}},
+		UpdateAll: true,
+	}).Create(fromDealLease(lease)).Error
+}
+
+func (r *dealLeaseRepo) GetDealLease(ctx context.Context, miner address.Address, dealID abi.DealID) (*types.DealLease, error) {
+	var row dealLease
+	if err := r.WithContext(ctx).Take(&row, "miner = ? AND deal_id = ?", DBAddress(miner).String(), dealID).Error; err != nil {
+		return nil, err
+	}
+	return row.toDealLease(), nil
+}
+
+func (r *dealLeaseRepo) RemoveDealLease(ctx context.Context, miner address.Address, dealID abi.DealID) error {
+	return r.WithContext(ctx).Where("miner = ? AND deal_id = ?", DBAddress(miner).String(), dealID).Delete(&dealLease{}).Error
+}
+
+func (r *dealLeaseRepo) RenewDealLease(ctx context.Context, miner address.Address, dealID abi.DealID, owner string, expiry time.Time) (bool, error) {
+	var value int64
+	if !expiry.IsZero() {
+		value = expiry.Unix()
+	}
+	where := func() *gorm.DB {
+		return r.WithContext(ctx).Model(&dealLease{}).Where("miner = ? AND deal_id = ? AND owner = ?", DBAddress(miner).String(), dealID, owner)
+	}
+	res := where().Update("expiry", value)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected > 0 {
+		return true, nil
+	}
+	// the rows set to the value they had are not counted
+	var count int64
+	if err := where().Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *dealLeaseRepo) RemoveExpiredDealLease(ctx context.Context, miner address.Address, dealID abi.DealID, owner string, now time.Time) (bool, error) {
+	res := r.WithContext(ctx).
+		Where("miner = ? AND deal_id = ? AND owner = ? AND expiry > 0 AND expiry < ?", DBAddress(miner).String(), dealID, owner, now.Unix()).
+		Delete(&dealLease{})
+	return res.RowsAffected > 0, res.Error
+}
+
+func (r *dealLeaseRepo) ListDealLeases(ctx context.Context, miner address.Address) ([]*types.DealLease, error) {
+	query := r.WithContext(ctx).Table(dealLeaseTableName)
+	if miner != address.Undef {
+		query = query.Where("miner = ?", DBAddress(miner).String())
+	}
+	var rows []dealLease
+	if err := query.Order("miner").Order("deal_id").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	leases := make([]*types.DealLease, len(rows))
+	for index := range rows {
+		leases[index] = rows[index].toDealLease()
+	}
+	return leases, nil
+}
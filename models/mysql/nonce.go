@@ -0,0 +1,200 @@
+package mysql
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/exitcode"
+	"github.com/filecoin-project/venus-messager/models/mtypes"
+	"github.com/ipfs/go-cid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/filecoin-project/venus-market/models/repo"
+	"github.com/filecoin-project/venus-market/types"
+
+	vtypes "github.com/filecoin-project/venus/venus-shared/types"
+)
+
+const (
+	nonceLedgerTableName  = "nonce_ledgers"
+	nonceMessageTableName = "nonce_messages"
+)
+
+type nonceLedger struct {
+	ID        uint      `gorm:"primary_key"`
+	Addr      DBAddress `gorm:"column:addr;type:varchar(256);uniqueIndex"`
+	NextNonce uint64    `gorm:"column:next_nonce;type:bigint unsigned;"`
+	TimeStampOrm
+}
+
+func (l *nonceLedger) TableName() string {
+	return nonceLedgerTableName
+}
+
+type nonceMessage struct {
+	ID          uint       `gorm:"primary_key"`
+	From        DBAddress  `gorm:"column:from_addr;type:varchar(256);uniqueIndex:idx_from_nonce"`
+	Nonce       uint64     `gorm:"column:nonce;type:bigint unsigned;uniqueIndex:idx_from_nonce"`
+	Cid         DBCid      `gorm:"column:cid;type:varchar(256);index"`
+	OriginCid   DBCid      `gorm:"column:origin_cid;type:varchar(256);index"`
+	Message     []byte     `gorm:"column:message;type:blob;"`
+	MaxFee      mtypes.Int `gorm:"column:max_fee;type:varchar(256);"`
+	State       string     `gorm:"column:state;type:varchar(32);"`
+	Filler      bool       `gorm:"column:filler;"`
+	Repushed    int        `gorm:"column:repushed;"`
+	Replaced    int        `gorm:"column:replaced;"`
+	PushedEpoch int64      `gorm:"column:pushed_epoch;type:bigint;"`
+	Height      int64      `gorm:"column:height;type:bigint;"`
+	ExitCode    int64      `gorm:"column:exit_code;type:bigint;"`
+	CreatedAt   int64      `gorm:"column:created_at;type:bigint;"`
+	UpdatedAt   int64      `gorm:"column:updated_at;type:bigint;"`
+}
+
+func (m *nonceMessage) TableName() string {
+	return nonceMessageTableName
+}
+
+func fromNonceMessage(src *types.NonceMessage) (*nonceMessage, error) {
+	msg := &nonceMessage{
+		From:        DBAddress(src.From),
+		Nonce:       src.Nonce,
+		Cid:         DBCid(src.Cid),
+		OriginCid:   DBCid(src.OriginCid),
+		MaxFee:      convertBigInt(src.MaxFee),
+		State:       string(src.State),
+		Filler:      src.Filler,
+		Repushed:    src.Repushed,
+		Replaced:    src.Replaced,
+		PushedEpoch: int64(src.PushedEpoch),
+		Height:      int64(src.Height),
+		ExitCode:    int64(src.ExitCode),
+		CreatedAt:   src.CreatedAt.Unix(),
+		UpdatedAt:   src.UpdatedAt.Unix(),
+	}
+	if src.Message != nil {
+		buf := new(bytes.Buffer)
+		if err := src.Message.MarshalCBOR(buf); err != nil {
+			return nil, err
+		}
+		msg.Message = buf.Bytes()
+	}
+	return msg, nil
+}
+
+func toNonceMessage(src *nonceMessage) (*types.NonceMessage, error) {
+	msg := &types.NonceMessage{
+		From:        src.From.addr(),
+		Nonce:       src.Nonce,
+		Cid:         src.Cid.cid(),
+		OriginCid:   src.OriginCid.cid(),
+		MaxFee:      abi.TokenAmount{Int: src.MaxFee.Int},
+		State:       types.MsgState(src.State),
+		Filler:      src.Filler,
+		Repushed:    src.Repushed,
+		Replaced:    src.Replaced,
+		PushedEpoch: abi.ChainEpoch(src.PushedEpoch),
+		Height:      abi.ChainEpoch(src.Height),
+		ExitCode:    exitcode.ExitCode(src.ExitCode),
+		CreatedAt:   time.Unix(src.CreatedAt, 0),
+		UpdatedAt:   time.Unix(src.UpdatedAt, 0),
+	}
+	if len(src.Message) > 0 {
+		msg.Message = &vtypes.SignedMessage{}
+		if err := msg.Message.UnmarshalCBOR(bytes.NewReader(src.Message)); err != nil {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
+
+type nonceRepo struct {
+	*gorm.DB
+}
+
+var _ repo.INonceRepo = (*nonceRepo)(nil)
+
+func NewNonceRepo(db *gorm.DB) *nonceRepo {
+	return &nonceRepo{db}
+}
+
+func (r *nonceRepo) GetNextNonce(ctx context.Context, addr address.Address) (uint64, error) {
+	var ledger nonceLedger
+	if err := r.WithContext(ctx).Take(&ledger, "addr = ?", DBAddress(addr).String()).Error; err != nil {
+		return 0, err
+	}
+	return ledger.NextNonce, nil
+}
+
+func (r *nonceRepo) SetNextNonce(ctx context.Context, addr address.Address, nonce uint64) error {
+	now := uint64(time.Now().Unix())
+	return r.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "addr"}},
+		DoUpdates: clause.AssignmentColumns([]string{"next_nonce", "updated_at"}),
+	}).Create(&nonceLedger{
+		Addr:         DBAddress(addr),
+		NextNonce:    nonce,
+		TimeStampOrm: TimeStampOrm{CreatedAt: now, UpdatedAt: now},
+	}).Error
+}
+
+func (r *nonceRepo) ListNonceAddrs(ctx context.Context) ([]address.Address, error) {
+	var ledgers []nonceLedger
+	if err := r.WithContext(ctx).Table(nonceLedgerTableName).Find(&ledgers).Error; err != nil {
+		return nil, err
+	}
+	addrs := make([]address.Address, len(ledgers))
+	for index, ledger := range ledgers {
+		addrs[index] = ledger.Addr.addr()
+	}
+	return addrs, nil
+}
+
+func (r *nonceRepo) SaveNonceMessage(ctx context.Context, msg *types.NonceMessage) error {
+	dbMsg, err := fromNonceMessage(msg)
+	if err != nil {
+		return err
+	}
+	return r.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "from_addr"}, {Name: "nonce"}},
+		UpdateAll: true,
+	}).Create(dbMsg).Error
+}
+
+func (r *nonceRepo) ListNonceMessages(ctx context.Context, addr address.Address) ([]*types.NonceMessage, error) {
+	var dbMsgs []nonceMessage
+	query := r.WithContext(ctx).Table(nonceMessageTableName)
+	if addr != address.Undef {
+		query = query.Where("from_addr = ?", DBAddress(addr).String())
+	}
+	if err := query.Order("from_addr").Order("nonce").Find(&dbMsgs).Error; err != nil {
+		return nil, err
+	}
+	msgs := make([]*types.NonceMessage, len(dbMsgs))
+	for index := range dbMsgs {
+		msg, err := toNonceMessage(&dbMsgs[index])
+		if err != nil {
+			return nil, err
+		}
+		msgs[index] = msg
+	}
+	return msgs, nil
+}
+
+func (r *nonceRepo) GetNonceMessageByCid(ctx context.Context, c cid.Cid) (*types.NonceMessage, error) {
+	var dbMsg nonceMessage
+	if err := r.WithContext(ctx).Table(nonceMessageTableName).
+		Where("cid = ? OR origin_cid = ?", c.String(), c.String()).
+		Take(&dbMsg).Error; err != nil {
+		return nil, err
+	}
+	return toNonceMessage(&dbMsg)
+}
+
+func (r *nonceRepo) RemoveNonceMessages(ctx context.Context, addr address.Address, belowNonce uint64) error {
+	return r.WithContext(ctx).Where("from_addr = ? and nonce < ?", DBAddress(addr).String(), belowNonce).
+		Delete(&nonceMessage{}).Error
+}
@@ -0,0 +1,83 @@
+package mysql
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/filecoin-project/venus-market/models/repo"
+	"github.com/filecoin-project/venus-market/types"
+)
+
+const pieceVerifyTableName = "piece_verifies"
+
+type pieceVerify struct {
+	PieceCid    DBCid  `gorm:"column:piece_cid;primaryKey;type:varchar(256);"`
+	State       string `gorm:"column:state;type:varchar(32);index"`
+	ComputedCid DBCid  `gorm:"column:computed_cid;type:varchar(256);"`
+	Size        int64  `gorm:"column:size;type:bigint;"`
+	Error       string `gorm:"column:error;type:text;"`
+	VerifiedAt  int64  `gorm:"column:verified_at;type:bigint;"`
+}
+
+func (v *pieceVerify) TableName() string {
+	return pieceVerifyTableName
+}
+
+func (v *pieceVerify) toResult() *types.PieceVerifyResult {
+	return &types.PieceVerifyResult{
+		PieceCID:    v.PieceCid.cid(),
+		State:       types.PieceVerifyState(v.State),
+		ComputedCID: v.ComputedCid.cid(),
+		Size:        v.Size,
+		Error:       v.Error,
+		VerifiedAt:  time.Unix(v.VerifiedAt, 0),
+	}
+}
+
+type pieceVerifyRepo struct {
+	*gorm.DB
+}
+
+var _ repo.IPieceVerifyRepo = (*pieceVerifyRepo)(nil)
+
+func NewPieceVerifyRepo(db *gorm.DB) *pieceVerifyRepo {
+	return &pieceVerifyRepo{db}
+}
+
+func (r *pieceVerifyRepo) SavePieceVerify(ctx context.Context, res *types.PieceVerifyResult) error {
+	return r.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "piece_cid"}},
+		UpdateAll: true,
+	}).Create(&pieceVerify{
+		PieceCid:    DBCid(res.PieceCID),
+		State:       string(res.State),
+		ComputedCid: DBCid(res.ComputedCID),
+		Size:        res.Size,
+		Error:       res.Error,
+		VerifiedAt:  res.VerifiedAt.Unix(),
+	}).Error
+}
+
+func (r *pieceVerifyRepo) GetPieceVerify(ctx context.Context, pieceCID cid.Cid) (*types.PieceVerifyResult, error) {
+	var row pieceVerify
+	if err := r.WithContext(ctx).Take(&row, "piece_cid = ?", pieceCID.String()).Error; err != nil {
+		return nil, err
+	}
+	return row.toResult(), nil
+}
+
+func (r *pieceVerifyRepo) ListPieceVerify(ctx context.Context) ([]*types.PieceVerifyResult, error) {
+	var rows []pieceVerify
+	if err := r.WithContext(ctx).Table(pieceVerifyTableName).Order("piece_cid").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	results := make([]*types.PieceVerifyResult, len(rows))
+	for index := range rows {
+		results[index] = rows[index].toResult()
+	}
+	return results, nil
+}
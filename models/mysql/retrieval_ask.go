@@ -9,12 +9,18 @@ import (
 	"github.com/filecoin-project/go-address"
 	fbig "github.com/filecoin-project/go-state-types/big"
 	"github.com/filecoin-project/venus-market/models/repo"
+	vmtypes "github.com/filecoin-project/venus-market/types"
 	"github.com/filecoin-project/venus-messager/models/mtypes"
+	"github.com/libp2p/go-libp2p-core/peer"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
-const retrievalAskTableName = "retrieval_asks"
+const (
+	retrievalAskTableName          = "retrieval_asks"
+	retrievalAskRuleTableName      = "retrieval_ask_rules"
+	retrievalClientWalletTableName = "retrieval_client_wallets"
+)
 
 type retrievalAskRepo struct {
 	*gorm.DB
@@ -86,3 +92,102 @@ func (rar *retrievalAskRepo) ListAsk(ctx context.Context) ([]*types.RetrievalAsk
 	}
 	return results, nil
 }
+
+type retrievalAskRule struct {
+	ID                      uint       `gorm:"primary_key"`
+	Miner                   DBAddress  `gorm:"column:miner;type:varchar(256);uniqueIndex:idx_miner_kind_value"`
+	Kind                    string     `gorm:"column:kind;type:varchar(32);uniqueIndex:idx_miner_kind_value"`
+	Value                   string     `gorm:"column:value;type:varchar(256);uniqueIndex:idx_miner_kind_value"`
+	PricePerByte            mtypes.Int `gorm:"column:price_per_byte;type:varchar(256);"`
+	UnsealPrice             mtypes.Int `gorm:"column:unseal_price;type:varchar(256);"`
+	PaymentInterval         uint64     `gorm:"column:payment_interval;type:bigint unsigned;"`
+	PaymentIntervalIncrease uint64     `gorm:"column:payment_interval_increase;type:bigint unsigned;"`
+	TimeStampOrm
+}
+
+func (a *retrievalAskRule) TableName() string {
+	return retrievalAskRuleTableName
+}
+
+func (rar *retrievalAskRepo) SetAskRule(ctx context.Context, rule *vmtypes.RetrievalAskRule) error {
+	rule.UpdatedAt = time.Now()
+	return rar.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "miner"}, {Name: "kind"}, {Name: "value"}},
+		UpdateAll: true,
+	}).Save(&retrievalAskRule{
+		Miner:                   DBAddress(rule.Miner),
+		Kind:                    string(rule.Kind),
+		Value:                   rule.Value,
+		PricePerByte:            convertBigInt(rule.PricePerByte),
+		UnsealPrice:             convertBigInt(rule.UnsealPrice),
+		PaymentInterval:         rule.PaymentInterval,
+		PaymentIntervalIncrease: rule.PaymentIntervalIncrease,
+		TimeStampOrm:            TimeStampOrm{UpdatedAt: uint64(rule.UpdatedAt.Unix())},
+	}).Error
+}
+
+func (rar *retrievalAskRepo) RemoveAskRule(ctx context.Context, miner address.Address, kind vmtypes.RetrievalAskRuleKind, value string) error {
+	res := rar.WithContext(ctx).Where("miner = ? and kind = ? and value = ?", DBAddress(miner).String(), string(kind), value).
+		Delete(&retrievalAskRule{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return repo.ErrNotFound
+	}
+	return nil
+}
+
+func (rar *retrievalAskRepo) ListAskRules(ctx context.Context, miner address.Address) ([]*vmtypes.RetrievalAskRule, error) {
+	query := rar.WithContext(ctx).Table(retrievalAskRuleTableName)
+	if miner != address.Undef {
+		query = query.Where("miner = ?", DBAddress(miner).String())
+	}
+	var dbRules []retrievalAskRule
+	if err := query.Find(&dbRules).Error; err != nil {
+		return nil, err
+	}
+	results := make([]*vmtypes.RetrievalAskRule, len(dbRules))
+	for index, rule := range dbRules {
+		results[index] = &vmtypes.RetrievalAskRule{
+			Miner:                   rule.Miner.addr(),
+			Kind:                    vmtypes.RetrievalAskRuleKind(rule.Kind),
+			Value:                   rule.Value,
+			PricePerByte:            fbig.Int{Int: rule.PricePerByte.Int},
+			UnsealPrice:             fbig.Int{Int: rule.UnsealPrice.Int},
+			PaymentInterval:         rule.PaymentInterval,
+			PaymentIntervalIncrease: rule.PaymentIntervalIncrease,
+			UpdatedAt:               time.Unix(int64(rule.UpdatedAt), 0),
+		}
+	}
+	return results, nil
+}
+
+type retrievalClientWallet struct {
+	Client string    `gorm:"column:client;type:varchar(128);primary_key"`
+	Wallet DBAddress `gorm:"column:wallet;type:varchar(256);"`
+	TimeStampOrm
+}
+
+func (w *retrievalClientWallet) TableName() string {
+	return retrievalClientWalletTableName
+}
+
+func (rar *retrievalAskRepo) SetClientWallet(ctx context.Context, client peer.ID, wallet address.Address) error {
+	return rar.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "client"}},
+		UpdateAll: true,
+	}).Save(&retrievalClientWallet{
+		Client:       client.String(),
+		Wallet:       DBAddress(wallet),
+		TimeStampOrm: TimeStampOrm{UpdatedAt: uint64(time.Now().Unix())},
+	}).Error
+}
+
+func (rar *retrievalAskRepo) GetClientWallet(ctx context.Context, client peer.ID) (address.Address, error) {
+	var w retrievalClientWallet
+	if err := rar.WithContext(ctx).Take(&w, "client = ?", client.String()).Error; err != nil {
+		return address.Undef, err
+	}
+	return w.Wallet.addr(), nil
+}
@@ -8,13 +8,17 @@ import (
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/crypto"
+	"github.com/filecoin-project/venus-market/types"
 	"github.com/filecoin-project/venus-messager/models/mtypes"
 	"golang.org/x/xerrors"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
-const storageAskTableName = "storage_asks"
+const (
+	storageAskTableName        = "storage_asks"
+	storageAskHistoryTableName = "storage_ask_histories"
+)
 
 type storageAsk struct {
 	ID            uint       `gorm:"primary_key"`
@@ -124,3 +128,80 @@ func (sar *storageAskRepo) ListAsk(ctx context.Context) ([]*storagemarket.Signed
 	}
 	return results, nil
 }
+
+type storageAskHistory struct {
+	ID            uint       `gorm:"primary_key"`
+	Miner         DBAddress  `gorm:"column:miner;type:varchar(256);index"`
+	Price         mtypes.Int `gorm:"column:price;type:varchar(256);"`
+	VerifiedPrice mtypes.Int `gorm:"column:verified_price;type:varchar(256);"`
+	MinPieceSize  int64      `gorm:"column:min_piece_size;type:bigint;"`
+	MaxPieceSize  int64      `gorm:"column:max_piece_size;type:bigint;"`
+	Timestamp     int64      `gorm:"column:timestamp;type:bigint;"`
+	Expiry        int64      `gorm:"column:expiry;type:bigint;"`
+	SeqNo         uint64     `gorm:"column:seq_no;type:bigint unsigned;"`
+	Reason        string     `gorm:"column:reason;type:varchar(512);"`
+	CreatedAt     int64      `gorm:"column:created_at;type:bigint;"`
+}
+
+func (a *storageAskHistory) TableName() string {
+	return storageAskHistoryTableName
+}
+
+func fromStorageAskChange(src *types.StorageAskChange) *storageAskHistory {
+	return &storageAskHistory{
+		Miner:         DBAddress(src.Miner),
+		Price:         convertBigInt(src.Price),
+		VerifiedPrice: convertBigInt(src.VerifiedPrice),
+		MinPieceSize:  int64(src.MinPieceSize),
+		MaxPieceSize:  int64(src.MaxPieceSize),
+		Timestamp:     int64(src.Timestamp),
+		Expiry:        int64(src.Expiry),
+		SeqNo:         src.SeqNo,
+		Reason:        src.Reason,
+		CreatedAt:     src.CreatedAt.UnixNano(),
+	}
+}
+
+func toStorageAskChange(src *storageAskHistory) *types.StorageAskChange {
+	return &types.StorageAskChange{
+		Miner:         src.Miner.addr(),
+		Price:         abi.TokenAmount{Int: src.Price.Int},
+		VerifiedPrice: abi.TokenAmount{Int: src.VerifiedPrice.Int},
+		MinPieceSize:  abi.PaddedPieceSize(src.MinPieceSize),
+		MaxPieceSize:  abi.PaddedPieceSize(src.MaxPieceSize),
+		Timestamp:     abi.ChainEpoch(src.Timestamp),
+		Expiry:        abi.ChainEpoch(src.Expiry),
+		SeqNo:         src.SeqNo,
+		Reason:        src.Reason,
+		CreatedAt:     time.Unix(0, src.CreatedAt),
+	}
+}
+
+func (sar *storageAskRepo) AddAskHistory(ctx context.Context, change *types.StorageAskChange) error {
+	if change == nil {
+		return xerrors.Errorf("param is nil")
+	}
+	if change.CreatedAt.IsZero() {
+		change.CreatedAt = time.Now()
+	}
+	return sar.WithContext(ctx).Create(fromStorageAskChange(change)).Error
+}
+
+func (sar *storageAskRepo) ListAskHistory(ctx context.Context, miner address.Address, limit int) ([]*types.StorageAskChange, error) {
+	query := sar.WithContext(ctx).Table(storageAskHistoryTableName).
+		Where("miner = ?", DBAddress(miner).String()).
+		Order("created_at desc, id desc")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	var dbChanges []storageAskHistory
+	if err := query.Find(&dbChanges).Error; err != nil {
+		return nil, err
+	}
+	results := make([]*types.StorageAskChange, len(dbChanges))
+	for index := range dbChanges {
+		results[index] = toStorageAskChange(&dbChanges[index])
+	}
+	return results, nil
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/ipfs/go-cid"
 	typegen "github.com/whyrusleeping/cbor-gen"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 const storageDealTableName = "storage_deals"
@@ -68,7 +69,7 @@ type ClientDealProposal struct {
 	PieceCID     DBCid     `gorm:"column:piece_cid;type:varchar(256);index"`
 	PieceSize    uint64    `gorm:"column:piece_size;type:bigint unsigned;"`
 	VerifiedDeal bool      `gorm:"column:verified_deal;"`
-	Client       DBAddress `gorm:"column:client;type:varchar(256);"`
+	Client       DBAddress `gorm:"column:client;type:varchar(256);index"`
 	Provider     DBAddress `gorm:"column:provider;type:varchar(256);index"`
 
 	// Label is an arbitrary client chosen label to apply to the deal
@@ -376,6 +377,14 @@ func (sdr *storageDealRepo) ListDealByAddr(ctx context.Context, miner address.Ad
 	return fromDbDeals(storageDeals)
 }
 
+func (sdr *storageDealRepo) ListDealByClient(ctx context.Context, client address.Address) ([]*types.MinerDeal, error) {
+	var storageDeals []*storageDeal
+	if err := sdr.WithContext(ctx).Table(storageDealTableName).Find(&storageDeals, "cdp_client = ?", DBAddress(client).String()).Error; err != nil {
+		return nil, err
+	}
+	return fromDbDeals(storageDeals)
+}
+
 func (sdr *storageDealRepo) ListDeal(ctx context.Context) ([]*types.MinerDeal, error) {
 	var storageDeals []*storageDeal
 	if err := sdr.Table(storageDealTableName).Find(&storageDeals).Error; err != nil {
@@ -437,6 +446,22 @@ func (sdr *storageDealRepo) GetDealByDealID(ctx context.Context, mAddr address.A
 	return toStorageDeal(dbDeal)
 }
 
+func (sdr *storageDealRepo) CASPieceStatus(ctx context.Context, mAddr address.Address, dealID abi.DealID, from, to string) (bool, error) {
+	var dbDeal storageDeal
+	// the row stays locked until the transaction ends, a concurrent caller waits and then reads the new status
+	if err := sdr.WithContext(ctx).Table(storageDealTableName).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Take(&dbDeal, "cdp_provider = ? and deal_id = ?", DBAddress(mAddr).String(), dealID).Error; err != nil {
+		return false, err
+	}
+	if dbDeal.PieceStatus != from {
+		return false, nil
+	}
+	err := sdr.WithContext(ctx).Table(storageDealTableName).
+		Where("proposal_cid = ?", dbDeal.ProposalCid.String()).
+		UpdateColumns(map[string]interface{}{"piece_status": to, "updated_at": time.Now().Unix()}).Error
+	return err == nil, err
+}
+
 func (sdr *storageDealRepo) GetDealsByPieceStatus(ctx context.Context, mAddr address.Address, pieceStatus string) ([]*types.MinerDeal, error) {
 	var dbDeals []*storageDeal
 	if err := sdr.WithContext(ctx).Table(storageDealTableName).Find(&dbDeals, "cdp_provider = ? and piece_status = ?", DBAddress(mAddr).String(), pieceStatus).Error; err != nil {
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/crypto"
+	"github.com/filecoin-project/venus-market/models/badger"
+	"github.com/filecoin-project/venus-market/models/repo"
+	"github.com/filecoin-project/venus-market/types"
+	vtypes "github.com/filecoin-project/venus/venus-shared/types"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNonceLedger(t *testing.T) {
+	t.Run("mysql", func(t *testing.T) {
+		repo := MysqlDB(t)
+		defer func() { require.NoError(t, repo.Close()) }()
+		testNonceLedger(t, repo.NonceRepo())
+	})
+	t.Run("badger", func(t *testing.T) {
+		testNonceLedger(t, badger.NewNonceRepo(BadgerDB(t)))
+	})
+}
+
+func testNonceLedger(t *testing.T, nonceRepo repo.INonceRepo) {
+	ctx := context.Background()
+	addr := randAddress(t)
+
+	_, err := nonceRepo.GetNextNonce(ctx, addr)
+	require.ErrorIs(t, err, repo.ErrNotFound)
+
+	require.NoError(t, nonceRepo.SetNextNonce(ctx, addr, 3))
+	require.NoError(t, nonceRepo.SetNextNonce(ctx, addr, 5))
+	next, err := nonceRepo.GetNextNonce(ctx, addr)
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), next)
+
+	addrs, err := nonceRepo.ListNonceAddrs(ctx)
+	require.NoError(t, err)
+	require.Contains(t, addrs, addr)
+
+	now := time.Unix(time.Now().Unix(), 0)
+	for _, nonce := range []uint64{4, 2, 3} {
+		signed := &vtypes.SignedMessage{
+			Message: vtypes.Message{
+				From:       addr,
+				To:         addr,
+				Nonce:      nonce,
+				Value:      big.Zero(),
+				GasFeeCap:  big.NewInt(100),
+				GasPremium: big.NewInt(10),
+				GasLimit:   1000,
+			},
+			Signature: crypto.Signature{Type: crypto.SigTypeSecp256k1, Data: []byte{1, 2, 3}},
+		}
+		require.NoError(t, nonceRepo.SaveNonceMessage(ctx, &types.NonceMessage{
+			From:        addr,
+			Nonce:       nonce,
+			Cid:         signed.Cid(),
+			OriginCid:   signed.Cid(),
+			Message:     signed,
+			MaxFee:      big.NewInt(1000),
+			State:       types.MsgStatePending,
+			PushedEpoch: 100,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}))
+	}
+
+	msgs, err := nonceRepo.ListNonceMessages(ctx, addr)
+	require.NoError(t, err)
+	require.Len(t, msgs, 3)
+	for index, msg := range msgs {
+		require.Equal(t, uint64(index+2), msg.Nonce)
+		require.Equal(t, msg.Nonce, msg.Message.Message.Nonce)
+		require.Equal(t, msg.Message.Cid(), msg.Cid)
+		require.Equal(t, big.NewInt(1000), msg.MaxFee)
+		require.Equal(t, types.MsgStatePending, msg.State)
+		require.Equal(t, abi.ChainEpoch(100), msg.PushedEpoch)
+	}
+
+	all, err := nonceRepo.ListNonceMessages(ctx, address.Undef)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(all), 3)
+
+	// the messages are found by the cid they were pushed with and by the one they were replaced with
+	found, err := nonceRepo.GetNonceMessageByCid(ctx, msgs[1].OriginCid)
+	require.NoError(t, err)
+	require.Equal(t, msgs[1].Nonce, found.Nonce)
+	origin := msgs[1].OriginCid
+	msgs[1].Cid = randCid(t)
+	require.NoError(t, nonceRepo.SaveNonceMessage(ctx, msgs[1]))
+	for _, c := range []cid.Cid{origin, msgs[1].Cid} {
+		found, err = nonceRepo.GetNonceMessageByCid(ctx, c)
+		require.NoError(t, err)
+		require.Equal(t, msgs[1].Nonce, found.Nonce)
+	}
+	_, err = nonceRepo.GetNonceMessageByCid(ctx, randCid(t))
+	require.ErrorIs(t, err, repo.ErrNotFound)
+
+	// saving the same nonce again replaces the message
+	msgs[1].Filler = true
+	msgs[1].Repushed = 2
+	msgs[1].State = types.MsgStateOnChain
+	msgs[1].Height = 120
+	require.NoError(t, nonceRepo.SaveNonceMessage(ctx, msgs[1]))
+
+	require.NoError(t, nonceRepo.RemoveNonceMessages(ctx, addr, 3))
+	msgs, err = nonceRepo.ListNonceMessages(ctx, addr)
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	require.Equal(t, uint64(3), msgs[0].Nonce)
+	require.True(t, msgs[0].Filler)
+	require.Equal(t, 2, msgs[0].Repushed)
+	require.Equal(t, types.MsgStateOnChain, msgs[0].State)
+	require.Equal(t, abi.ChainEpoch(120), msgs[0].Height)
+	_, err = nonceRepo.GetNonceMessageByCid(ctx, msgs[0].OriginCid)
+	require.NoError(t, err)
+	_, err = nonceRepo.GetNonceMessageByCid(ctx, origin)
+	require.NoError(t, err)
+
+	msgs, err = nonceRepo.ListNonceMessages(ctx, randAddress(t))
+	require.NoError(t, err)
+	require.Len(t, msgs, 0)
+}
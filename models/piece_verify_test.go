@@ -0,0 +1,79 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/venus-market/models/badger"
+	"github.com/filecoin-project/venus-market/models/repo"
+	"github.com/filecoin-project/venus-market/types"
+)
+
+func TestPieceVerify(t *testing.T) {
+	t.Run("mysql", func(t *testing.T) {
+		repo := MysqlDB(t)
+		defer func() { require.NoError(t, repo.Close()) }()
+		testPieceVerify(t, repo.PieceVerifyRepo())
+	})
+	t.Run("badger", func(t *testing.T) {
+		testPieceVerify(t, badger.NewPieceVerifyRepo(BadgerDB(t)))
+	})
+}
+
+func testPieceVerify(t *testing.T, verifyRepo repo.IPieceVerifyRepo) {
+	ctx := context.Background()
+	piece1, piece2 := randCid(t), randCid(t)
+
+	_, err := verifyRepo.GetPieceVerify(ctx, piece1)
+	require.ErrorIs(t, err, repo.ErrNotFound)
+
+	missing := &types.PieceVerifyResult{
+		PieceCID:   piece1,
+		State:      types.PieceVerifyMissing,
+		Error:      "not found",
+		VerifiedAt: time.Now().Add(-time.Hour),
+	}
+	require.NoError(t, verifyRepo.SavePieceVerify(ctx, missing))
+	require.NoError(t, verifyRepo.SavePieceVerify(ctx, &types.PieceVerifyResult{
+		PieceCID:    piece2,
+		State:       types.PieceVerifyOK,
+		ComputedCID: piece2,
+		Size:        2032,
+		VerifiedAt:  time.Now(),
+	}))
+
+	res, err := verifyRepo.GetPieceVerify(ctx, piece1)
+	require.NoError(t, err)
+	require.Equal(t, types.PieceVerifyMissing, res.State)
+	require.Equal(t, cid.Undef, res.ComputedCID)
+	require.Equal(t, "not found", res.Error)
+	require.Equal(t, missing.VerifiedAt.Unix(), res.VerifiedAt.Unix())
+
+	// saving again replaces the previous result
+	require.NoError(t, verifyRepo.SavePieceVerify(ctx, &types.PieceVerifyResult{
+		PieceCID:    piece1,
+		State:       types.PieceVerifyMismatch,
+		ComputedCID: piece2,
+		Size:        1016,
+		VerifiedAt:  time.Now(),
+	}))
+	res, err = verifyRepo.GetPieceVerify(ctx, piece1)
+	require.NoError(t, err)
+	require.Equal(t, types.PieceVerifyMismatch, res.State)
+	require.Equal(t, piece2, res.ComputedCID)
+	require.Equal(t, int64(1016), res.Size)
+	require.Empty(t, res.Error)
+
+	results, err := verifyRepo.ListPieceVerify(ctx)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	states := make(map[cid.Cid]types.PieceVerifyState)
+	for _, res := range results {
+		states[res.PieceCID] = res.State
+	}
+	require.Equal(t, map[cid.Cid]types.PieceVerifyState{piece1: types.PieceVerifyMismatch, piece2: types.PieceVerifyOK}, states)
+}
@@ -2,6 +2,7 @@ package repo
 
 import (
 	"context"
+	"time"
 
 	"github.com/filecoin-project/go-address"
 	datatransfer "github.com/filecoin-project/go-data-transfer"
@@ -10,9 +11,11 @@ import (
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
 	"github.com/filecoin-project/go-state-types/abi"
 	fbig "github.com/filecoin-project/go-state-types/big"
+	mtypes "github.com/filecoin-project/venus-market/types"
 	types "github.com/filecoin-project/venus/venus-shared/types/market"
 	"github.com/ipfs/go-cid"
 	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/multiformats/go-multihash"
 	"golang.org/x/xerrors"
 )
 
@@ -31,7 +34,12 @@ type StorageDealRepo interface {
 	GetDeals(ctx context.Context, mAddr address.Address, pageIndex, pageSize int) ([]*types.MinerDeal, error)
 	GetDealsByPieceStatus(ctx context.Context, mAddr address.Address, pieceStatus string) ([]*types.MinerDeal, error)
 	GetDealByDealID(ctx context.Context, mAddr address.Address, dealID abi.DealID) (*types.MinerDeal, error)
+	// CASPieceStatus sets the piece status of the deal to `to` only when it is `from` and reports whether it was set.
+	// It is atomic against the other transactions when called inside Repo.Transaction
+	CASPieceStatus(ctx context.Context, mAddr address.Address, dealID abi.DealID, from, to string) (bool, error)
 	ListDealByAddr(ctx context.Context, mAddr address.Address) ([]*types.MinerDeal, error)
+	// ListDealByClient lists the deals proposed by the client address
+	ListDealByClient(ctx context.Context, client address.Address) ([]*types.MinerDeal, error)
 	ListDeal(ctx context.Context) ([]*types.MinerDeal, error)
 	GetPieceInfo(ctx context.Context, pieceCID cid.Cid) (*piecestore.PieceInfo, error)
 	GetPieceSize(ctx context.Context, pieceCID cid.Cid) (abi.UnpaddedPieceSize, abi.PaddedPieceSize, error)
@@ -68,12 +76,37 @@ type IStorageAskRepo interface {
 	ListAsk(ctx context.Context) ([]*storagemarket.SignedStorageAsk, error)
 	GetAsk(ctx context.Context, miner address.Address) (*storagemarket.SignedStorageAsk, error)
 	SetAsk(ctx context.Context, ask *storagemarket.SignedStorageAsk) error
+	AddAskHistory(ctx context.Context, change *mtypes.StorageAskChange) error
+	// ListAskHistory returns the latest changes of the ask of the miner first, limit <= 0 means all of them
+	ListAskHistory(ctx context.Context, miner address.Address, limit int) ([]*mtypes.StorageAskChange, error)
 }
 
 type IRetrievalAskRepo interface {
 	ListAsk(ctx context.Context) ([]*types.RetrievalAsk, error)
 	GetAsk(ctx context.Context, addr address.Address) (*types.RetrievalAsk, error)
 	SetAsk(ctx context.Context, ask *types.RetrievalAsk) error
+	SetAskRule(ctx context.Context, rule *mtypes.RetrievalAskRule) error
+	RemoveAskRule(ctx context.Context, miner address.Address, kind mtypes.RetrievalAskRuleKind, value string) error
+	// ListAskRules lists the rules of the miner, or of all miners when miner is undef
+	ListAskRules(ctx context.Context, miner address.Address) ([]*mtypes.RetrievalAskRule, error)
+	// SetClientWallet records the wallet the retrieval client last paid from, the client rules on an address match it
+	SetClientWallet(ctx context.Context, client peer.ID, wallet address.Address) error
+	GetClientWallet(ctx context.Context, client peer.ID) (address.Address, error)
+}
+
+// INonceRepo is the ledger of the nonces assigned locally to the messages pushed without venus-messager
+type INonceRepo interface {
+	// GetNextNonce returns the next nonce to assign to a message of addr
+	GetNextNonce(ctx context.Context, addr address.Address) (uint64, error)
+	SetNextNonce(ctx context.Context, addr address.Address, nonce uint64) error
+	ListNonceAddrs(ctx context.Context) ([]address.Address, error)
+	SaveNonceMessage(ctx context.Context, msg *mtypes.NonceMessage) error
+	// ListNonceMessages returns the messages of addr ordered by nonce, an undefined addr lists the messages of all addresses
+	ListNonceMessages(ctx context.Context, addr address.Address) ([]*mtypes.NonceMessage, error)
+	// GetNonceMessageByCid returns the message pushed first or last with the cid, ErrNotFound when none is
+	GetNonceMessageByCid(ctx context.Context, c cid.Cid) (*mtypes.NonceMessage, error)
+	// RemoveNonceMessages removes the messages of addr with a nonce lower than the given one
+	RemoveNonceMessages(ctx context.Context, addr address.Address, belowNonce uint64) error
 }
 
 type ICidInfoRepo interface {
@@ -89,6 +122,51 @@ type ICidInfoRepo interface {
 	// GetPieceInfoFromCid(ctx context.Context, payloadCID, pieceCID cid.Cid) (piecestore.PieceInfo, bool, error)
 }
 
+// IBlockIndexRepo maps every block inside a piece to the piece, so that any block, not only the payload root, can be retrieved
+type IBlockIndexRepo interface {
+	// AddBlockIndex records the blocks of a piece, adding the same piece again is a no-op for the known blocks
+	AddBlockIndex(ctx context.Context, pieceCID cid.Cid, blocks []multihash.Multihash) error
+	// GetPiecesByBlock returns the pieces containing the block, ErrNotFound when no piece does
+	GetPiecesByBlock(ctx context.Context, block multihash.Multihash) ([]cid.Cid, error)
+	HasBlockIndex(ctx context.Context, pieceCID cid.Cid) (bool, error)
+	RemoveBlockIndex(ctx context.Context, pieceCID cid.Cid) error
+}
+
+// IPieceVerifyRepo keeps the result of the last integrity check of every piece file
+type IPieceVerifyRepo interface {
+	// SavePieceVerify replaces the previous result of the piece
+	SavePieceVerify(ctx context.Context, res *mtypes.PieceVerifyResult) error
+	// GetPieceVerify returns ErrNotFound when the piece was never verified
+	GetPieceVerify(ctx context.Context, pieceCID cid.Cid) (*mtypes.PieceVerifyResult, error)
+	ListPieceVerify(ctx context.Context) ([]*mtypes.PieceVerifyResult, error)
+}
+
+// IDealLeaseRepo keeps the leases of the deals assigned to sealers
+type IDealLeaseRepo interface {
+	SaveDealLease(ctx context.Context, lease *mtypes.DealLease) error
+	// GetDealLease returns ErrNotFound when the deal is not leased
+	GetDealLease(ctx context.Context, miner address.Address, dealID abi.DealID) (*mtypes.DealLease, error)
+	// RemoveDealLease removes the lease of the deal, removing a lease that does not exist is not an error
+	RemoveDealLease(ctx context.Context, miner address.Address, dealID abi.DealID) error
+	// RenewDealLease sets the expiry of the lease when owner holds it and reports whether it does.
+	// It is atomic against the other transactions when called inside Repo.Transaction
+	RenewDealLease(ctx context.Context, miner address.Address, dealID abi.DealID, owner string, expiry time.Time) (bool, error)
+	// RemoveExpiredDealLease removes the lease held by owner only when it expired before now and reports whether it
+	// did, a lease renewed or taken meanwhile is kept. It is atomic against the other transactions when called inside
+	// Repo.Transaction
+	RemoveExpiredDealLease(ctx context.Context, miner address.Address, dealID abi.DealID, owner string, now time.Time) (bool, error)
+	// ListDealLeases lists the leases of the miner ordered by deal id, or of all miners when miner is undef
+	ListDealLeases(ctx context.Context, miner address.Address) ([]*mtypes.DealLease, error)
+}
+
+// IDealFailureRepo keeps when the piece gc first saw each failed deal, the deals do not keep when they failed
+type IDealFailureRepo interface {
+	// SaveDealFailure keeps the first time the deal was seen failed, saving it again does not move it
+	SaveDealFailure(ctx context.Context, proposalCid cid.Cid, failedAt time.Time) error
+	ListDealFailures(ctx context.Context) (map[cid.Cid]time.Time, error)
+	RemoveDealFailure(ctx context.Context, proposalCid cid.Cid) error
+}
+
 type Repo interface {
 	FundRepo() FundRepo
 	StorageDealRepo() StorageDealRepo
@@ -98,6 +176,11 @@ type Repo interface {
 	RetrievalAskRepo() IRetrievalAskRepo
 	CidInfoRepo() ICidInfoRepo
 	RetrievalDealRepo() IRetrievalDealRepo
+	NonceRepo() INonceRepo
+	BlockIndexRepo() IBlockIndexRepo
+	PieceVerifyRepo() IPieceVerifyRepo
+	DealLeaseRepo() IDealLeaseRepo
+	DealFailureRepo() IDealFailureRepo
 	Close() error
 	Migrate() error
 	Transaction(func(txRepo TxRepo) error) error
@@ -105,6 +188,7 @@ type Repo interface {
 
 type TxRepo interface {
 	StorageDealRepo() StorageDealRepo
+	DealLeaseRepo() IDealLeaseRepo
 }
 
 var ErrNotFound = xerrors.New("record not found")
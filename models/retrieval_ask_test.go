@@ -6,9 +6,12 @@ import (
 
 	types "github.com/filecoin-project/venus/venus-shared/types/market"
 
+	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/venus-market/models/badger"
 	"github.com/filecoin-project/venus-market/models/repo"
+	mtypes "github.com/filecoin-project/venus-market/types"
+	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -20,11 +23,15 @@ func TestRetrievalAsk(t *testing.T) {
 		retrievalAskRepo := repo.RetrievalAskRepo()
 		defer func() { require.NoError(t, repo.Close()) }()
 		testRetrievalAsk(t, retrievalAskRepo)
+		testRetrievalAskRules(t, retrievalAskRepo)
+		testRetrievalClientWallet(t, retrievalAskRepo)
 	})
 
 	t.Run("badger", func(t *testing.T) {
-		db := BadgerDB(t)
-		testRetrievalAsk(t, badger.NewRetrievalAskRepo(db))
+		retrievalAskRepo := badger.NewRetrievalAskRepo(BadgerDB(t), BadgerDB(t), BadgerDB(t))
+		testRetrievalAsk(t, retrievalAskRepo)
+		testRetrievalAskRules(t, retrievalAskRepo)
+		testRetrievalClientWallet(t, retrievalAskRepo)
 	})
 }
 
@@ -57,3 +64,69 @@ func testRetrievalAsk(t *testing.T, rtAskRepo repo.IRetrievalAskRepo) {
 	assert.Nil(t, err)
 	assert.Equal(t, ask, ask2)
 }
+
+func testRetrievalAskRules(t *testing.T, rtAskRepo repo.IRetrievalAskRepo) {
+	ctx := context.Background()
+	miner := randAddress(t)
+	other := randAddress(t)
+
+	newRule := func(miner address.Address, kind mtypes.RetrievalAskRuleKind, value string, price int64) *mtypes.RetrievalAskRule {
+		return &mtypes.RetrievalAskRule{
+			Miner:                   miner,
+			Kind:                    kind,
+			Value:                   value,
+			PricePerByte:            abi.NewTokenAmount(price),
+			UnsealPrice:             abi.NewTokenAmount(0),
+			PaymentInterval:         1 << 20,
+			PaymentIntervalIncrease: 1 << 20,
+		}
+	}
+
+	piece := randCid(t).String()
+	require.NoError(t, rtAskRepo.SetAskRule(ctx, newRule(miner, mtypes.RetrievalAskRulePiece, piece, 10)))
+	require.NoError(t, rtAskRepo.SetAskRule(ctx, newRule(miner, mtypes.RetrievalAskRuleLabel, "premium/*", 20)))
+	require.NoError(t, rtAskRepo.SetAskRule(ctx, newRule(other, mtypes.RetrievalAskRulePiece, piece, 30)))
+
+	// setting the same kind and value again replaces the rule
+	require.NoError(t, rtAskRepo.SetAskRule(ctx, newRule(miner, mtypes.RetrievalAskRulePiece, piece, 40)))
+
+	rules, err := rtAskRepo.ListAskRules(ctx, miner)
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	prices := map[mtypes.RetrievalAskRuleKind]abi.TokenAmount{}
+	for _, rule := range rules {
+		require.Equal(t, miner, rule.Miner)
+		prices[rule.Kind] = rule.PricePerByte
+	}
+	require.Equal(t, abi.NewTokenAmount(40), prices[mtypes.RetrievalAskRulePiece])
+	require.Equal(t, abi.NewTokenAmount(20), prices[mtypes.RetrievalAskRuleLabel])
+
+	require.NoError(t, rtAskRepo.RemoveAskRule(ctx, miner, mtypes.RetrievalAskRuleLabel, "premium/*"))
+	require.ErrorIs(t, rtAskRepo.RemoveAskRule(ctx, miner, mtypes.RetrievalAskRuleLabel, "premium/*"), repo.ErrNotFound)
+
+	rules, err = rtAskRepo.ListAskRules(ctx, miner)
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+
+	rules, err = rtAskRepo.ListAskRules(ctx, other)
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	require.Equal(t, abi.NewTokenAmount(30), rules[0].PricePerByte)
+}
+
+func testRetrievalClientWallet(t *testing.T, rtAskRepo repo.IRetrievalAskRepo) {
+	ctx := context.Background()
+	client, err := peer.Decode("12D3KooWG8tR9PHjjXcMknbNPVWT75BuXXA2RaYx3fMwwg2oPZXd")
+	require.NoError(t, err)
+
+	_, err = rtAskRepo.GetClientWallet(ctx, client)
+	require.ErrorIs(t, err, repo.ErrNotFound)
+
+	// the wallet paid from last is kept
+	for _, wallet := range []address.Address{randAddress(t), randAddress(t)} {
+		require.NoError(t, rtAskRepo.SetClientWallet(ctx, client, wallet))
+		got, err := rtAskRepo.GetClientWallet(ctx, client)
+		require.NoError(t, err)
+		require.Equal(t, wallet, got)
+	}
+}
@@ -3,12 +3,14 @@ package models
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/crypto"
 	"github.com/filecoin-project/venus-market/models/badger"
 	"github.com/filecoin-project/venus-market/models/repo"
+	"github.com/filecoin-project/venus-market/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -19,10 +21,12 @@ func TestStorageAsk(t *testing.T) {
 		askRepo := repo.StorageAskRepo()
 		defer func() { require.NoError(t, repo.Close()) }()
 		testStorageAsk(t, askRepo)
+		testStorageAskHistory(t, askRepo)
 	})
 	t.Run("badger", func(t *testing.T) {
-		db := BadgerDB(t)
-		testStorageAsk(t, repo.IStorageAskRepo(badger.NewStorageAskRepo(db)))
+		askRepo := repo.IStorageAskRepo(badger.NewStorageAskRepo(BadgerDB(t), BadgerDB(t)))
+		testStorageAsk(t, askRepo)
+		testStorageAskHistory(t, askRepo)
 	})
 }
 
@@ -69,3 +73,50 @@ func testStorageAsk(t *testing.T, askRepo repo.IStorageAskRepo) {
 	assert.Nil(t, err)
 	assert.Equal(t, res2, ask2)
 }
+
+func testStorageAskHistory(t *testing.T, askRepo repo.IStorageAskRepo) {
+	ctx := context.Background()
+	miner := randAddress(t)
+	other := randAddress(t)
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, askRepo.AddAskHistory(ctx, &types.StorageAskChange{
+			Miner:         miner,
+			Price:         abi.NewTokenAmount(int64(10 * (i + 1))),
+			VerifiedPrice: abi.NewTokenAmount(1),
+			MinPieceSize:  256,
+			MaxPieceSize:  1024,
+			Timestamp:     abi.ChainEpoch(i),
+			Expiry:        abi.ChainEpoch(100 + i),
+			SeqNo:         uint64(i),
+			Reason:        types.StorageAskReasonManual,
+			CreatedAt:     now.Add(time.Duration(i) * time.Second),
+		}))
+	}
+	require.NoError(t, askRepo.AddAskHistory(ctx, &types.StorageAskChange{
+		Miner:         other,
+		Price:         abi.NewTokenAmount(1),
+		VerifiedPrice: abi.NewTokenAmount(1),
+		Reason:        types.StorageAskReasonResign,
+	}))
+
+	changes, err := askRepo.ListAskHistory(ctx, miner, 0)
+	require.NoError(t, err)
+	require.Len(t, changes, 3)
+	for i, change := range changes {
+		require.Equal(t, miner, change.Miner)
+		require.Equal(t, uint64(2-i), change.SeqNo)
+	}
+	require.Equal(t, abi.NewTokenAmount(30), changes[0].Price)
+
+	changes, err = askRepo.ListAskHistory(ctx, miner, 2)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	require.Equal(t, uint64(2), changes[0].SeqNo)
+
+	changes, err = askRepo.ListAskHistory(ctx, other, 0)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	require.Equal(t, types.StorageAskReasonResign, changes[0].Reason)
+}
@@ -150,6 +150,25 @@ func testStorageDeal(t *testing.T, dealRepo repo.StorageDealRepo) {
 	assert.Nil(t, err)
 	assert.Equal(t, 2, len(list))
 
+	list, err = dealRepo.ListDealByClient(ctx, deal.Proposal.Client)
+	require.NoError(t, err)
+	assert.Len(t, list, 2)
+	list, err = dealRepo.ListDealByClient(ctx, randAddress(t))
+	require.NoError(t, err)
+	assert.Len(t, list, 0)
+
+	deal.PieceStatus = types.Undefine
+	require.NoError(t, dealRepo.SaveDeal(ctx, deal))
+	set, err := dealRepo.CASPieceStatus(ctx, deal.Proposal.Provider, deal.DealID, types.Undefine, types.Assigned)
+	require.NoError(t, err)
+	assert.True(t, set)
+	set, err = dealRepo.CASPieceStatus(ctx, deal.Proposal.Provider, deal.DealID, types.Undefine, types.Assigned)
+	require.NoError(t, err)
+	assert.False(t, set)
+	res, err = dealRepo.GetDeal(ctx, deal.ProposalCid)
+	require.NoError(t, err)
+	assert.Equal(t, types.Assigned, res.PieceStatus)
+
 	_, err = dealRepo.GetDeal(ctx, randCid(t))
 	require.Error(t, err, "recode shouldn't be found")
 
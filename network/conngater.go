@@ -0,0 +1,271 @@
+package network
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+
+	"github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	"github.com/libp2p/go-libp2p-core/connmgr"
+	"github.com/libp2p/go-libp2p-core/control"
+	libp2pnet "github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/multiformats/go-base32"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/venus-market/config"
+	"github.com/filecoin-project/venus-market/models/badger"
+	"github.com/filecoin-project/venus-market/types"
+)
+
+const (
+	connGaterPeerPrefix   = "/peer"
+	connGaterSubnetPrefix = "/subnet"
+)
+
+// ConnGater refuses the connections of the denied peers and ip ranges. The ones of the config cannot be unblocked,
+// the ones blocked at runtime are kept in the datastore across restarts
+type ConnGater struct {
+	ds datastore.Datastore
+
+	lk            sync.RWMutex
+	configPeers   map[peer.ID]struct{}
+	configSubnets map[string]*net.IPNet
+	peers         map[peer.ID]struct{}
+	subnets       map[string]*net.IPNet
+}
+
+var _ connmgr.ConnectionGater = (*ConnGater)(nil)
+
+func NewConnGater(ds badger.ConnGaterDS, cfg *config.Libp2p) (*ConnGater, error) {
+	g := &ConnGater{
+		ds:            ds,
+		configPeers:   make(map[peer.ID]struct{}),
+		configSubnets: make(map[string]*net.IPNet),
+		peers:         make(map[peer.ID]struct{}),
+		subnets:       make(map[string]*net.IPNet),
+	}
+	for _, s := range cfg.DenyPeers {
+		p, err := peer.Decode(s)
+		if err != nil {
+			return nil, xerrors.Errorf("invalid denied peer %s: %w", s, err)
+		}
+		g.configPeers[p] = struct{}{}
+	}
+	for _, s := range cfg.DenyAddresses {
+		subnet, err := ParseSubnet(s)
+		if err != nil {
+			return nil, xerrors.Errorf("invalid denied address %s: %w", s, err)
+		}
+		g.configSubnets[subnet.String()] = subnet
+	}
+
+	ctx := context.TODO()
+	if err := g.load(ctx, connGaterPeerPrefix, func(value []byte) error {
+		p, err := peer.IDFromBytes(value)
+		if err != nil {
+			return err
+		}
+		g.peers[p] = struct{}{}
+		return nil
+	}); err != nil {
+		return nil, xerrors.Errorf("load blocked peers: %w", err)
+	}
+	if err := g.load(ctx, connGaterSubnetPrefix, func(value []byte) error {
+		subnet, err := ParseSubnet(string(value))
+		if err != nil {
+			return err
+		}
+		g.subnets[subnet.String()] = subnet
+		return nil
+	}); err != nil {
+		return nil, xerrors.Errorf("load blocked subnets: %w", err)
+	}
+	return g, nil
+}
+
+func (g *ConnGater) load(ctx context.Context, prefix string, add func(value []byte) error) error {
+	res, err := g.ds.Query(ctx, dsq.Query{Prefix: prefix})
+	if err != nil {
+		return err
+	}
+	defer res.Close() //nolint:errcheck
+
+	for e := range res.Next() {
+		if e.Error != nil {
+			return e.Error
+		}
+		if err := add(e.Value); err != nil {
+			return xerrors.Errorf("parse %s: %w", e.Key, err)
+		}
+	}
+	return nil
+}
+
+// ParseSubnet parses an ip range in cidr notation, a single ip is a range of one ip
+func ParseSubnet(s string) (*net.IPNet, error) {
+	if ip := net.ParseIP(s); ip != nil {
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, subnet, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, err
+	}
+	return subnet, nil
+}
+
+func connGaterPeerKey(p peer.ID) datastore.Key {
+	return datastore.NewKey(connGaterPeerPrefix).ChildString(p.String())
+}
+
+func connGaterSubnetKey(subnet *net.IPNet) datastore.Key {
+	return datastore.NewKey(connGaterSubnetPrefix).ChildString(base32.RawStdEncoding.EncodeToString([]byte(subnet.String())))
+}
+
+func (g *ConnGater) BlockPeers(ctx context.Context, peers []peer.ID) error {
+	g.lk.Lock()
+	defer g.lk.Unlock()
+	for _, p := range peers {
+		if err := g.ds.Put(ctx, connGaterPeerKey(p), []byte(p)); err != nil {
+			return xerrors.Errorf("save blocked peer %s: %w", p, err)
+		}
+		g.peers[p] = struct{}{}
+	}
+	return nil
+}
+
+func (g *ConnGater) UnblockPeers(ctx context.Context, peers []peer.ID) error {
+	g.lk.Lock()
+	defer g.lk.Unlock()
+	for _, p := range peers {
+		if _, ok := g.configPeers[p]; ok {
+			return xerrors.Errorf("peer %s is denied by the config", p)
+		}
+		if err := g.ds.Delete(ctx, connGaterPeerKey(p)); err != nil {
+			return xerrors.Errorf("remove blocked peer %s: %w", p, err)
+		}
+		delete(g.peers, p)
+	}
+	return nil
+}
+
+func (g *ConnGater) BlockSubnets(ctx context.Context, subnets []*net.IPNet) error {
+	g.lk.Lock()
+	defer g.lk.Unlock()
+	for _, subnet := range subnets {
+		if err := g.ds.Put(ctx, connGaterSubnetKey(subnet), []byte(subnet.String())); err != nil {
+			return xerrors.Errorf("save blocked subnet %s: %w", subnet, err)
+		}
+		g.subnets[subnet.String()] = subnet
+	}
+	return nil
+}
+
+func (g *ConnGater) UnblockSubnets(ctx context.Context, subnets []*net.IPNet) error {
+	g.lk.Lock()
+	defer g.lk.Unlock()
+	for _, subnet := range subnets {
+		if _, ok := g.configSubnets[subnet.String()]; ok {
+			return xerrors.Errorf("subnet %s is denied by the config", subnet)
+		}
+		if err := g.ds.Delete(ctx, connGaterSubnetKey(subnet)); err != nil {
+			return xerrors.Errorf("remove blocked subnet %s: %w", subnet, err)
+		}
+		delete(g.subnets, subnet.String())
+	}
+	return nil
+}
+
+func (g *ConnGater) BlockList() *types.NetBlockList {
+	g.lk.RLock()
+	defer g.lk.RUnlock()
+
+	list := &types.NetBlockList{}
+	for p := range g.peers {
+		list.Peers = append(list.Peers, p)
+	}
+	for p := range g.configPeers {
+		list.ConfigPeers = append(list.ConfigPeers, p)
+	}
+	for s := range g.subnets {
+		list.Subnets = append(list.Subnets, s)
+	}
+	for s := range g.configSubnets {
+		list.ConfigSubnets = append(list.ConfigSubnets, s)
+	}
+	sortPeers := func(peers []peer.ID) {
+		sort.Slice(peers, func(i, j int) bool { return peers[i] < peers[j] })
+	}
+	sortPeers(list.Peers)
+	sortPeers(list.ConfigPeers)
+	sort.Strings(list.Subnets)
+	sort.Strings(list.ConfigSubnets)
+	return list
+}
+
+// DisconnectBlocked closes the connections to the blocked peers and ip ranges
+func (g *ConnGater) DisconnectBlocked(n libp2pnet.Network) {
+	for _, conn := range n.Conns() {
+		if g.peerBlocked(conn.RemotePeer()) || g.addrBlocked(conn.RemoteMultiaddr()) {
+			if err := conn.Close(); err != nil {
+				log.Warnf("close connection to blocked peer %s: %v", conn.RemotePeer(), err)
+			}
+		}
+	}
+}
+
+func (g *ConnGater) peerBlocked(p peer.ID) bool {
+	g.lk.RLock()
+	defer g.lk.RUnlock()
+	_, blocked := g.peers[p]
+	if !blocked {
+		_, blocked = g.configPeers[p]
+	}
+	return blocked
+}
+
+func (g *ConnGater) addrBlocked(addr ma.Multiaddr) bool {
+	ip, err := manet.ToIP(addr)
+	if err != nil {
+		// not an ip address, eg. a relay address
+		return false
+	}
+	g.lk.RLock()
+	defer g.lk.RUnlock()
+	for _, subnets := range []map[string]*net.IPNet{g.subnets, g.configSubnets} {
+		for _, subnet := range subnets {
+			if subnet.Contains(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (g *ConnGater) InterceptPeerDial(p peer.ID) bool {
+	return !g.peerBlocked(p)
+}
+
+func (g *ConnGater) InterceptAddrDial(_ peer.ID, addr ma.Multiaddr) bool {
+	return !g.addrBlocked(addr)
+}
+
+func (g *ConnGater) InterceptAccept(addrs libp2pnet.ConnMultiaddrs) bool {
+	return !g.addrBlocked(addrs.RemoteMultiaddr())
+}
+
+func (g *ConnGater) InterceptSecured(_ libp2pnet.Direction, p peer.ID, addrs libp2pnet.ConnMultiaddrs) bool {
+	return !g.peerBlocked(p) && !g.addrBlocked(addrs.RemoteMultiaddr())
+}
+
+func (g *ConnGater) InterceptUpgraded(libp2pnet.Conn) (bool, control.DisconnectReason) {
+	return true, 0
+}
@@ -0,0 +1,122 @@
+package network
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	libp2pnet "github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	"github.com/libp2p/go-libp2p-core/test"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/venus-market/config"
+)
+
+func TestConnGater(t *testing.T) {
+	ctx := context.Background()
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	configPeer := test.RandPeerIDFatal(t)
+	blockedPeer := test.RandPeerIDFatal(t)
+	otherPeer := test.RandPeerIDFatal(t)
+
+	cfg := &config.Libp2p{
+		DenyPeers:     []string{configPeer.String()},
+		DenyAddresses: []string{"10.0.0.0/8"},
+	}
+	g, err := NewConnGater(ds, cfg)
+	require.NoError(t, err)
+
+	addr := func(s string) ma.Multiaddr {
+		a, err := ma.NewMultiaddr(s)
+		require.NoError(t, err)
+		return a
+	}
+	require.False(t, g.InterceptPeerDial(configPeer))
+	require.True(t, g.InterceptPeerDial(blockedPeer))
+	require.False(t, g.InterceptAddrDial(otherPeer, addr("/ip4/10.1.2.3/tcp/1234")))
+	require.True(t, g.InterceptAddrDial(otherPeer, addr("/ip4/192.168.1.1/tcp/1234")))
+	require.True(t, g.InterceptAddrDial(otherPeer, addr("/dns4/example.com/tcp/1234")))
+
+	single, err := ParseSubnet("192.168.1.1")
+	require.NoError(t, err)
+	require.NoError(t, g.BlockPeers(ctx, []peer.ID{blockedPeer}))
+	require.NoError(t, g.BlockSubnets(ctx, []*net.IPNet{single}))
+	require.False(t, g.InterceptPeerDial(blockedPeer))
+	require.False(t, g.InterceptAddrDial(otherPeer, addr("/ip4/192.168.1.1/tcp/1234")))
+	require.True(t, g.InterceptAddrDial(otherPeer, addr("/ip4/192.168.1.2/tcp/1234")))
+
+	// the configured ones cannot be unblocked
+	require.Error(t, g.UnblockPeers(ctx, []peer.ID{configPeer}))
+	configSubnet, err := ParseSubnet("10.0.0.0/8")
+	require.NoError(t, err)
+	require.Error(t, g.UnblockSubnets(ctx, []*net.IPNet{configSubnet}))
+
+	// the runtime ones are kept across restarts
+	g, err = NewConnGater(ds, cfg)
+	require.NoError(t, err)
+	list := g.BlockList()
+	require.Equal(t, []peer.ID{blockedPeer}, list.Peers)
+	require.Equal(t, []string{"192.168.1.1/32"}, list.Subnets)
+	require.Equal(t, []peer.ID{configPeer}, list.ConfigPeers)
+	require.Equal(t, []string{"10.0.0.0/8"}, list.ConfigSubnets)
+
+	require.NoError(t, g.UnblockPeers(ctx, []peer.ID{blockedPeer}))
+	require.NoError(t, g.UnblockSubnets(ctx, []*net.IPNet{single}))
+	require.True(t, g.InterceptPeerDial(blockedPeer))
+	require.True(t, g.InterceptAddrDial(otherPeer, addr("/ip4/192.168.1.1/tcp/1234")))
+
+	g, err = NewConnGater(ds, cfg)
+	require.NoError(t, err)
+	require.Empty(t, g.BlockList().Peers)
+	require.Empty(t, g.BlockList().Subnets)
+}
+
+func TestResourceManager(t *testing.T) {
+	p1 := test.RandPeerIDFatal(t)
+	p2 := test.RandPeerIDFatal(t)
+
+	rm, err := ResourceManager(&config.Libp2p{Limits: config.Libp2pLimits{
+		MaxStreamsPerPeer:     2,
+		MaxStreamsPerProtocol: 3,
+		ProtocolStreams:       map[string]int{"/ask": 1},
+	}})
+	require.NoError(t, err)
+	defer rm.Close() //nolint:errcheck
+
+	open := func(p peer.ID, proto protocol.ID) libp2pnet.StreamManagementScope {
+		s, err := rm.OpenStream(p, libp2pnet.DirInbound)
+		if err != nil {
+			return nil
+		}
+		if err := s.SetProtocol(proto); err != nil {
+			s.Done()
+			return nil
+		}
+		return s
+	}
+
+	deal1 := open(p1, "/deal")
+	require.NotNil(t, deal1)
+	require.NotNil(t, open(p1, "/deal"))
+	// the peer is at its limit
+	require.Nil(t, open(p1, "/deal"))
+	require.NotNil(t, open(p2, "/deal"))
+	// the protocol is at its limit
+	deal1.Done()
+	deal1 = open(p1, "/deal")
+	require.NotNil(t, deal1)
+	require.Nil(t, open(p2, "/deal"))
+
+	// the ask protocol has its own limit
+	ask := open(p2, "/ask")
+	require.NotNil(t, ask)
+	deal1.Done()
+	require.Nil(t, open(p1, "/ask"))
+	ask.Done()
+	require.NotNil(t, open(p1, "/ask"))
+}
@@ -3,12 +3,16 @@ package network
 import (
 	"context"
 	"fmt"
+	"github.com/filecoin-project/venus-market/config"
+	"github.com/filecoin-project/venus-market/types"
 	"github.com/filecoin-project/venus-market/version"
 	"github.com/ipfs-force-community/venus-common-utils/metrics"
 	"github.com/libp2p/go-libp2p"
 	"github.com/libp2p/go-libp2p-core/host"
+	libp2pnet "github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/libp2p/go-libp2p-core/peerstore"
+	basichost "github.com/libp2p/go-libp2p/p2p/host/basic"
 	"go.uber.org/fx"
 )
 
@@ -17,6 +21,7 @@ type P2PHostIn struct {
 
 	ID        peer.ID
 	Peerstore peerstore.Peerstore
+	Cfg       *config.Libp2p
 
 	Opts [][]libp2p.Option `group:"libp2p"`
 }
@@ -28,8 +33,14 @@ func Host(mctx metrics.MetricsCtx, lc fx.Lifecycle, params P2PHostIn) (host.Host
 		return nil, fmt.Errorf("missing private key for node ID: %s", params.ID.Pretty())
 	}
 
+	rm, err := ResourceManager(params.Cfg)
+	if err != nil {
+		return nil, fmt.Errorf("create resource manager: %w", err)
+	}
+
 	opts := []libp2p.Option{
 		libp2p.Identity(pkey),
+		libp2p.ResourceManager(rm),
 		libp2p.Peerstore(params.Peerstore),
 		libp2p.NoListenAddrs,
 		libp2p.Ping(true),
@@ -46,9 +57,32 @@ func Host(mctx metrics.MetricsCtx, lc fx.Lifecycle, params P2PHostIn) (host.Host
 
 	lc.Append(fx.Hook{
 		OnStop: func(ctx context.Context) error {
-			return h.Close()
+			if err := h.Close(); err != nil {
+				return err
+			}
+			return rm.Close()
 		},
 	})
 
 	return h, nil
 }
+
+// Reachability returns what autonat found about the host, unknown when autonat is not running
+func Reachability(h host.Host) *types.NetReachability {
+	info := &types.NetReachability{Reachability: libp2pnet.ReachabilityUnknown}
+	bh, ok := h.(*basichost.BasicHost)
+	if !ok {
+		return info
+	}
+	autonat := bh.GetAutoNat()
+	if autonat == nil {
+		return info
+	}
+	info.Reachability = autonat.Status()
+	if info.Reachability == libp2pnet.ReachabilityPublic {
+		if addr, err := autonat.PublicAddr(); err == nil {
+			info.PublicAddr = addr.String()
+		}
+	}
+	return info
+}
@@ -0,0 +1,43 @@
+package network
+
+import (
+	"math"
+
+	libp2pnet "github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	rcmgr "github.com/libp2p/go-libp2p-resource-manager"
+
+	"github.com/filecoin-project/venus-market/config"
+)
+
+// ResourceManager is the libp2p resource manager with the default limits, the inbound streams per peer and per
+// protocol are capped by the config on top of them. The resource manager resets a stream over a limit before it
+// reaches the handler of its protocol, and releases it when the stream is closed or reset
+func ResourceManager(cfg *config.Libp2p) (libp2pnet.ResourceManager, error) {
+	limiter := rcmgr.NewDefaultLimiter()
+	limits := cfg.Limits
+	if limits.MaxStreamsPerPeer > 0 {
+		limiter.DefaultPeerLimits = withInboundStreams(limiter.DefaultPeerLimits, limits.MaxStreamsPerPeer)
+	}
+	if limits.MaxStreamsPerProtocol > 0 {
+		limiter.DefaultProtocolLimits = withInboundStreams(limiter.DefaultProtocolLimits, limits.MaxStreamsPerProtocol)
+	}
+	if limiter.ProtocolLimits == nil {
+		limiter.ProtocolLimits = make(map[protocol.ID]rcmgr.Limit)
+	}
+	for proto, streams := range limits.ProtocolStreams {
+		limiter.ProtocolLimits[protocol.ID(proto)] = withInboundStreams(limiter.DefaultProtocolLimits, streams)
+	}
+	return rcmgr.NewResourceManager(limiter)
+}
+
+// withInboundStreams caps the inbound streams of the limit, 0 lifts the cap
+func withInboundStreams(limit rcmgr.Limit, streams int) rcmgr.Limit {
+	total := limit.GetStreamTotalLimit()
+	if streams <= 0 {
+		streams, total = math.MaxInt32, math.MaxInt32
+	} else if total < streams {
+		total = streams
+	}
+	return limit.WithStreamLimit(streams, limit.GetStreamLimit(libp2pnet.DirOutbound), total)
+}
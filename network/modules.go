@@ -4,6 +4,7 @@ import (
 	"github.com/ipfs-force-community/venus-common-utils/builder"
 	"github.com/libp2p/go-libp2p-core/crypto"
 	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/metrics"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/libp2p/go-libp2p-core/peerstore"
 )
@@ -16,6 +17,8 @@ var (
 	SmuxTransportKey     = builder.Special{ID: 4} // Libp2p option
 	RelayKey             = builder.Special{ID: 5} // Libp2p option
 	SecurityKey          = builder.Special{ID: 6} // Libp2p option
+	ConnectionManagerKey = builder.Special{ID: 7} // Libp2p option
+	ConnGaterKey         = builder.Special{ID: 8} // Libp2p option
 )
 
 // Invokes are called in the order they are defined.
@@ -26,6 +29,7 @@ var (
 
 var NetworkOpts = func(server bool, simultaneousTransfersForRetrieval, simultaneousTransfersForStoragePerClient, simultaneousTransfersForStorage uint64) builder.Option {
 	opts := builder.Options(
+		builder.Override(ConnectionManagerKey, ConnectionManager),
 		builder.Override(new(host.Host), Host),
 		//libp2p
 		builder.Override(new(crypto.PrivKey), PrivKey),
@@ -39,6 +43,9 @@ var NetworkOpts = func(server bool, simultaneousTransfersForRetrieval, simultane
 		builder.Override(SmuxTransportKey, SmuxTransport(true)),
 		builder.Override(RelayKey, NoRelay()),
 		builder.Override(SecurityKey, Security(true, false)),
+		builder.Override(new(*ConnGater), NewConnGater),
+		builder.Override(ConnGaterKey, ConnectionGater),
+		builder.Override(new(metrics.Reporter), BandwidthCounter),
 	)
 	if server {
 		return builder.Options(opts,
@@ -4,9 +4,12 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
+	"time"
+
 	"github.com/filecoin-project/venus-market/config"
 	logging "github.com/ipfs/go-log/v2"
 	"github.com/libp2p/go-libp2p"
+	connmgr "github.com/libp2p/go-libp2p-connmgr"
 	"github.com/libp2p/go-libp2p-core/crypto"
 	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/libp2p/go-libp2p-core/peer"
@@ -86,6 +89,28 @@ func NoRelay() func() (opts Libp2pOpts, err error) {
 	}
 }
 
+// ConnectionManager trims the connections over the high water mark, the configured peers are protected
+func ConnectionManager(cfg *config.Libp2p) (opts Libp2pOpts, err error) {
+	cm, err := connmgr.NewConnManager(int(cfg.ConnMgrLow), int(cfg.ConnMgrHigh), connmgr.WithGracePeriod(time.Duration(cfg.ConnMgrGrace)))
+	if err != nil {
+		return opts, err
+	}
+	for _, s := range cfg.ProtectedPeers {
+		p, err := peer.Decode(s)
+		if err != nil {
+			return opts, fmt.Errorf("failed to parse protected peer %s: %w", s, err)
+		}
+		cm.Protect(p, "config-prot")
+	}
+	opts.Opts = append(opts.Opts, libp2p.ConnectionManager(cm))
+	return
+}
+
+func ConnectionGater(gater *ConnGater) (opts Libp2pOpts, err error) {
+	opts.Opts = append(opts.Opts, libp2p.ConnectionGater(gater))
+	return
+}
+
 func makeAddrsFactory(announce []string, noAnnounce []string) (p2pbhost.AddrsFactory, error) {
 	var annAddrs []ma.Multiaddr
 	for _, addr := range announce {
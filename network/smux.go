@@ -4,18 +4,22 @@ import (
 	"os"
 	"strings"
 
+	"github.com/filecoin-project/venus-market/config"
 	"github.com/libp2p/go-libp2p"
 	smux "github.com/libp2p/go-libp2p-core/mux"
 	mplex "github.com/libp2p/go-libp2p-mplex"
 	yamux "github.com/libp2p/go-libp2p-yamux"
 )
 
-func makeSmuxTransportOption(mplexExp bool) libp2p.Option {
+func makeSmuxTransportOption(mplexExp bool, streamWindow uint32) libp2p.Option {
 	const yamuxID = "/yamux/1.0.0"
 	const mplexID = "/mplex/6.7.0"
 
 	ymxtpt := *yamux.DefaultTransport
 	ymxtpt.AcceptBacklog = 512
+	if streamWindow > 0 {
+		ymxtpt.MaxStreamWindowSize = streamWindow
+	}
 
 	if os.Getenv("YAMUX_DEBUG") != "" {
 		ymxtpt.LogOutput = os.Stderr
@@ -46,9 +50,9 @@ func makeSmuxTransportOption(mplexExp bool) libp2p.Option {
 	return libp2p.ChainOptions(opts...)
 }
 
-func SmuxTransport(mplex bool) func() (opts Libp2pOpts, err error) {
-	return func() (opts Libp2pOpts, err error) {
-		opts.Opts = append(opts.Opts, makeSmuxTransportOption(mplex))
+func SmuxTransport(mplex bool) func(cfg *config.Libp2p) (opts Libp2pOpts, err error) {
+	return func(cfg *config.Libp2p) (opts Libp2pOpts, err error) {
+		opts.Opts = append(opts.Opts, makeSmuxTransportOption(mplex, cfg.Limits.StreamWindow))
 		return
 	}
 }
@@ -109,6 +109,18 @@ func (a *PaychAPI) PaychStatus(ctx context.Context, pch address.Address) (*types
 	}, nil
 }
 
+// PaychFrom returns the key address paying into the channel
+func (a *PaychAPI) PaychFrom(ctx context.Context, pch address.Address) (address.Address, error) {
+	ci, err := a.paychMgr.GetChannelInfo(ctx, pch)
+	if err != nil {
+		return address.Undef, err
+	}
+	if types.PCHDir(ci.Direction) == types.PCHInbound {
+		return ci.Target, nil
+	}
+	return ci.Control, nil
+}
+
 func (a *PaychAPI) PaychSettle(ctx context.Context, addr address.Address) (cid.Cid, error) {
 	return a.paychMgr.Settle(ctx, addr)
 }
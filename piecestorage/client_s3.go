@@ -77,6 +77,10 @@ func (c PresignS3Storage) Has(ctx context.Context, s string) (bool, error) {
 	panic("implement me")
 }
 
+func (c PresignS3Storage) Delete(ctx context.Context, s string) error {
+	return fmt.Errorf("client s3 storage does not support delete")
+}
+
 func (c PresignS3Storage) Validate(s string) error {
 	if c.presignUrl == nil {
 		return fmt.Errorf("client s3 storage must has presign url")
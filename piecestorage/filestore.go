@@ -24,6 +24,8 @@ type IPieceStorage interface {
 	Len(ctx context.Context, string2 string) (int64, error)
 	ReadOffset(context.Context, string, int, int) (io.ReadCloser, error)
 	Has(context.Context, string) (bool, error)
+	// Delete removes the resource, removing a resource that does not exist is not an error
+	Delete(context.Context, string) error
 	Validate(s string) error
 
 	IPreSignOp
@@ -89,6 +91,14 @@ func (f fsPieceStorage) Has(ctx context.Context, s string) (bool, error) {
 	return true, nil
 }
 
+func (f fsPieceStorage) Delete(ctx context.Context, s string) error {
+	err := os.Remove(path.Join(f.baseUrl, s))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
 func (f fsPieceStorage) Validate(s string) error {
 	st, err := os.Stat(f.baseUrl)
 	if err != nil {
@@ -172,6 +172,14 @@ func (s s3PieceStorage) Has(ctx context.Context, piececid string) (bool, error)
 	return true, nil
 }
 
+func (s s3PieceStorage) Delete(ctx context.Context, piececid string) error {
+	_, err := s.s3Client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(piececid),
+	})
+	return err
+}
+
 //todo 下面presign两个方法用于给客户端使用，暂时仅仅支持对象存储。 可能需要一个更合适的抽象模式
 func (s s3PieceStorage) GetReadUrl(ctx context.Context, s2 string) (string, error) {
 	if has, err := s.Has(ctx, s2); err != nil {
@@ -0,0 +1,133 @@
+package retrievalprovider
+
+import (
+	"context"
+	"path"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/venus-market/models/repo"
+	mtypes "github.com/filecoin-project/venus-market/types"
+	types "github.com/filecoin-project/venus/venus-shared/types/market"
+)
+
+// askRuleParams is what a retrieval is matched against the retrieval ask rules
+type askRuleParams struct {
+	client peer.ID
+	// wallet is the wallet the client paid its earlier retrievals from, it is looked up when a rule needs it
+	wallet     address.Address
+	payloadCID cid.Cid
+	// the storage deals the data is retrieved from
+	deals []*types.MinerDeal
+}
+
+// resolveAsk returns the ask of the rule matching the retrieval, or the ask of the miner when no rule matches.
+// queries and pull validation both price a retrieval with it, so that a client is never quoted a price it is refused with
+func resolveAsk(ctx context.Context, askRepo repo.IRetrievalAskRepo, miner address.Address, params askRuleParams) (*types.RetrievalAsk, error) {
+	rules, err := askRepo.ListAskRules(ctx, miner)
+	if err != nil {
+		return nil, err
+	}
+	if params.wallet == address.Undef && params.client != "" && hasWalletRule(rules) {
+		wallet, err := askRepo.GetClientWallet(ctx, params.client)
+		if err != nil && !xerrors.Is(err, repo.ErrNotFound) {
+			return nil, err
+		}
+		params.wallet = wallet
+	}
+	if rule := matchAskRule(rules, params); rule != nil {
+		log.Debugf("retrieval of %s by %s priced by %s rule %s", params.payloadCID, params.client, rule.Kind, rule.Value)
+		return &types.RetrievalAsk{
+			Miner:                   miner,
+			PricePerByte:            rule.PricePerByte,
+			UnsealPrice:             rule.UnsealPrice,
+			PaymentInterval:         rule.PaymentInterval,
+			PaymentIntervalIncrease: rule.PaymentIntervalIncrease,
+		}, nil
+	}
+	return askRepo.GetAsk(ctx, miner)
+}
+
+// NormalizeAskRuleValue checks the value of a rule of the kind and returns it in the form retrievals are matched with
+func NormalizeAskRuleValue(kind mtypes.RetrievalAskRuleKind, value string) (string, error) {
+	switch kind {
+	case mtypes.RetrievalAskRuleClient:
+		if p, err := peer.Decode(value); err == nil {
+			return p.String(), nil
+		}
+		addr, err := address.NewFromString(value)
+		if err != nil {
+			return "", xerrors.Errorf("client %s is neither a peer id nor an address", value)
+		}
+		// the wallet of a payment channel is known by its key address
+		if addr.Protocol() == address.ID {
+			return "", xerrors.Errorf("client %s is an id address, use the key address of the wallet", value)
+		}
+		return addr.String(), nil
+	case mtypes.RetrievalAskRulePiece, mtypes.RetrievalAskRulePayload:
+		c, err := cid.Decode(value)
+		if err != nil {
+			return "", xerrors.Errorf("parse %s cid %s: %w", kind, value, err)
+		}
+		return c.String(), nil
+	case mtypes.RetrievalAskRuleLabel:
+		if _, err := path.Match(value, ""); err != nil {
+			return "", xerrors.Errorf("label pattern %s: %w", value, err)
+		}
+		return value, nil
+	}
+	return "", xerrors.Errorf("unknown retrieval ask rule kind %s", kind)
+}
+
+// matchAskRule returns the first matching rule of the kind with the highest precedence
+func matchAskRule(rules []*mtypes.RetrievalAskRule, params askRuleParams) *mtypes.RetrievalAskRule {
+	for _, kind := range mtypes.RetrievalAskRuleKinds {
+		for _, rule := range rules {
+			if rule.Kind == kind && askRuleMatches(rule, params) {
+				return rule
+			}
+		}
+	}
+	return nil
+}
+
+// hasWalletRule returns whether a client rule is set on a wallet address instead of a peer id
+func hasWalletRule(rules []*mtypes.RetrievalAskRule) bool {
+	for _, rule := range rules {
+		if rule.Kind != mtypes.RetrievalAskRuleClient {
+			continue
+		}
+		if _, err := peer.Decode(rule.Value); err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func askRuleMatches(rule *mtypes.RetrievalAskRule, params askRuleParams) bool {
+	switch rule.Kind {
+	case mtypes.RetrievalAskRuleClient:
+		if params.client != "" && params.client.String() == rule.Value {
+			return true
+		}
+		return params.wallet != address.Undef && params.wallet.String() == rule.Value
+	case mtypes.RetrievalAskRulePiece:
+		for _, deal := range params.deals {
+			if deal.Proposal.PieceCID.String() == rule.Value {
+				return true
+			}
+		}
+	case mtypes.RetrievalAskRulePayload:
+		return params.payloadCID.Defined() && params.payloadCID.String() == rule.Value
+	case mtypes.RetrievalAskRuleLabel:
+		for _, deal := range params.deals {
+			if ok, _ := path.Match(rule.Value, deal.Proposal.Label); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
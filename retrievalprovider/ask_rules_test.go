@@ -0,0 +1,136 @@
+package retrievalprovider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/market"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/venus-market/models"
+	"github.com/filecoin-project/venus-market/models/badger"
+	mtypes "github.com/filecoin-project/venus-market/types"
+	types "github.com/filecoin-project/venus/venus-shared/types/market"
+)
+
+func TestMatchAskRule(t *testing.T) {
+	newCid := func(data string) cid.Cid {
+		mh, err := multihash.Sum([]byte(data), multihash.SHA2_256, -1)
+		require.NoError(t, err)
+		return cid.NewCidV1(cid.Raw, mh)
+	}
+	client, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+	partner, err := peer.Decode("12D3KooWG8tR9PHjjXcMknbNPVWT75BuXXA2RaYx3fMwwg2oPZXd")
+	require.NoError(t, err)
+	otherClient, err := address.NewIDAddress(1001)
+	require.NoError(t, err)
+	wallet, err := address.NewSecp256k1Address([]byte("retrieval client wallet"))
+	require.NoError(t, err)
+
+	piece := newCid("piece")
+	payload := newCid("payload")
+	deal := &types.MinerDeal{
+		ClientDealProposal: market.ClientDealProposal{
+			Proposal: market.DealProposal{
+				PieceCID: piece,
+				Client:   client,
+				Label:    "premium/genome-2021",
+			},
+		},
+	}
+
+	otherClientDeal := &types.MinerDeal{
+		ClientDealProposal: market.ClientDealProposal{
+			Proposal: market.DealProposal{PieceCID: piece, Client: otherClient, Label: "public"},
+		},
+	}
+
+	rule := func(kind mtypes.RetrievalAskRuleKind, value string) *mtypes.RetrievalAskRule {
+		return &mtypes.RetrievalAskRule{Kind: kind, Value: value}
+	}
+	labelRule := rule(mtypes.RetrievalAskRuleLabel, "premium/*")
+	payloadRule := rule(mtypes.RetrievalAskRulePayload, payload.String())
+	pieceRule := rule(mtypes.RetrievalAskRulePiece, piece.String())
+	peerRule := rule(mtypes.RetrievalAskRuleClient, partner.String())
+	// a rule for the client of the storage deal, which is not the peer retrieving the data
+	storageClientRule := rule(mtypes.RetrievalAskRuleClient, client.String())
+	walletRule := rule(mtypes.RetrievalAskRuleClient, wallet.String())
+
+	params := askRuleParams{client: partner, payloadCID: payload, deals: []*types.MinerDeal{deal}}
+	paid := askRuleParams{client: partner, wallet: wallet, payloadCID: payload, deals: []*types.MinerDeal{deal}}
+	other := askRuleParams{payloadCID: newCid("other"), deals: []*types.MinerDeal{{
+		ClientDealProposal: market.ClientDealProposal{Proposal: market.DealProposal{PieceCID: newCid("other piece"), Label: "public"}},
+	}}}
+
+	cases := []struct {
+		name   string
+		rules  []*mtypes.RetrievalAskRule
+		params askRuleParams
+		expect *mtypes.RetrievalAskRule
+	}{
+		{"no rules", nil, params, nil},
+		{"label pattern", []*mtypes.RetrievalAskRule{labelRule}, params, labelRule},
+		{"payload over label", []*mtypes.RetrievalAskRule{labelRule, payloadRule}, params, payloadRule},
+		{"piece over payload", []*mtypes.RetrievalAskRule{labelRule, payloadRule, pieceRule}, params, pieceRule},
+		{"client peer over piece", []*mtypes.RetrievalAskRule{pieceRule, peerRule}, params, peerRule},
+		{"storage deal client is not the retrieval client", []*mtypes.RetrievalAskRule{labelRule, storageClientRule}, params, labelRule},
+		{"retrieval client of data of another client", []*mtypes.RetrievalAskRule{peerRule}, askRuleParams{client: partner, payloadCID: payload, deals: []*types.MinerDeal{otherClientDeal}}, peerRule},
+		{"wallet of the retrieval client", []*mtypes.RetrievalAskRule{pieceRule, walletRule}, paid, walletRule},
+		{"wallet not known yet", []*mtypes.RetrievalAskRule{pieceRule, walletRule}, params, pieceRule},
+		{"nothing matches other data", []*mtypes.RetrievalAskRule{labelRule, payloadRule, pieceRule, storageClientRule}, other, nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			require.Equal(t, c.expect, matchAskRule(c.rules, c.params))
+		})
+	}
+
+	// client rules take the peer id of the retrieval client or the key address of its wallet
+	_, err = NormalizeAskRuleValue(mtypes.RetrievalAskRuleClient, client.String())
+	require.Error(t, err)
+	_, err = NormalizeAskRuleValue(mtypes.RetrievalAskRuleClient, "not a client")
+	require.Error(t, err)
+	value, err := NormalizeAskRuleValue(mtypes.RetrievalAskRuleClient, partner.String())
+	require.NoError(t, err)
+	require.Equal(t, partner.String(), value)
+	value, err = NormalizeAskRuleValue(mtypes.RetrievalAskRuleClient, wallet.String())
+	require.NoError(t, err)
+	require.Equal(t, wallet.String(), value)
+}
+
+func TestResolveAskByWallet(t *testing.T) {
+	ctx := context.Background()
+	miner, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+	wallet, err := address.NewSecp256k1Address([]byte("retrieval client wallet"))
+	require.NoError(t, err)
+	partner, err := peer.Decode("12D3KooWG8tR9PHjjXcMknbNPVWT75BuXXA2RaYx3fMwwg2oPZXd")
+	require.NoError(t, err)
+
+	askRepo := badger.NewRetrievalAskRepo(models.BadgerDB(t), models.BadgerDB(t), models.BadgerDB(t))
+	require.NoError(t, askRepo.SetAsk(ctx, &types.RetrievalAsk{Miner: miner, PricePerByte: abi.NewTokenAmount(10), UnsealPrice: abi.NewTokenAmount(0)}))
+	require.NoError(t, askRepo.SetAskRule(ctx, &mtypes.RetrievalAskRule{
+		Miner:        miner,
+		Kind:         mtypes.RetrievalAskRuleClient,
+		Value:        wallet.String(),
+		PricePerByte: abi.NewTokenAmount(1),
+		UnsealPrice:  abi.NewTokenAmount(0),
+	}))
+
+	// the client has not paid from its wallet yet
+	ask, err := resolveAsk(ctx, askRepo, miner, askRuleParams{client: partner})
+	require.NoError(t, err)
+	require.Equal(t, abi.NewTokenAmount(10), ask.PricePerByte)
+
+	require.NoError(t, askRepo.SetClientWallet(ctx, partner, wallet))
+	ask, err = resolveAsk(ctx, askRepo, miner, askRuleParams{client: partner})
+	require.NoError(t, err)
+	require.Equal(t, abi.NewTokenAmount(1), ask.PricePerByte)
+}
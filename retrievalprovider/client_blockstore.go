@@ -1,6 +1,7 @@
 package retrievalprovider
 
 import (
+	"context"
 	"fmt"
 	"path/filepath"
 	"sync"
@@ -36,6 +37,7 @@ type CARBlockstoreAccessor struct {
 	rootdir string
 	lk      sync.Mutex
 	open    map[retrievalmarket.DealID]*blockstore.ReadWrite
+	seeds   map[retrievalmarket.DealID][]retrievalmarket.DealID
 }
 
 var _ retrievalmarket.BlockstoreAccessor = (*CARBlockstoreAccessor)(nil)
@@ -44,9 +46,20 @@ func NewCARBlockstoreAccessor(rootdir string) *CARBlockstoreAccessor {
 	return &CARBlockstoreAccessor{
 		rootdir: rootdir,
 		open:    make(map[retrievalmarket.DealID]*blockstore.ReadWrite),
+		seeds:   make(map[retrievalmarket.DealID][]retrievalmarket.DealID),
 	}
 }
 
+// Seed makes the blockstore of the deal start with the blocks received by earlier deals of the same payload,
+// so the blocks of a failed retrieval are kept by the one taking over.
+// must be called before the deal is started.
+func (c *CARBlockstoreAccessor) Seed(id retrievalmarket.DealID, from []retrievalmarket.DealID) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	c.seeds[id] = from
+}
+
 func (c *CARBlockstoreAccessor) Get(id retrievalmarket.DealID, payloadCid retrievalmarket.PayloadCID) (bstore.Blockstore, error) {
 	c.lk.Lock()
 	defer c.lk.Unlock()
@@ -62,9 +75,56 @@ func (c *CARBlockstoreAccessor) Get(id retrievalmarket.DealID, payloadCid retrie
 		return nil, err
 	}
 	c.open[id] = bs
+
+	if from, ok := c.seeds[id]; ok {
+		delete(c.seeds, id)
+		for _, prev := range from {
+			if err := c.copyBlocks(prev, bs); err != nil {
+				log.Warnf("seed retrieval %d with blocks of %d: %s", id, prev, err)
+			}
+		}
+	}
 	return bs, nil
 }
 
+// copyBlocks copies the blocks received by the deal prev, a stalled deal may not be done yet so its car is read
+// through the blockstore still open
+func (c *CARBlockstoreAccessor) copyBlocks(prev retrievalmarket.DealID, to *blockstore.ReadWrite) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var from bstore.Blockstore
+	if bs, ok := c.open[prev]; ok {
+		from = bs
+	} else {
+		bs, err := blockstore.OpenReadOnly(c.PathFor(prev), blockstore.UseWholeCIDs(true))
+		if err != nil {
+			return err
+		}
+		defer bs.Close() //nolint:errcheck
+		from = bs
+	}
+
+	keysCh, err := from.AllKeysChan(ctx)
+	if err != nil {
+		return err
+	}
+	var keys []cid.Cid
+	for k := range keysCh {
+		keys = append(keys, k)
+	}
+	for _, k := range keys {
+		blk, err := from.Get(ctx, k)
+		if err != nil {
+			return err
+		}
+		if err := to.Put(ctx, blk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (c *CARBlockstoreAccessor) Done(id retrievalmarket.DealID) error {
 	c.lk.Lock()
 	defer c.lk.Unlock()
@@ -11,14 +11,15 @@ import (
 )
 
 type PieceInfo struct {
-	cidInfoRepo repo.ICidInfoRepo
-	dealRepo    repo.StorageDealRepo
+	cidInfoRepo    repo.ICidInfoRepo
+	blockIndexRepo repo.IBlockIndexRepo
+	dealRepo       repo.StorageDealRepo
 }
 
 func (pinfo *PieceInfo) GetPieceInfoFromCid(ctx context.Context, payloadCID cid.Cid, piececid *cid.Cid) ([]*types.MinerDeal, error) {
-	cidInfo, err := pinfo.cidInfoRepo.GetCIDInfo(ctx, payloadCID)
+	pieces, err := pinfo.piecesOfCid(ctx, payloadCID)
 	if err != nil {
-		return nil, xerrors.Errorf("get cid info: %w", err)
+		return nil, err
 	}
 
 	if piececid != nil && (*piececid).Defined() {
@@ -29,8 +30,8 @@ func (pinfo *PieceInfo) GetPieceInfoFromCid(ctx context.Context, payloadCID cid.
 		return minerDeals, nil
 	} else {
 		var allMinerDeals []*types.MinerDeal
-		for _, pieceBlockLocation := range cidInfo.PieceBlockLocations {
-			minerDeals, err := pinfo.dealRepo.GetDealsByPieceCidAndStatus(ctx, pieceBlockLocation.PieceCID, storageprovider.ReadyRetrievalDealStatus...)
+		for _, pieceCid := range pieces {
+			minerDeals, err := pinfo.dealRepo.GetDealsByPieceCidAndStatus(ctx, pieceCid, storageprovider.ReadyRetrievalDealStatus...)
 			if err != nil {
 				return nil, err
 			}
@@ -42,3 +43,29 @@ func (pinfo *PieceInfo) GetPieceInfoFromCid(ctx context.Context, payloadCID cid.
 	}
 	return nil, xerrors.Errorf("unable to find ready data for piece (%s) payload (%s)", piececid, payloadCID)
 }
+
+// piecesOfCid returns the pieces holding the cid, payload roots are found in the cid infos and every other block of
+// the pieces in the block index
+func (pinfo *PieceInfo) piecesOfCid(ctx context.Context, c cid.Cid) ([]cid.Cid, error) {
+	var pieces []cid.Cid
+	cidInfo, err := pinfo.cidInfoRepo.GetCIDInfo(ctx, c)
+	if err == nil {
+		for _, pieceBlockLocation := range cidInfo.PieceBlockLocations {
+			if pieceBlockLocation.PieceCID.Defined() {
+				pieces = append(pieces, pieceBlockLocation.PieceCID)
+			}
+		}
+	}
+	if len(pieces) > 0 {
+		return pieces, nil
+	}
+
+	pieces, blockErr := pinfo.blockIndexRepo.GetPiecesByBlock(ctx, c.Hash())
+	if blockErr == nil {
+		return pieces, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("get cid info: %w", err)
+	}
+	return nil, xerrors.Errorf("get pieces of block: %w", blockErr)
+}
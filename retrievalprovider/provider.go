@@ -63,7 +63,7 @@ func NewProvider(network rmnet.RetrievalMarketNetwork,
 	cidInfoRepo := repo.CidInfoRepo()
 	retrievalAskRepo := repo.RetrievalAskRepo()
 
-	pieceInfo := &PieceInfo{cidInfoRepo: cidInfoRepo, dealRepo: storageDealsRepo}
+	pieceInfo := &PieceInfo{cidInfoRepo: cidInfoRepo, blockIndexRepo: repo.BlockIndexRepo(), dealRepo: storageDealsRepo}
 	p := &RetrievalProvider{
 		dataTransfer:           dataTransfer,
 		network:                network,
@@ -77,7 +77,7 @@ func NewProvider(network rmnet.RetrievalMarketNetwork,
 	retrievalHandler := NewRetrievalDealHandler(&providerDealEnvironment{p}, retrievalDealRepo, storageDealsRepo)
 	p.requestValidator = NewProviderRequestValidator(address.Address(cfg.RetrievalPaymentAddress.Addr), storageDealsRepo, retrievalDealRepo, retrievalAskRepo, pieceInfo)
 	transportConfigurer := dtutils.TransportConfigurer(network.ID(), &providerStoreGetter{retrievalDealRepo, p.stores})
-	p.reValidator = NewProviderRevalidator(fullNode, payAPI, retrievalDealRepo, retrievalHandler)
+	p.reValidator = NewProviderRevalidator(fullNode, payAPI, retrievalDealRepo, retrievalAskRepo, retrievalHandler)
 
 	var err error
 	if p.disableNewDeals {
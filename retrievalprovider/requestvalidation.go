@@ -174,7 +174,11 @@ func (rv *ProviderRequestValidator) acceptDeal(ctx context.Context, deal *types.
 
 	//todo how to select deal
 	deal.SelStorageProposalCid = minerdeals[0].ProposalCid
-	ask, err := rv.retrievalAsk.GetAsk(ctx, rv.paymentAddr)
+	ask, err := resolveAsk(ctx, rv.retrievalAsk, rv.paymentAddr, askRuleParams{
+		client:     deal.Receiver,
+		payloadCID: deal.PayloadCID,
+		deals:      minerdeals,
+	})
 	if err != nil {
 		return retrievalmarket.DealStatusErrored, err
 	}
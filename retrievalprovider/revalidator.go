@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 
+	"github.com/filecoin-project/go-address"
 	datatransfer "github.com/filecoin-project/go-data-transfer"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/big"
@@ -11,6 +12,7 @@ import (
 	"github.com/filecoin-project/venus-market/paychmgr"
 	v1api "github.com/filecoin-project/venus/venus-shared/api/chain/v1"
 	types "github.com/filecoin-project/venus/venus-shared/types/market"
+	"github.com/libp2p/go-libp2p-core/peer"
 
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket/migrations"
@@ -22,15 +24,17 @@ type ProviderRevalidator struct {
 	fullNode             v1api.FullNode
 	payAPI               *paychmgr.PaychAPI
 	deals                repo.IRetrievalDealRepo
+	askRepo              repo.IRetrievalAskRepo
 	retrievalDealHandler IRetrievalHandler
 }
 
 // NewProviderRevalidator returns a new instance of a ProviderRevalidator
-func NewProviderRevalidator(fullNode v1api.FullNode, payAPI *paychmgr.PaychAPI, deals repo.IRetrievalDealRepo, retrievalDealHandler IRetrievalHandler) *ProviderRevalidator {
+func NewProviderRevalidator(fullNode v1api.FullNode, payAPI *paychmgr.PaychAPI, deals repo.IRetrievalDealRepo, askRepo repo.IRetrievalAskRepo, retrievalDealHandler IRetrievalHandler) *ProviderRevalidator {
 	return &ProviderRevalidator{
 		fullNode:             fullNode,
 		payAPI:               payAPI,
 		deals:                deals,
+		askRepo:              askRepo,
 		retrievalDealHandler: retrievalDealHandler,
 	}
 }
@@ -73,6 +77,7 @@ func (pr *ProviderRevalidator) processPayment(ctx context.Context, deal *types.P
 		_ = pr.retrievalDealHandler.CancelDeal(ctx, deal)
 		return errorDealResponse(deal.Identifier(), err), err
 	}
+	pr.recordWallet(ctx, deal.Receiver, payment.PaymentChannel)
 
 	totalPaid := big.Add(deal.FundsReceived, received)
 
@@ -334,3 +339,15 @@ func (lrv *legacyRevalidator) OnComplete(chid datatransfer.ChannelID) (bool, dat
 func NewLegacyRevalidator(providerRevalidator *ProviderRevalidator) datatransfer.Revalidator {
 	return &legacyRevalidator{providerRevalidator: providerRevalidator}
 }
+
+// recordWallet remembers the wallet the client pays from, the client rules on its address price its next retrievals
+func (pr *ProviderRevalidator) recordWallet(ctx context.Context, client peer.ID, pch address.Address) {
+	wallet, err := pr.payAPI.PaychFrom(ctx, pch)
+	if err != nil {
+		log.Warnf("get the wallet paying into %s: %s", pch, err)
+		return
+	}
+	if err := pr.askRepo.SetClientWallet(ctx, client, wallet); err != nil {
+		log.Warnf("record the wallet %s of retrieval client %s: %s", wallet, client, err)
+	}
+}
@@ -82,7 +82,11 @@ func (p *RetrievalStreamHandler) HandleQueryStream(stream rmnet.RetrievalQuerySt
 	answer.PaymentAddress = p.paymentAddr
 
 	//todo use market ask maybe need miner ask list for future
-	ask, err := p.askRepo.GetAsk(ctx, p.paymentAddr)
+	ask, err := resolveAsk(ctx, p.askRepo, p.paymentAddr, askRuleParams{
+		client:     stream.RemotePeer(),
+		payloadCID: query.PayloadCID,
+		deals:      minerDeals,
+	})
 	if err != nil {
 		log.Errorf("Retrieval query: GetAsk: %s", err)
 		answer.Status = retrievalmarket.QueryResponseError
@@ -0,0 +1,65 @@
+package rpc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/filecoin-project/venus-auth/auth"
+	"golang.org/x/xerrors"
+)
+
+type accountKey struct{}
+
+// WithAccount scopes the request to the miners owned by account
+func WithAccount(ctx context.Context, account string) context.Context {
+	return context.WithValue(ctx, accountKey{}, account)
+}
+
+// AccountFromContext returns the account of the token the request was made with.
+// ok is false when the request is not scoped to an account, that is when venus-market does not serve a pool
+func AccountFromContext(ctx context.Context) (account string, ok bool) {
+	account, ok = ctx.Value(accountKey{}).(string)
+	return
+}
+
+// accountHandler puts the account of the token into the request context.
+// it runs behind the auth mux, so the token is already verified and only its payload is decoded
+func accountHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("Authorization")
+		if token == "" {
+			token = r.FormValue("token")
+		}
+		token = strings.TrimSpace(strings.TrimPrefix(token, "Bearer "))
+
+		var account string
+		if token != "" {
+			payload, err := decodeTokenPayload(token)
+			if err != nil {
+				log.Warnf("decode token payload: %v", err)
+			} else {
+				account = payload.Name
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(WithAccount(r.Context(), account)))
+	})
+}
+
+func decodeTokenPayload(token string) (*auth.JWTPayload, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, xerrors.Errorf("token has %d parts", len(parts))
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	var payload auth.JWTPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	return &payload, nil
+}
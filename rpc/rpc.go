@@ -51,8 +51,9 @@ func ServeRPC(ctx context.Context, home config.IHome, cfg *config.API, mux *mux.
 
 	var handler http.Handler
 	if len(authUrl) > 0 {
+		// in pool mode the tokens of venus-auth only reach the miners of their account
 		cli := jwtclient.NewJWTClient(authUrl)
-		handler = jwtclient.NewAuthMux(localJwtClient, jwtclient.WarpIJwtAuthClient(cli), mux, logging.Logger("auth"))
+		handler = jwtclient.NewAuthMux(localJwtClient, jwtclient.WarpIJwtAuthClient(cli), accountHandler(mux), logging.Logger("auth"))
 	} else {
 		handler = jwtclient.NewAuthMux(localJwtClient, nil, mux, logging.Logger("auth"))
 	}
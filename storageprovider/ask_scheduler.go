@@ -0,0 +1,368 @@
+package storageprovider
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.uber.org/fx"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+
+	"github.com/filecoin-project/venus-market/config"
+	"github.com/filecoin-project/venus-market/minermgr"
+	"github.com/filecoin-project/venus-market/models/repo"
+	mtypes "github.com/filecoin-project/venus-market/types"
+
+	"github.com/filecoin-project/venus/pkg/constants"
+	v1api "github.com/filecoin-project/venus/venus-shared/api/chain/v1"
+	vTypes "github.com/filecoin-project/venus/venus-shared/types"
+	types "github.com/filecoin-project/venus/venus-shared/types/market"
+
+	"github.com/ipfs-force-community/venus-common-utils/metrics"
+)
+
+const (
+	defaultAskDuration      = 30 * 24 * time.Hour
+	askMultiplierPrecision  = 1_000_000
+	askScheduleReasonPrefix = "schedule"
+)
+
+// askSignals are the measurements the pricing rules of a policy are evaluated against
+type askSignals struct {
+	now time.Time
+	// number of deals waiting to be assigned to a sector, negative when unknown
+	pendingDeals int
+	// used fraction of the filesystem of the piece storage, negative when unknown
+	stagingUsage float64
+	// escrow minus locked funds of the miner in the market actor, nil when unknown
+	balance *abi.TokenAmount
+	// tags of the miner in its sources
+	tags []string
+}
+
+// AskScheduler re-signs the storage asks of the configured miners with the price computed by their pricing rules,
+// whenever the price changes or the current ask is about to expire
+type AskScheduler struct {
+	cfg         config.StorageAskSchedule
+	storageAsk  IStorageAsk
+	dealRepo    repo.StorageDealRepo
+	fullNode    v1api.FullNode
+	minerMgr    minermgr.IAddrMgr
+	stagingPath string
+}
+
+func NewAskScheduler(mctx metrics.MetricsCtx, lc fx.Lifecycle, cfg *config.MarketConfig, storageAsk IStorageAsk, r repo.Repo, fullNode v1api.FullNode, minerMgr minermgr.IAddrMgr) (*AskScheduler, error) {
+	for _, policy := range cfg.StorageAskSchedule.Policies {
+		if err := validateAskPolicy(policy); err != nil {
+			return nil, xerrors.Errorf("invalid ask policy of miner %s: %w", address.Address(policy.Miner), err)
+		}
+	}
+
+	scheduler := &AskScheduler{
+		cfg:        cfg.StorageAskSchedule,
+		storageAsk: storageAsk,
+		dealRepo:   r.StorageDealRepo(),
+		fullNode:   fullNode,
+		minerMgr:   minerMgr,
+	}
+	if cfg.PieceStorage.Fs.Enable {
+		scheduler.stagingPath = cfg.PieceStorage.Fs.Path
+	}
+
+	if !scheduler.cfg.Enable || len(scheduler.cfg.Policies) == 0 {
+		return scheduler, nil
+	}
+
+	ctx := metrics.LifecycleCtx(mctx, lc)
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go scheduler.Start(ctx)
+			return nil
+		},
+	})
+	return scheduler, nil
+}
+
+func (s *AskScheduler) Start(ctx context.Context) {
+	interval := time.Duration(s.cfg.CheckInterval)
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+
+	s.checkPolicies(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.checkPolicies(ctx)
+		case <-ctx.Done():
+			log.Warnf("exit ask scheduler by context")
+			return
+		}
+	}
+}
+
+func (s *AskScheduler) checkPolicies(ctx context.Context) {
+	for _, policy := range s.cfg.Policies {
+		// the ask of a disabled miner is not served, signing it again is useless
+		if s.minerMgr.IsDisabled(ctx, address.Address(policy.Miner)) {
+			continue
+		}
+		if err := s.checkPolicy(ctx, policy); err != nil {
+			log.Errorf("schedule the ask of miner %s: %v", address.Address(policy.Miner), err)
+		}
+	}
+}
+
+func (s *AskScheduler) checkPolicy(ctx context.Context, policy config.StorageAskPolicy) error {
+	miner := address.Address(policy.Miner)
+	head, err := s.fullNode.ChainHead(ctx)
+	if err != nil {
+		return xerrors.Errorf("get chain head: %w", err)
+	}
+
+	multiplier, matched := evalAskPolicy(policy, s.collectSignals(ctx, miner, policy, head.Key()))
+	price := scaleAskPrice(abi.TokenAmount(policy.Price), multiplier)
+	verifiedPrice := scaleAskPrice(abi.TokenAmount(policy.VerifiedPrice), multiplier)
+
+	current, err := s.storageAsk.GetAsk(ctx, miner)
+	if err != nil && !xerrors.Is(err, repo.ErrNotFound) {
+		return xerrors.Errorf("get current ask: %w", err)
+	}
+
+	duration := time.Duration(policy.Duration)
+	if duration <= 0 {
+		duration = defaultAskDuration
+	}
+	resignBefore := time.Duration(policy.ResignBefore)
+	if resignBefore <= 0 {
+		resignBefore = 2 * time.Duration(s.cfg.CheckInterval)
+	}
+
+	var reason string
+	switch {
+	case current == nil || current.Ask == nil,
+		!current.Ask.Price.Equals(price),
+		!current.Ask.VerifiedPrice.Equals(verifiedPrice),
+		policy.MinPieceSize != 0 && uint64(current.Ask.MinPieceSize) != policy.MinPieceSize,
+		policy.MaxPieceSize != 0 && uint64(current.Ask.MaxPieceSize) != policy.MaxPieceSize:
+		reason = askScheduleReasonPrefix
+		if len(matched) > 0 {
+			reason += ": " + strings.Join(matched, ", ")
+		}
+	case current.Ask.Expiry-head.Height() <= durationToEpochs(resignBefore):
+		reason = mtypes.StorageAskReasonResign
+	default:
+		return nil
+	}
+
+	var options []storagemarket.StorageAskOption
+	if policy.MinPieceSize != 0 {
+		options = append(options, storagemarket.MinPieceSize(abi.PaddedPieceSize(policy.MinPieceSize)))
+	}
+	if policy.MaxPieceSize != 0 {
+		options = append(options, storagemarket.MaxPieceSize(abi.PaddedPieceSize(policy.MaxPieceSize)))
+	}
+
+	log.Infof("set the ask of miner %s to %s (verified %s), %s", miner, vTypes.FIL(price), vTypes.FIL(verifiedPrice), reason)
+	return s.storageAsk.SetAskWithReason(ctx, reason, miner, price, verifiedPrice, durationToEpochs(duration), options...)
+}
+
+// collectSignals only measures what the rules of the policy need, a signal that can't be measured disables its rules
+func (s *AskScheduler) collectSignals(ctx context.Context, miner address.Address, policy config.StorageAskPolicy, tsk vTypes.TipSetKey) askSignals {
+	signals := askSignals{now: time.Now(), pendingDeals: -1, stagingUsage: -1}
+
+	if len(policy.PendingDeals) > 0 {
+		deals, err := s.dealRepo.GetDealsByPieceStatus(ctx, miner, types.Undefine)
+		if err != nil && !xerrors.Is(err, repo.ErrNotFound) {
+			log.Warnf("get pending deals of miner %s: %v", miner, err)
+		} else {
+			signals.pendingDeals = len(deals)
+		}
+	}
+
+	if len(policy.StagingUsage) > 0 {
+		if s.stagingPath == "" {
+			log.Warnf("staging usage rules of miner %s need the filesystem piece storage", miner)
+		} else if usage, err := fsUsage(s.stagingPath); err != nil {
+			log.Warnf("get usage of %s: %v", s.stagingPath, err)
+		} else {
+			signals.stagingUsage = usage
+		}
+	}
+
+	if len(policy.LowBalance) > 0 {
+		bal, err := s.fullNode.StateMarketBalance(ctx, miner, tsk)
+		if err != nil {
+			log.Warnf("get market balance of miner %s: %v", miner, err)
+		} else {
+			avail := big.Sub(bal.Escrow, bal.Locked)
+			signals.balance = &avail
+		}
+	}
+
+	if len(policy.Tags) > 0 {
+		state, err := s.minerMgr.MinerState(ctx, miner)
+		if err != nil {
+			log.Warnf("get tags of miner %s: %v", miner, err)
+		} else {
+			signals.tags = state.Tags
+		}
+	}
+
+	return signals
+}
+
+// evalAskPolicy returns the product of the multipliers of the rules matching the signals, and a description of each of them
+func evalAskPolicy(policy config.StorageAskPolicy, signals askSignals) (float64, []string) {
+	multiplier := 1.0
+	var matched []string
+
+	minute := signals.now.Hour()*60 + signals.now.Minute()
+	for _, rule := range policy.TimeOfDay {
+		from, err := parseClock(rule.From)
+		if err != nil {
+			continue
+		}
+		to, err := parseClock(rule.To)
+		if err != nil {
+			continue
+		}
+		if inClockWindow(minute, from, to) {
+			multiplier *= rule.Multiplier
+			matched = append(matched, fmt.Sprintf("time-of-day %s-%s x%g", rule.From, rule.To, rule.Multiplier))
+			break
+		}
+	}
+
+	if signals.pendingDeals >= 0 {
+		if rule, ok := matchThreshold(policy.PendingDeals, float64(signals.pendingDeals)); ok {
+			multiplier *= rule.Multiplier
+			matched = append(matched, fmt.Sprintf("pending-deals %d > %g x%g", signals.pendingDeals, rule.Above, rule.Multiplier))
+		}
+	}
+
+	if signals.stagingUsage >= 0 {
+		if rule, ok := matchThreshold(policy.StagingUsage, signals.stagingUsage); ok {
+			multiplier *= rule.Multiplier
+			matched = append(matched, fmt.Sprintf("staging-usage %.2f > %g x%g", signals.stagingUsage, rule.Above, rule.Multiplier))
+		}
+	}
+
+	if signals.balance != nil {
+		var low *config.BalancePriceRule
+		for i, rule := range policy.LowBalance {
+			if signals.balance.LessThan(abi.TokenAmount(rule.Below)) && (low == nil || big.Int(rule.Below).LessThan(big.Int(low.Below))) {
+				low = &policy.LowBalance[i]
+			}
+		}
+		if low != nil {
+			multiplier *= low.Multiplier
+			matched = append(matched, fmt.Sprintf("low-balance %s < %s x%g", vTypes.FIL(*signals.balance), low.Below, low.Multiplier))
+		}
+	}
+
+	for _, rule := range policy.Tags {
+		for _, tag := range signals.tags {
+			if tag == rule.Tag {
+				multiplier *= rule.Multiplier
+				matched = append(matched, fmt.Sprintf("tag %s x%g", rule.Tag, rule.Multiplier))
+				break
+			}
+		}
+	}
+
+	return multiplier, matched
+}
+
+// matchThreshold returns the rule with the highest threshold below the value
+func matchThreshold(rules []config.ThresholdPriceRule, value float64) (config.ThresholdPriceRule, bool) {
+	var (
+		found config.ThresholdPriceRule
+		ok    bool
+	)
+	for _, rule := range rules {
+		if value > rule.Above && (!ok || rule.Above > found.Above) {
+			found, ok = rule, true
+		}
+	}
+	return found, ok
+}
+
+func validateAskPolicy(policy config.StorageAskPolicy) error {
+	if address.Address(policy.Miner) == address.Undef {
+		return xerrors.New("miner is required")
+	}
+	for _, rule := range policy.TimeOfDay {
+		from, err := parseClock(rule.From)
+		if err != nil {
+			return err
+		}
+		to, err := parseClock(rule.To)
+		if err != nil {
+			return err
+		}
+		if from == to {
+			return xerrors.Errorf("empty time of day window %s-%s", rule.From, rule.To)
+		}
+	}
+	for _, rule := range policy.StagingUsage {
+		if rule.Above < 0 || rule.Above >= 1 {
+			return xerrors.Errorf("staging usage threshold %g out of range [0, 1)", rule.Above)
+		}
+	}
+	for _, rule := range policy.Tags {
+		if rule.Tag == "" {
+			return xerrors.New("tag rule without tag")
+		}
+	}
+	return nil
+}
+
+// parseClock returns the minute of the day of a 15:04 formatted time
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, xerrors.Errorf("parse time of day %s: %w", s, err)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func inClockWindow(minute, from, to int) bool {
+	if from <= to {
+		return minute >= from && minute < to
+	}
+	return minute >= from || minute < to
+}
+
+func scaleAskPrice(price abi.TokenAmount, multiplier float64) abi.TokenAmount {
+	if price.Nil() {
+		return big.Zero()
+	}
+	return big.Div(big.Mul(price, big.NewInt(int64(math.Round(multiplier*askMultiplierPrecision)))), big.NewInt(askMultiplierPrecision))
+}
+
+func durationToEpochs(d time.Duration) abi.ChainEpoch {
+	return abi.ChainEpoch(d / (time.Duration(constants.MainNetBlockDelaySecs) * time.Second))
+}
+
+// fsUsage returns the used fraction of the filesystem containing path
+func fsUsage(path string) (float64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	if st.Blocks == 0 {
+		return 0, nil
+	}
+	return 1 - float64(st.Bavail)/float64(st.Blocks), nil
+}
@@ -0,0 +1,123 @@
+package storageprovider
+
+import (
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/venus-market/config"
+
+	vTypes "github.com/filecoin-project/venus/venus-shared/types"
+)
+
+func TestEvalAskPolicy(t *testing.T) {
+	at := func(clock string) time.Time {
+		tm, err := time.Parse("15:04", clock)
+		require.NoError(t, err)
+		return tm
+	}
+	fil := func(v int64) vTypes.FIL {
+		return vTypes.FIL(vTypes.NewInt(uint64(v)))
+	}
+	balance := func(v int64) *abi.TokenAmount {
+		b := abi.NewTokenAmount(v)
+		return &b
+	}
+
+	policy := config.StorageAskPolicy{
+		TimeOfDay: []config.TimeOfDayPriceRule{
+			{From: "22:00", To: "06:00", Multiplier: 0.5},
+			{From: "09:00", To: "18:00", Multiplier: 2},
+		},
+		PendingDeals: []config.ThresholdPriceRule{
+			{Above: 10, Multiplier: 1.5},
+			{Above: 100, Multiplier: 3},
+		},
+		StagingUsage: []config.ThresholdPriceRule{
+			{Above: 0.8, Multiplier: 1.25},
+		},
+		LowBalance: []config.BalancePriceRule{
+			{Below: fil(1000), Multiplier: 1.1},
+			{Below: fil(100), Multiplier: 2},
+		},
+		Tags: []config.TagPriceRule{
+			{Tag: "premium", Multiplier: 1.2},
+			{Tag: "cold", Multiplier: 0.8},
+		},
+	}
+
+	cases := []struct {
+		name       string
+		signals    askSignals
+		multiplier float64
+		matched    int
+	}{
+		{
+			name:       "no rule matches",
+			signals:    askSignals{now: at("07:00"), pendingDeals: 1, stagingUsage: 0.1, balance: balance(5000)},
+			multiplier: 1,
+		},
+		{
+			name:       "window spanning midnight",
+			signals:    askSignals{now: at("23:30"), pendingDeals: -1, stagingUsage: -1},
+			multiplier: 0.5,
+			matched:    1,
+		},
+		{
+			name:       "window spanning midnight after midnight",
+			signals:    askSignals{now: at("05:59"), pendingDeals: -1, stagingUsage: -1},
+			multiplier: 0.5,
+			matched:    1,
+		},
+		{
+			name:       "window end is exclusive",
+			signals:    askSignals{now: at("18:00"), pendingDeals: -1, stagingUsage: -1},
+			multiplier: 1,
+		},
+		{
+			name:       "highest threshold applies",
+			signals:    askSignals{now: at("07:00"), pendingDeals: 150, stagingUsage: -1},
+			multiplier: 3,
+			matched:    1,
+		},
+		{
+			name:       "lowest balance threshold applies",
+			signals:    askSignals{now: at("07:00"), pendingDeals: -1, stagingUsage: -1, balance: balance(50)},
+			multiplier: 2,
+			matched:    1,
+		},
+		{
+			name:       "multipliers compose",
+			signals:    askSignals{now: at("10:00"), pendingDeals: 20, stagingUsage: 0.9, balance: balance(500)},
+			multiplier: 2 * 1.5 * 1.25 * 1.1,
+			matched:    4,
+		},
+		{
+			name:       "every tag rule of the miner applies",
+			signals:    askSignals{now: at("07:00"), pendingDeals: -1, stagingUsage: -1, tags: []string{"cold", "premium", "other"}},
+			multiplier: 1.2 * 0.8,
+			matched:    2,
+		},
+		{
+			name:       "unknown signals are ignored",
+			signals:    askSignals{now: at("07:00"), pendingDeals: -1, stagingUsage: -1},
+			multiplier: 1,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			multiplier, matched := evalAskPolicy(policy, c.signals)
+			require.InDelta(t, c.multiplier, multiplier, 1e-9)
+			require.Len(t, matched, c.matched)
+		})
+	}
+}
+
+func TestScaleAskPrice(t *testing.T) {
+	require.Equal(t, abi.NewTokenAmount(150), scaleAskPrice(abi.NewTokenAmount(100), 1.5))
+	require.Equal(t, abi.NewTokenAmount(33), scaleAskPrice(abi.NewTokenAmount(100), 1.0/3))
+	require.Equal(t, abi.NewTokenAmount(0), scaleAskPrice(abi.TokenAmount{}, 2))
+}
@@ -0,0 +1,213 @@
+package storageprovider
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/venus-market/config"
+	"github.com/filecoin-project/venus-market/models/repo"
+	mtypes "github.com/filecoin-project/venus-market/types"
+
+	types "github.com/filecoin-project/venus/venus-shared/types/market"
+)
+
+const (
+	quotaDay   = 24 * time.Hour
+	quotaMonth = 30 * quotaDay
+)
+
+// ClientQuota limits the deals proposed by every client address of the proposals. The proposals per minute are
+// counted in memory, the bytes are summed from the deals of the client in the repo. The checks of a client are
+// serialized, a proposal saved but not checked yet does not count until it passed its check
+type ClientQuota struct {
+	cfg   config.ClientQuota
+	deals repo.StorageDealRepo
+	now   func() time.Time
+
+	lk        sync.Mutex
+	proposals map[address.Address][]time.Time
+	clientLks map[address.Address]*sync.Mutex
+	// admitted are the proposals that passed the check and may still be saved as StorageDealUnknown
+	admitted map[cid.Cid]struct{}
+}
+
+func NewClientQuota(cfg *config.MarketConfig, r repo.Repo) *ClientQuota {
+	return newClientQuota(cfg.ClientQuota, r.StorageDealRepo())
+}
+
+func newClientQuota(cfg config.ClientQuota, deals repo.StorageDealRepo) *ClientQuota {
+	return &ClientQuota{
+		cfg:       cfg,
+		deals:     deals,
+		now:       time.Now,
+		proposals: make(map[address.Address][]time.Time),
+		clientLks: make(map[address.Address]*sync.Mutex),
+		admitted:  make(map[cid.Cid]struct{}),
+	}
+}
+
+func (q *ClientQuota) clientLock(client address.Address) *sync.Mutex {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+	lk, ok := q.clientLks[client]
+	if !ok {
+		lk = &sync.Mutex{}
+		q.clientLks[client] = lk
+	}
+	return lk
+}
+
+func (q *ClientQuota) limits(client address.Address) config.ClientLimits {
+	for _, override := range q.cfg.Overrides {
+		if address.Address(override.Client) == client {
+			return override.Limits
+		}
+	}
+	return q.cfg.Default
+}
+
+// recentProposals drops the proposals older than a minute and returns the number of the remaining ones
+func (q *ClientQuota) recentProposals(client address.Address, now time.Time) int {
+	times := q.proposals[client]
+	idx := 0
+	for idx < len(times) && now.Sub(times[idx]) >= time.Minute {
+		idx++
+	}
+	times = times[idx:]
+	if len(times) == 0 {
+		delete(q.proposals, client)
+	} else {
+		q.proposals[client] = times
+	}
+	return len(times)
+}
+
+func (q *ClientQuota) addProposal(client address.Address) int {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+	now := q.now()
+	q.proposals[client] = append(q.proposals[client], now)
+	return q.recentProposals(client, now)
+}
+
+// Check counts the proposal of the deal and returns why it exceeds a quota of its client, nil when it fits
+func (q *ClientQuota) Check(ctx context.Context, deal *types.MinerDeal) error {
+	client := deal.Proposal.Client
+	limits := q.limits(client)
+
+	lk := q.clientLock(client)
+	lk.Lock()
+	defer lk.Unlock()
+
+	proposals := q.addProposal(client)
+	if limits.ProposalsPerMinute > 0 && proposals > limits.ProposalsPerMinute {
+		return xerrors.Errorf("client %s exceeded the quota of %d proposals per minute", client, limits.ProposalsPerMinute)
+	}
+	if limits.MaxPendingBytes == 0 && limits.MaxBytesPerDay == 0 && limits.MaxBytesPerMonth == 0 {
+		q.admit(deal.ProposalCid)
+		return nil
+	}
+
+	deals, err := q.deals.ListDealByClient(ctx, client)
+	if err != nil {
+		return xerrors.Errorf("list deals of client %s: %w", client, err)
+	}
+	usage := q.usage(client, deals, deal.ProposalCid)
+	size := uint64(deal.Proposal.PieceSize)
+	for _, quota := range []struct {
+		name  string
+		used  uint64
+		limit uint64
+	}{
+		{"pending bytes", usage.PendingBytes, limits.MaxPendingBytes},
+		{"bytes per day", usage.BytesLastDay, limits.MaxBytesPerDay},
+		{"bytes per month", usage.BytesLastMonth, limits.MaxBytesPerMonth},
+	} {
+		if quota.limit > 0 && quota.used+size > quota.limit {
+			return xerrors.Errorf("client %s exceeded the quota of %d %s: %d used, %d proposed", client, quota.limit, quota.name, quota.used, size)
+		}
+	}
+	q.admit(deal.ProposalCid)
+	return nil
+}
+
+func (q *ClientQuota) admit(proposal cid.Cid) {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+	q.admitted[proposal] = struct{}{}
+}
+
+// usage sums the deals of the client but the excluded one, which is the proposal being checked
+func (q *ClientQuota) usage(client address.Address, deals []*types.MinerDeal, exclude cid.Cid) mtypes.ClientUsage {
+	now := q.now()
+	usage := mtypes.ClientUsage{Client: client, Limits: mtypes.ClientLimits(q.limits(client))}
+
+	q.lk.Lock()
+	defer q.lk.Unlock()
+	for _, deal := range deals {
+		if deal.State == storagemarket.StorageDealUnknown {
+			// the proposals of concurrent checks count once they passed them
+			if _, ok := q.admitted[deal.ProposalCid]; !ok {
+				continue
+			}
+		} else {
+			delete(q.admitted, deal.ProposalCid)
+		}
+		// rejected deals were never accepted
+		if deal.ProposalCid == exclude || deal.State == storagemarket.StorageDealRejecting {
+			continue
+		}
+		size := uint64(deal.Proposal.PieceSize)
+		usage.Deals++
+		usage.TotalBytes += size
+		if !isTerminateState(deal) && deal.State != storagemarket.StorageDealActive {
+			usage.PendingBytes += size
+		}
+		age := now.Sub(deal.CreationTime.Time())
+		if age < quotaDay {
+			usage.BytesLastDay += size
+		}
+		if age < quotaMonth {
+			usage.BytesLastMonth += size
+		}
+	}
+
+	usage.ProposalsLastMinute = q.recentProposals(client, now)
+	return usage
+}
+
+// Usage lists the usage of every client with a deal or a recent proposal
+func (q *ClientQuota) Usage(ctx context.Context) ([]mtypes.ClientUsage, error) {
+	deals, err := q.deals.ListDeal(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("list deals: %w", err)
+	}
+	byClient := make(map[address.Address][]*types.MinerDeal)
+	for _, deal := range deals {
+		byClient[deal.Proposal.Client] = append(byClient[deal.Proposal.Client], deal)
+	}
+	q.lk.Lock()
+	for client := range q.proposals {
+		if _, ok := byClient[client]; !ok {
+			byClient[client] = nil
+		}
+	}
+	q.lk.Unlock()
+
+	usages := make([]mtypes.ClientUsage, 0, len(byClient))
+	for client, deals := range byClient {
+		usages = append(usages, q.usage(client, deals, cid.Undef))
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		return usages[i].Client.String() < usages[j].Client.String()
+	})
+	return usages, nil
+}
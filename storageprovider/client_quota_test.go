@@ -0,0 +1,124 @@
+package storageprovider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/market"
+	blocksutil "github.com/ipfs/go-ipfs-blocksutil"
+	"github.com/stretchr/testify/require"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	"github.com/filecoin-project/venus-market/config"
+	"github.com/filecoin-project/venus-market/models/repo"
+	mtypes "github.com/filecoin-project/venus-market/types"
+
+	types "github.com/filecoin-project/venus/venus-shared/types/market"
+)
+
+type fakeQuotaDealRepo struct {
+	repo.StorageDealRepo
+	deals []*types.MinerDeal
+}
+
+func (f *fakeQuotaDealRepo) ListDeal(context.Context) ([]*types.MinerDeal, error) {
+	return f.deals, nil
+}
+
+func (f *fakeQuotaDealRepo) ListDealByClient(_ context.Context, client address.Address) ([]*types.MinerDeal, error) {
+	var deals []*types.MinerDeal
+	for _, deal := range f.deals {
+		if deal.Proposal.Client == client {
+			deals = append(deals, deal)
+		}
+	}
+	return deals, nil
+}
+
+func TestClientQuota(t *testing.T) {
+	ctx := context.Background()
+	bgen := blocksutil.NewBlockGenerator()
+	limited, _ := address.NewIDAddress(1000)
+	vip, _ := address.NewIDAddress(1001)
+	now := time.Now()
+
+	dealRepo := &fakeQuotaDealRepo{}
+	deal := func(client address.Address, size abi.PaddedPieceSize, state storagemarket.StorageDealStatus, created time.Time) *types.MinerDeal {
+		d := &types.MinerDeal{
+			ClientDealProposal: market.ClientDealProposal{Proposal: market.DealProposal{Client: client, PieceSize: size}},
+			ProposalCid:        bgen.Next().Cid(),
+			State:              state,
+			CreationTime:       cbg.CborTime(created),
+		}
+		dealRepo.deals = append(dealRepo.deals, d)
+		return d
+	}
+	// pending, accepted today
+	deal(limited, 8<<10, storagemarket.StorageDealWaitingForData, now.Add(-time.Hour))
+	// active, accepted this month
+	deal(limited, 16<<10, storagemarket.StorageDealActive, now.Add(-10*24*time.Hour))
+	// out of the month
+	deal(limited, 32<<10, storagemarket.StorageDealActive, now.Add(-40*24*time.Hour))
+	// rejected deals do not count
+	deal(limited, 64<<10, storagemarket.StorageDealRejecting, now.Add(-time.Minute))
+
+	q := newClientQuota(config.ClientQuota{
+		Default: config.ClientLimits{ProposalsPerMinute: 3, MaxPendingBytes: 16 << 10, MaxBytesPerDay: 32 << 10, MaxBytesPerMonth: 36 << 10},
+		Overrides: []config.ClientQuotaOverride{
+			{Client: config.Address(vip), Limits: config.ClientLimits{}},
+		},
+	}, dealRepo)
+	q.now = func() time.Time { return now }
+
+	// the proposal being checked is saved already, it does not count against itself
+	require.NoError(t, q.Check(ctx, deal(limited, 8<<10, storagemarket.StorageDealUnknown, now)))
+	// 8k pending + 8k + 8k proposed > 16k
+	err := q.Check(ctx, deal(limited, 8<<10, storagemarket.StorageDealUnknown, now))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "pending bytes")
+	dealRepo.deals[len(dealRepo.deals)-1].State = storagemarket.StorageDealRejecting
+	dealRepo.deals[len(dealRepo.deals)-2].State = storagemarket.StorageDealActive
+
+	// 8k + 16k + 8k + 8k proposed > 36k in the month
+	err = q.Check(ctx, deal(limited, 8<<10, storagemarket.StorageDealUnknown, now))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "bytes per month")
+	dealRepo.deals[len(dealRepo.deals)-1].State = storagemarket.StorageDealRejecting
+
+	// the fourth proposal within a minute
+	err = q.Check(ctx, deal(limited, 128, storagemarket.StorageDealUnknown, now))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "proposals per minute")
+	dealRepo.deals[len(dealRepo.deals)-1].State = storagemarket.StorageDealRejecting
+	now = now.Add(time.Minute)
+	require.NoError(t, q.Check(ctx, deal(limited, 128, storagemarket.StorageDealUnknown, now)))
+
+	// the override lifts the limits
+	for i := 0; i < 5; i++ {
+		require.NoError(t, q.Check(ctx, deal(vip, 1<<20, storagemarket.StorageDealUnknown, now)))
+	}
+
+	// a proposal saved but not checked yet does not count
+	deal(limited, 1<<20, storagemarket.StorageDealUnknown, now)
+
+	usages, err := q.Usage(ctx)
+	require.NoError(t, err)
+	require.Len(t, usages, 2)
+	require.Equal(t, mtypes.ClientUsage{
+		Client:              limited,
+		Deals:               5,
+		TotalBytes:          8<<10 + 16<<10 + 32<<10 + 8<<10 + 128,
+		ProposalsLastMinute: 1,
+		PendingBytes:        8<<10 + 128,
+		BytesLastDay:        8<<10 + 8<<10 + 128,
+		BytesLastMonth:      8<<10 + 16<<10 + 8<<10 + 128,
+		Limits:              mtypes.ClientLimits{ProposalsPerMinute: 3, MaxPendingBytes: 16 << 10, MaxBytesPerDay: 32 << 10, MaxBytesPerMonth: 36 << 10},
+	}, usages[0])
+	require.Equal(t, vip, usages[1].Client)
+	require.Equal(t, 5, usages[1].ProposalsLastMinute)
+	require.Equal(t, uint64(5<<20), usages[1].PendingBytes)
+}
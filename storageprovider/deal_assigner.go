@@ -2,9 +2,11 @@ package storageprovider
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"sort"
+	"time"
 
 	"github.com/filecoin-project/go-fil-markets/piecestore"
 	"github.com/filecoin-project/venus/venus-shared/actors/builtin/market"
@@ -16,93 +18,204 @@ import (
 
 	"github.com/filecoin-project/venus-market/config"
 	"github.com/filecoin-project/venus-market/models/repo"
+	mtypes "github.com/filecoin-project/venus-market/types"
+
+	v1api "github.com/filecoin-project/venus/venus-shared/api/chain/v1"
 	types "github.com/filecoin-project/venus/venus-shared/types/market"
+
+	"github.com/ipfs-force-community/venus-common-utils/metrics"
 )
 
 type DealAssiger interface {
-	MarkDealsAsPacking(ctx context.Context, miner address.Address, dealIDs []abi.DealID) error
-	UpdateDealOnPacking(ctx context.Context, miner address.Address, dealID abi.DealID, sectorid abi.SectorNumber, offset abi.PaddedPieceSize) error
+	// the deals are leased to the sealer given to MarkDealsAsPacking and AssignUnPackedDeals, only that sealer may pack,
+	// renew or take them again until the lease is released
+	MarkDealsAsPacking(ctx context.Context, miner address.Address, sealer string, dealIDs []abi.DealID) error
+	UpdateDealOnPacking(ctx context.Context, miner address.Address, sealer string, dealID abi.DealID, sectorid abi.SectorNumber, offset abi.PaddedPieceSize) error
 	UpdateDealStatus(ctx context.Context, miner address.Address, dealID abi.DealID, pieceStatus string) error
 	GetDeals(ctx context.Context, miner address.Address, pageIndex, pageSize int) ([]*types.DealInfo, error)
 	GetUnPackedDeals(ctx context.Context, miner address.Address, spec *types.GetDealSpec) ([]*types.DealInfoIncludePath, error)
-	AssignUnPackedDeals(ctx context.Context, miner address.Address, ssize abi.SectorSize, spec *types.GetDealSpec) ([]*types.DealInfoIncludePath, error)
+	AssignUnPackedDeals(ctx context.Context, miner address.Address, sealer string, ssize abi.SectorSize, spec *types.GetDealSpec) ([]*types.DealInfoIncludePath, error)
+
+	// RenewDealLeases extends the leases the sealer holds on the deals, the deals it lost are returned in the error
+	RenewDealLeases(ctx context.Context, miner address.Address, sealer string, dealIDs []abi.DealID) error
+	ListDealLeases(ctx context.Context, miner address.Address) ([]*mtypes.DealLease, error)
+	// ReleaseDealLeases returns the deals to the deals waiting to be assigned, whoever holds them
+	ReleaseDealLeases(ctx context.Context, miner address.Address, dealIDs []abi.DealID) error
 }
 
 var _ DealAssiger = (*dealAssigner)(nil)
 
 // NewProviderPieceStore creates a statestore for storing metadata about pieces
 // shared by the piecestorage and retrieval providers
-func NewDealAssigner(lc fx.Lifecycle, pieceStorage *config.PieceStorage, r repo.Repo) (DealAssiger, error) {
+func NewDealAssigner(mctx metrics.MetricsCtx,
+	lc fx.Lifecycle,
+	cfg *config.MarketConfig,
+	pieceStorage *config.PieceStorage,
+	r repo.Repo,
+	fullNode v1api.FullNode,
+	sealDuration config.GetExpectedSealDurationFunc,
+) (DealAssiger, error) {
 	ps, err := newPieceStoreEx(pieceStorage, r)
 	if err != nil {
 		return nil, xerrors.Errorf("construct extend piece store %w", err)
 	}
+	ps.leaseCfg = cfg.DealLease
+	ps.packingCfg = cfg.DealPacking
+	ps.riskCfg = cfg.DealRisk
+	// a typo in a strategy should not wait for the first assignment to show up
+	if _, err := packingStrategyByName(ps.packingCfg.Strategy); err != nil {
+		return nil, err
+	}
+	for _, miner := range ps.packingCfg.Miners {
+		if _, err := packingStrategyByName(miner.Strategy); err != nil {
+			return nil, xerrors.Errorf("miner %s: %w", address.Address(miner.Miner), err)
+		}
+	}
+	ps.sealDuration = sealDuration
+	ps.head = func(ctx context.Context) (abi.ChainEpoch, error) {
+		head, err := fullNode.ChainHead(ctx)
+		if err != nil {
+			return 0, err
+		}
+		return head.Height(), nil
+	}
+
+	// leases that never expire need no reaper
+	if ps.leaseCfg.Duration <= 0 {
+		return ps, nil
+	}
+	ctx := metrics.LifecycleCtx(mctx, lc)
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go ps.reapLeases(ctx)
+			return nil
+		},
+	})
 	return ps, nil
 }
 
 type dealAssigner struct {
 	pieceStorage config.PieceStorage
 	repo         repo.Repo
+
+	leaseCfg     config.DealLease
+	packingCfg   config.DealPacking
+	riskCfg      config.DealRisk
+	sealDuration config.GetExpectedSealDurationFunc
+	head         func(ctx context.Context) (abi.ChainEpoch, error)
+	now          func() time.Time
 }
 
 // NewDsPieceStore returns a new piecestore based on the given datastore
-func newPieceStoreEx(pieceStorage *config.PieceStorage, r repo.Repo) (DealAssiger, error) {
+func newPieceStoreEx(pieceStorage *config.PieceStorage, r repo.Repo) (*dealAssigner, error) {
 	return &dealAssigner{
 		pieceStorage: *pieceStorage,
 
 		repo: r,
+		now:  time.Now,
 	}, nil
 }
 
-func (ps *dealAssigner) MarkDealsAsPacking(ctx context.Context, miner address.Address, dealIDs []abi.DealID) error {
+// MarkDealsAsPacking assigns the deals to the sealer, a deal assigned to another one meanwhile is not taken, the
+// deals lost that way are returned in the error
+func (ps *dealAssigner) MarkDealsAsPacking(ctx context.Context, miner address.Address, sealer string, dealIDs []abi.DealID) error {
+	var lost []abi.DealID
 	for _, dealID := range dealIDs {
-		md, err := ps.repo.StorageDealRepo().GetDealByDealID(ctx, miner, dealID)
-		if err != nil {
-			log.Error("get deal [%d] error for %s", dealID, miner)
-			return xerrors.Errorf("failed to get deal %d for miner %s: %w", dealID, miner.String(), err)
-		}
+		err := ps.repo.Transaction(func(txRepo repo.TxRepo) error {
+			md, err := txRepo.StorageDealRepo().GetDealByDealID(ctx, miner, dealID)
+			if err != nil {
+				log.Error("get deal [%d] error for %s", dealID, miner)
+				return xerrors.Errorf("failed to get deal %d for miner %s: %w", dealID, miner.String(), err)
+			}
+			lease, err := checkLeaseOwner(ctx, txRepo, miner, sealer, dealID)
+			if err != nil {
+				return err
+			}
 
-		md.PieceStatus = types.Assigned
-		if err := ps.repo.StorageDealRepo().SaveDeal(ctx, md); err != nil {
-			return xerrors.Errorf("failed to update deal %d piece status for miner %s: %w", dealID, miner.String(), err)
+			// the sealer marking again a deal it holds only renews its lease
+			if lease == nil {
+				set, err := txRepo.StorageDealRepo().CASPieceStatus(ctx, miner, dealID, types.Undefine, types.Assigned)
+				if err != nil {
+					return xerrors.Errorf("failed to update deal %d piece status for miner %s: %w", dealID, miner.String(), err)
+				}
+				if !set {
+					return errDealTaken
+				}
+			}
+			if err := txRepo.DealLeaseRepo().SaveDealLease(ctx, ps.newLease(sealer, miner, dealID, md.Proposal.StartEpoch)); err != nil {
+				return xerrors.Errorf("failed to save lease of deal %d for miner %s: %w", dealID, miner.String(), err)
+			}
+			return nil
+		})
+		if errors.Is(err, errDealTaken) {
+			lost = append(lost, dealID)
+			continue
+		}
+		if err != nil {
+			return err
 		}
 	}
+	if len(lost) > 0 {
+		return xerrors.Errorf("deals %v of miner %s are taken, they are not assigned to %q", lost, miner, sealer)
+	}
 
 	return nil
 }
 
-//
-func (ps *dealAssigner) UpdateDealOnPacking(ctx context.Context, miner address.Address, dealID abi.DealID, sectorID abi.SectorNumber, offset abi.PaddedPieceSize) error {
-	md, err := ps.repo.StorageDealRepo().GetDealByDealID(ctx, miner, dealID)
-	if err != nil {
-		log.Error("get deal [%d] error for %s", dealID, miner)
-		return xerrors.Errorf("failed to get deal %d for miner %s: %w", dealID, miner.String(), err)
-	}
-
-	md.PieceStatus = types.Assigned
-	md.Offset = offset
-	md.SectorNumber = sectorID
-	if err := ps.repo.StorageDealRepo().SaveDeal(ctx, md); err != nil {
-		return xerrors.Errorf("failed to update deal %d piece status for miner %s: %w", dealID, miner.String(), err)
-	}
+// UpdateDealOnPacking records the sector of a deal, the sealer must hold the lease of the deal or, when the deal
+// has no lease, the deal must still be assigned
+func (ps *dealAssigner) UpdateDealOnPacking(ctx context.Context, miner address.Address, sealer string, dealID abi.DealID, sectorID abi.SectorNumber, offset abi.PaddedPieceSize) error {
+	return ps.repo.Transaction(func(txRepo repo.TxRepo) error {
+		md, err := txRepo.StorageDealRepo().GetDealByDealID(ctx, miner, dealID)
+		if err != nil {
+			log.Error("get deal [%d] error for %s", dealID, miner)
+			return xerrors.Errorf("failed to get deal %d for miner %s: %w", dealID, miner.String(), err)
+		}
+		lease, err := checkLeaseOwner(ctx, txRepo, miner, sealer, dealID)
+		if err != nil {
+			return err
+		}
+		if lease == nil && md.PieceStatus != types.Assigned {
+			return xerrors.Errorf("deal %d of miner %s is not leased, its piece status is %s", dealID, miner, md.PieceStatus)
+		}
 
-	return nil
+		md.PieceStatus = types.Assigned
+		md.Offset = offset
+		md.SectorNumber = sectorID
+		if err := txRepo.StorageDealRepo().SaveDeal(ctx, md); err != nil {
+			return xerrors.Errorf("failed to update deal %d piece status for miner %s: %w", dealID, miner.String(), err)
+		}
+		// the deal is in a sector, it can not be abandoned anymore
+		if err := txRepo.DealLeaseRepo().RemoveDealLease(ctx, miner, dealID); err != nil {
+			return xerrors.Errorf("failed to remove lease of deal %d for miner %s: %w", dealID, miner.String(), err)
+		}
+		return nil
+	})
 }
 
-// Store `dealInfo` in the dealAssigner with key `pieceCID`.
+// UpdateDealStatus sets the piece status of the deal, it fails when an assignment changed it meanwhile
 func (ps *dealAssigner) UpdateDealStatus(ctx context.Context, miner address.Address, dealID abi.DealID, pieceStatus string) error {
-	md, err := ps.repo.StorageDealRepo().GetDealByDealID(ctx, miner, dealID)
-	if err != nil {
-		log.Error("get deal [%d] error for %s", dealID, miner)
-		return xerrors.Errorf("failed to get deal %d for miner %s: %w", dealID, miner.String(), err)
-	}
-
-	md.PieceStatus = pieceStatus
-	if err := ps.repo.StorageDealRepo().SaveDeal(ctx, md); err != nil {
-		return xerrors.Errorf("failed to update deal %d piece status for miner %s: %w", dealID, miner.String(), err)
-	}
+	return ps.repo.Transaction(func(txRepo repo.TxRepo) error {
+		md, err := txRepo.StorageDealRepo().GetDealByDealID(ctx, miner, dealID)
+		if err != nil {
+			log.Error("get deal [%d] error for %s", dealID, miner)
+			return xerrors.Errorf("failed to get deal %d for miner %s: %w", dealID, miner.String(), err)
+		}
 
-	return nil
+		set, err := txRepo.StorageDealRepo().CASPieceStatus(ctx, miner, dealID, md.PieceStatus, pieceStatus)
+		if err != nil {
+			return xerrors.Errorf("failed to update deal %d piece status for miner %s: %w", dealID, miner.String(), err)
+		}
+		if !set {
+			return xerrors.Errorf("piece status of deal %d for miner %s changed meanwhile: %w", dealID, miner.String(), errDealTaken)
+		}
+		if pieceStatus != types.Assigned {
+			if err := txRepo.DealLeaseRepo().RemoveDealLease(ctx, miner, dealID); err != nil {
+				return xerrors.Errorf("failed to remove lease of deal %d for miner %s: %w", dealID, miner.String(), err)
+			}
+		}
+		return nil
+	})
 }
 
 func (ps *dealAssigner) GetDeals(ctx context.Context, mAddr address.Address, pageIndex, pageSize int) ([]*types.DealInfo, error) {
@@ -144,6 +257,16 @@ var defaultGetDealSpec = &types.GetDealSpec{
 }
 
 func (ps *dealAssigner) GetUnPackedDeals(ctx context.Context, miner address.Address, spec *types.GetDealSpec) ([]*types.DealInfoIncludePath, error) {
+	env, err := ps.packingEnv(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ps.unPackedDeals(ctx, ps.repo.StorageDealRepo(), env, miner, spec)
+}
+
+// unPackedDeals returns the deals waiting to be assigned, the critical ones first so that the limits of the spec
+// never leave them out
+func (ps *dealAssigner) unPackedDeals(ctx context.Context, dealRepo repo.StorageDealRepo, env PackingEnv, miner address.Address, spec *types.GetDealSpec) ([]*types.DealInfoIncludePath, error) {
 	if spec == nil {
 		spec = defaultGetDealSpec
 	}
@@ -151,10 +274,20 @@ func (ps *dealAssigner) GetUnPackedDeals(ctx context.Context, miner address.Addr
 		spec.MaxPiece = defaultMaxPiece
 	}
 
-	mds, err := ps.repo.StorageDealRepo().GetDealsByPieceStatus(ctx, miner, types.Undefine)
+	mds, err := dealRepo.GetDealsByPieceStatus(ctx, miner, types.Undefine)
 	if err != nil {
 		return nil, err
 	}
+	critical := func(md *types.MinerDeal) bool {
+		return dealRiskLevel(ps.riskCfg, dealSlack(md.Proposal.StartEpoch, env.Head, env.SealEpochs)) == mtypes.DealRiskCritical
+	}
+	sort.SliceStable(mds, func(i, j int) bool {
+		ci, cj := critical(mds[i]), critical(mds[j])
+		if ci && cj {
+			return mds[i].Proposal.StartEpoch < mds[j].Proposal.StartEpoch
+		}
+		return ci && !cj
+	})
 
 	var (
 		result       []*types.DealInfoIncludePath
@@ -187,58 +320,118 @@ func (ps *dealAssigner) GetUnPackedDeals(ctx context.Context, miner address.Addr
 	return result, nil
 }
 
-func (ps *dealAssigner) AssignUnPackedDeals(ctx context.Context, miner address.Address, ssize abi.SectorSize, spec *types.GetDealSpec) ([]*types.DealInfoIncludePath, error) {
-	deals, err := ps.GetUnPackedDeals(ctx, miner, &types.GetDealSpec{MaxPiece: math.MaxInt32}) //TODO get all pending deals ???
+// errDealTaken rolls back an assignment that lost a deal to a concurrent one
+var errDealTaken = xerrors.New("deal assigned concurrently")
+
+func (ps *dealAssigner) AssignUnPackedDeals(ctx context.Context, miner address.Address, sealer string, ssize abi.SectorSize, spec *types.GetDealSpec) ([]*types.DealInfoIncludePath, error) {
+	strategy, err := ps.packingStrategy(miner)
 	if err != nil {
 		return nil, err
 	}
-
-	if len(deals) == 0 {
-		return nil, nil
+	env, err := ps.packingEnv(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	// 按照尺寸, 时间, 价格排序
-	sort.Slice(deals, func(i, j int) bool {
-		left, right := deals[i], deals[j]
-		if left.PieceSize != right.PieceSize {
-			return left.PieceSize < right.PieceSize
+	// a batch losing a deal is packed again, the assignment that took the deal made progress, so the retries are
+	// bounded by the number of deals
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
 		}
 
-		if left.StartEpoch != right.StartEpoch {
-			return left.StartEpoch < right.StartEpoch
-		}
+		var pieces []*types.DealInfoIncludePath
+		err := ps.repo.Transaction(func(txRepo repo.TxRepo) error {
+			pieces = nil
+			deals, err := ps.unPackedDeals(ctx, txRepo.StorageDealRepo(), env, miner, &types.GetDealSpec{MaxPiece: math.MaxInt32}) //TODO get all pending deals ???
+			if err != nil {
+				return err
+			}
+			if len(deals) == 0 {
+				return nil
+			}
 
-		return left.StoragePricePerEpoch.GreaterThan(right.StoragePricePerEpoch)
-	})
+			picked, err := strategy.Pack(env, deals, ssize, spec)
+			if err != nil {
+				return fmt.Errorf("unable to pick and align pieces from deals: %w", err)
+			}
 
-	pieces, err := pickAndAlign(deals, ssize, spec)
-	if err != nil {
-		return nil, fmt.Errorf("unable to pick and align pieces from deals: %w", err)
+			// the rows are locked in the same order by all the assignments so that they can not deadlock
+			dealPieces := make([]*types.DealInfoIncludePath, 0, len(picked))
+			for _, piece := range picked {
+				// fillers are not deals
+				if piece.DealID != 0 {
+					dealPieces = append(dealPieces, piece)
+				}
+			}
+			sort.Slice(dealPieces, func(i, j int) bool {
+				return dealPieces[i].DealID < dealPieces[j].DealID
+			})
+			assigned := make([]abi.DealID, 0, len(dealPieces))
+			for _, piece := range dealPieces {
+				set, err := txRepo.StorageDealRepo().CASPieceStatus(ctx, miner, piece.DealID, types.Undefine, types.Assigned)
+				if err == nil && !set {
+					err = errDealTaken
+				}
+				if err == nil {
+					assigned = append(assigned, piece.DealID)
+					err = txRepo.DealLeaseRepo().SaveDealLease(ctx, ps.newLease(sealer, miner, piece.DealID, piece.StartEpoch))
+				}
+				if err != nil {
+					undoAssignment(ctx, txRepo, miner, assigned)
+					return err
+				}
+			}
+			pieces = picked
+			return nil
+		})
+		if errors.Is(err, errDealTaken) {
+			// the deals assigned meanwhile are not candidates anymore, the batch is packed again without them
+			log.Debugf("deal of miner %s assigned concurrently, packing again", miner)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return pieces, nil
 	}
+}
 
-	if len(pieces) == 0 {
-		return nil, nil
+// undoAssignment returns the deals an aborted batch assigned to the deals waiting to be assigned. The badger repo
+// does not roll its transactions back, without it the deals would stay assigned to a sealer never told about them
+func undoAssignment(ctx context.Context, txRepo repo.TxRepo, miner address.Address, dealIDs []abi.DealID) {
+	for _, dealID := range dealIDs {
+		if err := txRepo.DealLeaseRepo().RemoveDealLease(ctx, miner, dealID); err != nil {
+			log.Errorf("remove lease of deal %d for miner %s of an aborted assignment: %v", dealID, miner, err)
+		}
+		if _, err := txRepo.StorageDealRepo().CASPieceStatus(ctx, miner, dealID, types.Assigned, types.Undefine); err != nil {
+			log.Errorf("return deal %d of miner %s of an aborted assignment: %v", dealID, miner, err)
+		}
 	}
+}
 
-	// TODO: is this concurrent safe?
-	if err := ps.repo.Transaction(func(txRepo repo.TxRepo) error {
-		for _, piece := range pieces {
-			md, err := txRepo.StorageDealRepo().GetDealByDealID(ctx, miner, piece.DealID)
-			if err != nil {
-				return err
-			}
-
-			md.PieceStatus = types.Assigned
-			if err := txRepo.StorageDealRepo().SaveDeal(ctx, md); err != nil {
-				return err
-			}
+// packingStrategy returns the strategy configured for the miner, or the default one
+func (ps *dealAssigner) packingStrategy(miner address.Address) (PackingStrategy, error) {
+	name := ps.packingCfg.Strategy
+	for _, override := range ps.packingCfg.Miners {
+		if address.Address(override.Miner) == miner {
+			name = override.Strategy
+			break
 		}
-		return nil
-	}); err != nil {
-		return nil, err
 	}
+	return packingStrategyByName(name)
+}
 
-	return pieces, nil
+func (ps *dealAssigner) packingEnv(ctx context.Context) (PackingEnv, error) {
+	head, err := ps.head(ctx)
+	if err != nil {
+		return PackingEnv{}, xerrors.Errorf("failed to get chain head: %w", err)
+	}
+	sealDuration, err := ps.sealDuration()
+	if err != nil {
+		return PackingEnv{}, xerrors.Errorf("failed to get expected seal duration: %w", err)
+	}
+	return PackingEnv{Head: head, SealEpochs: durationToEpochs(sealDuration)}, nil
 }
 
 type CombinedPieces struct {
@@ -0,0 +1,204 @@
+package storageprovider
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/crypto"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/market"
+	blocksutil "github.com/ipfs/go-ipfs-blocksutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/venus-market/config"
+	"github.com/filecoin-project/venus-market/models"
+	"github.com/filecoin-project/venus-market/models/badger"
+	"github.com/filecoin-project/venus-market/models/repo"
+	"github.com/filecoin-project/venus-market/rpc"
+
+	types "github.com/filecoin-project/venus/venus-shared/types/market"
+)
+
+// newTestingMinerDeal returns a published deal waiting to be assigned
+func newTestingMinerDeal(bgen *blocksutil.BlockGenerator, miner address.Address, dealID abi.DealID, size abi.PaddedPieceSize, start abi.ChainEpoch) *types.MinerDeal {
+	pieceCid := bgen.Next().Cid()
+	return &types.MinerDeal{
+		ClientDealProposal: market.ClientDealProposal{
+			Proposal: market.DealProposal{
+				PieceCID:             pieceCid,
+				PieceSize:            size,
+				Client:               miner,
+				Provider:             miner,
+				StartEpoch:           start,
+				EndEpoch:             start + 1000,
+				StoragePricePerEpoch: abi.NewTokenAmount(1),
+				ProviderCollateral:   abi.NewTokenAmount(0),
+				ClientCollateral:     abi.NewTokenAmount(0),
+			},
+			ClientSignature: crypto.Signature{Type: crypto.SigTypeBLS, Data: []byte("bls")},
+		},
+		ProposalCid: bgen.Next().Cid(),
+		PublishCid:  &pieceCid,
+		DealID:      dealID,
+		PieceStatus: types.Undefine,
+	}
+}
+
+func TestDealAssignConcurrently(t *testing.T) {
+	t.Run("mysql", func(t *testing.T) {
+		r := models.MysqlDB(t)
+		defer func() { require.NoError(t, r.Close()) }()
+		testDealAssignConcurrently(t, r)
+	})
+	t.Run("badger", func(t *testing.T) {
+		testDealAssignConcurrently(t, badger.NewBadgerRepo(badger.BadgerDSParams{
+			StorageDealsDS: models.BadgerDB(t),
+			DealLeaseDS:    models.BadgerDB(t),
+		}))
+	})
+}
+
+func testDealAssignConcurrently(t *testing.T, r repo.Repo) {
+	const (
+		dealCount = 64
+		sealers   = 8
+	)
+	ctx := context.Background()
+	bgen := blocksutil.NewBlockGenerator()
+	// a fresh miner keeps the deals of the other runs of the shared mysql database out
+	miner, _ := address.NewIDAddress(uint64(time.Now().UnixNano() & 0xffffffff))
+
+	for dealID := abi.DealID(1); dealID <= dealCount; dealID++ {
+		require.NoError(t, r.StorageDealRepo().SaveDeal(ctx, newTestingMinerDeal(bgen, miner, dealID, 512, 100_000)))
+	}
+
+	ps, err := newPieceStoreEx(&config.PieceStorage{}, r)
+	require.NoError(t, err)
+	ps.head = func(context.Context) (abi.ChainEpoch, error) { return 1000, nil }
+	ps.sealDuration = func() (time.Duration, error) { return time.Hour, nil }
+
+	// all the sealers share the token of one account, the leases are keyed on their sealer id
+	pool := rpc.WithAccount(ctx, "pool")
+	var (
+		wg       sync.WaitGroup
+		lk       sync.Mutex
+		assigned = make(map[abi.DealID]string)
+	)
+	for i := 0; i < sealers; i++ {
+		wg.Add(1)
+		go func(sealer string) {
+			defer wg.Done()
+			for {
+				// 4 deals of 512 fill a 2KiB sector
+				pieces, err := ps.AssignUnPackedDeals(pool, miner, sealer, abi.SectorSize(2<<10), nil)
+				if err != nil {
+					// require can not stop the test from another goroutine
+					t.Errorf("assign deals: %v", err)
+					return
+				}
+				if len(pieces) == 0 {
+					return
+				}
+				lk.Lock()
+				for _, piece := range pieces {
+					if piece.DealID == 0 {
+						continue
+					}
+					if owner, ok := assigned[piece.DealID]; ok {
+						t.Errorf("deal %d assigned to %s and %s", piece.DealID, owner, sealer)
+					}
+					assigned[piece.DealID] = sealer
+				}
+				lk.Unlock()
+			}
+		}(fmt.Sprintf("sealer-%d", i))
+	}
+	wg.Wait()
+
+	require.Len(t, assigned, dealCount)
+	deals, err := r.StorageDealRepo().GetDealsByPieceStatus(ctx, miner, types.Undefine)
+	require.NoError(t, err)
+	require.Empty(t, deals)
+	leases, err := r.DealLeaseRepo().ListDealLeases(ctx, miner)
+	require.NoError(t, err)
+	require.Len(t, leases, dealCount)
+	for _, lease := range leases {
+		require.Equal(t, assigned[lease.DealID], lease.Owner)
+	}
+}
+
+// takingRepo has the deals of taken assigned by someone else once, when the assignment reaches them
+type takingRepo struct {
+	repo.Repo
+	taken map[abi.DealID]bool
+}
+
+func (r *takingRepo) Transaction(cb func(txRepo repo.TxRepo) error) error {
+	return r.Repo.Transaction(func(txRepo repo.TxRepo) error {
+		return cb(&takingTxRepo{TxRepo: txRepo, r: r})
+	})
+}
+
+type takingTxRepo struct {
+	repo.TxRepo
+	r *takingRepo
+}
+
+func (tx *takingTxRepo) StorageDealRepo() repo.StorageDealRepo {
+	return &takingDealRepo{StorageDealRepo: tx.TxRepo.StorageDealRepo(), r: tx.r}
+}
+
+type takingDealRepo struct {
+	repo.StorageDealRepo
+	r *takingRepo
+}
+
+func (d *takingDealRepo) CASPieceStatus(ctx context.Context, mAddr address.Address, dealID abi.DealID, from, to string) (bool, error) {
+	if d.r.taken[dealID] && to == types.Assigned {
+		delete(d.r.taken, dealID)
+		return false, nil
+	}
+	return d.StorageDealRepo.CASPieceStatus(ctx, mAddr, dealID, from, to)
+}
+
+func TestDealAssignAborted(t *testing.T) {
+	ctx := context.Background()
+	bgen := blocksutil.NewBlockGenerator()
+	miner, _ := address.NewIDAddress(1000)
+
+	r := &takingRepo{
+		Repo: badger.NewBadgerRepo(badger.BadgerDSParams{
+			StorageDealsDS: models.BadgerDB(t),
+			DealLeaseDS:    models.BadgerDB(t),
+		}),
+		taken: map[abi.DealID]bool{3: true},
+	}
+	for dealID := abi.DealID(1); dealID <= 4; dealID++ {
+		require.NoError(t, r.StorageDealRepo().SaveDeal(ctx, newTestingMinerDeal(bgen, miner, dealID, 512, 100_000)))
+	}
+
+	ps, err := newPieceStoreEx(&config.PieceStorage{}, r)
+	require.NoError(t, err)
+	ps.head = func(context.Context) (abi.ChainEpoch, error) { return 1000, nil }
+	ps.sealDuration = func() (time.Duration, error) { return time.Hour, nil }
+
+	// the batch is aborted on deal 3, deals 1 and 2 it assigned already are packed again rather than kept leased
+	pieces, err := ps.AssignUnPackedDeals(ctx, miner, "sealer", abi.SectorSize(2<<10), nil)
+	require.NoError(t, err)
+	require.Len(t, pieces, 4)
+	leases, err := r.DealLeaseRepo().ListDealLeases(ctx, miner)
+	require.NoError(t, err)
+	require.Len(t, leases, 4)
+
+	// the piece status is set in a transaction, the lease goes with the assignment
+	require.NoError(t, ps.UpdateDealStatus(ctx, miner, 1, types.Undefine))
+	md, err := r.StorageDealRepo().GetDealByDealID(ctx, miner, 1)
+	require.NoError(t, err)
+	require.Equal(t, types.Undefine, md.PieceStatus)
+	_, err = r.DealLeaseRepo().GetDealLease(ctx, miner, 1)
+	require.ErrorIs(t, err, repo.ErrNotFound)
+}
@@ -63,6 +63,7 @@ type StorageDealProcessImpl struct {
 
 	minerMgr     minermgr2.IAddrMgr
 	pieceStorage piecestorage.IPieceStorage
+	quota        *ClientQuota
 }
 
 // NewStorageDealProcessImpl returns a new deal process instance
@@ -78,6 +79,7 @@ func NewStorageDealProcessImpl(
 	pieceStorage piecestorage.IPieceStorage,
 	dataTransfer network2.ProviderDataTransfer,
 	dagStore stores.DAGStoreWrapper,
+	quota *ClientQuota,
 ) (StorageDealHandler, error) {
 	stores := stores.NewReadWriteBlockstores()
 
@@ -106,6 +108,7 @@ func NewStorageDealProcessImpl(
 
 		cidInfoRepo: repo.CidInfoRepo(),
 		dagStore:    dagStore,
+		quota:       quota,
 	}, nil
 }
 
@@ -128,6 +131,15 @@ func (storageDealPorcess *StorageDealProcessImpl) AcceptDeal(ctx context.Context
 	if !storageDealPorcess.minerMgr.Has(ctx, proposal.Provider) {
 		return storageDealPorcess.HandleReject(ctx, minerDeal, storagemarket.StorageDealRejecting, xerrors.Errorf("incorrect provider for deal"))
 	}
+	// the deals accepted before the miner was disabled go on
+	if storageDealPorcess.minerMgr.IsDisabled(ctx, proposal.Provider) {
+		return storageDealPorcess.HandleReject(ctx, minerDeal, storagemarket.StorageDealRejecting, xerrors.Errorf("provider %s is disabled and takes no new deals", proposal.Provider))
+	}
+
+	// the client is known from the signature verified above
+	if err := storageDealPorcess.quota.Check(ctx, minerDeal); err != nil {
+		return storageDealPorcess.HandleReject(ctx, minerDeal, storagemarket.StorageDealRejecting, err)
+	}
 
 	if len(proposal.Label) > DealMaxLabelSize {
 		return storageDealPorcess.HandleReject(ctx, minerDeal, storagemarket.StorageDealRejecting, xerrors.Errorf("deal label can be at most %d bytes, is %d", DealMaxLabelSize, len(proposal.Label)))
@@ -0,0 +1,199 @@
+package storageprovider
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/venus-market/models/repo"
+	mtypes "github.com/filecoin-project/venus-market/types"
+
+	types "github.com/filecoin-project/venus/venus-shared/types/market"
+)
+
+// newLease leases the deal to the sealer
+func (ps *dealAssigner) newLease(sealer string, miner address.Address, dealID abi.DealID, startEpoch abi.ChainEpoch) *mtypes.DealLease {
+	now := ps.now()
+	return &mtypes.DealLease{
+		Miner:      miner,
+		DealID:     dealID,
+		Owner:      sealer,
+		AssignedAt: now,
+		Expiry:     ps.leaseExpiry(now),
+		StartEpoch: startEpoch,
+	}
+}
+
+// leaseExpiry is zero when the leases do not expire
+func (ps *dealAssigner) leaseExpiry(now time.Time) time.Time {
+	if ps.leaseCfg.Duration <= 0 {
+		return time.Time{}
+	}
+	return now.Add(time.Duration(ps.leaseCfg.Duration))
+}
+
+func (ps *dealAssigner) RenewDealLeases(ctx context.Context, miner address.Address, sealer string, dealIDs []abi.DealID) error {
+	var lost []abi.DealID
+	for _, dealID := range dealIDs {
+		var renewed bool
+		err := ps.repo.Transaction(func(txRepo repo.TxRepo) error {
+			var err error
+			renewed, err = txRepo.DealLeaseRepo().RenewDealLease(ctx, miner, dealID, sealer, ps.leaseExpiry(ps.now()))
+			return err
+		})
+		if err != nil {
+			return xerrors.Errorf("failed to renew lease of deal %d for miner %s: %w", dealID, miner, err)
+		}
+		if !renewed {
+			lost = append(lost, dealID)
+		}
+	}
+	if len(lost) > 0 {
+		return xerrors.Errorf("deals %v of miner %s are not leased by %q anymore", lost, miner, sealer)
+	}
+	return nil
+}
+
+// checkLeaseOwner fails when the deal is leased to another sealer
+func checkLeaseOwner(ctx context.Context, txRepo repo.TxRepo, miner address.Address, sealer string, dealID abi.DealID) (*mtypes.DealLease, error) {
+	lease, err := txRepo.DealLeaseRepo().GetDealLease(ctx, miner, dealID)
+	if err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, xerrors.Errorf("failed to get lease of deal %d for miner %s: %w", dealID, miner, err)
+	}
+	if lease.Owner != sealer {
+		return nil, xerrors.Errorf("deal %d of miner %s is leased by %q, not %q: %w", dealID, miner, lease.Owner, sealer, errDealTaken)
+	}
+	return lease, nil
+}
+
+func (ps *dealAssigner) ListDealLeases(ctx context.Context, miner address.Address) ([]*mtypes.DealLease, error) {
+	leases, err := ps.repo.DealLeaseRepo().ListDealLeases(ctx, miner)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to list deal leases: %w", err)
+	}
+	if len(leases) == 0 {
+		return leases, nil
+	}
+
+	deadline, err := ps.atRiskDeadline(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, lease := range leases {
+		lease.AtRisk = lease.StartEpoch <= deadline
+	}
+	return leases, nil
+}
+
+// atRiskDeadline is the epoch a deal starting before can not be sealed in time anymore
+func (ps *dealAssigner) atRiskDeadline(ctx context.Context) (abi.ChainEpoch, error) {
+	env, err := ps.packingEnv(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return env.Head + env.SealEpochs, nil
+}
+
+func (ps *dealAssigner) ReleaseDealLeases(ctx context.Context, miner address.Address, dealIDs []abi.DealID) error {
+	for _, dealID := range dealIDs {
+		if err := ps.releaseLease(ctx, miner, dealID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseLease drops the lease whoever holds it and returns the deal to `Undefine` unless it moved on since it was
+// assigned
+func (ps *dealAssigner) releaseLease(ctx context.Context, miner address.Address, dealID abi.DealID) error {
+	return ps.repo.Transaction(func(txRepo repo.TxRepo) error {
+		if err := txRepo.DealLeaseRepo().RemoveDealLease(ctx, miner, dealID); err != nil {
+			return xerrors.Errorf("failed to remove lease of deal %d for miner %s: %w", dealID, miner, err)
+		}
+		if _, err := txRepo.StorageDealRepo().CASPieceStatus(ctx, miner, dealID, types.Assigned, types.Undefine); err != nil {
+			return xerrors.Errorf("failed to update deal %d piece status for miner %s: %w", dealID, miner, err)
+		}
+		return nil
+	})
+}
+
+// releaseExpiredLease releases the lease like releaseLease only when it is still the expired lease of its owner,
+// it reports false when the lease was renewed or released meanwhile
+func (ps *dealAssigner) releaseExpiredLease(ctx context.Context, lease *mtypes.DealLease, now time.Time) (bool, error) {
+	var released bool
+	err := ps.repo.Transaction(func(txRepo repo.TxRepo) error {
+		var err error
+		released, err = txRepo.DealLeaseRepo().RemoveExpiredDealLease(ctx, lease.Miner, lease.DealID, lease.Owner, now)
+		if err != nil {
+			return xerrors.Errorf("failed to remove lease of deal %d for miner %s: %w", lease.DealID, lease.Miner, err)
+		}
+		if !released {
+			return nil
+		}
+		if _, err := txRepo.StorageDealRepo().CASPieceStatus(ctx, lease.Miner, lease.DealID, types.Assigned, types.Undefine); err != nil {
+			return xerrors.Errorf("failed to update deal %d piece status for miner %s: %w", lease.DealID, lease.Miner, err)
+		}
+		return nil
+	})
+	return released, err
+}
+
+func (ps *dealAssigner) reapLeases(ctx context.Context) {
+	interval := time.Duration(ps.leaseCfg.ReapInterval)
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := ps.reap(ctx); err != nil {
+				log.Errorf("reap deal leases: %v", err)
+			}
+		case <-ctx.Done():
+			log.Warnf("exit deal lease reaper by context")
+			return
+		}
+	}
+}
+
+// reap releases the expired leases and warns about the leased deals that are at risk to miss their start epoch
+func (ps *dealAssigner) reap(ctx context.Context) error {
+	leases, err := ps.ListDealLeases(ctx, address.Undef)
+	if err != nil {
+		return err
+	}
+	now := ps.now()
+	for _, lease := range leases {
+		if !lease.Expiry.IsZero() && lease.Expiry.Before(now) {
+			released, err := ps.releaseExpiredLease(ctx, lease, now)
+			if err != nil {
+				log.Errorf("release expired lease of deal %d for miner %s: %v", lease.DealID, lease.Miner, err)
+				continue
+			}
+			if !released {
+				log.Debugw("deal lease was renewed or released meanwhile", "miner", lease.Miner, "deal", lease.DealID,
+					"owner", lease.Owner)
+				continue
+			}
+			log.Warnw("released expired deal lease", "miner", lease.Miner, "deal", lease.DealID, "owner", lease.Owner,
+				"expiry", lease.Expiry, "at_risk", lease.AtRisk)
+			continue
+		}
+		if lease.AtRisk {
+			log.Warnw("leased deal is at risk to miss its start epoch", "miner", lease.Miner, "deal", lease.DealID,
+				"owner", lease.Owner, "start_epoch", lease.StartEpoch)
+		}
+	}
+	return nil
+}
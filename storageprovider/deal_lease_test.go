@@ -0,0 +1,111 @@
+package storageprovider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	blocksutil "github.com/ipfs/go-ipfs-blocksutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/venus-market/config"
+	"github.com/filecoin-project/venus-market/models"
+	"github.com/filecoin-project/venus-market/models/badger"
+	"github.com/filecoin-project/venus-market/models/repo"
+	"github.com/filecoin-project/venus-market/rpc"
+
+	"github.com/filecoin-project/venus/pkg/constants"
+	types "github.com/filecoin-project/venus/venus-shared/types/market"
+)
+
+func TestDealLease(t *testing.T) {
+	ctx := context.Background()
+	bgen := blocksutil.NewBlockGenerator()
+	miner, _ := address.NewIDAddress(1000)
+	head := abi.ChainEpoch(10_000)
+	sealEpochs := abi.ChainEpoch(24 * time.Hour / (time.Duration(constants.MainNetBlockDelaySecs) * time.Second))
+
+	r := badger.NewBadgerRepo(badger.BadgerDSParams{
+		StorageDealsDS: models.BadgerDB(t),
+		DealLeaseDS:    models.BadgerDB(t),
+	})
+	for dealID, start := range map[abi.DealID]abi.ChainEpoch{1: head + 2*sealEpochs, 2: head + sealEpochs/2, 3: head + 2*sealEpochs} {
+		require.NoError(t, r.StorageDealRepo().SaveDeal(ctx, newTestingMinerDeal(bgen, miner, dealID, 2048, start)))
+	}
+
+	ps, err := newPieceStoreEx(&config.PieceStorage{}, r)
+	require.NoError(t, err)
+	now := time.Now()
+	ps.now = func() time.Time { return now }
+	ps.leaseCfg = config.DealLease{Duration: config.Duration(time.Minute)}
+	ps.head = func(context.Context) (abi.ChainEpoch, error) { return head, nil }
+	ps.sealDuration = func() (time.Duration, error) { return 24 * time.Hour, nil }
+
+	// the sealers of an account share its token, the leases tell them apart by their sealer id
+	pool := rpc.WithAccount(ctx, "pool")
+	const sealerA, sealerB = "sealer-a", "sealer-b"
+	require.NoError(t, ps.MarkDealsAsPacking(pool, miner, sealerA, []abi.DealID{1, 2}))
+	require.NoError(t, ps.MarkDealsAsPacking(pool, miner, sealerB, []abi.DealID{3}))
+
+	leases, err := ps.ListDealLeases(ctx, miner)
+	require.NoError(t, err)
+	require.Len(t, leases, 3)
+	require.Equal(t, "sealer-a", leases[0].Owner)
+	require.Equal(t, now.Add(time.Minute).Unix(), leases[0].Expiry.Unix())
+	require.False(t, leases[0].AtRisk)
+	require.True(t, leases[1].AtRisk)
+	stale := leases[0]
+
+	// a sealer can not take or pack the deals leased by another one
+	require.Error(t, ps.MarkDealsAsPacking(pool, miner, sealerB, []abi.DealID{1}))
+	require.Error(t, ps.UpdateDealOnPacking(pool, miner, sealerB, 1, 100, 0))
+
+	// a sealer can not renew the leases of another one
+	now = now.Add(40 * time.Second)
+	require.NoError(t, ps.RenewDealLeases(pool, miner, sealerA, []abi.DealID{1}))
+	err = ps.RenewDealLeases(pool, miner, sealerA, []abi.DealID{3})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "[3]")
+
+	// the reaper does not release a lease renewed after it listed the leases
+	released, err := ps.releaseExpiredLease(ctx, stale, now.Add(40*time.Second))
+	require.NoError(t, err)
+	require.False(t, released)
+
+	// deal 2 is packed, deal 3 is abandoned by its sealer
+	require.NoError(t, ps.UpdateDealOnPacking(pool, miner, sealerA, 2, 100, 0))
+	now = now.Add(40 * time.Second)
+	require.NoError(t, ps.reap(ctx))
+
+	leases, err = ps.ListDealLeases(ctx, address.Undef)
+	require.NoError(t, err)
+	require.Len(t, leases, 1)
+	require.Equal(t, abi.DealID(1), leases[0].DealID)
+	pieceStatus := func(dealID abi.DealID) string {
+		md, err := r.StorageDealRepo().GetDealByDealID(ctx, miner, dealID)
+		require.NoError(t, err)
+		return md.PieceStatus
+	}
+	require.Equal(t, types.Assigned, pieceStatus(1))
+	require.Equal(t, types.Assigned, pieceStatus(2))
+	require.Equal(t, types.Undefine, pieceStatus(3))
+	require.Error(t, ps.UpdateDealOnPacking(pool, miner, sealerB, 3, 100, 0))
+
+	// the operator takes the deal back from a live sealer
+	require.NoError(t, ps.ReleaseDealLeases(ctx, miner, []abi.DealID{1}))
+	require.Equal(t, types.Undefine, pieceStatus(1))
+	_, err = r.DealLeaseRepo().GetDealLease(ctx, miner, 1)
+	require.ErrorIs(t, err, repo.ErrNotFound)
+
+	// the deals assigned meanwhile are not taken and are returned in the error
+	err = ps.MarkDealsAsPacking(pool, miner, sealerB, []abi.DealID{1, 2})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "[2]")
+	require.Equal(t, types.Assigned, pieceStatus(1))
+	lease, err := r.DealLeaseRepo().GetDealLease(ctx, miner, 1)
+	require.NoError(t, err)
+	require.Equal(t, "sealer-b", lease.Owner)
+	require.NoError(t, ps.MarkDealsAsPacking(pool, miner, sealerB, []abi.DealID{1}))
+}
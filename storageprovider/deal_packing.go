@@ -0,0 +1,170 @@
+package storageprovider
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	mtypes "github.com/filecoin-project/venus/venus-shared/types/market"
+)
+
+const (
+	// PackingDefault orders the deals by size, start epoch and price and fills the sector from the smallest deal
+	PackingDefault = "default"
+	// PackingUrgencyFirst packs first the deals whose seal must begin the soonest to make their start epoch
+	PackingUrgencyFirst = "urgency-first"
+	// PackingBestFit fills the sector as much as possible, with the most valuable deals first among the same size
+	PackingBestFit = "best-fit"
+	// PackingVerifiedFirst packs the verified deals before the others
+	PackingVerifiedFirst = "verified-first"
+)
+
+// PackingEnv is what the strategies know of the chain when they pack
+type PackingEnv struct {
+	Head abi.ChainEpoch
+	// SealEpochs is the expected seal duration in epochs
+	SealEpochs abi.ChainEpoch
+}
+
+// PackingStrategy picks the deals to put into a sector among the deals waiting to be assigned.
+// The returned pieces are aligned, the space between the deals is taken by zeroed filler pieces
+type PackingStrategy interface {
+	Pack(env PackingEnv, deals []*mtypes.DealInfoIncludePath, ssize abi.SectorSize, spec *mtypes.GetDealSpec) ([]*mtypes.DealInfoIncludePath, error)
+}
+
+var packingStrategies = map[string]PackingStrategy{
+	PackingDefault:       defaultPacking{},
+	PackingUrgencyFirst:  urgencyFirstPacking{},
+	PackingBestFit:       bestFitPacking{},
+	PackingVerifiedFirst: verifiedFirstPacking{},
+}
+
+func packingStrategyByName(name string) (PackingStrategy, error) {
+	if name == "" {
+		name = PackingDefault
+	}
+	strategy, ok := packingStrategies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown packing strategy %q", name)
+	}
+	return strategy, nil
+}
+
+type defaultPacking struct{}
+
+func (defaultPacking) Pack(_ PackingEnv, deals []*mtypes.DealInfoIncludePath, ssize abi.SectorSize, spec *mtypes.GetDealSpec) ([]*mtypes.DealInfoIncludePath, error) {
+	// 按照尺寸, 时间, 价格排序
+	sort.Slice(deals, func(i, j int) bool {
+		left, right := deals[i], deals[j]
+		if left.PieceSize != right.PieceSize {
+			return left.PieceSize < right.PieceSize
+		}
+
+		if left.StartEpoch != right.StartEpoch {
+			return left.StartEpoch < right.StartEpoch
+		}
+
+		return left.StoragePricePerEpoch.GreaterThan(right.StoragePricePerEpoch)
+	})
+
+	return pickAndAlign(deals, ssize, spec)
+}
+
+type urgencyFirstPacking struct{}
+
+func (urgencyFirstPacking) Pack(env PackingEnv, deals []*mtypes.DealInfoIncludePath, ssize abi.SectorSize, spec *mtypes.GetDealSpec) ([]*mtypes.DealInfoIncludePath, error) {
+	// a deal already started can not be activated anymore, it would only waste space
+	live := make([]*mtypes.DealInfoIncludePath, 0, len(deals))
+	for _, deal := range deals {
+		if deal.StartEpoch > env.Head {
+			live = append(live, deal)
+		}
+	}
+
+	// the seal of a deal must begin by its start epoch minus the seal duration. The deals past that deadline are
+	// unlikely to make it, they come after the ones that still can so as not to take their space
+	late := func(deal *mtypes.DealInfoIncludePath) bool {
+		return deal.StartEpoch-env.SealEpochs < env.Head
+	}
+	sort.SliceStable(live, func(i, j int) bool {
+		left, right := live[i], live[j]
+		if late(left) != late(right) {
+			return !late(left)
+		}
+		if left.StartEpoch != right.StartEpoch {
+			return left.StartEpoch < right.StartEpoch
+		}
+		return left.StoragePricePerEpoch.GreaterThan(right.StoragePricePerEpoch)
+	})
+
+	return fillSector(live, ssize, spec)
+}
+
+type bestFitPacking struct{}
+
+func (bestFitPacking) Pack(_ PackingEnv, deals []*mtypes.DealInfoIncludePath, ssize abi.SectorSize, spec *mtypes.GetDealSpec) ([]*mtypes.DealInfoIncludePath, error) {
+	// the sizes are powers of two, taking the largest deals first that still fit leaves the least space unused
+	sort.SliceStable(deals, func(i, j int) bool {
+		left, right := deals[i], deals[j]
+		if left.PieceSize != right.PieceSize {
+			return left.PieceSize > right.PieceSize
+		}
+		if !left.TotalStorageFee.Equals(right.TotalStorageFee) {
+			return left.TotalStorageFee.GreaterThan(right.TotalStorageFee)
+		}
+		return left.StartEpoch < right.StartEpoch
+	})
+
+	return fillSector(deals, ssize, spec)
+}
+
+type verifiedFirstPacking struct{}
+
+func (verifiedFirstPacking) Pack(_ PackingEnv, deals []*mtypes.DealInfoIncludePath, ssize abi.SectorSize, spec *mtypes.GetDealSpec) ([]*mtypes.DealInfoIncludePath, error) {
+	sort.SliceStable(deals, func(i, j int) bool {
+		left, right := deals[i], deals[j]
+		if left.VerifiedDeal != right.VerifiedDeal {
+			return left.VerifiedDeal
+		}
+		if left.StartEpoch != right.StartEpoch {
+			return left.StartEpoch < right.StartEpoch
+		}
+		return left.PieceSize < right.PieceSize
+	})
+
+	return fillSector(deals, ssize, spec)
+}
+
+// fillSector takes the deals in the given order as long as they fit into the sector and the limits of the spec,
+// a deal too large for the space left is skipped for the next ones. The picked deals are then aligned
+func fillSector(deals []*mtypes.DealInfoIncludePath, ssize abi.SectorSize, spec *mtypes.GetDealSpec) ([]*mtypes.DealInfoIncludePath, error) {
+	space := abi.PaddedPieceSize(ssize)
+	if err := space.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %d", errInvalidSpaceSize, space)
+	}
+
+	picked := make([]*mtypes.DealInfoIncludePath, 0)
+	for _, deal := range deals {
+		if spec != nil && spec.MaxPiece > 0 && len(picked) >= spec.MaxPiece {
+			break
+		}
+		if err := deal.PieceSize.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: deal %d size: %d", errInvalidDealPieceSize, deal.DealID, deal.PieceSize)
+		}
+		if spec != nil && spec.MaxPieceSize > 0 && uint64(deal.PieceSize) > spec.MaxPieceSize {
+			continue
+		}
+		if deal.PieceSize > space {
+			continue
+		}
+		picked = append(picked, deal)
+		space -= deal.PieceSize
+	}
+
+	// placed from the smallest, the fillers before a piece align it, and the pieces that fit the sector
+	// together always fit it aligned since their sizes are powers of two
+	sort.SliceStable(picked, func(i, j int) bool {
+		return picked[i].PieceSize < picked[j].PieceSize
+	})
+	return pickAndAlign(picked, ssize, nil)
+}
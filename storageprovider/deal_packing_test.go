@@ -0,0 +1,223 @@
+package storageprovider
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/venus/venus-shared/actors/builtin/market"
+	mtypes "github.com/filecoin-project/venus/venus-shared/types/market"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/venus-market/config"
+)
+
+type testingPackDeal struct {
+	id       abi.DealID
+	size     abi.PaddedPieceSize
+	start    abi.ChainEpoch
+	fee      int64
+	verified bool
+}
+
+func generatePackingDeals(deals []testingPackDeal) []*mtypes.DealInfoIncludePath {
+	res := make([]*mtypes.DealInfoIncludePath, len(deals))
+	for di, deal := range deals {
+		res[di] = &mtypes.DealInfoIncludePath{
+			DealID: deal.id,
+			DealProposal: market.DealProposal{
+				PieceSize:            deal.size,
+				StartEpoch:           deal.start,
+				VerifiedDeal:         deal.verified,
+				StoragePricePerEpoch: abi.NewTokenAmount(deal.fee),
+			},
+			TotalStorageFee: abi.NewTokenAmount(deal.fee),
+		}
+	}
+
+	return res
+}
+
+func TestDealPackingStrategies(t *testing.T) {
+	const SectorSize2K = abi.SectorSize(2 << 10)
+	env := PackingEnv{Head: 1000, SealEpochs: 100}
+
+	cases := []struct {
+		name              string
+		strategy          string
+		deals             []testingPackDeal
+		spec              *mtypes.GetDealSpec
+		expectedDealIDs   []abi.DealID
+		expectedPieceSize []abi.PaddedPieceSize
+		expectedErr       error
+	}{
+		{
+			name:     "default from the smallest",
+			strategy: PackingDefault,
+			deals: []testingPackDeal{
+				{id: 1, size: 512, start: 2000, fee: 1},
+				{id: 2, size: 128, start: 2000, fee: 1},
+			},
+			expectedDealIDs:   []abi.DealID{2, nonDeal, nonDeal, 1, nonDeal},
+			expectedPieceSize: []abi.PaddedPieceSize{128, 128, 256, 512, 1024},
+		},
+
+		{
+			name:     "urgency first",
+			strategy: PackingUrgencyFirst,
+			deals: []testingPackDeal{
+				{id: 1, size: 1024, start: 5000, fee: 1},
+				// must be sealed from 1050
+				{id: 2, size: 1024, start: 1150, fee: 1},
+				// late, sealing should have begun at 950
+				{id: 3, size: 1024, start: 1050, fee: 10},
+				// started already
+				{id: 4, size: 512, start: 900, fee: 10},
+			},
+			expectedDealIDs:   []abi.DealID{2, 1},
+			expectedPieceSize: []abi.PaddedPieceSize{1024, 1024},
+		},
+
+		{
+			name:     "urgency first late deals fill the space left",
+			strategy: PackingUrgencyFirst,
+			deals: []testingPackDeal{
+				{id: 1, size: 1024, start: 1050, fee: 1},
+				{id: 2, size: 512, start: 3000, fee: 1},
+				{id: 3, size: 512, start: 2000, fee: 1},
+			},
+			expectedDealIDs:   []abi.DealID{3, 2, 1},
+			expectedPieceSize: []abi.PaddedPieceSize{512, 512, 1024},
+		},
+
+		{
+			name:     "best fit fills the sector",
+			strategy: PackingBestFit,
+			deals: []testingPackDeal{
+				{id: 1, size: 128, start: 2000, fee: 1},
+				{id: 2, size: 256, start: 2000, fee: 1},
+				{id: 3, size: 512, start: 2000, fee: 10},
+				{id: 4, size: 512, start: 2000, fee: 20},
+				{id: 5, size: 1024, start: 2000, fee: 3},
+			},
+			expectedDealIDs:   []abi.DealID{4, 3, 5},
+			expectedPieceSize: []abi.PaddedPieceSize{512, 512, 1024},
+		},
+
+		{
+			name:     "best fit prefers value among the same size",
+			strategy: PackingBestFit,
+			deals: []testingPackDeal{
+				{id: 1, size: 1024, start: 2000, fee: 1},
+				{id: 2, size: 1024, start: 2000, fee: 30},
+				{id: 3, size: 1024, start: 2000, fee: 20},
+			},
+			expectedDealIDs:   []abi.DealID{2, 3},
+			expectedPieceSize: []abi.PaddedPieceSize{1024, 1024},
+		},
+
+		{
+			name:     "best fit deal limit",
+			strategy: PackingBestFit,
+			deals: []testingPackDeal{
+				{id: 1, size: 512, start: 2000, fee: 1},
+				{id: 2, size: 512, start: 2000, fee: 2},
+				{id: 3, size: 1024, start: 2000, fee: 1},
+			},
+			spec:              &mtypes.GetDealSpec{MaxPiece: 2},
+			expectedDealIDs:   []abi.DealID{2, nonDeal, 3},
+			expectedPieceSize: []abi.PaddedPieceSize{512, 512, 1024},
+		},
+
+		{
+			name:     "best fit deal size limit",
+			strategy: PackingBestFit,
+			deals: []testingPackDeal{
+				{id: 1, size: 256, start: 2000, fee: 1},
+				{id: 2, size: 1024, start: 2000, fee: 1},
+			},
+			spec:              &mtypes.GetDealSpec{MaxPieceSize: 512},
+			expectedDealIDs:   []abi.DealID{1, nonDeal, nonDeal, nonDeal},
+			expectedPieceSize: []abi.PaddedPieceSize{256, 256, 512, 1024},
+		},
+
+		{
+			name:     "verified first",
+			strategy: PackingVerifiedFirst,
+			deals: []testingPackDeal{
+				{id: 1, size: 1024, start: 2000, fee: 1},
+				{id: 2, size: 1024, start: 1500, fee: 1},
+				{id: 3, size: 1024, start: 3000, fee: 1, verified: true},
+			},
+			expectedDealIDs:   []abi.DealID{3, 2},
+			expectedPieceSize: []abi.PaddedPieceSize{1024, 1024},
+		},
+
+		{
+			name:     "no deal fits",
+			strategy: PackingVerifiedFirst,
+			deals: []testingPackDeal{
+				{id: 1, size: 4096, start: 2000, fee: 1, verified: true},
+			},
+			expectedDealIDs:   []abi.DealID{},
+			expectedPieceSize: []abi.PaddedPieceSize{},
+		},
+
+		// ## Err Cases
+		{
+			name:     "invalid piece size",
+			strategy: PackingBestFit,
+			deals: []testingPackDeal{
+				{id: 1, size: 257, start: 2000, fee: 1},
+			},
+			expectedErr: errInvalidDealPieceSize,
+		},
+	}
+
+	for ci := range cases {
+		c := cases[ci]
+		expectedPieceCount := len(c.expectedPieceSize)
+		require.Lenf(t, c.expectedDealIDs, expectedPieceCount, "<%s> expected deal ids & piece sizes should be equal", c.name)
+
+		strategy, err := packingStrategyByName(c.strategy)
+		require.NoErrorf(t, err, "<%s> strategy", c.name)
+		gotDeals, gotErr := strategy.Pack(env, generatePackingDeals(c.deals), SectorSize2K, c.spec)
+
+		if c.expectedErr != nil {
+			require.ErrorIsf(t, gotErr, c.expectedErr, "<%s> expected a specified error", c.name)
+			continue
+		}
+
+		require.NoErrorf(t, gotErr, "<%s> case should be valid", c.name)
+		require.Lenf(t, gotDeals, expectedPieceCount, "<%s> result deals count", c.name)
+
+		for i := range gotDeals {
+			require.Equalf(t, c.expectedDealIDs[i], gotDeals[i].DealID, "<%s> id of deal %d not match", c.name, i)
+			require.Equalf(t, c.expectedPieceSize[i], gotDeals[i].PieceSize, "<%s> piece size of deal %d not match", c.name, i)
+		}
+	}
+}
+
+func TestDealPackingStrategyOfMiner(t *testing.T) {
+	miner, _ := address.NewIDAddress(1000)
+	other, _ := address.NewIDAddress(1001)
+
+	ps := &dealAssigner{packingCfg: config.DealPacking{
+		Strategy: PackingBestFit,
+		Miners:   []config.MinerDealPacking{{Miner: config.Address(miner), Strategy: PackingUrgencyFirst}},
+	}}
+	strategy, err := ps.packingStrategy(miner)
+	require.NoError(t, err)
+	require.Equal(t, urgencyFirstPacking{}, strategy)
+	strategy, err = ps.packingStrategy(other)
+	require.NoError(t, err)
+	require.Equal(t, bestFitPacking{}, strategy)
+
+	ps.packingCfg.Strategy = ""
+	strategy, err = ps.packingStrategy(other)
+	require.NoError(t, err)
+	require.Equal(t, defaultPacking{}, strategy)
+
+	_, err = packingStrategyByName("largest-first")
+	require.Error(t, err)
+}
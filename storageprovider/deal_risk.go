@@ -0,0 +1,263 @@
+package storageprovider
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.uber.org/fx"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/venus-market/config"
+	"github.com/filecoin-project/venus-market/models/repo"
+	mtypes "github.com/filecoin-project/venus-market/types"
+
+	v1api "github.com/filecoin-project/venus/venus-shared/api/chain/v1"
+	types "github.com/filecoin-project/venus/venus-shared/types/market"
+
+	"github.com/ipfs-force-community/venus-common-utils/journal"
+	"github.com/ipfs-force-community/venus-common-utils/metrics"
+)
+
+var (
+	dealRiskMinerKey, _ = tag.NewKey("miner")
+	dealRiskLevelKey, _ = tag.NewKey("level")
+
+	dealsAtRisk     = stats.Int64("storage/deals_at_risk", "Number of published deals not packed nor precommitted by risk level", stats.UnitDimensionless)
+	dealsAtRiskView = &view.View{
+		Measure:     dealsAtRisk,
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{dealRiskMinerKey, dealRiskLevelKey},
+	}
+)
+
+// DealRiskEvt is recorded in the journal when a deal rises to the warning or critical level
+type DealRiskEvt struct {
+	Deal mtypes.DealRisk
+}
+
+// dealSlack is how many epochs are left before the seal of a deal starting at start must begin
+func dealSlack(start, head, sealEpochs abi.ChainEpoch) abi.ChainEpoch {
+	return start - head - sealEpochs
+}
+
+func dealRiskLevel(cfg config.DealRisk, slack abi.ChainEpoch) mtypes.DealRiskLevel {
+	switch {
+	case slack < durationToEpochs(time.Duration(cfg.CriticalSlack)):
+		return mtypes.DealRiskCritical
+	case slack < durationToEpochs(time.Duration(cfg.WarningSlack)):
+		return mtypes.DealRiskWarning
+	default:
+		return mtypes.DealRiskOK
+	}
+}
+
+// isDealUnsealed tells whether the deal is published but neither packed into a sector nor precommitted. sector 0
+// is a valid sector, the piece status tells whether the deal was packed
+func isDealUnsealed(deal *types.MinerDeal) bool {
+	if deal.DealID == 0 || isTerminateState(deal) {
+		return false
+	}
+	if deal.PieceStatus == types.Packing || deal.PieceStatus == types.Proving {
+		return false
+	}
+	return deal.State != storagemarket.StorageDealSealing && deal.State != storagemarket.StorageDealActive
+}
+
+// DealRiskMonitor finds the published deals whose start epoch comes too soon for them to be sealed in time
+type DealRiskMonitor struct {
+	cfg          config.DealRisk
+	dealRepo     repo.StorageDealRepo
+	head         func(ctx context.Context) (abi.ChainEpoch, error)
+	sealDuration config.GetExpectedSealDurationFunc
+
+	journal journal.Journal
+	evtType journal.EventType
+
+	lk sync.Mutex
+	// levels is the level of every deal at the last check, an event is recorded when the level of a deal rises
+	levels map[cid.Cid]mtypes.DealRiskLevel
+	// miners reported at the last check, their gauges are reset when they have no deal left
+	miners map[address.Address]struct{}
+}
+
+func NewDealRiskMonitor(mctx metrics.MetricsCtx,
+	lc fx.Lifecycle,
+	cfg *config.MarketConfig,
+	r repo.Repo,
+	fullNode v1api.FullNode,
+	sealDuration config.GetExpectedSealDurationFunc,
+	j journal.Journal,
+) (*DealRiskMonitor, error) {
+	m := newDealRiskMonitor(cfg.DealRisk, r.StorageDealRepo(), func(ctx context.Context) (abi.ChainEpoch, error) {
+		head, err := fullNode.ChainHead(ctx)
+		if err != nil {
+			return 0, err
+		}
+		return head.Height(), nil
+	}, sealDuration, j)
+	if !m.cfg.Enable {
+		return m, nil
+	}
+
+	if err := view.Register(dealsAtRiskView); err != nil {
+		return nil, xerrors.Errorf("register deals at risk view: %w", err)
+	}
+	ctx := metrics.LifecycleCtx(mctx, lc)
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go m.Start(ctx)
+			return nil
+		},
+	})
+	return m, nil
+}
+
+func newDealRiskMonitor(cfg config.DealRisk,
+	dealRepo repo.StorageDealRepo,
+	head func(ctx context.Context) (abi.ChainEpoch, error),
+	sealDuration config.GetExpectedSealDurationFunc,
+	j journal.Journal,
+) *DealRiskMonitor {
+	return &DealRiskMonitor{
+		cfg:          cfg,
+		dealRepo:     dealRepo,
+		head:         head,
+		sealDuration: sealDuration,
+		journal:      j,
+		evtType:      j.RegisterEventType("markets/storage/provider", "deal_at_risk"),
+		levels:       make(map[cid.Cid]mtypes.DealRiskLevel),
+		miners:       make(map[address.Address]struct{}),
+	}
+}
+
+func (m *DealRiskMonitor) Start(ctx context.Context) {
+	interval := time.Duration(m.cfg.Interval)
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := m.check(ctx); err != nil {
+				log.Errorf("check deals at risk: %v", err)
+			}
+		case <-ctx.Done():
+			log.Warnf("exit deal risk monitor by context")
+			return
+		}
+	}
+}
+
+// DealRisks lists the published deals of the miner that are neither packed nor precommitted, or of all the miners
+// when miner is undef, with the least slack first
+func (m *DealRiskMonitor) DealRisks(ctx context.Context, miner address.Address) ([]mtypes.DealRisk, error) {
+	var deals []*types.MinerDeal
+	var err error
+	if miner == address.Undef {
+		deals, err = m.dealRepo.ListDeal(ctx)
+	} else {
+		deals, err = m.dealRepo.ListDealByAddr(ctx, miner)
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("list deals: %w", err)
+	}
+
+	head, err := m.head(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("get chain head: %w", err)
+	}
+	sealDuration, err := m.sealDuration()
+	if err != nil {
+		return nil, xerrors.Errorf("get expected seal duration: %w", err)
+	}
+	sealEpochs := durationToEpochs(sealDuration)
+
+	risks := make([]mtypes.DealRisk, 0)
+	for _, deal := range deals {
+		if !isDealUnsealed(deal) {
+			continue
+		}
+		slack := dealSlack(deal.Proposal.StartEpoch, head, sealEpochs)
+		risks = append(risks, mtypes.DealRisk{
+			Miner:       deal.Proposal.Provider,
+			DealID:      deal.DealID,
+			ProposalCid: deal.ProposalCid,
+			PieceCID:    deal.Proposal.PieceCID,
+			PieceSize:   deal.Proposal.PieceSize,
+			State:       storagemarket.DealStates[deal.State],
+			PieceStatus: deal.PieceStatus,
+			StartEpoch:  deal.Proposal.StartEpoch,
+			Slack:       slack,
+			Level:       dealRiskLevel(m.cfg, slack),
+		})
+	}
+	sort.Slice(risks, func(i, j int) bool {
+		return risks[i].Slack < risks[j].Slack
+	})
+	return risks, nil
+}
+
+// check records an event for every deal whose level rose since the last check and the number of deals by level
+func (m *DealRiskMonitor) check(ctx context.Context) error {
+	risks, err := m.DealRisks(ctx, address.Undef)
+	if err != nil {
+		return err
+	}
+
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	counts := make(map[address.Address]map[mtypes.DealRiskLevel]int64)
+	levels := make(map[cid.Cid]mtypes.DealRiskLevel, len(risks))
+	for _, risk := range risks {
+		if counts[risk.Miner] == nil {
+			counts[risk.Miner] = make(map[mtypes.DealRiskLevel]int64)
+		}
+		counts[risk.Miner][risk.Level]++
+		levels[risk.ProposalCid] = risk.Level
+
+		if risk.Level == mtypes.DealRiskOK || risk.Level == m.levels[risk.ProposalCid] {
+			continue
+		}
+		// critical is never followed by warning, the slack only shrinks
+		log.Warnw("deal at risk to miss its start epoch", "miner", risk.Miner, "deal", risk.DealID,
+			"start_epoch", risk.StartEpoch, "slack", risk.Slack, "level", risk.Level)
+		evt := DealRiskEvt{Deal: risk}
+		m.journal.RecordEvent(m.evtType, func() interface{} {
+			return evt
+		})
+	}
+	m.levels = levels
+
+	for miner := range m.miners {
+		if _, ok := counts[miner]; !ok {
+			counts[miner] = make(map[mtypes.DealRiskLevel]int64)
+		}
+	}
+	m.miners = make(map[address.Address]struct{}, len(counts))
+	for miner, byLevel := range counts {
+		m.miners[miner] = struct{}{}
+		for _, level := range []mtypes.DealRiskLevel{mtypes.DealRiskOK, mtypes.DealRiskWarning, mtypes.DealRiskCritical} {
+			if err := stats.RecordWithTags(ctx, []tag.Mutator{
+				tag.Upsert(dealRiskMinerKey, miner.String()),
+				tag.Upsert(dealRiskLevelKey, string(level)),
+			}, dealsAtRisk.M(byLevel[level])); err != nil {
+				log.Errorf("record deals at risk of %s: %v", miner, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package storageprovider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-state-types/abi"
+	blocksutil "github.com/ipfs/go-ipfs-blocksutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/venus-market/config"
+	"github.com/filecoin-project/venus-market/models"
+	"github.com/filecoin-project/venus-market/models/badger"
+	mtypes "github.com/filecoin-project/venus-market/types"
+
+	types "github.com/filecoin-project/venus/venus-shared/types/market"
+
+	"github.com/ipfs-force-community/venus-common-utils/journal"
+)
+
+type testingJournal struct {
+	events []interface{}
+}
+
+func (j *testingJournal) RegisterEventType(system, event string) journal.EventType {
+	return journal.EventType{System: system, Event: event}
+}
+
+func (j *testingJournal) RecordEvent(_ journal.EventType, supplier func() interface{}) {
+	j.events = append(j.events, supplier())
+}
+
+func (j *testingJournal) Close() error {
+	return nil
+}
+
+func TestDealRiskMonitor(t *testing.T) {
+	ctx := context.Background()
+	bgen := blocksutil.NewBlockGenerator()
+	miner, _ := address.NewIDAddress(1000)
+	head := abi.ChainEpoch(10_000)
+	// an hour of sealing takes 120 epochs, the deals are at warning level below 2880 epochs of slack and at
+	// critical level below 720
+	sealEpochs := durationToEpochs(time.Hour)
+	cfg := config.DealRisk{
+		WarningSlack:  config.Duration(24 * time.Hour),
+		CriticalSlack: config.Duration(6 * time.Hour),
+	}
+
+	r := badger.NewBadgerRepo(badger.BadgerDSParams{
+		StorageDealsDS: models.BadgerDB(t),
+		DealLeaseDS:    models.BadgerDB(t),
+	})
+	for dealID, slack := range map[abi.DealID]abi.ChainEpoch{1: 5000, 2: 1000, 3: 100, 4: 50, 5: 50} {
+		deal := newTestingMinerDeal(bgen, miner, dealID, 2048, head+sealEpochs+slack)
+		switch dealID {
+		case 4:
+			deal.State = storagemarket.StorageDealActive
+			deal.PieceStatus = types.Assigned
+		case 5:
+			// packed into sector 0
+			deal.SectorNumber = 0
+			deal.PieceStatus = types.Packing
+		}
+		require.NoError(t, r.StorageDealRepo().SaveDeal(ctx, deal))
+	}
+
+	j := &testingJournal{}
+	m := newDealRiskMonitor(cfg, r.StorageDealRepo(), func(context.Context) (abi.ChainEpoch, error) {
+		return head, nil
+	}, func() (time.Duration, error) {
+		return time.Hour, nil
+	}, j)
+
+	risks, err := m.DealRisks(ctx, miner)
+	require.NoError(t, err)
+	require.Len(t, risks, 3)
+	for i, expected := range []struct {
+		dealID abi.DealID
+		slack  abi.ChainEpoch
+		level  mtypes.DealRiskLevel
+	}{
+		{3, 100, mtypes.DealRiskCritical},
+		{2, 1000, mtypes.DealRiskWarning},
+		{1, 5000, mtypes.DealRiskOK},
+	} {
+		require.Equal(t, expected.dealID, risks[i].DealID)
+		require.Equal(t, expected.slack, risks[i].Slack)
+		require.Equal(t, expected.level, risks[i].Level)
+	}
+
+	// an event is recorded when a deal becomes at risk, and when its level rises again only
+	require.NoError(t, m.check(ctx))
+	require.Len(t, j.events, 2)
+	require.NoError(t, m.check(ctx))
+	require.Len(t, j.events, 2)
+	head += 500
+	require.NoError(t, m.check(ctx))
+	require.Len(t, j.events, 3)
+	evt := j.events[2].(DealRiskEvt)
+	require.Equal(t, abi.DealID(2), evt.Deal.DealID)
+	require.Equal(t, mtypes.DealRiskCritical, evt.Deal.Level)
+
+	// the critical deals are handed to the sealers first
+	ps, err := newPieceStoreEx(&config.PieceStorage{}, r)
+	require.NoError(t, err)
+	ps.riskCfg = cfg
+	deals, err := ps.unPackedDeals(ctx, r.StorageDealRepo(), PackingEnv{Head: head, SealEpochs: sealEpochs}, miner, &types.GetDealSpec{MaxPiece: 3})
+	require.NoError(t, err)
+	require.Len(t, deals, 2)
+	require.Equal(t, abi.DealID(3), deals[0].DealID)
+	require.Equal(t, abi.DealID(2), deals[1].DealID)
+}
@@ -0,0 +1,126 @@
+package storageprovider
+
+import (
+	"context"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/venus-market/models/repo"
+	mtypes "github.com/filecoin-project/venus-market/types"
+
+	types "github.com/filecoin-project/venus/venus-shared/types/market"
+)
+
+// fundReleaser is the part of the fund manager the reconciliation of the reservations needs
+type fundReleaser interface {
+	GetReserved(addr address.Address) abi.TokenAmount
+	Release(addr address.Address, amt abi.TokenAmount) error
+}
+
+// dealReservation is the provider collateral the deal holds in the fund manager, a deal reserving its funds holds
+// its collateral before it is saved with it
+func dealReservation(deal *types.MinerDeal) abi.TokenAmount {
+	if deal.FundsReserved.Nil() || deal.FundsReserved.IsZero() {
+		if deal.State == storagemarket.StorageDealReserveProviderFunds {
+			return deal.Proposal.ProviderCollateral
+		}
+		return big.Zero()
+	}
+	return deal.FundsReserved
+}
+
+// leakedState is whether a deal still holding a reservation in that state leaked it. The deals failing release
+// their reservation in their handler, so they are not taken for leaks
+func leakedState(state storagemarket.StorageDealStatus) bool {
+	switch state {
+	case storagemarket.StorageDealError, storagemarket.StorageDealSlashed, storagemarket.StorageDealExpired:
+		return true
+	}
+	return false
+}
+
+// FundDeals lists the live deals of the miner holding a reservation of provider collateral
+func FundDeals(ctx context.Context, dealRepo repo.StorageDealRepo, miner address.Address) ([]mtypes.FundDeal, error) {
+	deals, err := dealRepo.ListDealByAddr(ctx, miner)
+	if err != nil {
+		return nil, xerrors.Errorf("list deals of %s: %w", miner, err)
+	}
+	fundDeals := make([]mtypes.FundDeal, 0)
+	for _, deal := range deals {
+		reserved := dealReservation(deal)
+		if leakedState(deal.State) || reserved.IsZero() {
+			continue
+		}
+		fundDeals = append(fundDeals, mtypes.FundDeal{
+			ProposalCid:   deal.ProposalCid,
+			State:         storagemarket.DealStates[deal.State],
+			FundsReserved: reserved,
+			AddFundsCid:   deal.AddFundsCid,
+		})
+	}
+	return fundDeals, nil
+}
+
+// ReconcileFunds releases what the fund manager reserves for the miner beyond the collateral its live deals hold,
+// a reservation leaks when a deal errors, is slashed or expires without releaseReservedFunds. The deals that leaked
+// are saved without their reservation
+func ReconcileFunds(ctx context.Context, dealRepo repo.StorageDealRepo, fundMgr fundReleaser, miner address.Address, dryRun bool) (*mtypes.FundReconcile, error) {
+	// the reservation is read before the deals are listed, a deal reserving its funds meanwhile is then listed with
+	// them. A deal releasing its funds meanwhile is not listed with them anymore, so the lowest reservation seen
+	// around the listing is the one compared
+	reserved := fundMgr.GetReserved(miner)
+	deals, err := dealRepo.ListDealByAddr(ctx, miner)
+	if err != nil {
+		return nil, xerrors.Errorf("list deals of %s: %w", miner, err)
+	}
+	if after := fundMgr.GetReserved(miner); after.LessThan(reserved) {
+		reserved = after
+	}
+
+	res := &mtypes.FundReconcile{
+		Miner:       miner,
+		Reserved:    reserved,
+		Expected:    big.Zero(),
+		Released:    big.Zero(),
+		LeakedDeals: make([]cid.Cid, 0),
+	}
+	var leaked []*types.MinerDeal
+	for _, deal := range deals {
+		reserved := dealReservation(deal)
+		if reserved.IsZero() {
+			continue
+		}
+		if leakedState(deal.State) {
+			leaked = append(leaked, deal)
+			res.LeakedDeals = append(res.LeakedDeals, deal.ProposalCid)
+			continue
+		}
+		res.Expected = big.Add(res.Expected, reserved)
+	}
+	if res.Reserved.GreaterThan(res.Expected) {
+		res.Released = big.Sub(res.Reserved, res.Expected)
+	}
+	if dryRun {
+		return res, nil
+	}
+
+	if !res.Released.IsZero() {
+		if err := fundMgr.Release(miner, res.Released); err != nil {
+			return nil, xerrors.Errorf("release funds of %s: %w", miner, err)
+		}
+		log.Infow("released leaked funds", "miner", miner, "amount", res.Released, "reserved", res.Reserved)
+	}
+	for _, deal := range leaked {
+		deal.FundsReserved = big.Zero()
+		if err := dealRepo.SaveDeal(ctx, deal); err != nil {
+			return nil, xerrors.Errorf("save deal %s: %w", deal.ProposalCid, err)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,165 @@
+package storageprovider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/ipfs/go-cid"
+	blocksutil "github.com/ipfs/go-ipfs-blocksutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/venus-market/models"
+	"github.com/filecoin-project/venus-market/models/badger"
+	"github.com/filecoin-project/venus-market/models/repo"
+
+	types "github.com/filecoin-project/venus/venus-shared/types/market"
+)
+
+type testingFundReleaser struct {
+	reserved abi.TokenAmount
+	released []abi.TokenAmount
+}
+
+func (f *testingFundReleaser) GetReserved(address.Address) abi.TokenAmount {
+	return f.reserved
+}
+
+func (f *testingFundReleaser) Release(_ address.Address, amt abi.TokenAmount) error {
+	f.released = append(f.released, amt)
+	return nil
+}
+
+// racingDealRepo runs the deal handlers racing the reconciliation while it lists the deals
+type racingDealRepo struct {
+	repo.StorageDealRepo
+	before, after func()
+}
+
+func (r *racingDealRepo) ListDealByAddr(ctx context.Context, miner address.Address) ([]*types.MinerDeal, error) {
+	if r.before != nil {
+		r.before()
+		r.before = nil
+	}
+	deals, err := r.StorageDealRepo.ListDealByAddr(ctx, miner)
+	if r.after != nil {
+		r.after()
+		r.after = nil
+	}
+	return deals, err
+}
+
+func TestReconcileFunds(t *testing.T) {
+	ctx := context.Background()
+	bgen := blocksutil.NewBlockGenerator()
+	miner, _ := address.NewIDAddress(1000)
+
+	r := badger.NewBadgerRepo(badger.BadgerDSParams{
+		StorageDealsDS: models.BadgerDB(t),
+	})
+	dealRepo := r.StorageDealRepo()
+
+	publishing := newTestingMinerDeal(bgen, miner, 1, 2048, 10000)
+	publishing.State = storagemarket.StorageDealPublishing
+	publishing.FundsReserved = abi.NewTokenAmount(5)
+	// reserving its funds, the deal is not saved with them yet
+	reserving := newTestingMinerDeal(bgen, miner, 0, 2048, 10000)
+	reserving.State = storagemarket.StorageDealReserveProviderFunds
+	reserving.Proposal.ProviderCollateral = abi.NewTokenAmount(3)
+	failed := newTestingMinerDeal(bgen, miner, 0, 2048, 10000)
+	failed.State = storagemarket.StorageDealError
+	failed.FundsReserved = abi.NewTokenAmount(4)
+	staged := newTestingMinerDeal(bgen, miner, 2, 2048, 10000)
+	staged.State = storagemarket.StorageDealStaged
+	staged.FundsReserved = abi.NewTokenAmount(0)
+	require.NoError(t, dealRepo.SaveDeal(ctx, publishing))
+	require.NoError(t, dealRepo.SaveDeal(ctx, reserving))
+	require.NoError(t, dealRepo.SaveDeal(ctx, failed))
+	require.NoError(t, dealRepo.SaveDeal(ctx, staged))
+
+	fundDeals, err := FundDeals(ctx, dealRepo, miner)
+	require.NoError(t, err)
+	require.Len(t, fundDeals, 2)
+
+	fundMgr := &testingFundReleaser{reserved: abi.NewTokenAmount(20)}
+	res, err := ReconcileFunds(ctx, dealRepo, fundMgr, miner, true)
+	require.NoError(t, err)
+	require.Equal(t, abi.NewTokenAmount(8), res.Expected)
+	require.Equal(t, abi.NewTokenAmount(12), res.Released)
+	require.Equal(t, []cid.Cid{failed.ProposalCid}, res.LeakedDeals)
+	require.Empty(t, fundMgr.released)
+
+	res, err = ReconcileFunds(ctx, dealRepo, fundMgr, miner, false)
+	require.NoError(t, err)
+	require.Equal(t, abi.NewTokenAmount(12), res.Released)
+	require.Equal(t, []abi.TokenAmount{abi.NewTokenAmount(12)}, fundMgr.released)
+
+	deal, err := dealRepo.GetDeal(ctx, failed.ProposalCid)
+	require.NoError(t, err)
+	require.True(t, deal.FundsReserved.IsZero())
+
+	// the leaked deal holds nothing anymore
+	fundMgr.reserved = abi.NewTokenAmount(8)
+	res, err = ReconcileFunds(ctx, dealRepo, fundMgr, miner, false)
+	require.NoError(t, err)
+	require.True(t, res.Released.IsZero())
+	require.Empty(t, res.LeakedDeals)
+	require.Len(t, fundMgr.released, 1)
+}
+
+func TestReconcileFundsRaces(t *testing.T) {
+	ctx := context.Background()
+	bgen := blocksutil.NewBlockGenerator()
+	miner, _ := address.NewIDAddress(1000)
+
+	dealRepo := badger.NewBadgerRepo(badger.BadgerDSParams{
+		StorageDealsDS: models.BadgerDB(t),
+	}).StorageDealRepo()
+	publishing := newTestingMinerDeal(bgen, miner, 1, 2048, 10000)
+	publishing.State = storagemarket.StorageDealPublishing
+	publishing.FundsReserved = abi.NewTokenAmount(5)
+	require.NoError(t, dealRepo.SaveDeal(ctx, publishing))
+	fundMgr := &testingFundReleaser{reserved: abi.NewTokenAmount(5)}
+
+	// a deal reserving its funds after the deals are listed is not taken for a leak
+	racing := &racingDealRepo{StorageDealRepo: dealRepo, after: func() {
+		fundMgr.reserved = abi.NewTokenAmount(11)
+		reserving := newTestingMinerDeal(bgen, miner, 0, 2048, 10000)
+		reserving.State = storagemarket.StorageDealReserveProviderFunds
+		reserving.Proposal.ProviderCollateral = abi.NewTokenAmount(6)
+		require.NoError(t, dealRepo.SaveDeal(ctx, reserving))
+	}}
+	res, err := ReconcileFunds(ctx, racing, fundMgr, miner, false)
+	require.NoError(t, err)
+	require.True(t, res.Released.IsZero())
+	require.Empty(t, fundMgr.released)
+
+	// a deal releasing its funds before the deals are listed is not released twice
+	racing = &racingDealRepo{StorageDealRepo: dealRepo, before: func() {
+		fundMgr.reserved = abi.NewTokenAmount(6)
+		publishing.State = storagemarket.StorageDealError
+		publishing.FundsReserved = abi.NewTokenAmount(0)
+		require.NoError(t, dealRepo.SaveDeal(ctx, publishing))
+	}}
+	res, err = ReconcileFunds(ctx, racing, fundMgr, miner, false)
+	require.NoError(t, err)
+	require.True(t, res.Released.IsZero())
+	require.Empty(t, fundMgr.released)
+
+	// a failing deal is released by its handler, it is no leak and its reservation is expected
+	failing := newTestingMinerDeal(bgen, miner, 0, 2048, 10000)
+	failing.State = storagemarket.StorageDealFailing
+	failing.FundsReserved = abi.NewTokenAmount(4)
+	require.NoError(t, dealRepo.SaveDeal(ctx, failing))
+	fundMgr.reserved = abi.NewTokenAmount(10)
+	res, err = ReconcileFunds(ctx, dealRepo, fundMgr, miner, false)
+	require.NoError(t, err)
+	require.True(t, res.Released.IsZero())
+	require.Empty(t, res.LeakedDeals)
+	require.Equal(t, abi.NewTokenAmount(10), res.Expected)
+	deal, err := dealRepo.GetDeal(ctx, failing.ProposalCid)
+	require.NoError(t, err)
+	require.Equal(t, abi.NewTokenAmount(4), deal.FundsReserved)
+}
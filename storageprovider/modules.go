@@ -18,6 +18,7 @@ import (
 
 	"github.com/filecoin-project/venus-market/config"
 	"github.com/filecoin-project/venus-market/dealfilter"
+	"github.com/filecoin-project/venus-market/minermgr"
 	"github.com/filecoin-project/venus-market/models/badger"
 	"github.com/filecoin-project/venus-market/network"
 	"github.com/filecoin-project/venus-market/utils"
@@ -30,8 +31,9 @@ import (
 )
 
 var (
-	HandleDealsKey   = builder.NextInvoke()
-	StartDealTracker = builder.NextInvoke()
+	HandleDealsKey    = builder.NextInvoke()
+	StartDealTracker  = builder.NextInvoke()
+	StartAskScheduler = builder.NextInvoke()
 )
 
 func HandleDeals(mctx metrics.MetricsCtx, lc fx.Lifecycle, h StorageProviderV2, j journal.Journal) {
@@ -81,7 +83,8 @@ func BasicDealFilter(user config.StorageDealFilter) func(onlineOk config.Conside
 	blocklistFunc config.StorageDealPieceCidBlocklistConfigFunc,
 	expectedSealTimeFunc config.GetExpectedSealDurationFunc,
 	startDelay config.GetMaxDealStartDelayFunc,
-	spn storagemarket.StorageProviderNode) config.StorageDealFilter {
+	spn storagemarket.StorageProviderNode,
+	minerMgr minermgr.IAddrMgr) config.StorageDealFilter {
 	return func(onlineOk config.ConsiderOnlineStorageDealsConfigFunc,
 		offlineOk config.ConsiderOfflineStorageDealsConfigFunc,
 		verifiedOk config.ConsiderVerifiedStorageDealsConfigFunc,
@@ -89,7 +92,8 @@ func BasicDealFilter(user config.StorageDealFilter) func(onlineOk config.Conside
 		blocklistFunc config.StorageDealPieceCidBlocklistConfigFunc,
 		expectedSealTimeFunc config.GetExpectedSealDurationFunc,
 		startDelay config.GetMaxDealStartDelayFunc,
-		spn storagemarket.StorageProviderNode) config.StorageDealFilter {
+		spn storagemarket.StorageProviderNode,
+		minerMgr minermgr.IAddrMgr) config.StorageDealFilter {
 
 		return func(ctx context.Context, deal storagemarket.MinerDeal) (bool, string, error) {
 			b, err := onlineOk()
@@ -172,8 +176,17 @@ func BasicDealFilter(user config.StorageDealFilter) func(onlineOk config.Conside
 				return false, fmt.Sprintf("deal start epoch is too far in the future: %s > %s", deal.Proposal.StartEpoch, maxStartEpoch), nil
 			}
 
+			state, err := minerMgr.MinerState(ctx, deal.Proposal.Provider)
+			if err != nil {
+				return false, "miner error", err
+			}
+			if state.SectorSize != 0 && uint64(deal.Proposal.PieceSize) > uint64(state.SectorSize) {
+				return false, fmt.Sprintf("piece size %d exceeds the sector size %d of the miner", deal.Proposal.PieceSize, state.SectorSize), nil
+			}
+
 			if user != nil {
-				return user(ctx, deal)
+				// the filter command gets the account, the sector size and the tags of the miner
+				return user(minermgr.WithMinerState(ctx, state), deal)
 			}
 
 			return true, "", nil
@@ -203,6 +216,11 @@ var StorageProviderOpts = func(cfg *config.MarketConfig) builder.Option {
 		builder.Override(new(StorageProviderNode), NewProviderNodeAdapter(cfg)),
 		builder.Override(new(DealAssiger), NewDealAssigner),
 		builder.Override(StartDealTracker, NewDealTracker),
+		builder.Override(StartAskScheduler, NewAskScheduler),
+		builder.Override(new(*PieceGC), NewPieceGC),
+		builder.Override(new(*PieceVerifier), NewPieceVerifier),
+		builder.Override(new(*ClientQuota), NewClientQuota),
+		builder.Override(new(*DealRiskMonitor), NewDealRiskMonitor),
 	)
 }
 
@@ -2,21 +2,27 @@ package storageprovider
 
 import (
 	"github.com/filecoin-project/go-fil-markets/storagemarket/network"
+	"github.com/libp2p/go-libp2p-core/connmgr"
 	"github.com/libp2p/go-libp2p-core/peer"
 )
 
+// PeerTagger tags the clients of the deals in progress, the tagged peers are also protected from the connection
+// manager trimming until their deals are done
 type PeerTagger struct {
 	net network.StorageMarketNetwork
+	cm  connmgr.ConnManager
 }
 
-func newPeerTagger(net network.StorageMarketNetwork) *PeerTagger {
-	return &PeerTagger{net: net}
+func newPeerTagger(net network.StorageMarketNetwork, cm connmgr.ConnManager) *PeerTagger {
+	return &PeerTagger{net: net, cm: cm}
 }
 
 func (p *PeerTagger) TagPeer(id peer.ID, s string) {
 	p.net.TagPeer(id, s)
+	p.cm.Protect(id, s)
 }
 
 func (p *PeerTagger) UntagPeer(id peer.ID, s string) {
 	p.net.UntagPeer(id, s)
+	p.cm.Unprotect(id, s)
 }
@@ -0,0 +1,333 @@
+package storageprovider
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/fx"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/dagstore"
+	"github.com/filecoin-project/dagstore/shard"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/venus-market/config"
+	mdagstore "github.com/filecoin-project/venus-market/dagstore"
+	"github.com/filecoin-project/venus-market/models/repo"
+	"github.com/filecoin-project/venus-market/piecestorage"
+	mtypes "github.com/filecoin-project/venus-market/types"
+
+	"github.com/filecoin-project/venus/pkg/constants"
+	v1api "github.com/filecoin-project/venus/venus-shared/api/chain/v1"
+	types "github.com/filecoin-project/venus/venus-shared/types/market"
+
+	"github.com/ipfs-force-community/venus-common-utils/metrics"
+)
+
+// shardStore is the part of the dagstore the gc destroys the shards with
+type shardStore interface {
+	GetShardInfo(k shard.Key) (dagstore.ShardInfo, error)
+	DestroyShard(ctx context.Context, key shard.Key, out chan dagstore.ShardResult, opts dagstore.DestroyOpts) error
+}
+
+// PieceGC removes what is kept for the pieces whose deals are all terminated: the dagstore shard, the block index,
+// the transient copy and the piece file. A piece is removed once the grace period elapsed since its last deal terminated
+type PieceGC struct {
+	cfg          config.PieceGC
+	dagst        shardStore
+	pieceStorage piecestorage.IPieceStorage
+	dealRepo     repo.StorageDealRepo
+	blockIndex   repo.IBlockIndexRepo
+	transients   *mdagstore.TransientCache
+	head         func(ctx context.Context) (abi.ChainEpoch, error)
+
+	// failures keeps when the gc first saw each failed deal in its terminal state, the deals do not keep when they
+	// failed so the grace period of a failed deal starts there
+	failures repo.IDealFailureRepo
+
+	// a single gc runs at a time
+	lk sync.Mutex
+}
+
+func NewPieceGC(mctx metrics.MetricsCtx,
+	lc fx.Lifecycle,
+	cfg *config.MarketConfig,
+	dagst *dagstore.DAGStore,
+	pieceStorage piecestorage.IPieceStorage,
+	r repo.Repo,
+	transients *mdagstore.TransientCache,
+	fullNode v1api.FullNode,
+) (*PieceGC, error) {
+	gc := &PieceGC{
+		cfg:          cfg.PieceGC,
+		dagst:        dagst,
+		pieceStorage: pieceStorage,
+		dealRepo:     r.StorageDealRepo(),
+		blockIndex:   r.BlockIndexRepo(),
+		transients:   transients,
+		failures:     r.DealFailureRepo(),
+		head: func(ctx context.Context) (abi.ChainEpoch, error) {
+			head, err := fullNode.ChainHead(ctx)
+			if err != nil {
+				return 0, err
+			}
+			return head.Height(), nil
+		},
+	}
+
+	if !gc.cfg.Enable {
+		return gc, nil
+	}
+
+	ctx := metrics.LifecycleCtx(mctx, lc)
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go gc.Start(ctx)
+			return nil
+		},
+	})
+	return gc, nil
+}
+
+func (gc *PieceGC) Start(ctx context.Context) {
+	interval := time.Duration(gc.cfg.Interval)
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+
+	// the first run does not wait for a whole interval, which is a day by default
+	gc.runLogged(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			gc.runLogged(ctx)
+		case <-ctx.Done():
+			log.Warnf("exit piece gc by context")
+			return
+		}
+	}
+}
+
+func (gc *PieceGC) runLogged(ctx context.Context) {
+	report, err := gc.Run(ctx, gc.cfg.DryRun)
+	if err != nil {
+		log.Errorf("piece gc: %v", err)
+		return
+	}
+	for _, item := range report.Pieces {
+		switch item.State {
+		case mtypes.PieceGCRemoved, mtypes.PieceGCWouldRemove:
+			log.Infow("piece gc", "piece_cid", item.PieceCID, "state", item.State, "dead_since", item.DeadSince)
+		case mtypes.PieceGCFailed:
+			log.Warnw("piece gc", "piece_cid", item.PieceCID, "state", item.State, "error", item.Error)
+		}
+	}
+}
+
+// Run looks for the pieces whose deals are all terminated and removes the ones out of the grace period,
+// a dry run only reports them. The pieces that were removed already are not reported again
+func (gc *PieceGC) Run(ctx context.Context, dryRun bool) (*mtypes.PieceGCReport, error) {
+	gc.lk.Lock()
+	defer gc.lk.Unlock()
+
+	deals, err := gc.dealRepo.ListDeal(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("list deals: %w", err)
+	}
+	head, err := gc.head(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("get chain head: %w", err)
+	}
+	now := time.Now()
+
+	pieces := make(map[cid.Cid][]*types.MinerDeal)
+	var order []cid.Cid
+	for _, deal := range deals {
+		pieceCid := deal.Proposal.PieceCID
+		if _, ok := pieces[pieceCid]; !ok {
+			order = append(order, pieceCid)
+		}
+		pieces[pieceCid] = append(pieces[pieceCid], deal)
+	}
+
+	failedAt, err := gc.observeFailed(ctx, deals, now)
+	if err != nil {
+		return nil, err
+	}
+
+	grace := time.Duration(gc.cfg.GracePeriod)
+	report := &mtypes.PieceGCReport{DryRun: dryRun, GracePeriod: grace, Pieces: []mtypes.PieceGCItem{}}
+	for _, pieceCid := range order {
+		deadSince, dead := piecesDeadSince(pieces[pieceCid], failedAt, head, now)
+		if !dead {
+			continue
+		}
+
+		item := mtypes.PieceGCItem{PieceCID: pieceCid, Deals: len(pieces[pieceCid]), DeadSince: deadSince}
+		if now.Sub(deadSince) < grace {
+			item.State = mtypes.PieceGCGrace
+			report.Pieces = append(report.Pieces, item)
+			continue
+		}
+
+		present, err := gc.present(ctx, pieceCid)
+		if err != nil {
+			item.State = mtypes.PieceGCFailed
+			item.Error = err.Error()
+			report.Pieces = append(report.Pieces, item)
+			continue
+		}
+		if !present {
+			continue
+		}
+
+		if dryRun {
+			item.State = mtypes.PieceGCWouldRemove
+		} else if err := gc.remove(ctx, pieceCid); err != nil {
+			item.State = mtypes.PieceGCFailed
+			item.Error = err.Error()
+		} else {
+			item.State = mtypes.PieceGCRemoved
+		}
+		report.Pieces = append(report.Pieces, item)
+	}
+	return report, nil
+}
+
+// gcTerminated is whether the deal is over for the gc, the rejected deals are saved as rejecting and stay so
+func gcTerminated(deal *types.MinerDeal) bool {
+	return isTerminateState(deal) || deal.State == storagemarket.StorageDealRejecting
+}
+
+// isFailed is whether the deal terminated without reaching its end
+func isFailed(deal *types.MinerDeal) bool {
+	return gcTerminated(deal) && deal.State != storagemarket.StorageDealExpired &&
+		!(deal.State == storagemarket.StorageDealSlashed && deal.SlashEpoch > 0)
+}
+
+// observeFailed records when the failed deals are first seen and forgets the deals no longer listed, it returns when
+// the listed failed deals were first seen
+func (gc *PieceGC) observeFailed(ctx context.Context, deals []*types.MinerDeal, now time.Time) (map[cid.Cid]time.Time, error) {
+	failedAt, err := gc.failures.ListDealFailures(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("list deal failures: %w", err)
+	}
+	seen := make(map[cid.Cid]struct{}, len(deals))
+	for _, deal := range deals {
+		if !isFailed(deal) {
+			continue
+		}
+		seen[deal.ProposalCid] = struct{}{}
+		if _, ok := failedAt[deal.ProposalCid]; !ok {
+			if err := gc.failures.SaveDealFailure(ctx, deal.ProposalCid, now); err != nil {
+				return nil, xerrors.Errorf("save failure of deal %s: %w", deal.ProposalCid, err)
+			}
+			failedAt[deal.ProposalCid] = now
+		}
+	}
+	for proposalCid := range failedAt {
+		if _, ok := seen[proposalCid]; !ok {
+			if err := gc.failures.RemoveDealFailure(ctx, proposalCid); err != nil {
+				return nil, xerrors.Errorf("remove failure of deal %s: %w", proposalCid, err)
+			}
+			delete(failedAt, proposalCid)
+		}
+	}
+	return failedAt, nil
+}
+
+// piecesDeadSince returns when the last of the deals terminated, false when a deal is not terminated
+func piecesDeadSince(deals []*types.MinerDeal, failedAt map[cid.Cid]time.Time, head abi.ChainEpoch, now time.Time) (time.Time, bool) {
+	epochTime := func(epoch abi.ChainEpoch) time.Time {
+		return now.Add(-time.Duration(head-epoch) * time.Duration(constants.MainNetBlockDelaySecs) * time.Second)
+	}
+
+	var deadSince time.Time
+	for _, deal := range deals {
+		if !gcTerminated(deal) {
+			return time.Time{}, false
+		}
+
+		var at time.Time
+		switch {
+		case deal.State == storagemarket.StorageDealSlashed && deal.SlashEpoch > 0:
+			at = epochTime(deal.SlashEpoch)
+		case deal.State == storagemarket.StorageDealExpired:
+			at = epochTime(deal.Proposal.EndEpoch)
+		default:
+			// failed deals do not keep when they failed, the gc counts from when it saw them failed
+			at = now
+			if seenAt, ok := failedAt[deal.ProposalCid]; ok {
+				at = seenAt
+			}
+		}
+		if at.After(deadSince) {
+			deadSince = at
+		}
+	}
+	return deadSince, true
+}
+
+// present is whether anything is left to remove for the piece
+func (gc *PieceGC) present(ctx context.Context, pieceCid cid.Cid) (bool, error) {
+	if _, err := gc.dagst.GetShardInfo(shard.KeyFromCID(pieceCid)); err == nil {
+		return true, nil
+	} else if !errors.Is(err, dagstore.ErrShardUnknown) {
+		return false, xerrors.Errorf("get shard info: %w", err)
+	}
+
+	if gc.blockIndex != nil {
+		has, err := gc.blockIndex.HasBlockIndex(ctx, pieceCid)
+		if err != nil {
+			return false, xerrors.Errorf("check block index: %w", err)
+		}
+		if has {
+			return true, nil
+		}
+	}
+
+	has, err := gc.pieceStorage.Has(ctx, pieceCid.String())
+	if err != nil {
+		return false, xerrors.Errorf("check piece storage: %w", err)
+	}
+	return has, nil
+}
+
+func (gc *PieceGC) remove(ctx context.Context, pieceCid cid.Cid) error {
+	key := shard.KeyFromCID(pieceCid)
+	if _, err := gc.dagst.GetShardInfo(key); err == nil {
+		ch := make(chan dagstore.ShardResult, 1)
+		if err := gc.dagst.DestroyShard(ctx, key, ch, dagstore.DestroyOpts{}); err != nil {
+			return xerrors.Errorf("destroy shard: %w", err)
+		}
+		select {
+		case res := <-ch:
+			if res.Error != nil && !errors.Is(res.Error, dagstore.ErrShardUnknown) {
+				return xerrors.Errorf("destroy shard: %w", res.Error)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	} else if !errors.Is(err, dagstore.ErrShardUnknown) {
+		return xerrors.Errorf("get shard info: %w", err)
+	}
+
+	if gc.blockIndex != nil {
+		if err := gc.blockIndex.RemoveBlockIndex(ctx, pieceCid); err != nil {
+			return xerrors.Errorf("remove block index: %w", err)
+		}
+	}
+	if gc.transients != nil {
+		gc.transients.Drop(pieceCid)
+	}
+	if err := gc.pieceStorage.Delete(ctx, pieceCid.String()); err != nil {
+		return xerrors.Errorf("delete piece file: %w", err)
+	}
+	return nil
+}
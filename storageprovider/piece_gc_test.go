@@ -0,0 +1,169 @@
+package storageprovider
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/dagstore"
+	"github.com/filecoin-project/dagstore/shard"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/market"
+	"github.com/ipfs/go-cid"
+	blocksutil "github.com/ipfs/go-ipfs-blocksutil"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	"github.com/filecoin-project/venus-market/config"
+	"github.com/filecoin-project/venus-market/models"
+	"github.com/filecoin-project/venus-market/models/badger"
+	"github.com/filecoin-project/venus-market/models/repo"
+	"github.com/filecoin-project/venus-market/piecestorage"
+	mtypes "github.com/filecoin-project/venus-market/types"
+
+	types "github.com/filecoin-project/venus/venus-shared/types/market"
+)
+
+type fakeGCDealRepo struct {
+	repo.StorageDealRepo
+	deals []*types.MinerDeal
+}
+
+func (f *fakeGCDealRepo) ListDeal(context.Context) ([]*types.MinerDeal, error) {
+	return f.deals, nil
+}
+
+type fakeShardStore map[shard.Key]struct{}
+
+func (f fakeShardStore) GetShardInfo(k shard.Key) (dagstore.ShardInfo, error) {
+	if _, ok := f[k]; !ok {
+		return dagstore.ShardInfo{}, dagstore.ErrShardUnknown
+	}
+	return dagstore.ShardInfo{ShardState: dagstore.ShardStateAvailable}, nil
+}
+
+func (f fakeShardStore) DestroyShard(_ context.Context, key shard.Key, out chan dagstore.ShardResult, _ dagstore.DestroyOpts) error {
+	delete(f, key)
+	out <- dagstore.ShardResult{Key: key}
+	return nil
+}
+
+func TestPieceGC(t *testing.T) {
+	ctx := context.Background()
+	bgen := blocksutil.NewBlockGenerator()
+	expired, live, failed, gone := bgen.Next().Cid(), bgen.Next().Cid(), bgen.Next().Cid(), bgen.Next().Cid()
+	rejected := bgen.Next().Cid()
+
+	head := abi.ChainEpoch(100_000)
+	longAgo := time.Now().Add(-30 * 24 * time.Hour)
+	deal := func(pieceCid cid.Cid, state storagemarket.StorageDealStatus, endEpoch abi.ChainEpoch, created time.Time) *types.MinerDeal {
+		return &types.MinerDeal{
+			ClientDealProposal: market.ClientDealProposal{Proposal: market.DealProposal{PieceCID: pieceCid, EndEpoch: endEpoch}},
+			ProposalCid:        bgen.Next().Cid(),
+			State:              state,
+			CreationTime:       cbg.CborTime(created),
+		}
+	}
+	expiredFailed := deal(expired, storagemarket.StorageDealError, head+100, longAgo)
+	rejectedDeal := deal(rejected, storagemarket.StorageDealRejecting, head+100_000, longAgo)
+	dealRepo := &fakeGCDealRepo{deals: []*types.MinerDeal{
+		// expired about 35 days ago, the other deal failed 30 days ago
+		deal(expired, storagemarket.StorageDealExpired, head-100_000, longAgo),
+		expiredFailed,
+		// one deal is still active
+		deal(live, storagemarket.StorageDealExpired, head-100_000, longAgo),
+		deal(live, storagemarket.StorageDealActive, head+100_000, longAgo),
+		// created long ago but failed just now, it is within the grace period
+		deal(failed, storagemarket.StorageDealFailing, head+100_000, longAgo),
+		// rejected 30 days ago
+		rejectedDeal,
+		// nothing left to remove
+		deal(gone, storagemarket.StorageDealSlashed, head+100_000, longAgo),
+	}}
+
+	pieceStorage, err := piecestorage.NewPieceStorage(config.PieceStorage{Fs: config.FsPieceStorage{Enable: true, Path: t.TempDir()}})
+	require.NoError(t, err)
+	blockIndex := badger.NewBlockIndexRepo(models.BadgerDB(t))
+	shards := fakeShardStore{}
+	for _, pieceCid := range []cid.Cid{expired, live, failed, rejected} {
+		_, err := pieceStorage.SaveTo(ctx, pieceCid.String(), bytes.NewReader([]byte("piece")))
+		require.NoError(t, err)
+		require.NoError(t, blockIndex.AddBlockIndex(ctx, pieceCid, []multihash.Multihash{bgen.Next().Cid().Hash()}))
+		shards[shard.KeyFromCID(pieceCid)] = struct{}{}
+	}
+
+	// the gc saw these deals fail 30 days ago
+	failures := badger.NewDealFailureRepo(models.BadgerDB(t))
+	require.NoError(t, failures.SaveDealFailure(ctx, expiredFailed.ProposalCid, longAgo))
+	require.NoError(t, failures.SaveDealFailure(ctx, rejectedDeal.ProposalCid, longAgo))
+	forgotten := bgen.Next().Cid()
+	require.NoError(t, failures.SaveDealFailure(ctx, forgotten, longAgo))
+
+	newGC := func() *PieceGC {
+		return &PieceGC{
+			cfg:          config.PieceGC{GracePeriod: config.Duration(7 * 24 * time.Hour)},
+			dagst:        shards,
+			pieceStorage: pieceStorage,
+			dealRepo:     dealRepo,
+			blockIndex:   blockIndex,
+			failures:     failures,
+			head:         func(context.Context) (abi.ChainEpoch, error) { return head, nil },
+		}
+	}
+	gc := newGC()
+	states := func(report *mtypes.PieceGCReport) map[cid.Cid]mtypes.PieceGCState {
+		res := make(map[cid.Cid]mtypes.PieceGCState)
+		for _, item := range report.Pieces {
+			require.Empty(t, item.Error)
+			res[item.PieceCID] = item.State
+		}
+		return res
+	}
+
+	// a dry run removes nothing
+	report, err := gc.Run(ctx, true)
+	require.NoError(t, err)
+	require.Equal(t, map[cid.Cid]mtypes.PieceGCState{
+		expired:  mtypes.PieceGCWouldRemove,
+		failed:   mtypes.PieceGCGrace,
+		rejected: mtypes.PieceGCWouldRemove,
+	}, states(report))
+	require.InDelta(t, 30*24*time.Hour, time.Since(report.Pieces[0].DeadSince), float64(time.Hour))
+	require.Len(t, shards, 4)
+
+	report, err = gc.Run(ctx, false)
+	require.NoError(t, err)
+	require.Equal(t, map[cid.Cid]mtypes.PieceGCState{
+		expired:  mtypes.PieceGCRemoved,
+		failed:   mtypes.PieceGCGrace,
+		rejected: mtypes.PieceGCRemoved,
+	}, states(report))
+	require.Len(t, shards, 2)
+	has, err := pieceStorage.Has(ctx, expired.String())
+	require.NoError(t, err)
+	require.False(t, has)
+	has, err = blockIndex.HasBlockIndex(ctx, expired)
+	require.NoError(t, err)
+	require.False(t, has)
+	has, err = pieceStorage.Has(ctx, live.String())
+	require.NoError(t, err)
+	require.True(t, has)
+
+	// removed pieces are not reported again
+	report, err = gc.Run(ctx, false)
+	require.NoError(t, err)
+	require.Equal(t, map[cid.Cid]mtypes.PieceGCState{failed: mtypes.PieceGCGrace}, states(report))
+	failedSince := report.Pieces[0].DeadSince
+
+	// the failures are kept across restarts, the ones of the deals no longer listed are forgotten
+	failedAt, err := failures.ListDealFailures(ctx)
+	require.NoError(t, err)
+	require.Len(t, failedAt, 4)
+	require.NotContains(t, failedAt, forgotten)
+	report, err = newGC().Run(ctx, false)
+	require.NoError(t, err)
+	require.Equal(t, failedSince.Unix(), report.Pieces[0].DeadSince.Unix())
+}
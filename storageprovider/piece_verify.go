@@ -0,0 +1,318 @@
+package storageprovider
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"go.uber.org/fx"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-commp-utils/writer"
+	commcid "github.com/filecoin-project/go-fil-commcid"
+	commp "github.com/filecoin-project/go-fil-commp-hashhash"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/venus-market/config"
+	"github.com/filecoin-project/venus-market/models/repo"
+	"github.com/filecoin-project/venus-market/piecestorage"
+	mtypes "github.com/filecoin-project/venus-market/types"
+
+	"github.com/ipfs-force-community/venus-common-utils/metrics"
+)
+
+// PieceVerifier recomputes the commP of the piece files to find the ones corrupted or lost since they were saved.
+// The result of the last check of every piece is kept in the repo
+type PieceVerifier struct {
+	cfg          config.PieceVerify
+	pieceStorage piecestorage.IPieceStorage
+	dealRepo     repo.StorageDealRepo
+	verifyRepo   repo.IPieceVerifyRepo
+
+	// slots and limiter are shared by the periodic and the on-demand runs
+	slots   chan struct{}
+	limiter *rateLimiter
+}
+
+func NewPieceVerifier(mctx metrics.MetricsCtx,
+	lc fx.Lifecycle,
+	cfg *config.MarketConfig,
+	pieceStorage piecestorage.IPieceStorage,
+	r repo.Repo,
+) (*PieceVerifier, error) {
+	v := newPieceVerifier(cfg.PieceVerify, pieceStorage, r.StorageDealRepo(), r.PieceVerifyRepo())
+	if !v.cfg.Enable {
+		return v, nil
+	}
+
+	ctx := metrics.LifecycleCtx(mctx, lc)
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go v.Start(ctx)
+			return nil
+		},
+	})
+	return v, nil
+}
+
+func newPieceVerifier(cfg config.PieceVerify,
+	pieceStorage piecestorage.IPieceStorage,
+	dealRepo repo.StorageDealRepo,
+	verifyRepo repo.IPieceVerifyRepo,
+) *PieceVerifier {
+	parallel := cfg.Parallel
+	if parallel <= 0 {
+		parallel = 1
+	}
+	return &PieceVerifier{
+		cfg:          cfg,
+		pieceStorage: pieceStorage,
+		dealRepo:     dealRepo,
+		verifyRepo:   verifyRepo,
+		slots:        make(chan struct{}, parallel),
+		limiter:      &rateLimiter{rate: cfg.MaxBytesPerSecond},
+	}
+}
+
+func (v *PieceVerifier) Start(ctx context.Context) {
+	interval := time.Duration(v.cfg.Interval)
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			results, err := v.scrub(ctx)
+			if err != nil {
+				log.Errorf("piece verify: %v", err)
+				continue
+			}
+			for _, res := range results {
+				if res.State != mtypes.PieceVerifyOK {
+					log.Warnw("piece verify", "piece_cid", res.PieceCID, "state", res.State, "error", res.Error)
+				}
+			}
+		case <-ctx.Done():
+			log.Warnf("exit piece verify by context")
+			return
+		}
+	}
+}
+
+// scrub verifies the stored pieces whose last result is older than the max age
+func (v *PieceVerifier) scrub(ctx context.Context) ([]mtypes.PieceVerifyResult, error) {
+	pieces, err := v.storedPieces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	maxAge := time.Duration(v.cfg.MaxAge)
+	stale := make([]cid.Cid, 0, len(pieces))
+	for _, pieceCid := range pieces {
+		last, err := v.verifyRepo.GetPieceVerify(ctx, pieceCid)
+		if err != nil && !errors.Is(err, repo.ErrNotFound) {
+			return nil, xerrors.Errorf("get last verify result of %s: %w", pieceCid, err)
+		}
+		if err == nil && time.Since(last.VerifiedAt) < maxAge {
+			continue
+		}
+		stale = append(stale, pieceCid)
+	}
+	return v.verify(ctx, stale), nil
+}
+
+// storedPieces lists the pieces of the live deals whose file was saved to the piece storage
+func (v *PieceVerifier) storedPieces(ctx context.Context) ([]cid.Cid, error) {
+	deals, err := v.dealRepo.ListDeal(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("list deals: %w", err)
+	}
+	seen := make(map[cid.Cid]struct{})
+	var pieces []cid.Cid
+	for _, deal := range deals {
+		// the file is saved before the deal is available for retrieval, the file of a terminated deal may be gc-ed
+		if !deal.AvailableForRetrieval || isTerminateState(deal) {
+			continue
+		}
+		pieceCid := deal.Proposal.PieceCID
+		if _, ok := seen[pieceCid]; ok {
+			continue
+		}
+		seen[pieceCid] = struct{}{}
+		pieces = append(pieces, pieceCid)
+	}
+	return pieces, nil
+}
+
+// Verify checks the given pieces, or all the stored pieces when none is given, and records the results
+func (v *PieceVerifier) Verify(ctx context.Context, pieces []cid.Cid) ([]mtypes.PieceVerifyResult, error) {
+	if len(pieces) == 0 {
+		var err error
+		if pieces, err = v.storedPieces(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return v.verify(ctx, pieces), nil
+}
+
+func (v *PieceVerifier) verify(ctx context.Context, pieces []cid.Cid) []mtypes.PieceVerifyResult {
+	results := make([]mtypes.PieceVerifyResult, len(pieces))
+	var wg sync.WaitGroup
+	for index, pieceCid := range pieces {
+		select {
+		case v.slots <- struct{}{}:
+		case <-ctx.Done():
+			results[index] = mtypes.PieceVerifyResult{PieceCID: pieceCid, State: mtypes.PieceVerifyFailed, Error: ctx.Err().Error()}
+			continue
+		}
+		wg.Add(1)
+		go func(index int, pieceCid cid.Cid) {
+			defer func() {
+				<-v.slots
+				wg.Done()
+			}()
+			res := v.verifyPiece(ctx, pieceCid)
+			// a run interrupted by the context says nothing about the piece
+			if ctx.Err() == nil {
+				if err := v.verifyRepo.SavePieceVerify(ctx, &res); err != nil {
+					log.Errorf("save verify result of %s: %v", pieceCid, err)
+				}
+			}
+			results[index] = res
+		}(index, pieceCid)
+	}
+	wg.Wait()
+	return results
+}
+
+func (v *PieceVerifier) verifyPiece(ctx context.Context, pieceCid cid.Cid) mtypes.PieceVerifyResult {
+	res := mtypes.PieceVerifyResult{PieceCID: pieceCid, VerifiedAt: time.Now()}
+	fail := func(state mtypes.PieceVerifyState, err error) mtypes.PieceVerifyResult {
+		res.State = state
+		res.Error = err.Error()
+		return res
+	}
+
+	payloadSize, pieceSize, err := v.dealRepo.GetPieceSize(ctx, pieceCid)
+	if err != nil {
+		return fail(mtypes.PieceVerifyFailed, xerrors.Errorf("get piece size: %w", err))
+	}
+	if payloadSize == 0 {
+		return fail(mtypes.PieceVerifyFailed, xerrors.New("payload size of the piece is unknown"))
+	}
+
+	has, err := v.pieceStorage.Has(ctx, pieceCid.String())
+	if err != nil {
+		return fail(mtypes.PieceVerifyFailed, xerrors.Errorf("check piece storage: %w", err))
+	}
+	if !has {
+		return fail(mtypes.PieceVerifyMissing, xerrors.New("piece file not found"))
+	}
+	if res.Size, err = v.pieceStorage.Len(ctx, pieceCid.String()); err != nil {
+		return fail(mtypes.PieceVerifyFailed, xerrors.Errorf("get piece file length: %w", err))
+	}
+	if res.Size != int64(payloadSize) {
+		return fail(mtypes.PieceVerifySizeMismatch, xerrors.Errorf("piece file length %d, payload size %d", res.Size, payloadSize))
+	}
+
+	if res.ComputedCID, err = v.commP(ctx, pieceCid, payloadSize, pieceSize); err != nil {
+		return fail(mtypes.PieceVerifyFailed, err)
+	}
+	if !res.ComputedCID.Equals(pieceCid) {
+		return fail(mtypes.PieceVerifyMismatch, xerrors.Errorf("computed commP %s", res.ComputedCID))
+	}
+	res.State = mtypes.PieceVerifyOK
+	return res
+}
+
+// commP computes the commitment of the file the way the deal did, padding it up to the piece size of the deal
+func (v *PieceVerifier) commP(ctx context.Context, pieceCid cid.Cid, payloadSize abi.UnpaddedPieceSize, pieceSize abi.PaddedPieceSize) (cid.Cid, error) {
+	rd, err := v.pieceStorage.Read(ctx, pieceCid.String())
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("read piece file: %w", err)
+	}
+	defer rd.Close() //nolint:errcheck
+
+	w := &writer.Writer{}
+	written, err := io.Copy(w, &rateLimitedReader{ctx: ctx, r: rd, limiter: v.limiter})
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("read piece file: %w", err)
+	}
+	if written != int64(payloadSize) {
+		return cid.Undef, xerrors.Errorf("read %d bytes of the piece file, payload size %d", written, payloadSize)
+	}
+
+	cidAndSize, err := w.Sum()
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to get CommP: %w", err)
+	}
+	if cidAndSize.PieceSize < pieceSize {
+		rawPaddedCommp, err := commp.PadCommP(
+			cidAndSize.PieceCID.Hash()[len(cidAndSize.PieceCID.Hash())-32:],
+			uint64(cidAndSize.PieceSize),
+			uint64(pieceSize),
+		)
+		if err != nil {
+			return cid.Undef, err
+		}
+		return commcid.DataCommitmentV1ToCID(rawPaddedCommp)
+	}
+	return cidAndSize.PieceCID, nil
+}
+
+// rateLimiter spreads the reads of all the pieces being verified so that together they stay under the rate
+type rateLimiter struct {
+	// rate is in bytes per second, 0 is unlimited
+	rate uint64
+
+	lk   sync.Mutex
+	next time.Time
+}
+
+// wait blocks until n more bytes fit in the rate
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	if l.rate == 0 {
+		return nil
+	}
+	l.lk.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / float64(l.rate) * float64(time.Second)))
+	delay := l.next.Sub(now)
+	l.lk.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type rateLimitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rateLimiter
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if werr := r.limiter.wait(r.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
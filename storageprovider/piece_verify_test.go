@@ -0,0 +1,142 @@
+package storageprovider
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-commp-utils/writer"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-padreader"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/market"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/venus-market/config"
+	"github.com/filecoin-project/venus-market/models"
+	"github.com/filecoin-project/venus-market/models/badger"
+	"github.com/filecoin-project/venus-market/models/repo"
+	"github.com/filecoin-project/venus-market/piecestorage"
+	mtypes "github.com/filecoin-project/venus-market/types"
+
+	types "github.com/filecoin-project/venus/venus-shared/types/market"
+)
+
+type fakeVerifyDealRepo struct {
+	repo.StorageDealRepo
+	deals []*types.MinerDeal
+}
+
+func (f *fakeVerifyDealRepo) ListDeal(context.Context) ([]*types.MinerDeal, error) {
+	return f.deals, nil
+}
+
+func (f *fakeVerifyDealRepo) GetPieceSize(_ context.Context, pieceCid cid.Cid) (abi.UnpaddedPieceSize, abi.PaddedPieceSize, error) {
+	for _, deal := range f.deals {
+		if deal.Proposal.PieceCID == pieceCid {
+			return deal.PayloadSize, deal.Proposal.PieceSize, nil
+		}
+	}
+	return 0, 0, repo.ErrNotFound
+}
+
+// padCommP computes the commP of the payload padded with zeros up to the piece size, as the sealing sees it
+func padCommP(t *testing.T, payload []byte, pieceSize abi.PaddedPieceSize) cid.Cid {
+	rd, err := padreader.NewInflator(bytes.NewReader(payload), uint64(len(payload)), pieceSize.Unpadded())
+	require.NoError(t, err)
+	w := &writer.Writer{}
+	_, err = io.Copy(w, rd)
+	require.NoError(t, err)
+	sum, err := w.Sum()
+	require.NoError(t, err)
+	require.Equal(t, pieceSize, sum.PieceSize)
+	return sum.PieceCID
+}
+
+func TestPieceVerifier(t *testing.T) {
+	ctx := context.Background()
+	pieceSize := abi.PaddedPieceSize(4096)
+	payload := func() []byte {
+		data := make([]byte, 1500)
+		rand.Read(data) //nolint:gosec
+		return data
+	}
+
+	pieceStorage, err := piecestorage.NewPieceStorage(config.PieceStorage{Fs: config.FsPieceStorage{Enable: true, Path: t.TempDir()}})
+	require.NoError(t, err)
+	dealRepo := &fakeVerifyDealRepo{}
+	addPiece := func(data, stored []byte, available bool) cid.Cid {
+		pieceCid := padCommP(t, data, pieceSize)
+		dealRepo.deals = append(dealRepo.deals, &types.MinerDeal{
+			ClientDealProposal:    market.ClientDealProposal{Proposal: market.DealProposal{PieceCID: pieceCid, PieceSize: pieceSize}},
+			State:                 storagemarket.StorageDealActive,
+			PayloadSize:           abi.UnpaddedPieceSize(len(data)),
+			AvailableForRetrieval: available,
+		})
+		if stored != nil {
+			_, err := pieceStorage.SaveTo(ctx, pieceCid.String(), bytes.NewReader(stored))
+			require.NoError(t, err)
+		}
+		return pieceCid
+	}
+
+	good := payload()
+	okPiece := addPiece(good, good, true)
+	corrupted := payload()
+	flipped := append([]byte{}, corrupted...)
+	flipped[700] ^= 0xff
+	mismatchPiece := addPiece(corrupted, flipped, true)
+	truncated := payload()
+	truncatedPiece := addPiece(truncated, truncated[:1000], true)
+	missingPiece := addPiece(payload(), nil, true)
+	// the file of a deal still transferring is not saved yet
+	pendingPiece := addPiece(payload(), nil, false)
+
+	verifyRepo := badger.NewPieceVerifyRepo(models.BadgerDB(t))
+	v := newPieceVerifier(config.PieceVerify{
+		MaxAge:            config.Duration(time.Hour),
+		Parallel:          2,
+		MaxBytesPerSecond: 1 << 20,
+	}, pieceStorage, dealRepo, verifyRepo)
+
+	results, err := v.Verify(ctx, nil)
+	require.NoError(t, err)
+	states := make(map[cid.Cid]mtypes.PieceVerifyState)
+	for _, res := range results {
+		states[res.PieceCID] = res.State
+	}
+	require.Equal(t, map[cid.Cid]mtypes.PieceVerifyState{
+		okPiece:        mtypes.PieceVerifyOK,
+		mismatchPiece:  mtypes.PieceVerifyMismatch,
+		truncatedPiece: mtypes.PieceVerifySizeMismatch,
+		missingPiece:   mtypes.PieceVerifyMissing,
+	}, states)
+
+	res, err := verifyRepo.GetPieceVerify(ctx, mismatchPiece)
+	require.NoError(t, err)
+	require.Equal(t, mtypes.PieceVerifyMismatch, res.State)
+	require.Equal(t, padCommP(t, flipped, pieceSize), res.ComputedCID)
+	require.Equal(t, int64(len(flipped)), res.Size)
+	res, err = verifyRepo.GetPieceVerify(ctx, okPiece)
+	require.NoError(t, err)
+	require.Equal(t, okPiece, res.ComputedCID)
+
+	// a piece is verified on demand whatever the state of its deal
+	results, err = v.Verify(ctx, []cid.Cid{pendingPiece})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, mtypes.PieceVerifyMissing, results[0].State)
+
+	// the scrubber skips the pieces verified within the max age
+	results, err = v.scrub(ctx)
+	require.NoError(t, err)
+	require.Empty(t, results)
+	v.cfg.MaxAge = 0
+	results, err = v.scrub(ctx)
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+}
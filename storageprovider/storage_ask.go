@@ -2,6 +2,8 @@ package storageprovider
 
 import (
 	"context"
+	"time"
+
 	"github.com/filecoin-project/venus/venus-shared/types"
 	"golang.org/x/xerrors"
 
@@ -13,6 +15,7 @@ import (
 	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/storedask"
 
 	"github.com/filecoin-project/venus-market/models/repo"
+	mtypes "github.com/filecoin-project/venus-market/types"
 	"github.com/ipfs-force-community/venus-common-utils/metrics"
 
 	v1api "github.com/filecoin-project/venus/venus-shared/api/chain/v1"
@@ -22,6 +25,9 @@ type IStorageAsk interface {
 	ListAsk(ctx context.Context) ([]*storagemarket.SignedStorageAsk, error)
 	GetAsk(mctx context.Context, Addr address.Address) (*storagemarket.SignedStorageAsk, error)
 	SetAsk(ctx context.Context, mAddr address.Address, price abi.TokenAmount, verifiedPrice abi.TokenAmount, duration abi.ChainEpoch, options ...storagemarket.StorageAskOption) error
+	// SetAskWithReason works like SetAsk, the reason is recorded in the ask history instead of manual
+	SetAskWithReason(ctx context.Context, reason string, mAddr address.Address, price abi.TokenAmount, verifiedPrice abi.TokenAmount, duration abi.ChainEpoch, options ...storagemarket.StorageAskOption) error
+	ListAskHistory(ctx context.Context, mAddr address.Address, limit int) ([]*mtypes.StorageAskChange, error)
 }
 
 func NewStorageAsk(
@@ -45,7 +51,15 @@ func (storageAsk *StorageAsk) GetAsk(ctx context.Context, miner address.Address)
 	return storageAsk.repo.GetAsk(ctx, miner)
 }
 
+func (storageAsk *StorageAsk) ListAskHistory(ctx context.Context, miner address.Address, limit int) ([]*mtypes.StorageAskChange, error) {
+	return storageAsk.repo.ListAskHistory(ctx, miner, limit)
+}
+
 func (storageAsk *StorageAsk) SetAsk(ctx context.Context, miner address.Address, price abi.TokenAmount, verifiedPrice abi.TokenAmount, duration abi.ChainEpoch, options ...storagemarket.StorageAskOption) error {
+	return storageAsk.SetAskWithReason(ctx, mtypes.StorageAskReasonManual, miner, price, verifiedPrice, duration, options...)
+}
+
+func (storageAsk *StorageAsk) SetAskWithReason(ctx context.Context, reason string, miner address.Address, price abi.TokenAmount, verifiedPrice abi.TokenAmount, duration abi.ChainEpoch, options ...storagemarket.StorageAskOption) error {
 	minPieceSize := storedask.DefaultMinPieceSize
 	maxPieceSize := storedask.DefaultMaxPieceSize
 
@@ -83,7 +97,25 @@ func (storageAsk *StorageAsk) SetAsk(ctx context.Context, miner address.Address,
 		return xerrors.Errorf("miner %s sign data failed: %v", miner.String(), err)
 	}
 
-	return storageAsk.repo.SetAsk(ctx, signedAsk)
+	if err = storageAsk.repo.SetAsk(ctx, signedAsk); err != nil {
+		return err
+	}
+
+	if err = storageAsk.repo.AddAskHistory(ctx, &mtypes.StorageAskChange{
+		Miner:         ask.Miner,
+		Price:         ask.Price,
+		VerifiedPrice: ask.VerifiedPrice,
+		MinPieceSize:  ask.MinPieceSize,
+		MaxPieceSize:  ask.MaxPieceSize,
+		Timestamp:     ask.Timestamp,
+		Expiry:        ask.Expiry,
+		SeqNo:         ask.SeqNo,
+		Reason:        reason,
+		CreatedAt:     time.Now(),
+	}); err != nil {
+		log.Warnf("record the ask history of miner %s: %v", miner, err)
+	}
+	return nil
 }
 
 func (storageAsk *StorageAsk) signAsk(ask *storagemarket.StorageAsk) (*storagemarket.SignedStorageAsk, error) {
@@ -5,12 +5,16 @@ import (
 	"testing"
 
 	"github.com/filecoin-project/go-address"
+	cborutil "github.com/filecoin-project/go-cbor-util"
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/venus-market/keystore"
 	"github.com/filecoin-project/venus-market/models"
 	"github.com/filecoin-project/venus-market/models/badger"
 	"github.com/filecoin-project/venus-market/utils/test_helper"
+	vCrypto "github.com/filecoin-project/venus/pkg/crypto"
+	types2 "github.com/filecoin-project/venus/venus-shared/types"
 	"github.com/stretchr/testify/require"
 )
 
@@ -22,12 +26,31 @@ func TestStorageAsk(t *testing.T) {
 		testStorageAsk(t, mysqlAsk)
 	})
 	t.Run("badger", func(t *testing.T) {
-		badgerAsk := &StorageAsk{repo: badger.NewStorageAskRepo(models.BadgerDB(t)),
+		badgerAsk := &StorageAsk{repo: badger.NewStorageAskRepo(models.BadgerDB(t), models.BadgerDB(t)),
 			fullNode: test_helper.MockFullnode{T: t}}
 		testStorageAsk(t, badgerAsk)
 	})
 }
 
+func TestStorageAskSignedByKeystore(t *testing.T) {
+	ctx := context.Background()
+	ks, err := keystore.NewKeyStore(t.TempDir(), "password")
+	require.NoError(t, err)
+	worker, err := ks.New(types2.KTBLS)
+	require.NoError(t, err)
+
+	storageAsk := &StorageAsk{repo: badger.NewStorageAskRepo(models.BadgerDB(t), models.BadgerDB(t)),
+		fullNode: test_helper.MockFullnode{T: t, Signer: ks, Worker: worker}}
+	miner, _ := address.NewFromString("f02438")
+	require.NoError(t, storageAsk.SetAsk(ctx, miner, abi.NewTokenAmount(100), abi.NewTokenAmount(10), 10000))
+
+	signedAsk, err := storageAsk.GetAsk(ctx, miner)
+	require.NoError(t, err)
+	askBytes, err := cborutil.Dump(signedAsk.Ask)
+	require.NoError(t, err)
+	require.NoError(t, vCrypto.Verify(signedAsk.Signature, worker, askBytes))
+}
+
 func testStorageAsk(t *testing.T, repo *StorageAsk) {
 	ctx := context.Background()
 	miner, _ := address.NewFromString("f02438")
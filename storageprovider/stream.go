@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/filecoin-project/venus-market/api/clients"
+	"github.com/filecoin-project/venus-market/minermgr"
 	"github.com/filecoin-project/venus-market/utils"
 	vTypes "github.com/filecoin-project/venus/venus-shared/types"
 
@@ -34,6 +35,7 @@ type StorageDealStream struct {
 	fs           filestore.FileStore
 	dealProcess  StorageDealHandler
 	mixMsgClient clients.IMixMessage
+	minerMgr     minermgr.IAddrMgr
 }
 
 // NewStorageReceiver returns a new StorageReceiver implements functions for receiving incoming data on storage protocols
@@ -46,6 +48,7 @@ func NewStorageDealStream(
 	fs filestore.FileStore,
 	dealProcess StorageDealHandler,
 	mixMsgClient clients.IMixMessage,
+	minerMgr minermgr.IAddrMgr,
 ) (network.StorageReceiver, error) {
 
 	return &StorageDealStream{
@@ -57,6 +60,7 @@ func NewStorageDealStream(
 		fs:           fs,
 		dealProcess:  dealProcess,
 		mixMsgClient: mixMsgClient,
+		minerMgr:     minerMgr,
 	}, nil
 }
 
@@ -79,14 +83,17 @@ func (storageDealStream *StorageDealStream) HandleAskStream(s network.StorageAsk
 		return
 	}
 
-	ask, err := storageDealStream.storedAsk.GetAsk(context.TODO(), ar.Miner)
-	if err != nil {
-		log.Errorf("failed to get ask for [%s]: %s", ar.Miner, err)
-		return
-	}
-
-	resp := network.AskResponse{
-		Ask: ask,
+	var resp network.AskResponse
+	// the ask of a disabled miner is withdrawn until it is enabled again, it is answered like a miner without ask
+	if storageDealStream.minerMgr.IsDisabled(context.TODO(), ar.Miner) {
+		log.Infof("miner %s is disabled, answer with no ask", ar.Miner)
+	} else {
+		ask, err := storageDealStream.storedAsk.GetAsk(context.TODO(), ar.Miner)
+		if err != nil && !xerrors.Is(err, repo.ErrNotFound) {
+			log.Errorf("failed to get ask for [%s]: %s", ar.Miner, err)
+			return
+		}
+		resp.Ask = ask
 	}
 
 	if err := s.WriteAskResponse(resp, storageDealStream.spn.SignWithGivenMiner(ar.Miner)); err != nil {
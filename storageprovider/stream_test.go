@@ -0,0 +1,93 @@
+package storageprovider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-fil-markets/storagemarket/network"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/venus-market/minermgr"
+	"github.com/filecoin-project/venus-market/models/repo"
+)
+
+type fakeAskStream struct {
+	network.StorageAskStream
+	req       network.AskRequest
+	responses []network.AskResponse
+}
+
+func (s *fakeAskStream) ReadAskRequest() (network.AskRequest, error) {
+	return s.req, nil
+}
+
+func (s *fakeAskStream) WriteAskResponse(resp network.AskResponse, _ network.ResigningFunc) error {
+	s.responses = append(s.responses, resp)
+	return nil
+}
+
+func (s *fakeAskStream) Close() error {
+	return nil
+}
+
+type fakeAskMinerMgr struct {
+	minermgr.IAddrMgr
+	disabled map[address.Address]bool
+}
+
+func (m *fakeAskMinerMgr) IsDisabled(_ context.Context, addr address.Address) bool {
+	return m.disabled[addr]
+}
+
+type fakeStoredAsk struct {
+	IStorageAsk
+	asks map[address.Address]*storagemarket.SignedStorageAsk
+}
+
+func (f *fakeStoredAsk) GetAsk(_ context.Context, miner address.Address) (*storagemarket.SignedStorageAsk, error) {
+	ask, ok := f.asks[miner]
+	if !ok {
+		return nil, repo.ErrNotFound
+	}
+	return ask, nil
+}
+
+type fakeAskSigner struct {
+	StorageProviderNode
+}
+
+func (f *fakeAskSigner) SignWithGivenMiner(address.Address) network.ResigningFunc {
+	return nil
+}
+
+func TestHandleAskStream(t *testing.T) {
+	enabled, _ := address.NewIDAddress(1000)
+	disabled, _ := address.NewIDAddress(1001)
+	unknown, _ := address.NewIDAddress(1002)
+	newAsk := func(miner address.Address) *storagemarket.SignedStorageAsk {
+		return &storagemarket.SignedStorageAsk{Ask: &storagemarket.StorageAsk{Miner: miner, Price: big.NewInt(1)}}
+	}
+
+	sds := &StorageDealStream{
+		storedAsk: &fakeStoredAsk{asks: map[address.Address]*storagemarket.SignedStorageAsk{
+			enabled:  newAsk(enabled),
+			disabled: newAsk(disabled),
+		}},
+		spn:      &fakeAskSigner{},
+		minerMgr: &fakeAskMinerMgr{disabled: map[address.Address]bool{disabled: true}},
+	}
+	ask := func(miner address.Address) *fakeAskStream {
+		s := &fakeAskStream{req: network.AskRequest{Miner: miner}}
+		sds.HandleAskStream(s)
+		require.Len(t, s.responses, 1)
+		return s
+	}
+
+	require.Equal(t, enabled, ask(enabled).responses[0].Ask.Ask.Miner)
+	// a disabled miner is answered like a miner without ask
+	require.Nil(t, ask(disabled).responses[0].Ask)
+	require.Nil(t, ask(unknown).responses[0].Ask)
+}
@@ -0,0 +1,101 @@
+package types
+
+import (
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/venus/venus-shared/types/market/client"
+)
+
+type DealRenewalState string
+
+const (
+	// DealRenewalPending means the deal is inside the renewal window but no replacement was accepted yet
+	DealRenewalPending DealRenewalState = "pending"
+	// DealRenewalProposed means a replacement deal has been proposed successfully
+	DealRenewalProposed DealRenewalState = "proposed"
+	// DealRenewalFailed means every attempt to renew the deal failed
+	DealRenewalFailed DealRenewalState = "failed"
+)
+
+// RenewalAttempt is one try to propose a replacement deal to a provider
+type RenewalAttempt struct {
+	Provider     address.Address
+	ProposalCid  *cid.Cid
+	TransferType string
+	Err          string
+	CreatedAt    time.Time
+}
+
+// DealRenewal tracks the renewal of a storage deal, keyed by the proposal cid of the original deal
+type DealRenewal struct {
+	ProposalCid cid.Cid
+	DealID      abi.DealID
+	PieceCID    cid.Cid
+	PayloadCID  cid.Cid
+	Provider    address.Address
+	EndEpoch    abi.ChainEpoch
+
+	State    DealRenewalState
+	Rounds   int
+	Attempts []RenewalAttempt
+
+	// RenewedProposalCid and RenewedProvider are set once a replacement deal has been proposed
+	RenewedProposalCid *cid.Cid
+	RenewedProvider    address.Address
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// RetrievalFailoverOrder asks for a retrieval from the best ranked provider, failing over to the next one on error
+type RetrievalFailoverOrder struct {
+	Root         cid.Cid
+	Piece        *cid.Cid
+	DataSelector *client.DataSelector
+	Client       address.Address
+	// MaxPrice is the max total price of the retrieval, offers above it are ignored
+	MaxPrice abi.TokenAmount
+	// Providers are queried in addition to the ones found by discovery
+	Providers []address.Address
+}
+
+// RetrievalFailoverAttempt is the retrieval from one provider
+type RetrievalFailoverAttempt struct {
+	Provider      address.Address
+	DealID        retrievalmarket.DealID
+	Price         abi.TokenAmount
+	QueryLatency  time.Duration
+	Score         float64
+	BytesReceived uint64
+	Err           string
+}
+
+type RetrievalFailoverResult struct {
+	// DealID is the deal holding the retrieved data, pass it to ClientExport
+	DealID   retrievalmarket.DealID
+	Provider address.Address
+	Attempts []RetrievalFailoverAttempt
+	// Err is set when every attempt failed, DealID holds no data then
+	Err string
+}
+
+// ProviderReputation is the retrieval history of a provider, used to rank its offers
+type ProviderReputation struct {
+	Provider      address.Address
+	Successes     uint64
+	Failures      uint64
+	BytesReceived uint64
+	LastError     string
+	LastSuccess   time.Time
+	LastFailure   time.Time
+}
+
+// SuccessRate is smoothed so that providers without history rank in the middle
+func (r ProviderReputation) SuccessRate() float64 {
+	return float64(r.Successes+1) / float64(r.Successes+r.Failures+2)
+}
@@ -0,0 +1,29 @@
+package types
+
+import (
+	"github.com/filecoin-project/go-address"
+)
+
+// ClientLimits are the quota of a client, 0 is unlimited
+type ClientLimits struct {
+	ProposalsPerMinute int
+	MaxPendingBytes    uint64
+	MaxBytesPerDay     uint64
+	MaxBytesPerMonth   uint64
+}
+
+// ClientUsage is what a client address has used of its quota
+type ClientUsage struct {
+	Client address.Address
+	// Deals and TotalBytes count all the deals of the client but the rejected ones
+	Deals      int
+	TotalBytes uint64
+
+	ProposalsLastMinute int
+	// PendingBytes is the piece size of the live deals that are not active on chain yet
+	PendingBytes   uint64
+	BytesLastDay   uint64
+	BytesLastMonth uint64
+
+	Limits ClientLimits
+}
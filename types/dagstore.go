@@ -0,0 +1,33 @@
+package types
+
+import (
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// DagstoreTransientUsage is the usage of the unsealed copies the dagstore reads the shards from
+type DagstoreTransientUsage struct {
+	// Size is the bytes of the copies on disk and of the copies being fetched
+	Size int64
+	// MaxSize and MaxCount are the quota, 0 means unlimited
+	MaxSize  int64
+	Count    int
+	MaxCount int
+
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	// Rejections counts the fetches that failed because the quota was used by copies being read
+	Rejections uint64
+
+	Transients []DagstoreTransient
+}
+
+type DagstoreTransient struct {
+	PieceCID cid.Cid
+	Size     int64
+	// Readers is the number of readers of the copy, a copy with readers is never evicted
+	Readers  int
+	LastUsed time.Time
+}
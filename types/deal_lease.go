@@ -0,0 +1,24 @@
+package types
+
+import (
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+)
+
+// DealLease is the assignment of a deal to a sealer. The sealer renews it until the deal is packed into a sector,
+// a lease that expires returns the deal to the deals waiting to be assigned
+type DealLease struct {
+	Miner  address.Address
+	DealID abi.DealID
+	// Owner is the id of the sealer the deal was assigned to, the account of its token when it used the shared market api
+	Owner      string
+	AssignedAt time.Time
+	// Expiry is zero when the leases do not expire
+	Expiry     time.Time
+	StartEpoch abi.ChainEpoch
+
+	// AtRisk is set when listed, for the deals whose start epoch is closer than the expected seal duration
+	AtRisk bool
+}
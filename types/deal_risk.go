@@ -0,0 +1,31 @@
+package types
+
+import (
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/ipfs/go-cid"
+)
+
+type DealRiskLevel string
+
+const (
+	DealRiskOK       DealRiskLevel = "ok"
+	DealRiskWarning  DealRiskLevel = "warning"
+	DealRiskCritical DealRiskLevel = "critical"
+)
+
+// DealRisk is a published deal that is neither packed into a sector nor precommitted yet
+type DealRisk struct {
+	Miner       address.Address
+	DealID      abi.DealID
+	ProposalCid cid.Cid
+	PieceCID    cid.Cid
+	PieceSize   abi.PaddedPieceSize
+	State       string
+	PieceStatus string
+	StartEpoch  abi.ChainEpoch
+	// Slack is the start epoch minus the current epoch minus the expected seal duration, negative when the deal
+	// can not be sealed in time anymore
+	Slack abi.ChainEpoch
+	Level DealRiskLevel
+}
@@ -0,0 +1,60 @@
+package types
+
+import (
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/ipfs/go-cid"
+)
+
+const (
+	FundMsgAddBalance      = "add-balance"
+	FundMsgWithdrawBalance = "withdraw-balance"
+)
+
+// FundRequest is a request queued in the fund manager and not processed yet
+type FundRequest struct {
+	Wallet address.Address
+	Amount abi.TokenAmount
+}
+
+// FundDeal is a storage deal holding a reservation of provider collateral
+type FundDeal struct {
+	ProposalCid   cid.Cid
+	State         string
+	FundsReserved abi.TokenAmount
+	// AddFundsCid is the add balance message the deal waits for, nil when the escrow already covered it
+	AddFundsCid *cid.Cid
+}
+
+// FundState is the market escrow of an address and what the fund manager holds of it
+type FundState struct {
+	Addr     address.Address
+	Escrow   abi.TokenAmount
+	Locked   abi.TokenAmount
+	Reserved abi.TokenAmount
+	// Available is the escrow neither locked nor reserved, which can be withdrawn
+	Available abi.TokenAmount
+	// MsgCid is the message of the address in progress, the fund manager sends no other message before it lands.
+	// MsgKind is empty when the message was sent before a restart
+	MsgCid  *cid.Cid
+	MsgKind string
+
+	Reservations []FundRequest
+	Releases     []FundRequest
+	Withdrawals  []FundRequest
+
+	// Deals are the deals of a miner holding a reservation
+	Deals []FundDeal
+}
+
+// FundReconcile is the reservation of a miner checked against the collateral its deals hold
+type FundReconcile struct {
+	Miner    address.Address
+	Reserved abi.TokenAmount
+	// Expected is the collateral the live deals of the miner hold
+	Expected abi.TokenAmount
+	// Released is the reservation beyond Expected, it is not released on a dry run
+	Released abi.TokenAmount
+	// LeakedDeals are the deals in error, slashed or expired whose reservation was never released
+	LeakedDeals []cid.Cid
+}
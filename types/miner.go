@@ -0,0 +1,60 @@
+package types
+
+import (
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+)
+
+const (
+	// MinerSourceConfig is for the addresses of the config file, `actor add` saves the miners there
+	MinerSourceConfig = "config"
+	// MinerSourceVenusAuth is for the miners of the users of venus-auth
+	MinerSourceVenusAuth = "venus-auth"
+	// MinerSourceFile is for the miners of a json file, it is read again when it changes
+	MinerSourceFile = "file"
+	// MinerSourceHTTP is for the miners of a json http endpoint
+	MinerSourceHTTP = "http"
+
+	// MinerDisabledByOperator is for the miners disabled by `actor disable`
+	MinerDisabledByOperator = "operator"
+	// MinerDisabledByVenusAuth is for the miners of the disabled users of venus-auth, the other sources disabling a
+	// miner are named in DisabledBy the same way
+	MinerDisabledByVenusAuth = MinerSourceVenusAuth
+)
+
+// MinerMeta is a miner as one source describes it
+type MinerMeta struct {
+	Addr    address.Address
+	Account string
+	// SectorSize is zero when the source does not know it
+	SectorSize abi.SectorSize
+	Enabled    bool
+	Tags       []string
+}
+
+// MinerState is an address the market serves, the worker, owner and control addresses of the miners are left out.
+// A disabled miner takes no new deal and publishes no ask, the deals it accepted before go on
+type MinerState struct {
+	Addr    address.Address
+	Account string
+	// SectorSize and Tags come from the first source describing them
+	SectorSize abi.SectorSize
+	Tags       []string
+	// Sources the address comes from by precedence, it is removed when the last one drops it
+	Sources []string
+	// DisabledBy lists who disabled the miner, the operator or the sources, it is enabled when empty
+	DisabledBy []string
+}
+
+func (s MinerState) Disabled() bool {
+	return len(s.DisabledBy) > 0
+}
+
+func (s MinerState) HasTag(tag string) bool {
+	for _, t := range s.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package types
+
+import (
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/metrics"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+)
+
+// NetPeer is a connected peer
+type NetPeer struct {
+	ID peer.ID
+	// Addr is the remote address of the first connection
+	Addr string
+	// Direction is the direction of the first connection
+	Direction network.Direction
+	Conns     int
+	Streams   int
+	// Latency is the moving average of the pings, 0 when unknown
+	Latency time.Duration
+	Agent   string
+	// Protected peers are never trimmed by the connection manager
+	Protected bool
+	// TagValue is the sum of the weights of the tags of the peer in the connection manager
+	TagValue int
+}
+
+// NetBlockList lists the peers and ip ranges refused to connect, the ones of the config cannot be unblocked at runtime
+type NetBlockList struct {
+	Peers         []peer.ID
+	Subnets       []string
+	ConfigPeers   []peer.ID
+	ConfigSubnets []string
+}
+
+// NetBandwidth is the traffic of the libp2p host since it started
+type NetBandwidth struct {
+	Total metrics.Stats
+	// ByPeer is keyed by the string of the peer id, the raw id does not survive json
+	ByPeer     map[string]metrics.Stats
+	ByProtocol map[protocol.ID]metrics.Stats
+}
+
+// NetReachability is whether autonat found the node dialable from the internet
+type NetReachability struct {
+	Reachability network.Reachability
+	// PublicAddr is the address the node is dialable at, when it is public
+	PublicAddr string
+}
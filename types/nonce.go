@@ -0,0 +1,57 @@
+package types
+
+import (
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/exitcode"
+	"github.com/ipfs/go-cid"
+
+	vtypes "github.com/filecoin-project/venus/venus-shared/types"
+)
+
+type MsgState string
+
+const (
+	MsgStatePending MsgState = "pending"
+	MsgStateOnChain MsgState = "onchain"
+	// MsgStateDropped is set when the nonce of the message was used by a different message
+	MsgStateDropped MsgState = "dropped"
+)
+
+// NonceMessage is a message pushed to the daemon directly with a nonce assigned locally.
+// It is tracked until it lands and is kept until its height is final
+type NonceMessage struct {
+	From  address.Address
+	Nonce uint64
+	// Cid of the signed message, it changes when the message is replaced
+	Cid cid.Cid
+	// OriginCid is the cid the message was first pushed with, callers wait for the message by it
+	OriginCid cid.Cid
+	Message   *vtypes.SignedMessage
+	// MaxFee of the send spec the message was pushed with, a replacement never pays more
+	MaxFee abi.TokenAmount
+	State  MsgState
+	// Filler is set when the original message could not land any more and the nonce was filled with a self send
+	Filler bool
+	// Repushed counts how often the message was pushed again after it went missing from the mpool
+	Repushed int
+	// Replaced counts how often the fee of the message was bumped
+	Replaced int
+	// PushedEpoch is the head height when the message was last pushed, it is stuck when it stays pending too long after it
+	PushedEpoch abi.ChainEpoch
+	// Height and ExitCode are set once the message is on chain
+	Height    abi.ChainEpoch
+	ExitCode  exitcode.ExitCode
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// MsgReplaceParams sets the fee of a replacement, zero values are estimated.
+// a zero MaxFee keeps the max fee the message was pushed with
+type MsgReplaceParams struct {
+	GasPremium abi.TokenAmount
+	GasFeeCap  abi.TokenAmount
+	MaxFee     abi.TokenAmount
+}
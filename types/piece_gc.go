@@ -0,0 +1,35 @@
+package types
+
+import (
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+type PieceGCState string
+
+const (
+	// PieceGCGrace is a piece whose deals are all terminated, kept until the grace period elapses
+	PieceGCGrace PieceGCState = "grace"
+	// PieceGCWouldRemove is a piece a dry run would have removed
+	PieceGCWouldRemove PieceGCState = "would-remove"
+	PieceGCRemoved     PieceGCState = "removed"
+	PieceGCFailed      PieceGCState = "failed"
+)
+
+// PieceGCReport lists the pieces whose deals are all terminated, the pieces with a live deal are not reported
+type PieceGCReport struct {
+	DryRun      bool
+	GracePeriod time.Duration
+	Pieces      []PieceGCItem
+}
+
+type PieceGCItem struct {
+	PieceCID cid.Cid
+	// Deals is the number of deals of the piece
+	Deals int
+	// DeadSince is when the last deal of the piece terminated
+	DeadSince time.Time
+	State     PieceGCState
+	Error     string
+}
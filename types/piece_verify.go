@@ -0,0 +1,33 @@
+package types
+
+import (
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+type PieceVerifyState string
+
+const (
+	PieceVerifyOK PieceVerifyState = "ok"
+	// PieceVerifyMismatch is a piece file whose commP is not the piece cid
+	PieceVerifyMismatch PieceVerifyState = "mismatch"
+	// PieceVerifyMissing is a piece with a deal but without a file in the piece storage
+	PieceVerifyMissing PieceVerifyState = "missing"
+	// PieceVerifySizeMismatch is a piece file whose length is not the payload size of its deals, eg. a partial upload
+	PieceVerifySizeMismatch PieceVerifyState = "size-mismatch"
+	// PieceVerifyFailed is a piece that could not be checked, eg. the piece storage was unreachable
+	PieceVerifyFailed PieceVerifyState = "failed"
+)
+
+// PieceVerifyResult is the result of the last integrity check of a piece file
+type PieceVerifyResult struct {
+	PieceCID cid.Cid
+	State    PieceVerifyState
+	// ComputedCID is the commP of the file, undefined when the file was not read to the end
+	ComputedCID cid.Cid
+	// Size is the length of the file
+	Size       int64
+	Error      string
+	VerifiedAt time.Time
+}
@@ -0,0 +1,57 @@
+package types
+
+import (
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"golang.org/x/xerrors"
+)
+
+type RetrievalAskRuleKind string
+
+// the kinds of retrieval ask rules, from the highest precedence to the lowest
+const (
+	// RetrievalAskRuleClient matches the peer id of the retrieval client, or the wallet it pays from. the wallet of
+	// a peer is learnt from the payment channel of its first payment, so a rule on an address prices the retrievals
+	// that follow it
+	RetrievalAskRuleClient RetrievalAskRuleKind = "client"
+	// RetrievalAskRulePiece matches the piece cid the data is retrieved from
+	RetrievalAskRulePiece RetrievalAskRuleKind = "piece"
+	// RetrievalAskRulePayload matches the payload cid of the retrieval
+	RetrievalAskRulePayload RetrievalAskRuleKind = "payload"
+	// RetrievalAskRuleLabel matches a glob pattern against the label of the storage deal the data comes from
+	RetrievalAskRuleLabel RetrievalAskRuleKind = "label"
+)
+
+// RetrievalAskRuleKinds lists the kinds of rules in the order they are matched
+var RetrievalAskRuleKinds = []RetrievalAskRuleKind{
+	RetrievalAskRuleClient,
+	RetrievalAskRulePiece,
+	RetrievalAskRulePayload,
+	RetrievalAskRuleLabel,
+}
+
+func ParseRetrievalAskRuleKind(s string) (RetrievalAskRuleKind, error) {
+	for _, kind := range RetrievalAskRuleKinds {
+		if string(kind) == s {
+			return kind, nil
+		}
+	}
+	return "", xerrors.Errorf("unknown retrieval ask rule kind %s", s)
+}
+
+// RetrievalAskRule overrides the retrieval ask of a miner for the retrievals it matches.
+// A miner has at most one rule for each kind and value
+type RetrievalAskRule struct {
+	Miner address.Address
+	Kind  RetrievalAskRuleKind
+	Value string
+
+	PricePerByte            abi.TokenAmount
+	UnsealPrice             abi.TokenAmount
+	PaymentInterval         uint64
+	PaymentIntervalIncrease uint64
+
+	UpdatedAt time.Time
+}
@@ -0,0 +1,35 @@
+package types
+
+import (
+	"time"
+
+	"github.com/filecoin-project/go-address"
+)
+
+// SignerBackendState is the health of a signer backend at its last check
+type SignerBackendState struct {
+	Name    string
+	Type    string
+	Healthy bool
+	// Unknown is set until the backend is checked a first time, Healthy is meaningless then
+	Unknown bool
+	// LastError is the error of the last check or signature, empty once the backend answers again
+	LastError string
+	LastCheck time.Time
+	// Addresses are routed to the backend first, none when it serves every address
+	Addresses []address.Address
+}
+
+// SignerRoute is the backend signing for an address of a miner, Backend is empty when no healthy backend has the key
+type SignerRoute struct {
+	Miner address.Address
+	// Addr is the worker of a miner actor, or the address itself for the other addresses of the market
+	Addr    address.Address
+	Backend string
+	Error   string
+}
+
+type SignerStatus struct {
+	Backends []SignerBackendState
+	Routes   []SignerRoute
+}
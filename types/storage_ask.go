@@ -0,0 +1,30 @@
+package types
+
+import (
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+)
+
+const (
+	// StorageAskReasonManual is the reason of the asks set by the operator
+	StorageAskReasonManual = "manual"
+	// StorageAskReasonResign is the reason of the asks signed again only because the previous one was about to expire
+	StorageAskReasonResign = "resign"
+)
+
+// StorageAskChange records one change of the storage ask of a miner
+type StorageAskChange struct {
+	Miner         address.Address
+	Price         abi.TokenAmount
+	VerifiedPrice abi.TokenAmount
+	MinPieceSize  abi.PaddedPieceSize
+	MaxPieceSize  abi.PaddedPieceSize
+	Timestamp     abi.ChainEpoch
+	Expiry        abi.ChainEpoch
+	SeqNo         uint64
+	// Reason describes what triggered the change, the operator or the pricing rules that matched
+	Reason    string
+	CreatedAt time.Time
+}
@@ -23,6 +23,16 @@ import (
 
 type MockFullnode struct {
 	*testing.T
+	// Signer signs in place of the fixed signature when set, the keystore of the local signer lets the tests sign
+	// with real keys
+	Signer Signer
+	// Worker is the account key of every address when set
+	Worker address.Address
+}
+
+type Signer interface {
+	WalletHas(ctx context.Context, addr address.Address) (bool, error)
+	WalletSign(ctx context.Context, k address.Address, msg []byte, meta types.MsgMeta) (*crypto.Signature, error)
 }
 
 func (m MockFullnode) ChainReadObj(ctx context.Context, cid cid.Cid) ([]byte, error) {
@@ -42,6 +52,9 @@ func (m MockFullnode) ChainStatObj(ctx context.Context, obj cid.Cid, base cid.Ci
 }
 
 func (m MockFullnode) StateAccountKey(ctx context.Context, addr address.Address, tsk types.TipSetKey) (address.Address, error) {
+	if m.Worker != address.Undef {
+		return m.Worker, nil
+	}
 	return address.NewIDAddress(1)
 }
 
@@ -642,6 +655,9 @@ func (m MockFullnode) SyncState(ctx context.Context) (*types.SyncState, error) {
 }
 
 func (m MockFullnode) WalletSign(ctx context.Context, k address.Address, msg []byte, meta types.MsgMeta) (*crypto.Signature, error) {
+	if m.Signer != nil {
+		return m.Signer.WalletSign(ctx, k, msg, meta)
+	}
 	signStr := []byte(`{"Type": 1, "Data": "0Te6VibKM4W0E8cgNFZTgiNXzUqgOZJtCPN1DEp2kClTuzUGVzu/umhCM87o76AEpsMkjpJQGo+S8MYHXQdFTAE="}`)
 	sign := &crypto.Signature{}
 	return sign, json.Unmarshal(signStr, sign)
@@ -656,6 +672,9 @@ func (m MockFullnode) WalletImport(ctx context.Context, key *types.KeyInfo) (add
 }
 
 func (m MockFullnode) WalletHas(ctx context.Context, addr address.Address) (bool, error) {
+	if m.Signer != nil {
+		return m.Signer.WalletHas(ctx, addr)
+	}
 	panic("implement me")
 }
 